@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+var authTableSeparator string
+
+func init() {
+	registerFormat(processorFormat{
+		name:        "auth-table",
+		description: "Tab delimited file with one row per authority record: the heading (1XX), its type, the see from (4XX) and see also (5XX) tracings, and the control numbers",
+		contentType: "text/tab-separated-values; charset=utf-8",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			return NewProcessorAuthTable(params)
+		},
+		setFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&authTableSeparator, "separator", " | ", "Separator of the values in the columns with several values (e.g. the see from tracings).")
+		},
+	})
+}
+
+// authHeadingTypes are the types of the headings by the last two digits
+// of their tag, the same in the 1XX, 4XX, and 5XX.
+var authHeadingTypes = map[string]string{
+	"00": "Personal name",
+	"10": "Corporate name",
+	"11": "Meeting name",
+	"30": "Uniform title",
+	"47": "Named event",
+	"48": "Chronological term",
+	"50": "Topical term",
+	"51": "Geographic name",
+	"55": "Genre/form term",
+	"62": "Medium of performance term",
+	"80": "General subdivision",
+	"81": "Geographic subdivision",
+	"82": "Chronological subdivision",
+	"85": "Form subdivision",
+}
+
+// authSkipCodes are the subfields that are not part of the headings:
+// the control subfield ($w), the relationship information ($i), the
+// authority record and URIs ($0, $1), the source ($2), and the rest of
+// the numeric subfields.
+const authSkipCodes = "0123456789iw"
+
+// ProcessorAuthTable outputs a row for each authority record, the other
+// records (e.g. bibliographic records) and the ones without a 1XX are
+// skipped.
+type ProcessorAuthTable struct {
+	separator string
+}
+
+func NewProcessorAuthTable(params ProcessFileParams) (*ProcessorAuthTable, error) {
+	if params.HasFilters() {
+		return nil, errors.New("filters not supported for this format")
+	}
+	return &ProcessorAuthTable{separator: authTableSeparator}, nil
+}
+
+func (p *ProcessorAuthTable) Tags() []string {
+	tags := []string{"001", "010", "035"}
+	for _, prefix := range []string{"1", "4", "5"} {
+		for suffix := range authHeadingTypes {
+			tags = append(tags, prefix+suffix)
+		}
+	}
+	return tags
+}
+
+func (p *ProcessorAuthTable) Header(w io.Writer) error {
+	_, err := io.WriteString(w, "id\tlccn\tcontrol_numbers\ttype\theading\tsee_from\tsee_also\r\n")
+	return err
+}
+
+func (p *ProcessorAuthTable) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
+
+func (p *ProcessorAuthTable) RenderRecord(r marc.Record) ([]byte, error) {
+	if r.Leader.Type != 'z' {
+		return nil, errRecordSkipped
+	}
+	var heading marc.Field
+	found := false
+	seeFrom, seeAlso := []string{}, []string{}
+	for _, field := range r.Fields {
+		if _, ok := authHeadingTypes[field.Tag[1:]]; !ok || field.IsControlField() {
+			continue
+		}
+		switch field.Tag[0] {
+		case '1':
+			if !found {
+				heading, found = field, true
+			}
+		case '4':
+			if value := authHeading(field); value != "" {
+				seeFrom = append(seeFrom, value)
+			}
+		case '5':
+			if value := authHeading(field); value != "" {
+				seeAlso = append(seeAlso, value)
+			}
+		}
+	}
+	if !found {
+		return nil, errRecordSkipped
+	}
+
+	headingType := authHeadingTypes[heading.Tag[1:]]
+	if heading.Tag == "100" && heading.SubFieldValue("t") != "" {
+		headingType = "Name/title"
+	}
+	values := []string{
+		strings.TrimSpace(r.GetValue("001", "")),
+		strings.TrimSpace(r.GetValue("010", "a")),
+		p.join(r.GetValues("035", "a")),
+		headingType,
+		authHeading(heading),
+		p.join(seeFrom),
+		p.join(seeAlso),
+	}
+	for i, value := range values {
+		values[i] = itemsCleanValue(value)
+	}
+	return []byte(strings.Join(values, "\t") + "\r\n"), nil
+}
+
+func (p *ProcessorAuthTable) join(values []string) string {
+	trimmed := []string{}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return strings.Join(trimmed, p.separator)
+}
+
+// authHeading returns the heading of a 1XX, 4XX, or 5XX with the
+// subdivisions separated with "--", e.g. "Tea--History". The relator
+// terms ($e, or $j in X11) are skipped.
+func authHeading(field marc.Field) string {
+	skip := authSkipCodes
+	if field.Tag[1:] == "00" || field.Tag[1:] == "10" {
+		skip += "e"
+	} else if field.Tag[1:] == "11" {
+		skip += "j"
+	}
+	value := ""
+	for _, sub := range field.SubFields {
+		text := strings.TrimSpace(sub.Value)
+		if strings.Contains(skip, sub.Code) || text == "" {
+			continue
+		}
+		switch {
+		case value == "":
+			value = text
+		case strings.Contains(headingSubdivisions, sub.Code):
+			value = trimHeading(value) + "--" + text
+		default:
+			value += " " + text
+		}
+	}
+	return trimHeading(value)
+}
+
+func (p *ProcessorAuthTable) WriteRendered(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func (p *ProcessorAuthTable) Footer(w io.Writer) error {
+	return nil
+}
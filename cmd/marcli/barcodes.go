@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "barcodes",
+		description: "Report the item barcodes that are in more than one record",
+		flags:       []string{"barcode", "bib-id", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runBarcodes,
+	})
+}
+
+// runBarcodes reads all the records in the file and outputs the
+// barcodes found in more than one record. It returns an error if any
+// is found.
+func runBarcodes(ctx context.Context) error {
+	barcode, err := marc.NewFieldFilter(barcodeSpec)
+	if err != nil || barcode.Subfields == "" {
+		return fmt.Errorf("invalid -barcode %q, indicate the item field and subfield, e.g. 945i or 952p", barcodeSpec)
+	}
+	bibID, err := marc.NewFieldFilter(barcodeBibID)
+	if err != nil {
+		return fmt.Errorf("invalid -bib-id %q: %w", barcodeBibID, err)
+	}
+	processor := &barcodesProcessor{barcode: barcode, bibID: bibID, records: map[string][]string{}}
+	if err := processFile(ctx, fileParams(), processor, os.Stdout); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d barcodes in %d items, %d in more than one record\n", len(processor.records), processor.items, processor.duplicates)
+	if processor.duplicates > 0 {
+		return fmt.Errorf("found %d duplicate barcodes", processor.duplicates)
+	}
+	return nil
+}
+
+// barcodesProcessor keeps the ids of the records with each barcode, the
+// barcodes are kept in memory for the whole file.
+type barcodesProcessor struct {
+	barcode    marc.FieldFilter
+	bibID      marc.FieldFilter
+	records    map[string][]string
+	items      int
+	duplicates int
+}
+
+func (p *barcodesProcessor) Tags() []string {
+	return []string{p.barcode.Tag, p.bibID.Tag}
+}
+
+func (p *barcodesProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *barcodesProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	id := ""
+	if field, ok := firstMatch(r, p.bibID); ok {
+		if field.IsControlField() || p.bibID.Subfields == "" {
+			id = strings.TrimSpace(field.Value)
+		} else {
+			id = strings.TrimSpace(strings.Join(field.SubFieldValues(p.bibID.Subfields), " "))
+		}
+	}
+	if id == "" {
+		id = fmt.Sprintf("record at byte %d", r.Pos)
+	}
+
+	seen := map[string]bool{}
+	for _, field := range p.barcode.Select(r.Fields) {
+		for _, value := range field.SubFieldValues(p.barcode.Subfields) {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			p.items++
+			// A barcode repeated in the same record is listed once.
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			p.records[value] = append(p.records[value], id)
+		}
+	}
+	return nil
+}
+
+// Footer outputs the barcodes in more than one record, the number of
+// records, and their ids, separated by tabs.
+func (p *barcodesProcessor) Footer(w io.Writer) error {
+	barcodes := []string{}
+	for barcode, ids := range p.records {
+		if len(ids) > 1 {
+			barcodes = append(barcodes, barcode)
+		}
+	}
+	sort.Strings(barcodes)
+	p.duplicates = len(barcodes)
+
+	var sb strings.Builder
+	for _, barcode := range barcodes {
+		ids := p.records[barcode]
+		fmt.Fprintf(&sb, "%s\t%d\t%s\n", barcode, len(ids), strings.Join(ids, ", "))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
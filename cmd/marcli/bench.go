@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "bench",
+		description: "Measure the throughput of parsing, filtering, and converting the file with each reader",
+		flags:       []string{"match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "format", "workers"},
+		formatFlags: true,
+		run:         runBench,
+	})
+}
+
+// benchStage is one of the measurements, run processes all the records
+// in the file and returns how many there were.
+type benchStage struct {
+	name string
+	run  func(ctx context.Context, params ProcessFileParams) (int, error)
+}
+
+type benchResult struct {
+	records int
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+func runBench(ctx context.Context) error {
+	if fileName == "-" {
+		return errors.New("cannot benchmark stdin, indicate a file")
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+
+	params := fileParams()
+	stages := []benchStage{
+		{name: "parse", run: benchParse},
+		{name: "filter", run: benchFilter},
+		{name: "convert", run: benchConvert},
+	}
+
+	fmt.Printf("File: %s (%d bytes), format: %s, workers: %d\n\n", fileName, info.Size(), format, workers)
+	fmt.Printf("%-9s %-8s %9s %9s %11s %9s %11s %11s\n",
+		"Reader", "Stage", "Records", "Seconds", "Records/s", "MB/s", "Allocs/rec", "Bytes/rec")
+	for _, reader := range []string{"buffered", "mmap"} {
+		params.mmap = reader == "mmap"
+		for _, stage := range stages {
+			result, err := measure(ctx, stage, params)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", reader, stage.name, err)
+			}
+			seconds := result.elapsed.Seconds()
+			records := float64(result.records)
+			if records == 0 {
+				records = 1
+			}
+			fmt.Printf("%-9s %-8s %9d %9.3f %11.0f %9.2f %11.1f %11.0f\n",
+				reader, stage.name, result.records, seconds,
+				float64(result.records)/seconds,
+				float64(info.Size())/1024/1024/seconds,
+				float64(result.allocs)/records,
+				float64(result.bytes)/records)
+		}
+	}
+	return nil
+}
+
+// measure runs a stage and collects the time and the memory allocated.
+func measure(ctx context.Context, stage benchStage, params ProcessFileParams) (benchResult, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+
+	records, err := stage.run(ctx, params)
+
+	elapsed := time.Since(started)
+	runtime.ReadMemStats(&after)
+	return benchResult{
+		records: records,
+		elapsed: elapsed,
+		allocs:  after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+	}, err
+}
+
+// benchRecords calls fn for each record in the file.
+func benchRecords(ctx context.Context, params ProcessFileParams, fn func(r marc.Record) error) (int, error) {
+	marcFile, file, err := openMarcFile(params)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	for {
+		r, err := marcFile.NextContext(ctx)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+		if err := fn(r); err != nil {
+			return count, err
+		}
+	}
+}
+
+func benchParse(ctx context.Context, params ProcessFileParams) (int, error) {
+	return benchRecords(ctx, params, func(r marc.Record) error { return nil })
+}
+
+func benchFilter(ctx context.Context, params ProcessFileParams) (int, error) {
+	return benchRecords(ctx, params, func(r marc.Record) error {
+		params.plan.Match(r)
+		return nil
+	})
+}
+
+func benchConvert(ctx context.Context, params ProcessFileParams) (int, error) {
+	processor, err := newProcessor(format, params)
+	if err != nil {
+		return 0, err
+	}
+	if err := processor.Header(io.Discard); err != nil {
+		return 0, err
+	}
+	count, err := benchRecords(ctx, params, func(r marc.Record) error {
+		if err := processor.ProcessRecord(io.Discard, r); err != nil && err != errRecordSkipped {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, processor.Footer(io.Discard)
+}
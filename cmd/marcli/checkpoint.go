@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// checkpoint is saved periodically to the file indicated in the
+// -checkpoint parameter so that an interrupted run can be restarted
+// with -resume-from instead of from the beginning of the file.
+//
+// Offset is the position of the first record that has not been
+// processed yet. If the run is killed (rather than stopped with Ctrl-C
+// or -timeout) the records processed after the last checkpoint are
+// processed again when resuming.
+type checkpoint struct {
+	File           string `json:"file"`
+	Offset         int64  `json:"offset"`
+	RecordsRead    int    `json:"records_read"`
+	RecordsWritten int    `json:"records_written"`
+	Completed      bool   `json:"completed"`
+}
+
+// write saves the checkpoint, the file is replaced atomically so that
+// it is never left half written.
+func (cp checkpoint) write(path string) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// skipTo moves the input to the given offset, seeking when possible.
+func skipTo(r io.Reader, offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, r, offset)
+	return err
+}
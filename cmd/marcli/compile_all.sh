@@ -1,3 +1,5 @@
-GOOS=darwin go build -o marcli
-GOOS=linux go build -o marcli_linux
-GOOS=windows GOARCH=386 go build -o marcli.exe
+VERSION=$(git describe --tags --always --dirty)
+LDFLAGS="-X main.version=$VERSION"
+GOOS=darwin go build -ldflags "$LDFLAGS" -o marcli
+GOOS=linux go build -ldflags "$LDFLAGS" -o marcli_linux
+GOOS=windows GOARCH=386 go build -ldflags "$LDFLAGS" -o marcli.exe
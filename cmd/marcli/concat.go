@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "concat",
+		description: "Concatenate several MARC files into one MARC binary file, validating each record",
+		flags:       []string{"files", "o", "dedupe", "skip-errors", "error-file"},
+		noFile:      true,
+		args:        true,
+		run:         runConcat,
+	})
+}
+
+// concatStats are the totals reported at the end of the concat.
+type concatStats struct {
+	files      int
+	written    int
+	invalid    int
+	duplicates int
+}
+
+// runConcat reads the files in -files (and the ones given after the
+// command) in order and writes their records
+// to -o. Unlike cat, every record is parsed and validated so that a
+// corrupt file is detected before the output is loaded anywhere. The
+// output file is written under a temporary name and renamed at the end,
+// it is never left half written.
+func runConcat(ctx context.Context) error {
+	files, err := concatInputs(concatFiles, commandArgs)
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(outFile)
+	if err != nil {
+		return err
+	}
+	defer out.remove()
+
+	stats := concatStats{}
+	seen := map[string]string{}
+	for _, file := range files {
+		if err := concatFile(ctx, out, file, seen, &stats); err != nil {
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d files, %d records written, %d invalid records skipped, %d duplicates skipped\n",
+		stats.files, stats.written, stats.invalid, stats.duplicates)
+	return nil
+}
+
+// outputFile is the output of the commands with -o. The file is
+// written under a temporary name and renamed when it is closed, so it is
+// never left half written. Without -o the output goes to stdout.
+type outputFile struct {
+	*bufio.Writer
+	tmp  *os.File
+	path string
+}
+
+func createOutput(path string) (*outputFile, error) {
+	if path == "" {
+		return &outputFile{Writer: bufio.NewWriter(os.Stdout)}, nil
+	}
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &outputFile{Writer: bufio.NewWriter(tmp), tmp: tmp, path: path}, nil
+}
+
+// Close flushes the output and renames the file.
+func (o *outputFile) Close() error {
+	if err := o.Flush(); err != nil {
+		return err
+	}
+	if o.tmp == nil {
+		return nil
+	}
+	if err := o.tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(o.tmp.Name(), o.path); err != nil {
+		return err
+	}
+	o.tmp = nil
+	return nil
+}
+
+// remove removes the partial output if the command failed before
+// closing it.
+func (o *outputFile) remove() {
+	if o.tmp != nil {
+		o.tmp.Close()
+		os.Remove(o.tmp.Name())
+	}
+}
+
+// concatInputs returns the files in the comma separated list followed
+// by the ones in args, patterns (e.g. data/*.mrc) are expanded in
+// alphabetical order.
+func concatInputs(list string, args []string) ([]string, error) {
+	files := []string{}
+	for _, value := range append(strings.Split(list, ","), args...) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		matches, err := filepath.Glob(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", value, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", value)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("indicate the files to concatenate, e.g. marcli concat a.mrc b.mrc -o all.mrc")
+	}
+	return files, nil
+}
+
+// concatFile writes the records of a file. seen has the 001 of the
+// records written so far and the file where they were found, it is
+// only used with -dedupe.
+func concatFile(ctx context.Context, w io.Writer, filename string, seen map[string]string, stats *concatStats) error {
+	marcFile, file, err := openMarcFile(ProcessFileParams{filename: filename, mmap: useMmap, maxRecordSize: maxRecordSize, parseMode: parseMode()})
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stats.files++
+
+	for {
+		r, err := marcFile.NextContext(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			err = validateRecord(r, nil)
+		}
+		if err != nil {
+			if marcFile.Err() != nil || !(skipErrors || errorRecords != nil) {
+				return fmt.Errorf("%s: record at byte %d: %w", filename, r.Pos, err)
+			}
+			stats.invalid++
+			if errorRecords != nil {
+				if err := errorRecords.write(r, err); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s: ", filename)
+			logSkippedRecord(r, err)
+			continue
+		}
+		logWarnings(r)
+
+		if concatDedupe {
+			id := r.GetValue("001", "")
+			if first, ok := seen[id]; ok && id != "" {
+				stats.duplicates++
+				fmt.Fprintf(os.Stderr, "Warning: %s: record at byte %d: duplicate 001 %q, first seen in %s\n", filename, r.Pos, id, first)
+				continue
+			}
+			// Copy the value, it shares the memory of the whole record.
+			seen[string([]byte(id))] = filename
+		}
+
+		b, err := marc.EncodeMRC(r)
+		if err != nil {
+			return fmt.Errorf("%s: record at byte %d: %w", filename, r.Pos, err)
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		stats.written++
+	}
+}
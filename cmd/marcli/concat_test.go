@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// writeGenerated writes n generated records of the type in a MARC
+// binary file in dir and returns its name.
+func writeGenerated(t *testing.T, dir string, kind string, n int) string {
+	t.Helper()
+	g, err := marc.NewGenerator(kind, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		b, err := marc.EncodeMRC(g.Next())
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(b)
+	}
+	name := filepath.Join(dir, kind+".mrc")
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestConcatFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var out bytes.Buffer
+	stats := concatStats{}
+	for _, kind := range marc.GeneratorTypes {
+		file := writeGenerated(t, dir, kind, 5)
+		if err := concatFile(context.Background(), &out, file, map[string]string{}, &stats); err != nil {
+			t.Fatalf("unexpected error concatenating the %s records: %v", kind, err)
+		}
+	}
+	if stats.files != 3 || stats.written != 15 || stats.invalid != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	types := ""
+	marcFile := marc.NewMarcFile(&out)
+	for {
+		r, err := marcFile.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.ContainsRune(types, rune(r.Leader.Type)) {
+			types += string(r.Leader.Type)
+		}
+	}
+	if types != "azx" {
+		t.Errorf("expected bibliographic, authority, and holdings records in the output, got types %q", types)
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want []string
+		out  string
+	}{
+		{args: []string{"a.mrc", "b.mrc", "-o", "all.mrc"}, want: []string{"a.mrc", "b.mrc"}, out: "all.mrc"},
+		{args: []string{"-o", "all.mrc", "a.mrc", "-dedupe", "b.mrc"}, want: []string{"a.mrc", "b.mrc"}, out: "all.mrc"},
+		{args: []string{"a.mrc", "--", "-b.mrc"}, want: []string{"a.mrc", "-b.mrc"}},
+		{args: []string{"-dedupe"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("concat", flag.ContinueOnError)
+		out := fs.String("o", "", "")
+		fs.Bool("dedupe", false, "")
+		got := parseInterspersed(fs, tt.args)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") || *out != tt.out {
+			t.Errorf("%v: expected %v and -o %q, got %v and -o %q", tt.args, tt.want, tt.out, got, *out)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config represents the settings in the configuration file. Each
+// setting is the name of a command line flag and its value, for
+// example:
+//
+//	defaults:
+//	  format: xml
+//	  skip-errors: true
+//	formats:
+//	  xml:
+//	    xml.indent: true
+//	presets:
+//	  wildlife:
+//	    match: wildlife
+//	    fields: LDR,001,245
+//	profiles:
+//	  koha:
+//	    callnumber: $o
+//	schema:
+//	  "949": R a b+ i l
+//
+// Flags given in the command line take precedence over the preset
+// selected with -preset, which takes precedence over the profile
+// selected with -profile (see itemsProfiles), which takes precedence
+// over the settings for the selected format, which take precedence over
+// the defaults.
+//
+// The fields under schema are added to the MARC 21 schema used by
+// validate -schema (e.g. local 9XX fields), see marc.Schema.
+type config struct {
+	Defaults map[string]string            `yaml:"defaults"`
+	Formats  map[string]map[string]string `yaml:"formats"`
+	Presets  map[string]map[string]string `yaml:"presets"`
+	Profiles map[string]map[string]string `yaml:"profiles"`
+	Schema   map[string]string            `yaml:"schema"`
+}
+
+// defaultConfigPath returns the path of the configuration file
+// (e.g. ~/.config/marcli/config.yaml on Linux)
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "marcli", "config.yaml")
+}
+
+// loadConfig reads the configuration file. A missing file is not an
+// error unless the path was explicitly given by the user.
+func loadConfig(path string, required bool) (config, error) {
+	cfg := config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// apply sets the flags in fs that were not given in the command line
+// to the values in the configuration. Settings for flags that are not
+// in fs are ignored as long as they are in known (i.e. they are used by
+// other commands).
+func (cfg config) apply(fs *flag.FlagSet, preset string, known map[string]bool) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	presetValues, ok := cfg.Presets[preset]
+	if preset != "" && !ok {
+		return fmt.Errorf("preset %q not found in the configuration file", preset)
+	}
+
+	// The profile can come from the command line, the preset, or the
+	// defaults (in that order).
+	profile := ""
+	if f := fs.Lookup("profile"); f != nil {
+		profile = f.Value.String()
+	}
+	if !explicit["profile"] {
+		if value, ok := presetValues["profile"]; ok {
+			profile = value
+		} else if value, ok := cfg.Defaults["profile"]; ok {
+			profile = value
+		}
+	}
+	var profileValues map[string]string
+	if profile != "" {
+		var err error
+		if profileValues, err = profileSettings(profile, cfg.Profiles); err != nil {
+			return err
+		}
+	}
+
+	// The format can come from the command line, the preset, the
+	// profile, or the defaults (in that order).
+	format := ""
+	if f := fs.Lookup("format"); f != nil {
+		format = f.Value.String()
+	}
+	if !explicit["format"] {
+		if value, ok := presetValues["format"]; ok {
+			format = value
+		} else if value, ok := profileValues["format"]; ok {
+			format = value
+		} else if value, ok := cfg.Defaults["format"]; ok {
+			format = value
+		}
+	}
+
+	// The prefix can be omitted in the settings for a format,
+	// i.e. "indent" under "xml" is the same as "xml.indent".
+	formatValues := map[string]string{}
+	for name, value := range cfg.Formats[format] {
+		if !strings.HasPrefix(name, format+".") && fs.Lookup(name) == nil {
+			name = format + "." + name
+		}
+		formatValues[name] = value
+	}
+
+	values := map[string]string{}
+	for _, settings := range []map[string]string{cfg.Defaults, formatValues, profileValues, presetValues} {
+		for name, value := range settings {
+			values[name] = value
+		}
+	}
+
+	for name, value := range values {
+		if explicit[name] {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			if known[name] {
+				continue
+			}
+			return fmt.Errorf("unknown setting %q in the configuration file", name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for %q in the configuration file: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "covers",
+		description: "Report the records without a cover image in OpenLibrary or Google Books",
+		flags:       []string{"covers", "google-key", "missing", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file", "invalid-utf8"},
+		run:         runCovers,
+	})
+}
+
+// The endpoints of the cover services, they are variables to use a
+// test server, e.g.
+// go build -ldflags "-X main.openLibraryCoversURL=http://localhost:8080"
+var (
+	openLibraryCoversURL = "https://covers.openlibrary.org"
+	googleBooksAPI       = "https://www.googleapis.com/books/v1"
+)
+
+// coversMaxRetries is how many times a request is sent again when the
+// service is too busy (HTTP 429) or fails, waiting coversFirstBackoff
+// the first time and twice as long each time after that. OpenLibrary
+// limits the requests by ISBN to 100 every 5 minutes.
+const (
+	coversMaxRetries   = 5
+	coversFirstBackoff = 5 * time.Second
+)
+
+func runCovers(ctx context.Context) error {
+	if coversSource != "openlibrary" && coversSource != "google" {
+		return fmt.Errorf("invalid -covers %q, accepted values: openlibrary, google", coversSource)
+	}
+	params := fileParams()
+	p := &coversProcessor{
+		client:  newCoversClient(ctx, coversSource, googleKey),
+		missing: missingCovers,
+	}
+	err := processFile(ctx, params, p, os.Stdout)
+	fmt.Fprintf(os.Stderr, "%d records with a cover, %d without a cover, %d without an ISBN\n", p.covers, p.noCover, p.noISBN)
+	return err
+}
+
+// coversProcessor outputs a tab delimited report with the cover found
+// for each record, trying its ISBNs in order until one has a cover.
+// With missing only the records without a cover (including the records
+// without an ISBN) are reported.
+type coversProcessor struct {
+	client  *coversClient
+	missing bool
+	covers  int
+	noCover int
+	noISBN  int
+}
+
+func (p *coversProcessor) Header(w io.Writer) error {
+	_, err := fmt.Fprintln(w, "id\ttitle\tisbns\tstatus\tcover")
+	return err
+}
+
+func (p *coversProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	isbns := recordISBNs(r)
+	status, cover := "no ISBN", ""
+	if len(isbns) > 0 {
+		status = "no cover"
+		for _, isbn := range isbns {
+			var err error
+			if cover, err = p.client.cover(isbn); err != nil {
+				return err
+			}
+			if cover != "" {
+				status = "cover"
+				break
+			}
+		}
+	}
+	switch status {
+	case "cover":
+		p.covers++
+	case "no cover":
+		p.noCover++
+	default:
+		p.noISBN++
+	}
+	if p.missing && status == "cover" {
+		return errRecordSkipped
+	}
+
+	title := strings.TrimRight(itemsCleanValue(r.GetValue("245", "a")), " /:;,=")
+	values := []string{itemsCleanValue(r.ControlNum()), title, strings.Join(isbns, " "), status, cover}
+	_, err := fmt.Fprintln(w, strings.Join(values, "\t"))
+	return err
+}
+
+func (p *coversProcessor) Footer(w io.Writer) error {
+	return nil
+}
+
+// recordISBNs returns the ISBNs in 020 $a without hyphens or qualifiers
+// such as "(pbk.)", without repeating the same ISBN.
+func recordISBNs(r marc.Record) []string {
+	isbns := []string{}
+	seen := map[string]bool{}
+	for _, value := range r.GetValues("020", "a") {
+		words := strings.Fields(value)
+		if len(words) == 0 {
+			continue
+		}
+		isbn := strings.ToUpper(strings.ReplaceAll(words[0], "-", ""))
+		if (len(isbn) == 10 || len(isbn) == 13) && !seen[isbn] {
+			seen[isbn] = true
+			isbns = append(isbns, isbn)
+		}
+	}
+	return isbns
+}
+
+// coversClient looks up the covers of the ISBNs in OpenLibrary or
+// Google Books. Each ISBN is looked up once.
+type coversClient struct {
+	ctx    context.Context
+	source string
+	key    string
+	covers map[string]string
+	client *http.Client
+}
+
+func newCoversClient(ctx context.Context, source string, key string) *coversClient {
+	return &coversClient{
+		ctx:    ctx,
+		source: source,
+		key:    key,
+		covers: map[string]string{},
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			// OpenLibrary redirects to the image when there is one,
+			// there is no need to download it.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// cover returns the URL of the cover of the ISBN, empty if there is
+// none.
+func (cc *coversClient) cover(isbn string) (string, error) {
+	if cover, ok := cc.covers[isbn]; ok {
+		return cover, nil
+	}
+	backoff := coversFirstBackoff
+	for attempt := 0; ; attempt++ {
+		var cover string
+		var err error
+		if cc.source == "google" {
+			cover, err = cc.googleBooks(isbn)
+		} else {
+			cover, err = cc.openLibrary(isbn)
+		}
+		var retryable retryableError
+		if err == nil {
+			cc.covers[isbn] = cover
+			return cover, nil
+		}
+		if !errors.As(err, &retryable) || attempt == coversMaxRetries {
+			return "", err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s, retrying in %s\n", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-cc.ctx.Done():
+			return "", cc.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// openLibrary checks the cover in the OpenLibrary Covers API, which
+// returns 404 when there is no cover for the ISBN (with default=false).
+func (cc *coversClient) openLibrary(isbn string) (string, error) {
+	cover := openLibraryCoversURL + "/b/isbn/" + url.PathEscape(isbn) + "-M.jpg"
+	resp, err := cc.get(cover + "?default=false")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return "", retryableError{fmt.Errorf("error checking the cover of ISBN %s in OpenLibrary: %s", isbn, resp.Status)}
+	case resp.StatusCode >= 400:
+		return "", fmt.Errorf("error checking the cover of ISBN %s in OpenLibrary: %s", isbn, resp.Status)
+	}
+	return cover, nil
+}
+
+// googleBooks returns the thumbnail of the first volume with the ISBN
+// in Google Books.
+func (cc *coversClient) googleBooks(isbn string) (string, error) {
+	query := url.Values{"q": {"isbn:" + isbn}, "fields": {"items/volumeInfo/imageLinks"}}
+	if cc.key != "" {
+		query.Set("key", cc.key)
+	}
+	resp, err := cc.get(googleBooksAPI + "/volumes?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", retryableError{err}
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return "", retryableError{fmt.Errorf("error checking the cover of ISBN %s in Google Books: %s", isbn, resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("error checking the cover of ISBN %s in Google Books: %s", isbn, resp.Status)
+	}
+	var result struct {
+		Items []struct {
+			VolumeInfo struct {
+				ImageLinks struct {
+					Thumbnail string `json:"thumbnail"`
+				} `json:"imageLinks"`
+			} `json:"volumeInfo"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("invalid response from Google Books: %w", err)
+	}
+	for _, item := range result.Items {
+		if thumbnail := item.VolumeInfo.ImageLinks.Thumbnail; thumbnail != "" {
+			return thumbnail, nil
+		}
+	}
+	return "", nil
+}
+
+func (cc *coversClient) get(endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(cc.ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "marcli/"+version)
+	resp, err := cc.client.Do(req)
+	if err != nil {
+		if cc.ctx.Err() != nil {
+			return nil, cc.ctx.Err()
+		}
+		return nil, retryableError{err}
+	}
+	return resp, nil
+}
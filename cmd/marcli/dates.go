@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "dates",
+		description: "Report the publication dates (008/07-14) by decade and the types of date (008/06), as text, CSV, or JSON",
+		flags:       []string{"span", "csv", "json", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runDates,
+	})
+}
+
+func runDates(ctx context.Context) error {
+	if reportCSV && reportJSON {
+		return errors.New("use either -csv or -json")
+	}
+	processor := &datesProcessor{
+		span:    datesSpan,
+		types:   map[string]int{},
+		decades: map[int]int{},
+		other:   map[string]int{},
+		// The continuing resources still published are counted until
+		// the current decade.
+		thisYear: time.Now().Year(),
+	}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// datesProcessor counts the records by the decade of their Date1 or,
+// with span, in each decade between Date1 and Date2 for the types of
+// date with a range (e.g. serials or collections).
+type datesProcessor struct {
+	span     bool
+	thisYear int
+	records  int
+	types    map[string]int
+	decades  map[int]int
+	// other are the records without a decade, e.g. "(unknown)".
+	other map[string]int
+}
+
+func (p *datesProcessor) Tags() []string {
+	return []string{"008"}
+}
+
+func (p *datesProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *datesProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.records++
+	dates, ok := r.PublicationDates()
+	if !ok {
+		p.other["(no 008)"]++
+		return nil
+	}
+	code := dates.Type
+	if code == ' ' {
+		code = '#'
+	}
+	p.types[fmt.Sprintf("%c - %s", code, dates.TypeDescription())]++
+
+	if !p.span {
+		if decade, ok := dates.Decade(); ok {
+			p.decades[decade]++
+		} else {
+			p.other[datesUnknown(dates)]++
+		}
+		return nil
+	}
+	first, last, ok := dates.Span()
+	if !ok {
+		p.other[datesUnknown(dates)]++
+		return nil
+	}
+	if last > p.thisYear {
+		last = p.thisYear
+	}
+	for decade := first / 10 * 10; decade <= last; decade += 10 {
+		p.decades[decade]++
+	}
+	return nil
+}
+
+// datesUnknown returns why the decade of the dates is not known.
+func datesUnknown(dates marc.PublicationDates) string {
+	if dates.Type == 'b' {
+		return "(B.C.)"
+	}
+	return "(unknown)"
+}
+
+// histogram returns the decades in chronological order, including the
+// ones without records between the first and the last, and then the
+// records without a decade.
+func (p *datesProcessor) histogram() []overviewCount {
+	counts := map[string]int{}
+	first, last := 0, 0
+	for decade, count := range p.decades {
+		counts[fmt.Sprintf("%ds", decade)] = count
+		if first == 0 || decade < first {
+			first = decade
+		}
+		if decade > last {
+			last = decade
+		}
+	}
+	if first > 0 {
+		for decade := first; decade < last; decade += 10 {
+			label := fmt.Sprintf("%ds", decade)
+			if _, ok := counts[label]; !ok {
+				counts[label] = 0
+			}
+		}
+	}
+	for value, count := range p.other {
+		counts[value] = count
+	}
+	return sortedCounts(counts, p.records)
+}
+
+// datesReport is the JSON output.
+type datesReport struct {
+	Records   int             `json:"records"`
+	DateTypes []overviewCount `json:"date_types"`
+	Decades   []overviewCount `json:"decades"`
+}
+
+func (p *datesProcessor) Footer(w io.Writer) error {
+	decades := p.histogram()
+	types := sortedCounts(p.types, p.records)
+	switch {
+	case reportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"decade", "records", "percent"})
+		for _, count := range decades {
+			cw.Write([]string{count.Value, strconv.Itoa(count.Count), strconv.FormatFloat(count.Percent, 'f', 1, 64)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(datesReport{Records: p.records, DateTypes: types, Decades: decades})
+	}
+	title := "Publication dates by decade (008/07-10)"
+	if p.span {
+		title = "Publication dates by decade (008/07-14, the records with a range in each decade)"
+	}
+	str := fmt.Sprintf("Records: %d\n", p.records)
+	for _, section := range []overviewSection{
+		{Title: "Types of date (008/06)", Counts: types},
+		{Title: title, Counts: decades},
+	} {
+		if len(section.Counts) > 0 {
+			str += "\n" + section.String()
+		}
+	}
+	_, err := io.WriteString(w, str)
+	return err
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "db load",
+		description: "Load the records into a SQLite database to query them with db query",
+		flags:       []string{"db", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "fields", "exclude", "start", "count", "skip-errors", "error-file", "invalid-utf8", "warn-duplicates"},
+		run:         runDBLoad,
+	})
+	registerCommand(command{
+		name:        "db query",
+		description: "Run a SQL query on a database created with db load",
+		flags:       []string{"db", "sql"},
+		noFile:      true,
+		run:         runDBQuery,
+	})
+}
+
+// dbSchema are the tables where db load saves the records. The records
+// are numbered in the order they are in the file, starting at 1.
+const dbSchema = `
+DROP TABLE IF EXISTS subfields;
+DROP TABLE IF EXISTS fields;
+DROP TABLE IF EXISTS records;
+CREATE TABLE records (
+	id INTEGER PRIMARY KEY,
+	pos INTEGER NOT NULL,
+	control_number TEXT NOT NULL,
+	leader TEXT NOT NULL
+);
+CREATE TABLE fields (
+	record_id INTEGER NOT NULL REFERENCES records(id),
+	seq INTEGER NOT NULL,
+	tag TEXT NOT NULL,
+	ind1 TEXT NOT NULL,
+	ind2 TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (record_id, seq)
+);
+CREATE TABLE subfields (
+	record_id INTEGER NOT NULL,
+	field_seq INTEGER NOT NULL,
+	seq INTEGER NOT NULL,
+	code TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (record_id, field_seq, seq),
+	FOREIGN KEY (record_id, field_seq) REFERENCES fields(record_id, seq)
+);
+`
+
+// dbIndexes are created once the records are loaded, which is faster
+// than updating them for each row.
+const dbIndexes = `
+CREATE INDEX records_control_number ON records(control_number);
+CREATE INDEX fields_tag ON fields(tag);
+CREATE INDEX subfields_code ON subfields(code);
+`
+
+// dbPath returns the database indicated in -db, the name of the file
+// plus .db by default.
+func dbPath() (string, error) {
+	if dbFile != "" {
+		return dbFile, nil
+	}
+	if fileName == "" || fileName == "-" {
+		return "", errors.New("-db is required")
+	}
+	return fileName + ".db", nil
+}
+
+// dbDataSource returns the SQLite URI of the database file with the
+// options in query, the path is escaped so that a ?, #, or % in it is
+// not taken as the start of the options or an escape.
+func dbDataSource(path string, query string) string {
+	u := url.URL{Scheme: "file", Path: path, OmitHost: true, RawQuery: query}
+	return u.String()
+}
+
+// runDBLoad loads the records that match the search criteria into the
+// database, replacing the records loaded before.
+func runDBLoad(ctx context.Context) error {
+	params := fileParams()
+	if len(params.filters.Fields) > 0 && len(params.exclude.Fields) > 0 {
+		return errors.New("cannot specify fields and exclude at the same time")
+	}
+	path, err := dbPath()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", dbDataSource(path, ""))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	loader := &dbLoader{ctx: ctx, db: db, filters: params.filters, plan: params.plan}
+	if err := processFile(ctx, params, loader, os.Stdout); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d records loaded into %s\n", loader.records, path)
+	return nil
+}
+
+// dbLoader is the processor that saves the records in the database.
+// The records are loaded in one transaction so the database keeps the
+// previous records if loading fails or is interrupted.
+type dbLoader struct {
+	ctx      context.Context
+	db       *sql.DB
+	tx       *sql.Tx
+	records  int
+	filters  marc.FieldFilters
+	plan     *marc.FilterPlan
+	record   *sql.Stmt
+	field    *sql.Stmt
+	subfield *sql.Stmt
+}
+
+// Tags returns the tags of the fields requested in -fields, nil if all
+// the fields are loaded.
+func (l *dbLoader) Tags() []string {
+	tags := filterTags(l.filters)
+	if tags == nil {
+		return nil
+	}
+	return append(tags, "001")
+}
+
+func (l *dbLoader) Header(w io.Writer) error {
+	var err error
+	if l.tx, err = l.db.Begin(); err != nil {
+		return err
+	}
+	if _, err := l.tx.Exec(dbSchema); err != nil {
+		return err
+	}
+	if l.record, err = l.tx.Prepare("INSERT INTO records (id, pos, control_number, leader) VALUES (?, ?, ?, ?)"); err != nil {
+		return err
+	}
+	if l.field, err = l.tx.Prepare("INSERT INTO fields (record_id, seq, tag, ind1, ind2, value) VALUES (?, ?, ?, ?, ?, ?)"); err != nil {
+		return err
+	}
+	l.subfield, err = l.tx.Prepare("INSERT INTO subfields (record_id, field_seq, seq, code, value) VALUES (?, ?, ?, ?, ?)")
+	return err
+}
+
+func (l *dbLoader) ProcessRecord(w io.Writer, r marc.Record) error {
+	l.records++
+	id := l.records
+	if _, err := l.record.Exec(id, r.Pos, r.ControlNum(), r.Leader.String()); err != nil {
+		return err
+	}
+	for i, field := range l.plan.Filter(r) {
+		// The value of data fields is the text of their subfields, to
+		// search the whole field with LIKE.
+		value := field.Value
+		if !field.IsControlField() {
+			values := make([]string, len(field.SubFields))
+			for j, sub := range field.SubFields {
+				values[j] = sub.Value
+			}
+			value = strings.Join(values, " ")
+		}
+		if _, err := l.field.Exec(id, i+1, field.Tag, field.Indicator1, field.Indicator2, value); err != nil {
+			return err
+		}
+		for j, sub := range field.SubFields {
+			if _, err := l.subfield.Exec(id, i+1, j+1, sub.Code, sub.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *dbLoader) Footer(w io.Writer) error {
+	// processFile calls Footer after an interruption (e.g. -timeout),
+	// the records loaded so far would replace all the previous ones.
+	if err := l.ctx.Err(); err != nil {
+		l.rollback()
+		return err
+	}
+	if _, err := l.tx.Exec(dbIndexes); err != nil {
+		return err
+	}
+	err := l.tx.Commit()
+	l.tx = nil
+	return err
+}
+
+// rollback discards the records loaded after an error.
+func (l *dbLoader) rollback() {
+	if l.tx != nil {
+		l.tx.Rollback()
+		l.tx = nil
+	}
+}
+
+// dbValueReplacer replaces the characters that would break the rows
+// and columns of the output.
+var dbValueReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// runDBQuery runs the query in -sql and outputs the rows as tab
+// delimited values with the names of the columns in the first row.
+func runDBQuery(ctx context.Context) error {
+	if dbSQL == "" {
+		return errors.New("-sql is required")
+	}
+	path, err := dbPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("cannot open the database, use db load to create it: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbDataSource(path, "mode=ro"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, dbSQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	fmt.Fprintln(out, strings.Join(columns, "\t"))
+	values := make([]sql.NullString, len(columns))
+	scan := make([]interface{}, len(columns))
+	for i := range values {
+		scan[i] = &values[i]
+	}
+	line := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(scan...); err != nil {
+			return err
+		}
+		for i, value := range values {
+			line[i] = dbValueReplacer.Replace(value.String)
+		}
+		fmt.Fprintln(out, strings.Join(line, "\t"))
+	}
+	return rows.Err()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDBDataSource(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path  string
+		query string
+		want  string
+	}{
+		{path: "test.db", want: "file:test.db"},
+		{path: "/data/a?b#c%d.db", query: "mode=ro", want: "file:/data/a%3Fb%23c%25d.db?mode=ro"},
+	}
+	for _, tt := range tests {
+		if got := dbDataSource(tt.path, tt.query); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+
+	// The file is created with its name as is.
+	path := filepath.Join(t.TempDir(), "a?b#c%41.db")
+	db, err := sql.Open("sqlite", dbDataSource(path, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE records (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the database in %s: %v", path, err)
+	}
+}
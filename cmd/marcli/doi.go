@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "doi",
+		description: "Report the DOIs in 024 and 856, and verify them in Crossref with -crossref",
+		flags:       []string{"crossref", "crossref-mailto", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file", "invalid-utf8"},
+		run:         runDOI,
+	})
+}
+
+// crossrefAPI is the Crossref REST API, it is a variable to use a test
+// server, e.g. go build -ldflags "-X main.crossrefAPI=http://localhost:8080"
+var crossrefAPI = "https://api.crossref.org"
+
+// crossrefMaxRetries is how many times a request is sent again when
+// Crossref is too busy (HTTP 429) or fails, waiting crossrefFirstBackoff
+// the first time and twice as long each time after that.
+const (
+	crossrefMaxRetries   = 3
+	crossrefFirstBackoff = time.Second
+)
+
+// doiPattern is the syntax of a DOI: the 10. prefix, the registrant
+// code, and a suffix without spaces.
+var doiPattern = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+
+func runDOI(ctx context.Context) error {
+	params := fileParams()
+	p := &doiProcessor{}
+	if crossref {
+		p.crossref = newCrossrefClient(ctx, crossrefMailto)
+	}
+	err := processFile(ctx, params, p, os.Stdout)
+	fmt.Fprintf(os.Stderr, "%d DOIs in %d records: %d invalid", p.dois, p.records, p.invalid)
+	if p.crossref != nil {
+		fmt.Fprintf(os.Stderr, ", %d not found in Crossref, %d with a different title or year", p.notFound, p.mismatches)
+	}
+	fmt.Fprintln(os.Stderr)
+	return err
+}
+
+// doiProcessor outputs a tab delimited report with a row for each DOI
+// in the records (024 with $2 doi, and doi.org links in 856 $u) and its
+// status: invalid when it is not a valid DOI and, when checked in
+// Crossref, "not found" or the differences between the title and year
+// of the record and the ones registered in Crossref. Records without a
+// DOI are not reported.
+type doiProcessor struct {
+	crossref   *crossrefClient
+	records    int
+	dois       int
+	invalid    int
+	notFound   int
+	mismatches int
+}
+
+// recordDOI is a DOI found in a record and the field it is in.
+type recordDOI struct {
+	doi    string
+	source string
+}
+
+func (p *doiProcessor) Header(w io.Writer) error {
+	columns := []string{"id", "doi", "source", "status", "title", "year"}
+	if p.crossref != nil {
+		columns = append(columns, "crossref_title", "crossref_year")
+	}
+	_, err := fmt.Fprintln(w, strings.Join(columns, "\t"))
+	return err
+}
+
+func (p *doiProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	dois := recordDOIs(r)
+	if len(dois) == 0 {
+		return errRecordSkipped
+	}
+	p.records++
+	id := itemsCleanValue(r.ControlNum())
+	title := strings.TrimRight(itemsCleanValue(r.GetValue("245", "a")), " /:;,=")
+	year := recordYear(r)
+	for _, found := range dois {
+		p.dois++
+		status, registered := "valid", []string{"", ""}
+		switch {
+		case !doiPattern.MatchString(found.doi):
+			p.invalid++
+			status = "invalid"
+		case p.crossref != nil:
+			work, err := p.crossref.work(found.doi)
+			if err != nil {
+				return err
+			}
+			status = p.compare(title, year, work)
+			registered = []string{itemsCleanValue(work.title()), work.years().String()}
+		}
+		values := []string{id, itemsCleanValue(found.doi), found.source, status, title, year}
+		if p.crossref != nil {
+			values = append(values, registered...)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(values, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *doiProcessor) Footer(w io.Writer) error {
+	return nil
+}
+
+// compare returns the status of a DOI registered in Crossref: ok, not
+// found, or the differences between the record and the metadata in
+// Crossref.
+func (p *doiProcessor) compare(title string, year string, work *crossrefWork) string {
+	if work == nil {
+		p.notFound++
+		return "not found"
+	}
+	problems := []string{}
+	if !titlesMatch(title, work.title()) {
+		problems = append(problems, "title mismatch")
+	}
+	if year != "" && len(work.years()) > 0 && !work.years().contains(year) {
+		problems = append(problems, "year mismatch")
+	}
+	if len(problems) == 0 {
+		return "ok"
+	}
+	p.mismatches++
+	return strings.Join(problems, ", ")
+}
+
+// recordDOIs returns the DOIs in the 024 fields with $2 doi and in the
+// links to doi.org in 856 $u, without repeating the same DOI.
+func recordDOIs(r marc.Record) []recordDOI {
+	dois := []recordDOI{}
+	seen := map[string]bool{}
+	add := func(doi string, source string) {
+		doi = strings.TrimRight(strings.TrimSpace(doi), ".,;")
+		key := strings.ToLower(doi)
+		if doi == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		dois = append(dois, recordDOI{doi: doi, source: source})
+	}
+	for _, field := range r.Fields.GetAll("024") {
+		if strings.EqualFold(strings.TrimSpace(field.SubFieldValue("2")), "doi") {
+			for _, value := range field.SubFieldValues("a") {
+				add(strings.TrimPrefix(strings.TrimPrefix(value, "doi:"), "DOI:"), "024")
+			}
+		}
+	}
+	for _, field := range r.Fields.GetAll("856") {
+		for _, value := range field.SubFieldValues("u") {
+			if doi, ok := doiFromURL(value); ok {
+				add(doi, "856")
+			}
+		}
+	}
+	return dois
+}
+
+// doiFromURL returns the DOI in a link to doi.org (or dx.doi.org).
+func doiFromURL(value string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || (u.Host != "doi.org" && u.Host != "dx.doi.org" && u.Host != "www.doi.org") {
+		return "", false
+	}
+	return strings.TrimPrefix(u.Path, "/"), true
+}
+
+// recordYear returns the year of publication in the 008 (date 1), or
+// else the first year in 264 $c or 260 $c.
+func recordYear(r marc.Record) string {
+	if value := r.GetValue("008", ""); len(value) >= 11 && isYear(value[7:11]) {
+		return value[7:11]
+	}
+	for _, tag := range []string{"264", "260"} {
+		for _, value := range r.GetValues(tag, "c") {
+			if year := yearPattern.FindString(value); year != "" {
+				return year
+			}
+		}
+	}
+	return ""
+}
+
+var yearPattern = regexp.MustCompile(`\b[12]\d{3}\b`)
+
+func isYear(value string) bool {
+	_, err := strconv.Atoi(value)
+	return err == nil && (value[0] == '1' || value[0] == '2')
+}
+
+// titlesMatch returns true when one of the titles (ignoring case,
+// punctuation, and spaces) starts with the other, since the titles in
+// Crossref often do not include the subtitle and the 245 $a does not
+// include the title of the part.
+func titlesMatch(a, b string) bool {
+	a, b = normalizeTitle(a), normalizeTitle(b)
+	if a == "" || b == "" {
+		return true
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+func normalizeTitle(value string) string {
+	var sb strings.Builder
+	for _, c := range strings.ToLower(value) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// crossrefWork is the metadata of a DOI registered in Crossref.
+type crossrefWork struct {
+	Title           []string     `json:"title"`
+	Issued          crossrefDate `json:"issued"`
+	PublishedPrint  crossrefDate `json:"published-print"`
+	PublishedOnline crossrefDate `json:"published-online"`
+}
+
+type crossrefDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func (w *crossrefWork) title() string {
+	if w == nil || len(w.Title) == 0 {
+		return ""
+	}
+	return w.Title[0]
+}
+
+// years returns the years the work was issued and published in print
+// and online, any of them can be the year in the record.
+func (w *crossrefWork) years() crossrefYears {
+	years := crossrefYears{}
+	if w == nil {
+		return years
+	}
+	for _, date := range []crossrefDate{w.Issued, w.PublishedPrint, w.PublishedOnline} {
+		if len(date.DateParts) > 0 && len(date.DateParts[0]) > 0 && date.DateParts[0][0] > 0 {
+			year := strconv.Itoa(date.DateParts[0][0])
+			if !years.contains(year) {
+				years = append(years, year)
+			}
+		}
+	}
+	return years
+}
+
+type crossrefYears []string
+
+func (y crossrefYears) contains(year string) bool {
+	for _, value := range y {
+		if value == year {
+			return true
+		}
+	}
+	return false
+}
+
+func (y crossrefYears) String() string {
+	return strings.Join(y, " ")
+}
+
+// crossrefClient gets the metadata of the DOIs from the Crossref REST
+// API. With a mailto the requests go to the "polite" pool of Crossref,
+// which is recommended for large files. Each DOI is requested once.
+type crossrefClient struct {
+	ctx    context.Context
+	mailto string
+	works  map[string]*crossrefWork
+	client *http.Client
+}
+
+func newCrossrefClient(ctx context.Context, mailto string) *crossrefClient {
+	return &crossrefClient{
+		ctx:    ctx,
+		mailto: mailto,
+		works:  map[string]*crossrefWork{},
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// work returns the metadata of the DOI, nil if it is not registered in
+// Crossref.
+func (cc *crossrefClient) work(doi string) (*crossrefWork, error) {
+	key := strings.ToLower(doi)
+	if work, ok := cc.works[key]; ok {
+		return work, nil
+	}
+	backoff := crossrefFirstBackoff
+	for attempt := 0; ; attempt++ {
+		work, err := cc.get(doi)
+		var retryable retryableError
+		if err == nil {
+			cc.works[key] = work
+			return work, nil
+		}
+		if !errors.As(err, &retryable) || attempt == crossrefMaxRetries {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s, retrying in %s\n", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-cc.ctx.Done():
+			return nil, cc.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (cc *crossrefClient) get(doi string) (*crossrefWork, error) {
+	endpoint := crossrefAPI + "/works/" + url.PathEscape(doi)
+	if cc.mailto != "" {
+		endpoint += "?" + url.Values{"mailto": {cc.mailto}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(cc.ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "marcli/"+version)
+	resp, err := cc.client.Do(req)
+	if err != nil {
+		if cc.ctx.Err() != nil {
+			return nil, cc.ctx.Err()
+		}
+		return nil, retryableError{err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, retryableError{err}
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, retryableError{fmt.Errorf("error getting DOI %s from Crossref: %s", doi, resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("error getting DOI %s from Crossref: %s", doi, resp.Status)
+	}
+	var result struct {
+		Message crossrefWork `json:"message"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid response from Crossref for DOI %s: %w", doi, err)
+	}
+	return &result.Message, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// duplicateTracker keeps the control numbers (001) of the records read
+// to warn about duplicates (see -warn-duplicates), which silently break
+// overlay loads in most systems.
+type duplicateTracker struct {
+	seen  map[string]int64 // position of the first record with each 001
+	count int
+}
+
+func newDuplicateTracker() *duplicateTracker {
+	return &duplicateTracker{seen: map[string]int64{}}
+}
+
+// check logs a warning (and adds it to the report) if the control
+// number of the record has been seen before. It is safe to call on a
+// nil tracker.
+func (dt *duplicateTracker) check(r marc.Record, report *runReport) {
+	if dt == nil {
+		return
+	}
+	id := r.ControlNum()
+	if id == "" {
+		return
+	}
+	first, ok := dt.seen[id]
+	if !ok {
+		// Copy the value, it shares the memory of the whole record.
+		dt.seen[string([]byte(id))] = r.Pos
+		return
+	}
+	dt.count++
+	msg := fmt.Sprintf("duplicate 001 %q, first seen in the record at byte %d", id, first)
+	fmt.Fprintf(os.Stderr, "Warning: record at byte %d: %s\n", r.Pos, msg)
+	report.recordWarning(r, "DUPLICATE", msg)
+}
+
+// summary prints the number of duplicates found to stderr. It is safe
+// to call on a nil tracker.
+func (dt *duplicateTracker) summary() {
+	if dt == nil || dt.count == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d records with a duplicate 001\n", dt.count)
+}
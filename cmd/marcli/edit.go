@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "edit",
+		description: "Delete and add fields to the records",
+		flags:       []string{"delete", "add", "dry-run", "format", "start", "count", "skip-errors", "error-file", "metrics", "debug-addr", "resume-from", "checkpoint", "checkpoint-every", "buffer-size", "invalid-utf8", "warn-duplicates"},
+		formatFlags: true,
+		defaults:    map[string]string{"format": "mrc"},
+		run:         runEdit,
+	})
+}
+
+func runEdit(ctx context.Context) error {
+	params := fileParams()
+	editor := editProcessor{
+		delete: marc.NewFieldFilters(deleteFields),
+		dryRun: dryRun,
+	}
+	for _, line := range addFields {
+		field, err := marc.ParseMrkField(line)
+		if err != nil {
+			return err
+		}
+		editor.add = append(editor.add, field)
+	}
+	if len(editor.delete.Fields) == 0 && len(editor.add) == 0 {
+		return errors.New("no changes indicated, use the delete or add parameters")
+	}
+
+	var err error
+	editor.output, err = newProcessor(format, params)
+	if err != nil {
+		return err
+	}
+	return processFile(ctx, params, editor, os.Stdout)
+}
+
+// editProcessor applies the changes to each record and passes the
+// result to the output processor. In dry-run mode it outputs the
+// differences between the original and the edited records instead.
+type editProcessor struct {
+	output Processor
+	delete marc.FieldFilters
+	add    []marc.Field
+	dryRun bool
+}
+
+func (p editProcessor) Header(w io.Writer) error {
+	if p.dryRun {
+		return nil
+	}
+	return p.output.Header(w)
+}
+
+func (p editProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	edited := p.edit(r)
+	if !p.dryRun {
+		return p.output.ProcessRecord(w, edited)
+	}
+
+	diff := marc.Diff(r, edited)
+	if diff == "" {
+		return errRecordSkipped
+	}
+	_, err := io.WriteString(w, diff+"\n")
+	return err
+}
+
+func (p editProcessor) Footer(w io.Writer) error {
+	if p.dryRun {
+		return nil
+	}
+	return p.output.Footer(w)
+}
+
+// edit returns a copy of the record with the fields indicated deleted
+// and the new fields added (in tag order).
+func (p editProcessor) edit(r marc.Record) marc.Record {
+	edited := r.Clone()
+	if len(p.delete.Fields) > 0 {
+		edited.Fields = edited.Filter(marc.FieldFilters{}, p.delete)
+	}
+	for _, field := range p.add {
+		i := len(edited.Fields)
+		for j, existing := range edited.Fields {
+			if existing.Tag > field.Tag {
+				i = j
+				break
+			}
+		}
+		edited.Fields = append(edited.Fields[:i], append([]marc.Field{field.Clone()}, edited.Fields[i:]...)...)
+	}
+	// The original bytes no longer represent the record.
+	edited.Data = nil
+	return edited
+}
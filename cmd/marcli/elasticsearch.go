@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// esMaxRetries is how many times a batch (or the documents in it that
+// were rejected because the cluster was too busy) is sent again before
+// giving up. The wait between attempts starts at esFirstBackoff and
+// doubles each time.
+const (
+	esMaxRetries   = 6
+	esFirstBackoff = 500 * time.Millisecond
+)
+
+// esSink indexes the records in Elasticsearch (or OpenSearch) with the
+// bulk API. The documents are the records as rendered in the output
+// format, which must be JSON. Since bulk requests are sent one at a time
+// and retried while the cluster is overloaded (HTTP 429), reading the
+// file slows down to the pace at which the cluster can index.
+type esSink struct {
+	ctx      context.Context
+	endpoint string
+	index    string
+	client   *http.Client
+	// backoff is the first wait between attempts, esFirstBackoff.
+	backoff time.Duration
+}
+
+func newESSink(ctx context.Context, esURL string, index string) (*esSink, error) {
+	if index == "" {
+		return nil, errors.New("-es-index is required with -es-url")
+	}
+	if _, err := url.ParseRequestURI(esURL); err != nil {
+		return nil, fmt.Errorf("invalid -es-url: %w", err)
+	}
+	return &esSink{
+		ctx:      ctx,
+		endpoint: strings.TrimRight(esURL, "/") + "/_bulk",
+		index:    index,
+		client:   &http.Client{Timeout: 2 * time.Minute},
+		backoff:  esFirstBackoff,
+	}, nil
+}
+
+func (es *esSink) String() string {
+	return "Elasticsearch index " + es.index
+}
+
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func (es *esSink) send(batch []sinkMessage) error {
+	backoff := es.backoff
+	for attempt := 0; ; attempt++ {
+		rejected, err := es.sendBulk(batch)
+		if err == nil && len(rejected) == 0 {
+			return nil
+		}
+		if attempt == esMaxRetries {
+			if err == nil {
+				err = fmt.Errorf("%d documents rejected by %s, the cluster is too busy", len(rejected), es)
+			}
+			return err
+		}
+		var retryable retryableError
+		if err != nil && !errors.As(err, &retryable) {
+			return err
+		}
+		if ctxErr := es.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s, retrying in %s\n", err, backoff)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: %d documents rejected by %s, retrying in %s\n", len(rejected), es, backoff)
+			batch = rejected
+		}
+		if err := sleepContext(es.ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// retryableError is an error sending a request that might work if the
+// request is sent again later (e.g. HTTP 429 or 503).
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+// sendBulk sends the batch in a bulk request and returns the documents
+// rejected because the cluster was too busy, which can be sent again.
+func (es *esSink) sendBulk(batch []sinkMessage) ([]sinkMessage, error) {
+	var body bytes.Buffer
+	for _, msg := range batch {
+		action := map[string]map[string]string{"index": {"_index": es.index}}
+		if msg.key != "" {
+			action["index"]["_id"] = msg.key
+		}
+		b, err := json.Marshal(action)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(b)
+		body.WriteByte('\n')
+		doc, err := esDocument(msg.value)
+		if err != nil {
+			return nil, err
+		}
+		// The bulk API needs each document in a single line.
+		if err := json.Compact(&body, doc); err != nil {
+			return nil, fmt.Errorf("invalid document for record %q: %w", msg.key, err)
+		}
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(es.ctx, http.MethodPost, es.endpoint, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return nil, retryableError{err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, retryableError{err}
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, retryableError{fmt.Errorf("error indexing in %s: %s", es, resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("error indexing in %s: %s: %s", es, resp.Status, bytes.TrimSpace(data))
+	}
+
+	var bulk esBulkResponse
+	if err := json.Unmarshal(data, &bulk); err != nil {
+		return nil, fmt.Errorf("invalid response from Elasticsearch: %w", err)
+	}
+	if !bulk.Errors {
+		return nil, nil
+	}
+	rejected := []sinkMessage{}
+	for i, item := range bulk.Items {
+		result := item["index"]
+		switch {
+		case result.Status == http.StatusTooManyRequests && i < len(batch):
+			rejected = append(rejected, batch[i])
+		case result.Status >= 300:
+			return nil, fmt.Errorf("error indexing document %q in %s: %s", result.ID, es, result.Error)
+		}
+	}
+	return rejected, nil
+}
+
+// esDocument returns the record rendered in the output format as an
+// Elasticsearch document: JSON objects (e.g. -format solr) are used as
+// they are, arrays (-format json) are wrapped in {"fields": [...]}.
+func esDocument(value []byte) ([]byte, error) {
+	value = bytes.TrimSpace(value)
+	switch {
+	case bytes.HasPrefix(value, []byte("{")):
+		return value, nil
+	case bytes.HasPrefix(value, []byte("[")):
+		doc := append([]byte(`{"fields":`), value...)
+		return append(doc, '}'), nil
+	}
+	return nil, errors.New("the documents for Elasticsearch must be JSON, use -format json or solr")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// esTestServer answers the bulk requests with the responses in order
+// (the last one is repeated) and records the ids of the documents in
+// each request.
+func esTestServer(t *testing.T, responses ...func(w http.ResponseWriter)) (*httptest.Server, func() [][]string) {
+	var mu sync.Mutex
+	requests := [][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := []string{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if i := strings.Index(line, `"_id":"`); i != -1 && strings.HasPrefix(line, `{"index"`) {
+				id := line[i+len(`"_id":"`):]
+				ids = append(ids, id[:strings.Index(id, `"`)])
+			}
+		}
+		mu.Lock()
+		n := len(requests)
+		requests = append(requests, ids)
+		mu.Unlock()
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		responses[n](w)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func esStatus(status int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestESSink(t *testing.T) {
+	t.Parallel()
+
+	batch := []sinkMessage{
+		{key: "a", value: []byte(`{"title": "one"}`)},
+		{key: "b", value: []byte(`{"title": "two"}`)},
+	}
+	ok := esStatus(http.StatusOK, `{"errors": false, "items": []}`)
+	tests := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		wantErr   string
+		wantIDs   [][]string
+	}{
+		{
+			name:      "overloaded",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusTooManyRequests, ""), ok},
+			wantIDs:   [][]string{{"a", "b"}, {"a", "b"}},
+		},
+		{
+			name:      "server error",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusBadGateway, "")},
+			wantErr:   "502 Bad Gateway",
+		},
+		{
+			name: "rejected documents",
+			responses: []func(w http.ResponseWriter){
+				esStatus(http.StatusOK, `{"errors": true, "items": [{"index": {"_id": "a", "status": 201}}, {"index": {"_id": "b", "status": 429}}]}`),
+				ok,
+			},
+			wantIDs: [][]string{{"a", "b"}, {"b"}},
+		},
+		{
+			name:      "invalid document",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusOK, `{"errors": true, "items": [{"index": {"_id": "a", "status": 400, "error": {"type": "mapper_parsing_exception"}}}]}`)},
+			wantErr:   `error indexing document "a"`,
+			wantIDs:   [][]string{{"a", "b"}},
+		},
+		{
+			name:      "bad request",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusBadRequest, "no such index")},
+			wantErr:   "no such index",
+			wantIDs:   [][]string{{"a", "b"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server, requests := esTestServer(t, tt.responses...)
+			es, err := newESSink(context.Background(), server.URL, "records")
+			if err != nil {
+				t.Fatal(err)
+			}
+			es.backoff = time.Millisecond
+
+			err = es.send(batch)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error with %q, got %v", tt.wantErr, err)
+			}
+			got := requests()
+			if tt.wantIDs == nil {
+				// Retried until giving up.
+				if len(got) != esMaxRetries+1 {
+					t.Errorf("expected %d requests, got %d", esMaxRetries+1, len(got))
+				}
+				return
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("expected the requests %v, got %v", tt.wantIDs, got)
+			}
+			for i := range got {
+				if strings.Join(got[i], ",") != strings.Join(tt.wantIDs[i], ",") {
+					t.Errorf("expected the documents %v in request %d, got %v", tt.wantIDs[i], i, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestESSinkCancel(t *testing.T) {
+	t.Parallel()
+
+	server, requests := esTestServer(t, esStatus(http.StatusServiceUnavailable, ""))
+	ctx, cancel := context.WithCancel(context.Background())
+	es, err := newESSink(ctx, server.URL, "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	es.backoff = time.Hour
+
+	go func() {
+		for len(requests()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	err = es.send([]sinkMessage{{key: "a", value: []byte(`{}`)}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the send to be cancelled while waiting to retry, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// errorLog writes the records that cannot be parsed (or are not valid)
+// to the file indicated in -error-file so that they can be inspected
+// and re-processed while the rest of the records go to the output.
+//
+// By default each record is written as its position, the error, and
+// a hex dump of its data. When the file has the .mrc extension the raw
+// records are written instead (only for MARC binary input) so that the
+// file can be fixed and processed again.
+type errorLog struct {
+	filename string
+	file     *os.File
+	w        *bufio.Writer
+	raw      bool
+	count    int
+}
+
+func openErrorLog(filename string) (*errorLog, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &errorLog{
+		filename: filename,
+		file:     file,
+		w:        bufio.NewWriter(file),
+		raw:      strings.EqualFold(filepath.Ext(filename), ".mrc"),
+	}, nil
+}
+
+// write adds a record to the log.
+func (el *errorLog) write(r marc.Record, recErr error) error {
+	el.count++
+	if el.raw {
+		if len(r.Data) == 0 {
+			return nil
+		}
+		_, err := el.w.Write(r.Raw())
+		return err
+	}
+
+	if _, err := fmt.Fprintf(el.w, "Record at byte %d: %s\n", r.Pos, recErr); err != nil {
+		return err
+	}
+	if len(r.Data) > 0 {
+		if _, err := el.w.WriteString(hex.Dump(r.Data)); err != nil {
+			return err
+		}
+	}
+	_, err := el.w.WriteString("\n")
+	return err
+}
+
+// Close flushes and closes the file. It is safe to call on a nil log.
+func (el *errorLog) Close() error {
+	if el == nil {
+		return nil
+	}
+	err := el.w.Flush()
+	if closeErr := el.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && el.count > 0 {
+		fmt.Fprintf(os.Stderr, "%d records with errors written to %s\n", el.count, el.filename)
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// excelOptions change the encoding of the tabular outputs (the CSV of
+// the reports, and the items and auth-table formats) so that they open
+// correctly in Excel: a byte order mark for Excel to detect UTF-8, CRLF
+// line endings, or a legacy codepage for the versions of Excel that
+// ignore the byte order mark.
+type excelOptions struct {
+	bom      bool
+	crlf     bool
+	codepage string // "" for UTF-8, see codepages
+}
+
+func (o excelOptions) enabled() bool {
+	return o.bom || o.crlf || o.codepage != ""
+}
+
+// codepages are the legacy codepages supported by -codepage, with their
+// aliases.
+var codepages = map[string]string{
+	"windows-1252": "windows-1252",
+	"cp1252":       "windows-1252",
+	"iso-8859-1":   "iso-8859-1",
+	"latin1":       "iso-8859-1",
+}
+
+// parseExcelOptions validates the values given in -bom, -crlf, and
+// -codepage.
+func parseExcelOptions(bom bool, crlf bool, codepage string) (excelOptions, error) {
+	o := excelOptions{bom: bom, crlf: crlf}
+	name := strings.ToLower(codepage)
+	if name == "" || name == "utf-8" || name == "utf8" {
+		return o, nil
+	}
+	o.codepage = codepages[name]
+	if o.codepage == "" {
+		return o, fmt.Errorf("invalid -codepage %q, valid values: utf-8, windows-1252, iso-8859-1", codepage)
+	}
+	if bom {
+		return o, fmt.Errorf("-bom is only for UTF-8, it cannot be used with -codepage %s", codepage)
+	}
+	return o, nil
+}
+
+// windows1252 are the characters of windows-1252 in 0x80-0x9F, the rest
+// of the characters above 0x7F are the same as in iso-8859-1.
+var windows1252 = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// composedLetters are the letters with a combining mark (as in the
+// decomposed UTF-8 of many MARC records) that have a precomposed form in
+// the codepages, e.g. e followed by U+0301 is é.
+var composedLetters = map[[2]rune]rune{}
+
+func init() {
+	marks := map[rune]string{
+		'\u0300': "AÀEÈIÌOÒUÙaàeèiìoòuù",
+		'\u0301': "AÁEÉIÍOÓUÚYÝaáeéiíoóuúyý",
+		'\u0302': "AÂEÊIÎOÔUÛaâeêiîoôuû",
+		'\u0303': "AÃNÑOÕaãnñoõ",
+		'\u0308': "AÄEËIÏOÖUÜYŸaäeëiïoöuüyÿ",
+		'\u030A': "AÅaå",
+		'\u0327': "CÇcç",
+		'\u030C': "SŠsšZŽzž",
+	}
+	for mark, pairs := range marks {
+		letters := []rune(pairs)
+		for i := 0; i < len(letters); i += 2 {
+			composedLetters[[2]rune{letters[i], mark}] = letters[i+1]
+		}
+	}
+}
+
+// encodeRune returns the byte of the character in the codepage.
+func encodeRune(codepage string, r rune) (byte, bool) {
+	if r < 0x80 || (r >= 0xA0 && r <= 0xFF) || (codepage == "iso-8859-1" && r <= 0xFF) {
+		return byte(r), true
+	}
+	if codepage == "windows-1252" {
+		b, ok := windows1252[r]
+		return b, ok
+	}
+	return 0, false
+}
+
+// excelWriter applies the excelOptions to the output. The characters
+// that are not in the codepage are replaced with the letter without the
+// diacritics (e.g. ō with o) if possible, or with a question mark.
+type excelWriter struct {
+	w       io.Writer
+	options excelOptions
+	started bool
+	// last is the last byte of the output, to add the CR of the line
+	// endings that do not have it.
+	last byte
+	// partial is an incomplete UTF-8 sequence at the end of a write and
+	// pending the last character, which can still be combined with the
+	// mark that follows it.
+	partial []byte
+	pending rune
+	buf     []byte
+}
+
+func newExcelWriter(w io.Writer, options excelOptions) *excelWriter {
+	return &excelWriter{w: w, options: options, pending: -1}
+}
+
+func (ew *excelWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	out := ew.buf[:0]
+	if !ew.started {
+		ew.started = true
+		if ew.options.bom {
+			out = append(out, "\xEF\xBB\xBF"...)
+		}
+	}
+	if ew.options.crlf {
+		converted := make([]byte, 0, len(p)+16)
+		for _, b := range p {
+			if b == '\n' && ew.last != '\r' {
+				converted = append(converted, '\r')
+			}
+			converted = append(converted, b)
+			ew.last = b
+		}
+		p = converted
+	}
+	if ew.options.codepage == "" {
+		out = append(out, p...)
+	} else {
+		out = ew.encode(out, append(append([]byte(nil), ew.partial...), p...))
+	}
+	ew.buf = out
+	if _, err := ew.w.Write(out); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// encode appends the characters in s in the codepage to out, except for
+// the last one (see pending) and an incomplete UTF-8 sequence at the end.
+func (ew *excelWriter) encode(out []byte, s []byte) []byte {
+	ew.partial = ew.partial[:0]
+	for len(s) > 0 {
+		if !utf8.FullRune(s) {
+			ew.partial = append(ew.partial, s...)
+			break
+		}
+		r, size := utf8.DecodeRune(s)
+		s = s[size:]
+		if unicode.Is(unicode.Mn, r) {
+			if composed, ok := composedLetters[[2]rune{ew.pending, r}]; ok {
+				ew.pending = composed
+			}
+			// Other marks are dropped.
+			continue
+		}
+		out = ew.flushPending(out)
+		ew.pending = r
+	}
+	return out
+}
+
+func (ew *excelWriter) flushPending(out []byte) []byte {
+	r := ew.pending
+	ew.pending = -1
+	switch {
+	case r == -1:
+		return out
+	case r == utf8.RuneError:
+		return append(out, '?')
+	}
+	if b, ok := encodeRune(ew.options.codepage, r); ok {
+		return append(out, b)
+	}
+	if base := []rune(marc.RemoveDiacritics(string(r))); len(base) == 1 {
+		if b, ok := encodeRune(ew.options.codepage, base[0]); ok {
+			return append(out, b)
+		}
+	}
+	return append(out, '?')
+}
+
+// Close writes the last character.
+func (ew *excelWriter) Close() error {
+	if ew.options.codepage == "" {
+		return nil
+	}
+	out := ew.flushPending(ew.buf[:0])
+	for range ew.partial {
+		out = append(out, '?')
+	}
+	_, err := ew.w.Write(out)
+	return err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExcelWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		options excelOptions
+		writes  []string
+		want    string
+	}{
+		{
+			name:    "bom once",
+			options: excelOptions{bom: true},
+			writes:  []string{"id\ttitle\n", "1\tCafé\n"},
+			want:    "\xEF\xBB\xBFid\ttitle\n1\tCafé\n",
+		},
+		{
+			name:    "crlf",
+			options: excelOptions{crlf: true},
+			writes:  []string{"a\n", "b\r\n", "c\r", "\n", "d\n\n"},
+			want:    "a\r\nb\r\nc\r\nd\r\n\r\n",
+		},
+		{
+			name:    "codepage",
+			options: excelOptions{codepage: "windows-1252"},
+			writes:  []string{"Café “quoted” €5\n"},
+			want:    "Caf\xE9 \x93quoted\x94 \x805\n",
+		},
+		{
+			name:    "combining mark",
+			options: excelOptions{codepage: "iso-8859-1"},
+			writes:  []string{"Cafe\u0301 nin\u0303o\n"},
+			want:    "Caf\xE9 ni\xF1o\n",
+		},
+		{
+			name:    "combining mark in the next write",
+			options: excelOptions{codepage: "windows-1252"},
+			writes:  []string{"Cafe", "\u0301\n"},
+			want:    "Caf\xE9\n",
+		},
+		{
+			name:    "split UTF-8 sequence",
+			options: excelOptions{codepage: "windows-1252"},
+			writes:  []string{"Caf\xC3", "\xA9 \xE2\x82", "\xAC\n"},
+			want:    "Caf\xE9 \x80\n",
+		},
+		{
+			name:    "unmappable characters",
+			options: excelOptions{codepage: "iso-8859-1"},
+			writes:  []string{"T\u014dky\u014d \u6771\u4eac \u20ac\n"},
+			want:    "Tokyo ?? ?\n",
+		},
+		{
+			name:    "unmappable combining mark",
+			options: excelOptions{codepage: "windows-1252"},
+			writes:  []string{"a\u0323b\n"},
+			want:    "ab\n",
+		},
+		{
+			name:    "incomplete sequence at the end",
+			options: excelOptions{codepage: "windows-1252"},
+			writes:  []string{"abc\xE2\x82"},
+			want:    "abc??",
+		},
+		{
+			name:    "all",
+			options: excelOptions{crlf: true, codepage: "windows-1252"},
+			writes:  []string{"Cafe", "\u0301\n", "Ma\xC3", "\xB1ana\r\n"},
+			want:    "Caf\xE9\r\nMa\xF1ana\r\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := newExcelWriter(&buf, tt.options)
+		for _, s := range tt.writes {
+			n, err := w.Write([]byte(s))
+			if err != nil || n != len(s) {
+				t.Fatalf("%s: Write(%q) = %d, %v", tt.name, s, n, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestParseExcelOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		bom      bool
+		codepage string
+		want     string
+		wantErr  bool
+	}{
+		{codepage: "", want: ""},
+		{codepage: "UTF-8", want: ""},
+		{codepage: "cp1252", want: "windows-1252"},
+		{codepage: "Latin1", want: "iso-8859-1"},
+		{codepage: "ebcdic", wantErr: true},
+		{bom: true, codepage: "windows-1252", wantErr: true},
+	}
+	for _, tt := range tests {
+		o, err := parseExcelOptions(tt.bom, false, tt.codepage)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error %v", tt.codepage, err)
+			continue
+		}
+		if err == nil && o.codepage != tt.want {
+			t.Errorf("%q: expected codepage %q, got %q", tt.codepage, tt.want, o.codepage)
+		}
+	}
+}
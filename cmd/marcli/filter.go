@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "filter",
+		description: "Output the records that match the search criteria, the default",
+		flags: append([]string{"match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "fields", "exclude", "format", "profile",
+			"start", "count", "workers", "debug", "skip-errors", "error-file", "metrics", "debug-addr", "resume-from", "checkpoint", "checkpoint-every", "at", "id", "index-file", "buffer-size", "invalid-utf8", "warn-duplicates", "positions", "matched-only", "highlight", "raw", "bom", "crlf", "codepage"}, sinkFlags...),
+		formatFlags: true,
+		run:         runFilter,
+	})
+	registerCommand(command{
+		name:        "convert",
+		description: "Convert the records to another format",
+		flags: append([]string{"format", "profile", "start", "count", "workers", "debug", "skip-errors", "error-file", "metrics", "debug-addr", "resume-from", "checkpoint", "checkpoint-every",
+			"at", "id", "index-file", "buffer-size", "invalid-utf8", "warn-duplicates", "positions", "bom", "crlf", "codepage"}, sinkFlags...),
+		formatFlags: true,
+		run:         runFilter,
+	})
+}
+
+// runFilter outputs the records that match the search criteria
+// in the format requested.
+func runFilter(ctx context.Context) error {
+	if matchedOnly && (search == "" || fields != "" || exclude != "") {
+		return errors.New("-matched-only requires -match and cannot be used with -fields or -exclude")
+	}
+	params := fileParams()
+	if len(params.filters.Fields) > 0 && len(params.exclude.Fields) > 0 {
+		return errors.New("cannot specify fields and exclude at the same time")
+	}
+
+	var err error
+	if atRecords != "" || atIDs != "" {
+		if params.filename == "-" {
+			return errors.New("cannot use -at or -id with stdin")
+		}
+		if params.offsets, params.numbers, err = indexOffsets(atRecords, atIDs); err != nil {
+			return err
+		}
+	}
+	if params.highlight, err = newHighlighter(highlight, params.searchValue, params.searchFields, params.normalization, format); err != nil {
+		return err
+	}
+	if params.excel.enabled() && format != "items" && format != "auth-table" {
+		return errors.New("-bom, -crlf, and -codepage are only supported in the tabular formats (items and auth-table)")
+	}
+	if params.positions && format != "mrk" && format != "pretty" {
+		return errors.New("-positions is only supported in the mrk and pretty formats")
+	}
+	if params.raw {
+		if format != "mrc" {
+			return errors.New("-raw is only supported in the mrc format")
+		}
+		if len(params.filters.Fields) > 0 || len(params.exclude.Fields) > 0 || params.fixUTF8 {
+			return errors.New("-raw cannot be used with -fields, -exclude, -matched-only, or -invalid-utf8, the records are output as they are")
+		}
+	}
+	if params.positions && (kafkaURL != "" || pgURL != "" || esURL != "" || solrURL != "" || webhookURL != "") {
+		return errors.New("-positions cannot be used when sending the records to another system")
+	}
+
+	processor, err := newProcessor(format, params)
+	if err != nil {
+		return err
+	}
+	if processor, err = withSink(ctx, params, processor); err != nil {
+		return err
+	}
+	return processFile(ctx, params, processor, os.Stdout)
+}
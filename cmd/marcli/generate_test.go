@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// TestGeneratedRecordsAreValid checks that the records of each type of
+// generate pass validate.
+func TestGeneratedRecordsAreValid(t *testing.T) {
+	t.Parallel()
+
+	for _, kind := range marc.GeneratorTypes {
+		g, err := marc.NewGenerator(kind, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			r := g.Next()
+			if err := validateRecord(r, nil); err != nil {
+				t.Fatalf("%s: record %d is not valid: %v", kind, i+1, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "headings",
+		description: "Output the distinct access points (1XX, 6XX, 7XX, and 8XX) in alphabetical order with their counts and tags, e.g. for authority processing",
+		flags:       []string{"bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runHeadings,
+	})
+}
+
+func runHeadings(ctx context.Context) error {
+	processor := &headingsProcessor{headings: map[string]*heading{}}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// headingTags are the fields with access points that are under authority
+// control.
+var headingTags = []string{
+	"100", "110", "111", "130",
+	"600", "610", "611", "630", "647", "648", "650", "651", "655",
+	"700", "710", "711", "730",
+	"800", "810", "811", "830",
+}
+
+// headingSkipCodes are the subfields that are not part of the heading
+// in any of the fields: the authority record and URIs ($0, $1), the
+// source ($2), materials specified ($3), relationships ($4 and $i),
+// institution ($5), linkage ($6, $8), ISSN ($x in 7XX and 8XX), and
+// volume ($v in 8XX). The relator terms ($e, or $j in X11) are skipped
+// by headingValue.
+var headingSkipCodes = map[byte]string{
+	'1': "012345689",
+	'6': "012345689",
+	'7': "0123456789iw",
+	'8': "0123456789vwx",
+}
+
+// headingSubdivisions are the subdivisions of the subject headings,
+// separated with "--".
+const headingSubdivisions = "vxyz"
+
+// heading is an access point, the first form found is output.
+type heading struct {
+	value string
+	key   string
+	count int
+	tags  map[string]bool
+}
+
+// headingsProcessor collects the access points. The headings are the
+// same when their sort key (see marc.SortKey) is the same and they are
+// of the same type (e.g. 100, 600, 700, and 800 are personal names) so
+// that the same name with different punctuation is one heading.
+type headingsProcessor struct {
+	headings map[string]*heading
+}
+
+func (p *headingsProcessor) Tags() []string {
+	return headingTags
+}
+
+func (p *headingsProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *headingsProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	for _, field := range r.Fields {
+		if !isHeadingTag(field.Tag) {
+			continue
+		}
+		value := headingValue(field)
+		key := marc.SortKey(value)
+		if key == "" {
+			continue
+		}
+		id := field.Tag[1:] + " " + key
+		h, ok := p.headings[id]
+		if !ok {
+			h = &heading{value: value, key: key, tags: map[string]bool{}}
+			p.headings[id] = h
+		}
+		h.count++
+		h.tags[field.Tag] = true
+	}
+	return nil
+}
+
+// Footer outputs the heading, the number of times it is used, and the
+// tags where it is used separated by tabs, in the order of their sort
+// key.
+func (p *headingsProcessor) Footer(w io.Writer) error {
+	headings := make([]*heading, 0, len(p.headings))
+	for _, h := range p.headings {
+		headings = append(headings, h)
+	}
+	sort.Slice(headings, func(i, j int) bool {
+		a, b := headings[i], headings[j]
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.value < b.value
+	})
+	var sb strings.Builder
+	for _, h := range headings {
+		tags := make([]string, 0, len(h.tags))
+		for tag := range h.tags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		fmt.Fprintf(&sb, "%s\t%d\t%s\n", h.value, h.count, strings.Join(tags, ","))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func isHeadingTag(tag string) bool {
+	for _, t := range headingTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// headingValue returns the heading in the field without the subfields
+// that are not part of it, with the subdivisions of the subject headings
+// separated with "--" and without the final punctuation, e.g.
+// "Coal--Analysis" for "650 \0$aCoal$xAnalysis.".
+func headingValue(field marc.Field) string {
+	skip := headingSkipCodes[field.Tag[0]]
+	if field.Tag[1:] == "00" || field.Tag[1:] == "10" {
+		skip += "e"
+	} else if field.Tag[1:] == "11" {
+		skip += "j"
+	}
+	subject := field.Tag[0] == '6'
+	value := ""
+	for _, sub := range field.SubFields {
+		if strings.Contains(skip, sub.Code) {
+			continue
+		}
+		text := strings.TrimSpace(sub.Value)
+		if text == "" {
+			continue
+		}
+		switch {
+		case value == "":
+			value = text
+		case subject && strings.Contains(headingSubdivisions, sub.Code):
+			value = trimHeading(value) + "--" + text
+		default:
+			value += " " + text
+		}
+	}
+	return trimHeading(value)
+}
+
+// trimHeading removes the final punctuation and spaces, but not the
+// hyphen of the open dates (e.g. "1950-").
+func trimHeading(value string) string {
+	return strings.TrimRight(value, " .,;:/")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// ANSI escape codes used to highlight the matches in a terminal, the
+// same colour that grep uses.
+const (
+	ansiHighlight = "\x1b[01;31m"
+	ansiReset     = "\x1b[0m"
+)
+
+// highlighter wraps the values that match the search in the fields
+// searched between the begin and end markers.
+type highlighter struct {
+	search        string // normalized, see normalize
+	tags          []string
+	normalization marc.Normalization
+	begin         string
+	end           string
+}
+
+// newHighlighter returns the highlighter for the -highlight value given:
+// auto (ANSI colours when the output is a terminal), ansi, none, or the
+// markers to use separated by a comma (e.g. ">>,<<", or "**" to use the
+// same marker before and after). It returns nil when there is nothing
+// to highlight, including a search that is blank once normalized.
+func newHighlighter(value string, search string, tags []string, normalization marc.Normalization, format string) (*highlighter, error) {
+	if search == "" || value == "none" || value == "" {
+		return nil, nil
+	}
+	text := format == "mrk" || format == "pretty"
+	h := &highlighter{tags: tags, normalization: normalization}
+	h.search, _ = h.normalize(search)
+	h.search = strings.TrimSpace(h.search)
+	if h.search == "" {
+		// E.g. only punctuation with -normalize sortkey.
+		return nil, nil
+	}
+	switch value {
+	case "auto":
+		if !text || !isTerminal(os.Stdout) {
+			return nil, nil
+		}
+		h.begin, h.end = ansiHighlight, ansiReset
+	case "ansi":
+		h.begin, h.end = ansiHighlight, ansiReset
+	default:
+		h.begin, h.end = value, value
+		if i := strings.Index(value, ","); i != -1 {
+			h.begin, h.end = value[:i], value[i+1:]
+		}
+	}
+	if !text {
+		return nil, fmt.Errorf("-highlight is only supported in the mrk and pretty formats")
+	}
+	return h, nil
+}
+
+// isTerminal returns true if the file is a terminal (a character device)
+// rather than a file or a pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// record returns a copy of the record with the matches highlighted.
+func (h *highlighter) record(r marc.Record) marc.Record {
+	r = r.Clone()
+	for i := range r.Fields {
+		field := &r.Fields[i]
+		if len(h.tags) > 0 && !contains(h.tags, field.Tag) {
+			continue
+		}
+		if field.IsControlField() {
+			field.Value = h.mark(field.Value)
+			continue
+		}
+		for j := range field.SubFields {
+			field.SubFields[j].Value = h.mark(field.SubFields[j].Value)
+		}
+	}
+	return r
+}
+
+// mark wraps the occurrences of the search value in the markers,
+// ignoring case and with the normalization of the search.
+func (h *highlighter) mark(value string) string {
+	normalized, offsets := h.normalize(value)
+	if !strings.Contains(normalized, h.search) {
+		return value
+	}
+	var sb strings.Builder
+	last, from := 0, 0
+	for {
+		i := strings.Index(normalized[from:], h.search)
+		if i == -1 {
+			break
+		}
+		start, end := from+i, from+i+len(h.search)
+		begin := offsets[start]
+		stop := len(value)
+		if end < len(offsets) {
+			stop = offsets[end]
+		}
+		sb.WriteString(value[last:begin])
+		sb.WriteString(h.begin)
+		sb.WriteString(value[begin:stop])
+		sb.WriteString(h.end)
+		last, from = stop, end
+	}
+	sb.WriteString(value[last:])
+	return sb.String()
+}
+
+// normalize returns the value normalized and case folded and, for each
+// byte of the result, the offset in the value of the character it comes
+// from. Both can have different lengths, e.g. ß is folded to ss.
+//
+// The characters are normalized one at a time, so for sort keys the
+// punctuation is replaced here with a space (only one for consecutive
+// characters) like marc.SortKey does.
+func (h *highlighter) normalize(value string) (string, []int) {
+	var sb strings.Builder
+	offsets := make([]int, 0, len(value))
+	space := false
+	for i, r := range value {
+		var s string
+		if h.normalization == marc.NormalizeSortKey {
+			s = marc.FoldCase(marc.Transliterate(string(r)))
+			if s != "" && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				if space {
+					continue
+				}
+				s = " "
+			}
+			space = s == " "
+		} else {
+			s = marc.FoldCase(h.normalization.Apply(string(r)))
+		}
+		sb.WriteString(s)
+		for j := 0; j < len(s); j++ {
+			offsets = append(offsets, i)
+		}
+	}
+	return sb.String(), offsets
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
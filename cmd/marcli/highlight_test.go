@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func TestHighlighter(t *testing.T) {
+	t.Parallel()
+
+	h, err := newHighlighter(">>,<<", "coal", nil, marc.NormalizeNone, "mrk")
+	if err != nil || h == nil {
+		t.Fatalf("expected a highlighter, got %v %v", h, err)
+	}
+	if got, want := h.mark("Coal mining, coal"), ">>Coal<< mining, >>coal<<"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// A search that is blank once normalized has nothing to highlight.
+	tests := []struct {
+		search        string
+		normalization marc.Normalization
+	}{
+		{search: " ", normalization: marc.NormalizeNone},
+		{search: "--!", normalization: marc.NormalizeSortKey},
+	}
+	for _, tt := range tests {
+		h, err := newHighlighter("ansi", tt.search, nil, tt.normalization, "mrk")
+		if err != nil || h != nil {
+			t.Errorf("%q: expected no highlighter, got %v %v", tt.search, h, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "holdings",
+		description: "Output the holdings statements of the holdings records, expanding the enumeration and chronology (863-865) with their captions and pattern (853-855), e.g. v.1:no.1 (1990:Jan.)-v.5:no.12 (1994:Dec.)",
+		flags:       []string{"bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runHoldings,
+	})
+}
+
+func runHoldings(ctx context.Context) error {
+	processor := &holdingsProcessor{}
+	if err := processFile(ctx, fileParams(), processor, os.Stdout); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d holdings statements in %d records, %d without captions\n", processor.statements, processor.records, processor.missing)
+	return nil
+}
+
+// holdingsProcessor outputs a tab delimited row for each holdings
+// statement (see marc.Record.HoldingsStatements) with the id of the
+// holdings record (001) and of the bibliographic record (004). The
+// enumerations without their captions are reported as warnings.
+type holdingsProcessor struct {
+	records    int
+	statements int
+	missing    int
+}
+
+func (p *holdingsProcessor) Tags() []string {
+	return []string{"001", "004", "853", "854", "855", "863", "864", "865", "866", "867", "868"}
+}
+
+func (p *holdingsProcessor) Header(w io.Writer) error {
+	_, err := io.WriteString(w, "id\tbib\ttag\tlink\tstatement\tnote\r\n")
+	return err
+}
+
+func (p *holdingsProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	statements := r.HoldingsStatements()
+	if len(statements) == 0 {
+		return errRecordSkipped
+	}
+	p.records++
+	id := r.ControlNum()
+	bib := strings.TrimSpace(r.GetValue("004", ""))
+
+	var sb strings.Builder
+	for _, s := range statements {
+		if s.Err != nil {
+			p.missing++
+			fmt.Fprintf(os.Stderr, "Warning: record at byte %d: %s %s: %s\n", r.Pos, s.Tag, s.Link, s.Err)
+			continue
+		}
+		p.statements++
+		fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\t%s\t%s\r\n", id, bib, s.Tag, s.Link, itemsCleanValue(s.Statement), itemsCleanValue(s.Note))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (p *holdingsProcessor) Footer(w io.Writer) error {
+	return nil
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "idloc",
+		description: "Add the id.loc.gov URIs ($0) of the name and subject headings (1XX, 6XX, 7XX)",
+		flags:       []string{"idloc-cache", "offline", "dry-run", "format", "start", "count", "skip-errors", "error-file", "invalid-utf8"},
+		formatFlags: true,
+		defaults:    map[string]string{"format": "mrc"},
+		run:         runIDLoc,
+	})
+}
+
+// idlocURL is the id.loc.gov server, it is a variable to use a test
+// server, e.g. go build -ldflags "-X main.idlocURL=http://localhost:8080"
+var idlocURL = "https://id.loc.gov"
+
+// idlocMaxRetries is how many times a lookup is sent again when the
+// server is too busy (HTTP 429) or fails, waiting idlocFirstBackoff the
+// first time and twice as long each time after that.
+const (
+	idlocMaxRetries   = 3
+	idlocFirstBackoff = time.Second
+)
+
+// errNotCached is returned by the lookups in -offline mode for the
+// headings that are not in the cache.
+var errNotCached = errors.New("not in the cache")
+
+func runIDLoc(ctx context.Context) error {
+	if offline && idlocCache == "" {
+		return errors.New("-offline requires -idloc-cache")
+	}
+	params := fileParams()
+	cache, err := loadIDLocCache(idlocCache)
+	if err != nil {
+		return err
+	}
+	p := &idlocProcessor{
+		lookup: &idlocClient{ctx: ctx, cache: cache, offline: offline, client: newIDLocHTTPClient()},
+		dryRun: dryRun,
+	}
+	if p.output, err = newProcessor(format, params); err != nil {
+		return err
+	}
+	err = processFile(ctx, params, p, os.Stdout)
+	if saveErr := cache.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	fmt.Fprintf(os.Stderr, "%d headings linked, %d already linked, %d not found in id.loc.gov", p.linked, p.already, p.notFound)
+	if offline {
+		fmt.Fprintf(os.Stderr, ", %d not in the cache", p.notCached)
+	}
+	fmt.Fprintln(os.Stderr)
+	return err
+}
+
+// idlocProcessor adds a $0 with the URI of the authority in id.loc.gov
+// to the headings that do not have one and passes the record to the
+// output processor. In dry-run mode it outputs the differences with the
+// original records instead, to review the URIs found before changing
+// the records.
+type idlocProcessor struct {
+	lookup    *idlocClient
+	output    Processor
+	dryRun    bool
+	linked    int
+	already   int
+	notFound  int
+	notCached int
+}
+
+func (p *idlocProcessor) Header(w io.Writer) error {
+	if p.dryRun {
+		return nil
+	}
+	return p.output.Header(w)
+}
+
+func (p *idlocProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	linked, err := p.link(r)
+	if err != nil {
+		return err
+	}
+	if !p.dryRun {
+		return p.output.ProcessRecord(w, linked)
+	}
+
+	diff := marc.Diff(r, linked)
+	if diff == "" {
+		return errRecordSkipped
+	}
+	_, err = io.WriteString(w, diff+"\n")
+	return err
+}
+
+func (p *idlocProcessor) Footer(w io.Writer) error {
+	if p.dryRun {
+		return nil
+	}
+	return p.output.Footer(w)
+}
+
+// link returns a copy of the record with the URIs of the headings found
+// in id.loc.gov added at the end of their fields.
+func (p *idlocProcessor) link(r marc.Record) (marc.Record, error) {
+	linked := r.Clone()
+	changed := false
+	for i, field := range linked.Fields {
+		vocabulary := idlocVocabulary(field)
+		if vocabulary == "" {
+			continue
+		}
+		if hasIDLocURI(field) {
+			p.already++
+			continue
+		}
+		label := headingLabel(field)
+		if label == "" {
+			continue
+		}
+		uri, err := p.lookup.uri(vocabulary, label)
+		switch {
+		case err == errNotCached:
+			p.notCached++
+			continue
+		case err != nil:
+			return r, err
+		case uri == "":
+			p.notFound++
+			continue
+		}
+		p.linked++
+		linked.Fields[i].SubFields = append(linked.Fields[i].SubFields, marc.SubField{Code: "0", Value: uri})
+		changed = true
+	}
+	if !changed {
+		return r, nil
+	}
+	// The original bytes no longer represent the record.
+	linked.Data = nil
+	return linked, nil
+}
+
+// idlocVocabulary returns the vocabulary of id.loc.gov where the heading
+// of the field is looked up: names for the names and titles in 1XX and
+// 7XX, and in 6XX when they are LCSH (second indicator 0) without
+// subdivisions, subjects for the topical and geographic LCSH headings
+// and the names with subdivisions. Other fields are not looked up.
+func idlocVocabulary(f marc.Field) string {
+	if f.IsControlField() || len(f.Tag) != 3 {
+		return ""
+	}
+	switch f.Tag[0] {
+	case '1', '7':
+		switch f.Tag[1:] {
+		case "00", "10", "11", "30":
+			return "names"
+		}
+	case '6':
+		if f.Indicator2 != "0" {
+			return ""
+		}
+		switch f.Tag[1:] {
+		case "50", "51":
+			return "subjects"
+		case "00", "10", "11", "30":
+			if len(f.GetSubFields("vxyz")) > 0 {
+				return "subjects"
+			}
+			return "names"
+		}
+	}
+	return ""
+}
+
+// hasIDLocURI returns true if the field already has a $0 with a URI of
+// id.loc.gov.
+func hasIDLocURI(f marc.Field) bool {
+	for _, value := range f.SubFieldValues("0") {
+		if strings.Contains(value, "id.loc.gov/") {
+			return true
+		}
+	}
+	return false
+}
+
+// headingLabel returns the heading of the field as it is written in the
+// authorized labels of id.loc.gov, e.g. "Coal--Analysis" for
+// 650 $aCoal$xAnalysis. Relator terms, control subfields, and the final
+// punctuation are removed.
+func headingLabel(f marc.Field) string {
+	// $e is a relator term in X00 and X10 but part of the heading in X11,
+	// where the relator term is in $j.
+	skip := "012345678ei"
+	if strings.HasSuffix(f.Tag, "11") {
+		skip = "012345678ij"
+	}
+	label := ""
+	for _, sub := range f.SubFields {
+		value := strings.TrimSpace(sub.Value)
+		if strings.Contains(skip, sub.Code) || value == "" {
+			continue
+		}
+		switch {
+		case label == "":
+			label = value
+		case strings.Contains("vxyz", sub.Code):
+			label = trimHeadingPunctuation(label) + "--" + value
+		default:
+			label += " " + value
+		}
+	}
+	return trimHeadingPunctuation(label)
+}
+
+// trimHeadingPunctuation removes the punctuation at the end of a heading
+// but keeps the period of an initial or an abbreviation (e.g.
+// "Swanson, Vernon E." or "Huffman, Claude, Jr.").
+func trimHeadingPunctuation(label string) string {
+	label = strings.TrimRight(label, " ,;:/")
+	if !strings.HasSuffix(label, ".") {
+		return label
+	}
+	words := strings.Fields(label)
+	last := strings.TrimSuffix(words[len(words)-1], ".")
+	if len(last) == 1 || last == "Jr" || last == "Sr" || last == "etc" {
+		return label
+	}
+	return strings.TrimSuffix(label, ".")
+}
+
+// idlocClient looks up the URIs of the headings with the label service
+// of id.loc.gov, which redirects to the authority with that label. The
+// results (including the headings not found) are kept in the cache to
+// look up each heading only once.
+type idlocClient struct {
+	ctx     context.Context
+	cache   *idlocCacheFile
+	offline bool
+	client  *http.Client
+}
+
+func newIDLocHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		// The URI of the authority is in the redirect.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// uri returns the URI of the authority with the label given in the
+// vocabulary (names or subjects), empty if there is none.
+func (ic *idlocClient) uri(vocabulary string, label string) (string, error) {
+	key := vocabulary + "|" + label
+	if uri, ok := ic.cache.uris[key]; ok {
+		return uri, nil
+	}
+	if ic.offline {
+		return "", errNotCached
+	}
+
+	backoff := idlocFirstBackoff
+	for attempt := 0; ; attempt++ {
+		uri, err := ic.lookup(vocabulary, label)
+		var retryable retryableError
+		if err == nil {
+			ic.cache.set(key, uri)
+			return uri, nil
+		}
+		if !errors.As(err, &retryable) || attempt == idlocMaxRetries {
+			return "", err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s, retrying in %s\n", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ic.ctx.Done():
+			return "", ic.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (ic *idlocClient) lookup(vocabulary string, label string) (string, error) {
+	endpoint := idlocURL + "/authorities/" + vocabulary + "/label/" + url.PathEscape(label)
+	req, err := http.NewRequestWithContext(ic.ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := ic.client.Do(req)
+	if err != nil {
+		if ic.ctx.Err() != nil {
+			return "", ic.ctx.Err()
+		}
+		return "", retryableError{err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		if uri := resp.Header.Get("X-Uri"); uri != "" {
+			return uri, nil
+		}
+		// Without X-Uri the location is the URI plus the extension of
+		// the format (e.g. .html).
+		location := resp.Header.Get("Location")
+		return strings.TrimSuffix(location, filepath.Ext(location)), nil
+	case resp.StatusCode == http.StatusNotFound:
+		return "", nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return "", retryableError{fmt.Errorf("error looking up %q in id.loc.gov: %s", label, resp.Status)}
+	}
+	return "", fmt.Errorf("error looking up %q in id.loc.gov: %s", label, resp.Status)
+}
+
+// idlocCacheFile keeps the URIs found for each heading (vocabulary|label),
+// an empty URI when the heading was not found. With -idloc-cache the
+// cache is saved to a JSON file to use it in later runs, or offline.
+type idlocCacheFile struct {
+	path    string
+	uris    map[string]string
+	changed bool
+}
+
+func loadIDLocCache(path string) (*idlocCacheFile, error) {
+	cache := &idlocCacheFile{path: path, uris: map[string]string{}}
+	if path == "" {
+		return cache, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.uris); err != nil {
+		return nil, fmt.Errorf("invalid -idloc-cache file %s: %w", path, err)
+	}
+	return cache, nil
+}
+
+func (c *idlocCacheFile) set(key string, uri string) {
+	c.uris[key] = uri
+	c.changed = true
+}
+
+// save writes the cache to its file when there are new headings in it.
+// The file is replaced only once it has been written completely.
+func (c *idlocCacheFile) save() error {
+	if c.path == "" || !c.changed {
+		return nil
+	}
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.uris); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "index",
+		description: "Create an index (record number, byte offset, and 001) to access the records directly with -at and -id",
+		flags:       []string{"index-file"},
+		run:         runIndex,
+	})
+}
+
+// indexEntry is a line in the index: the record number (1-based),
+// the position of the record in the file, and its control number.
+type indexEntry struct {
+	number int
+	offset int64
+	id     string
+}
+
+const indexHeader = "record\toffset\tid"
+
+// indexFileName returns the name of the index for the file,
+// by default it is next to the file (e.g. data.mrc.idx)
+func indexFileName() string {
+	if indexFile != "" {
+		return indexFile
+	}
+	return fileName + ".idx"
+}
+
+func runIndex(ctx context.Context) error {
+	if fileName == "-" {
+		return errors.New("cannot index stdin")
+	}
+
+	marcFile, file, err := openMarcFile(ProcessFileParams{filename: fileName, mmap: useMmap, maxRecordSize: maxRecordSize, parseMode: parseMode(), tags: []string{"001"}})
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	out, err := os.Create(indexFileName())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, indexHeader)
+	number := 0
+	for {
+		r, err := marcFile.NextContext(ctx)
+		if err == io.EOF || ctx.Err() != nil {
+			break
+		}
+		if err != nil && marcFile.Err() != nil {
+			return err
+		}
+		// Records that cannot be parsed are indexed too so that the
+		// record numbers match the ones used by -start.
+		number++
+		id := strings.NewReplacer("\t", " ", "\n", " ").Replace(strings.TrimSpace(r.ControlNum()))
+		fmt.Fprintf(w, "%d\t%d\t%s\n", number, r.Pos, id)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Indexed %d records in %s\n", number, indexFileName())
+	return ctx.Err()
+}
+
+// loadIndex reads the index of the file.
+func loadIndex() ([]indexEntry, error) {
+	file, err := os.Open(indexFileName())
+	if err != nil {
+		return nil, fmt.Errorf("%w (create it with: marcli index -file %s)", err, fileName)
+	}
+	defer file.Close()
+
+	entries := []indexEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == indexHeader || line == "" {
+			continue
+		}
+		values := strings.SplitN(line, "\t", 3)
+		if len(values) != 3 {
+			return nil, fmt.Errorf("invalid line in index %s: %q", indexFileName(), line)
+		}
+		number, err1 := strconv.Atoi(values[0])
+		offset, err2 := strconv.ParseInt(values[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid line in index %s: %q", indexFileName(), line)
+		}
+		entries = append(entries, indexEntry{number: number, offset: offset, id: values[2]})
+	}
+	return entries, scanner.Err()
+}
+
+// indexOffsets returns the offsets (and record numbers) of the records
+// requested with -at
+// (record numbers) and -id (control numbers), in the order requested.
+func indexOffsets(at, ids string) ([]int64, []int, error) {
+	entries, err := loadIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offsets, numbers := []int64{}, []int{}
+	for _, value := range splitList(at) {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 || number > len(entries) {
+			return nil, nil, fmt.Errorf("record %q not found in the index", value)
+		}
+		offsets = append(offsets, entries[number-1].offset)
+		numbers = append(numbers, number)
+	}
+
+	byID := map[string]indexEntry{}
+	for _, entry := range entries {
+		if _, ok := byID[entry.id]; !ok {
+			byID[entry.id] = entry
+		}
+	}
+	for _, id := range splitList(ids) {
+		entry, ok := byID[id]
+		if !ok {
+			return nil, nil, fmt.Errorf("record with id %q not found in the index", id)
+		}
+		offsets = append(offsets, entry.offset)
+		numbers = append(numbers, entry.number)
+	}
+	return offsets, numbers, nil
+}
+
+func splitList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// offsetReader reads the records at the given offsets of a file.
+type offsetReader struct {
+	file    io.ReadSeeker
+	offsets []int64
+}
+
+func (or *offsetReader) Next() (marc.Record, error) {
+	if len(or.offsets) == 0 {
+		return marc.Record{}, io.EOF
+	}
+	offset := or.offsets[0]
+	or.offsets = or.offsets[1:]
+	return marc.ReadRecordAt(or.file, offset)
+}
+
+func (or *offsetReader) Err() error {
+	return nil
+}
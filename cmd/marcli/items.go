@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// Settings for the items format, the defaults work for Innovative
+// (Sierra) exports. The profiles of other systems can be selected with
+// -profile (see itemsProfiles), or the settings changed in the command
+// line or in the configuration file, e.g.:
+//
+//	formats:
+//	  items:
+//	    bib-id: 999c
+//	    field: 952
+//	    callnumber: $o,082a
+//	    columns: id:bib,barcode:$p,location:$c,callnumber,title:245a
+//	    truncate: title=60
+//	    normalize: title=sortkey
+var itemsBibID, itemsField, itemsCallNumber, itemsColumns, itemsTruncate, itemsNormalize, itemsNoItemsFile string
+var itemsNoItems bool
+
+func init() {
+	registerFormat(processorFormat{
+		name:        "items",
+		description: "Tab delimited file with one row per item field (e.g. 945)",
+		contentType: "text/tab-separated-values; charset=utf-8",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			items, err := NewProcessorItems(params)
+			if err != nil {
+				return nil, err
+			}
+			if items.noItemsFile != nil {
+				return &itemsNoItemsProcessor{items: items}, nil
+			}
+			return items, nil
+		},
+		setFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&itemsBibID, "bib-id", "907a", "Field with the bib id (e.g. 001 or 907a).")
+			fs.StringVar(&itemsField, "field", "945", "Tag of the item fields.")
+			fs.StringVar(&itemsCallNumber, "callnumber", "$a$b,099a,090ab,050ab",
+				"Comma delimited list of fields to get the call number from, the first one present is used. $x indicates a subfield of the item field.")
+			fs.StringVar(&itemsColumns, "columns", "bib:bib,callnumber,barcode:$i,location:$l,item:$y",
+				"Comma delimited list of columns as header:source, source can be bib, callnumber, $x (subfield x of the item field), or a field in the record (e.g. 245a, 650{ind2=0}a, or 856[last]u).")
+			fs.StringVar(&itemsTruncate, "truncate", "",
+				"Comma delimited list of header=width with the maximum number of characters of the values in a column, e.g. title=60. By default the values are not truncated.")
+			fs.StringVar(&itemsNormalize, "normalize", "",
+				"Comma delimited list of header=normalization to normalize the values of a column: strip (remove the diacritics), transliterate (also replace letters like ø or æ with ASCII), or sortkey (also lower case the value and remove the punctuation), e.g. title=sortkey.")
+			fs.BoolVar(&itemsNoItems, "no-items", false,
+				"Output a row with the item columns empty for the records without item fields, by default they are skipped.")
+			fs.StringVar(&itemsNoItemsFile, "no-items-file", "",
+				"File where to write the rows of the records without item fields (with the item columns empty) instead of the output, to review them separately.")
+		},
+	})
+}
+
+// itemSource is a value to get from the item field (subfields is
+// not empty and tag is empty) or from the first field in the record
+// that matches the filter.
+type itemSource struct {
+	tag       string
+	subfields string
+	filter    marc.FieldFilter
+}
+
+type itemColumn struct {
+	header string
+	source string // "bib", "callnumber", or "" for other values
+	value  itemSource
+	width  int // maximum number of characters, 0 for no limit
+	// normalization is applied to the value before it is truncated.
+	normalization marc.Normalization
+}
+
+type ProcessorItems struct {
+	bibID      itemSource
+	field      string
+	callNumber []itemSource
+	columns    []itemColumn
+	noItems    bool
+	// noItemsFile is where the rows of the records without items are
+	// written, nil unless requested with -items.no-items-file.
+	noItemsFile *os.File
+	noItemsW    *bufio.Writer
+	noItemsRows int
+}
+
+func NewProcessorItems(params ProcessFileParams) (*ProcessorItems, error) {
+	if params.HasFilters() {
+		return nil, fmt.Errorf("filters not supported for this format, use -items.columns instead")
+	}
+
+	p := &ProcessorItems{field: itemsField, noItems: itemsNoItems}
+	var err error
+	if p.bibID, err = parseItemSource(itemsBibID); err != nil {
+		return nil, err
+	}
+	for _, value := range marc.SplitFieldSpecs(itemsCallNumber) {
+		source, err := parseItemSource(value)
+		if err != nil {
+			return nil, err
+		}
+		p.callNumber = append(p.callNumber, source)
+	}
+	for _, value := range marc.SplitFieldSpecs(itemsColumns) {
+		column, err := parseItemColumn(value)
+		if err != nil {
+			return nil, err
+		}
+		p.columns = append(p.columns, column)
+	}
+	err = p.setColumns("truncate", "title=60", itemsTruncate, func(column *itemColumn, value string) error {
+		width, err := strconv.Atoi(value)
+		if err != nil || width < 1 {
+			return fmt.Errorf("invalid width %q", value)
+		}
+		column.width = width
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = p.setColumns("normalize", "title=sortkey", itemsNormalize, func(column *itemColumn, value string) error {
+		var err error
+		column.normalization, err = marc.ParseNormalization(value)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if itemsNoItemsFile != "" {
+		if p.noItemsFile, err = os.Create(itemsNoItemsFile); err != nil {
+			return nil, err
+		}
+		p.noItemsW = bufio.NewWriter(p.noItemsFile)
+	}
+	return p, nil
+}
+
+// setColumns calls set with the value given for each column in a list
+// of header=value, e.g. "title=60,callnumber=20" for -items.truncate.
+// example is shown in the error for a value without a header.
+func (p *ProcessorItems) setColumns(name string, example string, list string, set func(column *itemColumn, value string) error) error {
+	for _, spec := range splitList(list) {
+		i := strings.Index(spec, "=")
+		if i == -1 {
+			return fmt.Errorf("invalid -items.%s %q, indicate the header of the column and the value, e.g. %s", name, spec, example)
+		}
+		header, value := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		found := false
+		for j := range p.columns {
+			if p.columns[j].header != header {
+				continue
+			}
+			if err := set(&p.columns[j], value); err != nil {
+				return fmt.Errorf("invalid -items.%s %q: %w", name, spec, err)
+			}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("unknown column %q in -items.%s", header, name)
+		}
+	}
+	return nil
+}
+
+// parseItemSource parses "$ab" (subfields a and b of the item field)
+// or "245a" (subfield a of field 245 in the record), the field in the
+// record can have conditions on the indicators and an occurrence (e.g.
+// "650{ind2=0}a" or "856[last]u").
+func parseItemSource(value string) (itemSource, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "$") {
+		return itemSource{subfields: strings.ReplaceAll(value, "$", "")}, nil
+	}
+	filter, err := marc.NewFieldFilter(value)
+	if err != nil {
+		return itemSource{}, fmt.Errorf("invalid items setting %q: %w", value, err)
+	}
+	return itemSource{tag: filter.Tag, subfields: filter.Subfields, filter: filter}, nil
+}
+
+func parseItemColumn(value string) (itemColumn, error) {
+	header, source := value, value
+	if i := strings.Index(value, ":"); i != -1 {
+		header, source = value[:i], value[i+1:]
+	}
+	column := itemColumn{header: header}
+	if source == "bib" || source == "callnumber" {
+		column.source = source
+		return column, nil
+	}
+	var err error
+	column.value, err = parseItemSource(source)
+	return column, err
+}
+
+// Tags returns the tags of the fields used in the output, the rest
+// of the fields are not parsed.
+func (p *ProcessorItems) Tags() []string {
+	tags := []string{p.field}
+	sources := append([]itemSource{p.bibID}, p.callNumber...)
+	for _, column := range p.columns {
+		sources = append(sources, column.value)
+	}
+	for _, source := range sources {
+		if source.tag != "" {
+			tags = append(tags, source.tag)
+		}
+	}
+	return tags
+}
+
+func (p *ProcessorItems) Header(w io.Writer) error {
+	headers := []string{}
+	for _, column := range p.columns {
+		headers = append(headers, column.header)
+	}
+	header := strings.Join(headers, "\t") + "\r\n"
+	if p.noItemsW != nil {
+		if _, err := p.noItemsW.WriteString(header); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, header)
+	return err
+}
+
+func (p *ProcessorItems) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
+
+func (p *ProcessorItems) RenderRecord(r marc.Record) ([]byte, error) {
+	items := r.Fields.GetAll(p.field)
+	if len(items) == 0 {
+		if !p.noItems {
+			return nil, errRecordSkipped
+		}
+		return []byte(p.noItemsRow(r)), nil
+	}
+
+	bibID := p.value(r, marc.Field{}, p.bibID)
+	str := ""
+	for _, item := range items {
+		str += p.row(r, bibID, item)
+	}
+	return []byte(str), nil
+}
+
+// noItemsRow returns the line of a record without items, the values
+// from the item field are left empty.
+func (p *ProcessorItems) noItemsRow(r marc.Record) string {
+	return p.row(r, p.value(r, marc.Field{}, p.bibID), marc.Field{})
+}
+
+// row returns the line with the columns of an item.
+func (p *ProcessorItems) row(r marc.Record, bibID string, item marc.Field) string {
+	values := []string{}
+	for _, column := range p.columns {
+		var value string
+		switch column.source {
+		case "bib":
+			value = bibID
+		case "callnumber":
+			value = p.itemCallNumber(r, item)
+		default:
+			value = p.value(r, item, column.value)
+		}
+		value = column.normalization.Apply(value)
+		values = append(values, truncate(value, column.width))
+	}
+	return strings.Join(values, "\t") + "\r\n"
+}
+
+func (p *ProcessorItems) WriteRendered(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func (p *ProcessorItems) Footer(w io.Writer) error {
+	if p.noItemsFile == nil {
+		return nil
+	}
+	err := p.noItemsW.Flush()
+	if closeErr := p.noItemsFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		fmt.Fprintf(os.Stderr, "%d records without items written to %s\n", p.noItemsRows, p.noItemsFile.Name())
+	}
+	return err
+}
+
+// itemsNoItemsProcessor is the items format with -items.no-items-file.
+// Where the rows go depends on the record, so it is decided when the
+// record is processed and the records are not rendered concurrently
+// (see recordRenderer).
+type itemsNoItemsProcessor struct {
+	items *ProcessorItems
+}
+
+func (p *itemsNoItemsProcessor) Tags() []string {
+	return p.items.Tags()
+}
+
+func (p *itemsNoItemsProcessor) Header(w io.Writer) error {
+	return p.items.Header(w)
+}
+
+func (p *itemsNoItemsProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	if len(r.Fields.GetAll(p.items.field)) > 0 {
+		return processRendered(p.items, w, r)
+	}
+	p.items.noItemsRows++
+	if _, err := p.items.noItemsW.WriteString(p.items.noItemsRow(r)); err != nil {
+		return err
+	}
+	// Not counted as written to the output.
+	return errRecordSkipped
+}
+
+func (p *itemsNoItemsProcessor) Footer(w io.Writer) error {
+	return p.items.Footer(w)
+}
+
+// itemCallNumber returns the first call number found following the
+// order of precedence indicated.
+func (p *ProcessorItems) itemCallNumber(r marc.Record, item marc.Field) string {
+	for _, source := range p.callNumber {
+		if value := p.value(r, item, source); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// value returns the value for the source from the item field or from
+// the first field in the record that matches the source.
+func (p *ProcessorItems) value(r marc.Record, item marc.Field, source itemSource) string {
+	field := item
+	if source.tag != "" {
+		var ok bool
+		if field, ok = firstMatch(r, source.filter); !ok {
+			return ""
+		}
+	}
+
+	var values []string
+	if source.subfields == "" && !field.IsControlField() {
+		for _, sub := range field.SubFields {
+			values = append(values, sub.Value)
+		}
+	} else {
+		values = field.SubFieldValues(source.subfields)
+	}
+	return itemsCleanValue(strings.Join(values, " "))
+}
+
+// firstMatch returns the first field in the record selected by the
+// filter, e.g. the last 856 for "856[last]u".
+func firstMatch(r marc.Record, filter marc.FieldFilter) (marc.Field, bool) {
+	fields := filter.Select(r.Fields)
+	if len(fields) == 0 {
+		return marc.Field{}, false
+	}
+	return fields[0], true
+}
+
+// truncate returns the first width characters of the value, or the
+// whole value if width is 0.
+func truncate(value string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(value) <= width {
+		return value
+	}
+	return strings.TrimSpace(string([]rune(value)[:width]))
+}
+
+// itemsCleanValue removes the characters that would break the
+// tab delimited output.
+func itemsCleanValue(value string) string {
+	value = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(value)
+	return strings.TrimSpace(value)
+}
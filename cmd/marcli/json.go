@@ -1,65 +1,71 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/hectorcorrea/marcli/pkg/marc"
 )
 
+func init() {
+	registerFormat(processorFormat{
+		name:        "json",
+		description: "JSON array with the fields of each record",
+		contentType: "application/json",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			return NewProcessorJson(params)
+		},
+	})
+}
+
 // TODO: Add support for JSONL (JSON line delimited) format that makes JSON
 // easier to parse with Unix tools like grep, tail, and so on.
-func toJson(params ProcessFileParams) error {
+type ProcessorJson struct {
+	filters marc.FieldFilters
+	exclude marc.FieldFilters
+	plan    *marc.FilterPlan
+	out     int
+}
+
+func NewProcessorJson(params ProcessFileParams) (*ProcessorJson, error) {
 	if params.HasFilters() {
-		return errors.New("filters not supported for this format")
+		return nil, errors.New("filters not supported for this format")
 	}
+	return &ProcessorJson{filters: params.filters, exclude: params.exclude, plan: params.plan}, nil
+}
 
-	if count == 0 {
-		return nil
-	}
+func (p *ProcessorJson) Header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "[")
+	return err
+}
 
-	file, err := os.Open(params.filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+func (p *ProcessorJson) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
 
-	var i, out int
-	marc := marc.NewMarcFile(file)
+func (p *ProcessorJson) RenderRecord(r marc.Record) ([]byte, error) {
+	r.Fields = p.plan.Filter(r)
+	return marc.EncodeJSON(r)
+}
 
-	fmt.Printf("[")
-	for marc.Scan() {
-		r, err := marc.Record()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-		if i++; i < start {
-			continue
-		}
-		if r.Contains(params.searchValue, params.searchFields) && r.HasFields(params.hasFields) {
-			if out > 0 {
-				fmt.Printf(",\r\n")
-			} else {
-				fmt.Printf("\r\n")
-			}
-			b, err := json.Marshal(r.Filter(params.filters, params.exclude))
-			if err != nil {
-				fmt.Printf("%s\r\n", err)
-			}
-			// fmt.Printf("{ \"record\": %s}\r\n", b)
-			fmt.Printf("%s", b)
-			if out++; out == count {
-				break
-			}
-		}
-	}
-	fmt.Printf("\r\n]\r\n")
+func (p *ProcessorJson) WriteRendered(w io.Writer, b []byte) error {
+	return writeJsonElement(w, b, &p.out)
+}
 
-	return marc.Err()
+func (p *ProcessorJson) Footer(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "\r\n]\r\n")
+	return err
+}
+
+// writeJsonElement writes b as the next element of a JSON array,
+// out keeps track of how many elements have been written so far.
+func writeJsonElement(w io.Writer, b []byte, out *int) error {
+	separator := "\r\n"
+	if *out > 0 {
+		separator = ",\r\n"
+	}
+	*out++
+	_, err := fmt.Fprintf(w, "%s%s", separator, b)
+	return err
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// kafkaSink publishes the records to a Kafka topic through a Kafka REST
+// Proxy (the v2 API of the Confluent REST Proxy and compatible services,
+// e.g. Redpanda's HTTP Proxy) so that no Kafka client library is needed.
+// The keys and the values are sent as binary data, the values are the
+// records exactly as rendered in the output format. Batches that fail
+// because of the network or the proxy (HTTP 429 and 5xx) are sent again
+// up to kafkaMaxRetries times, waiting kafkaFirstBackoff the first time
+// and doubling it each time.
+type kafkaSink struct {
+	ctx      context.Context
+	endpoint string
+	topic    string
+	client   *http.Client
+	// backoff is the first wait between attempts, kafkaFirstBackoff.
+	backoff time.Duration
+}
+
+const (
+	kafkaMaxRetries   = 5
+	kafkaFirstBackoff = 500 * time.Millisecond
+)
+
+func newKafkaSink(ctx context.Context, proxyURL string, topic string) (*kafkaSink, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("-kafka-topic is required with -kafka-url")
+	}
+	if _, err := url.ParseRequestURI(proxyURL); err != nil {
+		return nil, fmt.Errorf("invalid -kafka-url: %w", err)
+	}
+	return &kafkaSink{
+		ctx:      ctx,
+		endpoint: strings.TrimRight(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		topic:    topic,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		backoff:  kafkaFirstBackoff,
+	}, nil
+}
+
+func (ks *kafkaSink) String() string {
+	return "Kafka topic " + ks.topic
+}
+
+type kafkaRecord struct {
+	Key   *string `json:"key"`
+	Value string  `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int     `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (ks *kafkaSink) send(batch []sinkMessage) error {
+	records := make([]kafkaRecord, len(batch))
+	for i, msg := range batch {
+		records[i].Value = base64.StdEncoding.EncodeToString(msg.value)
+		if msg.key != "" {
+			key := base64.StdEncoding.EncodeToString([]byte(msg.key))
+			records[i].Key = &key
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+
+	backoff := ks.backoff
+	for attempt := 0; ; attempt++ {
+		err := ks.produce(batch, body)
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt == kafkaMaxRetries {
+			return err
+		}
+		if ctxErr := ks.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s, retrying in %s\n", err, backoff)
+		if err := sleepContext(ks.ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// produce sends the records of the batch, already encoded in body, in
+// one request.
+func (ks *kafkaSink) produce(batch []sinkMessage, body []byte) error {
+	req, err := http.NewRequestWithContext(ks.ctx, http.MethodPost, ks.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.binary.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return retryableError{err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return retryableError{err}
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return retryableError{fmt.Errorf("error publishing to %s: %s", ks, resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("error publishing to %s: %s: %s", ks, resp.Status, bytes.TrimSpace(data))
+	}
+
+	// Each record is accepted or rejected on its own.
+	var produced kafkaProduceResponse
+	if err := json.Unmarshal(data, &produced); err != nil {
+		return fmt.Errorf("invalid response from the Kafka REST Proxy: %w", err)
+	}
+	for i, offset := range produced.Offsets {
+		if offset.Error != nil || offset.ErrorCode != nil {
+			msg := ""
+			if offset.Error != nil {
+				msg = *offset.Error
+			}
+			return fmt.Errorf("error publishing record %q to %s: %s", batch[i].key, ks, msg)
+		}
+	}
+	return nil
+}
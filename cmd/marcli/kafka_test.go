@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kafkaTestServer answers the produce requests with the responses in
+// order (the last one is repeated) and records the keys of the records
+// in each request.
+func kafkaTestServer(t *testing.T, responses ...func(w http.ResponseWriter)) (*httptest.Server, func() [][]string) {
+	var mu sync.Mutex
+	requests := [][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		keys := []string{}
+		for _, record := range body.Records {
+			key, _ := base64.StdEncoding.DecodeString(*record.Key)
+			keys = append(keys, string(key))
+		}
+		mu.Lock()
+		n := len(requests)
+		requests = append(requests, keys)
+		mu.Unlock()
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		responses[n](w)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	t.Parallel()
+
+	batch := []sinkMessage{
+		{key: "a", value: []byte("one")},
+		{key: "b", value: []byte("two")},
+	}
+	ok := esStatus(http.StatusOK, `{"offsets": [{"partition": 0, "offset": 1}, {"partition": 0, "offset": 2}]}`)
+	tests := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		wantErr   string
+		wantIDs   [][]string
+	}{
+		{
+			name:      "overloaded",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusTooManyRequests, ""), ok},
+			wantIDs:   [][]string{{"a", "b"}, {"a", "b"}},
+		},
+		{
+			name:      "server error",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusServiceUnavailable, "")},
+			wantErr:   "503 Service Unavailable",
+		},
+		{
+			name:      "rejected record",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusOK, `{"offsets": [{"partition": 0, "offset": 1}, {"error_code": 50002, "error": "record too large"}]}`)},
+			wantErr:   `error publishing record "b" to Kafka topic records: record too large`,
+			wantIDs:   [][]string{{"a", "b"}},
+		},
+		{
+			name:      "unknown topic",
+			responses: []func(w http.ResponseWriter){esStatus(http.StatusNotFound, `{"error_code": 40401, "message": "Topic not found"}`)},
+			wantErr:   "Topic not found",
+			wantIDs:   [][]string{{"a", "b"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server, requests := kafkaTestServer(t, tt.responses...)
+			ks, err := newKafkaSink(context.Background(), server.URL, "records")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ks.backoff = time.Millisecond
+
+			err = ks.send(batch)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error with %q, got %v", tt.wantErr, err)
+			}
+			got := requests()
+			if tt.wantIDs == nil {
+				// Retried until giving up.
+				if len(got) != kafkaMaxRetries+1 {
+					t.Errorf("expected %d requests, got %d", kafkaMaxRetries+1, len(got))
+				}
+				return
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("expected the requests %v, got %v", tt.wantIDs, got)
+			}
+			for i := range got {
+				if strings.Join(got[i], ",") != strings.Join(tt.wantIDs[i], ",") {
+					t.Errorf("expected the records %v in request %d, got %v", tt.wantIDs[i], i, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKafkaSinkCancel(t *testing.T) {
+	t.Parallel()
+
+	server, requests := kafkaTestServer(t, esStatus(http.StatusServiceUnavailable, ""))
+	ctx, cancel := context.WithCancel(context.Background())
+	ks, err := newKafkaSink(ctx, server.URL, "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.backoff = time.Hour
+
+	go func() {
+		for len(requests()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	err = ks.send([]sinkMessage{{key: "a", value: []byte("one")}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the send to be cancelled while waiting to retry, got %v", err)
+	}
+}
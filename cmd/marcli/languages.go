@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "languages",
+		description: "Report the languages in the 008/35-37 and the 041 $a with their names, and the records where they do not agree",
+		flags:       []string{"top", "csv", "json", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runLanguages,
+	})
+}
+
+func runLanguages(ctx context.Context) error {
+	if reportCSV && reportJSON {
+		return errors.New("use either -csv or -json")
+	}
+	processor := &languagesProcessor{
+		fixed:    map[string]int{},
+		coded:    map[string]int{},
+		problems: map[string]int{},
+		top:      overviewTop,
+	}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// languageConflict is a record where the language in the 008 is not one
+// of the languages in the 041 $a.
+type languageConflict struct {
+	Record string   `json:"record"`
+	Fixed  string   `json:"language_008"`
+	Coded  []string `json:"language_041"`
+}
+
+// languagesProcessor counts the records by the language codes in the
+// 008 and in the 041 $a. The 041 with a source other than the MARC
+// codes (second indicator 7) are ignored.
+type languagesProcessor struct {
+	records  int
+	fixed    map[string]int
+	coded    map[string]int
+	problems map[string]int
+	// conflicts are the first top records where the 008 and the 041 do
+	// not agree.
+	conflicts     []languageConflict
+	conflictCount int
+	top           int
+}
+
+func (p *languagesProcessor) Tags() []string {
+	return []string{"001", "008", "041"}
+}
+
+func (p *languagesProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *languagesProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.records++
+	fixed := ""
+	if value := r.GetValue("008", ""); len(value) >= 38 {
+		fixed = value[35:38]
+		if strings.Trim(fixed, " |") == "" {
+			p.fixed["(blank)"]++
+			fixed = ""
+		} else {
+			p.fixed[fixed]++
+			p.check(fixed, "008")
+		}
+	} else {
+		p.fixed["(no 008)"]++
+	}
+
+	coded := languageCodes(r)
+	for _, code := range coded {
+		p.coded[code]++
+		p.check(code, "041")
+	}
+
+	if fixed == "" || len(coded) == 0 {
+		return nil
+	}
+	for _, code := range coded {
+		if code == fixed {
+			return nil
+		}
+	}
+	p.problems["008 not in the 041 $a"]++
+	p.conflictCount++
+	if p.top == 0 || len(p.conflicts) < p.top {
+		id := r.ControlNum()
+		if id == "" {
+			id = fmt.Sprintf("record at byte %d", r.Pos)
+		}
+		p.conflicts = append(p.conflicts, languageConflict{Record: id, Fixed: fixed, Coded: coded})
+	}
+	return nil
+}
+
+// check counts the codes that are not valid or are obsolete.
+func (p *languagesProcessor) check(code string, tag string) {
+	if _, ok := marc.LanguageName(code); !ok {
+		p.problems["Invalid code in the "+tag]++
+	} else if marc.ObsoleteLanguage(code) {
+		p.problems["Obsolete code in the "+tag]++
+	}
+}
+
+// languageCodes returns the distinct codes in the 041 $a of the record.
+// Older records have several codes in the same subfield (e.g.
+// "engfre"), they are split.
+func languageCodes(r marc.Record) []string {
+	codes := []string{}
+	seen := map[string]bool{}
+	for _, field := range r.Fields.GetAll("041") {
+		if field.Indicator2 == "7" {
+			continue
+		}
+		for _, value := range field.SubFieldValues("a") {
+			value = strings.ToLower(strings.TrimSpace(value))
+			for i := 0; i+3 <= len(value); i += 3 {
+				code := value[i : i+3]
+				if !seen[code] {
+					seen[code] = true
+					codes = append(codes, code)
+				}
+			}
+		}
+	}
+	return codes
+}
+
+// languageLabel returns the code with the name of the language.
+func languageLabel(code string) string {
+	if strings.HasPrefix(code, "(") {
+		return code
+	}
+	name, ok := marc.LanguageName(code)
+	switch {
+	case !ok:
+		return code + " - (invalid code)"
+	case marc.ObsoleteLanguage(code):
+		return code + " - " + name + " (obsolete code)"
+	}
+	return code + " - " + name
+}
+
+// labeled returns the counts with the names of the languages.
+func labeled(counts map[string]int) map[string]int {
+	labels := map[string]int{}
+	for code, count := range counts {
+		labels[languageLabel(code)] = count
+	}
+	return labels
+}
+
+// languagesReport is the JSON output.
+type languagesReport struct {
+	Records   int                `json:"records"`
+	Fixed     []overviewCount    `json:"languages_008"`
+	Coded     []overviewCount    `json:"languages_041"`
+	Problems  []overviewCount    `json:"problems"`
+	Conflicts []languageConflict `json:"conflicts"`
+}
+
+func (p *languagesProcessor) Footer(w io.Writer) error {
+	switch {
+	case reportCSV:
+		return p.writeCSV(w)
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(languagesReport{
+			Records:   p.records,
+			Fixed:     topCounts("", labeled(p.fixed), p.records, 0).Counts,
+			Coded:     topCounts("", labeled(p.coded), p.records, 0).Counts,
+			Problems:  sortedCounts(p.problems, p.records),
+			Conflicts: append([]languageConflict{}, p.conflicts...),
+		})
+	}
+
+	str := fmt.Sprintf("Records: %d\n", p.records)
+	for _, section := range []overviewSection{
+		topCounts("Languages (008/35-37)", labeled(p.fixed), p.records, p.top),
+		topCounts("Languages (041 $a)", labeled(p.coded), p.records, p.top),
+		sortedSection("Problems", p.problems, p.records),
+	} {
+		if len(section.Counts) > 0 {
+			str += "\n" + section.String()
+		}
+	}
+	if len(p.conflicts) > 0 {
+		str += "\nRecords with a language in the 008 that is not in the 041 $a\n"
+		for _, conflict := range p.conflicts {
+			str += fmt.Sprintf("  %s: 008 %s, 041 %s\n", conflict.Record, conflict.Fixed, strings.Join(conflict.Coded, " "))
+		}
+		if more := p.conflictCount - len(p.conflicts); more > 0 {
+			str += fmt.Sprintf("  (%d more records)\n", more)
+		}
+	}
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// writeCSV outputs one row per language code with the number of records
+// with it in the 008 and in the 041.
+func (p *languagesProcessor) writeCSV(w io.Writer) error {
+	seen := map[string]bool{}
+	codes := []string{}
+	for _, counts := range []map[string]int{p.fixed, p.coded} {
+		for code := range counts {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"code", "name", "records_008", "records_041"})
+	for _, code := range codes {
+		name, _ := marc.LanguageName(code)
+		cw.Write([]string{code, name, strconv.Itoa(p.fixed[code]), strconv.Itoa(p.coded[code])})
+	}
+	cw.Flush()
+	return cw.Error()
+}
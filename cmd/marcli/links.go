@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "links",
+		description: "Report the linking entries (760-787) whose related record ($w) is or is not in the file, and the links without the reciprocal link",
+		flags:       []string{"top", "csv", "json", "bom", "crlf", "codepage", "start", "count", "skip-errors", "error-file"},
+		run:         runLinks,
+	})
+}
+
+func runLinks(ctx context.Context) error {
+	if reportCSV && reportJSON {
+		return errors.New("use either -csv or -json")
+	}
+	processor := &linksProcessor{ids: map[string]string{}, top: overviewTop}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// linkingTags are the linking entry fields.
+var linkingTags = []string{"760", "762", "765", "767", "770", "772", "773", "774", "775", "776", "777", "780", "785", "786", "787"}
+
+// reciprocalLinks are the tags of the link expected in the related
+// record, e.g. the succeeding title (785) of the preceding title (780).
+var reciprocalLinks = map[string]string{
+	"760": "762", "762": "760",
+	"765": "767", "767": "765",
+	"770": "772", "772": "770",
+	"773": "774", "774": "773",
+	"775": "775",
+	"776": "776",
+	"777": "777",
+	"780": "785", "785": "780",
+}
+
+// link is a linking entry, Status is "found" if the related record is in
+// the file, "missing" if it is not, or "no $w" if it does not have the
+// control number of the related record.
+type link struct {
+	Record  string `json:"record"`
+	Tag     string `json:"tag"`
+	Control string `json:"control_number,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Status  string `json:"status"`
+	// Related is the id of the related record when it is found.
+	Related string `json:"related,omitempty"`
+	// Reciprocal is false when the related record does not link back
+	// with the reciprocal tag (see reciprocalLinks).
+	Reciprocal *bool `json:"reciprocal,omitempty"`
+	keys       []string
+}
+
+// linksProcessor keeps the ids of all the records and their links to
+// resolve them at the end, since a link can be to a record further in
+// the file.
+type linksProcessor struct {
+	records int
+	// ids are the control numbers (001 and 035 $a) of the records, see
+	// linkKey, with the id of the record.
+	ids   map[string]string
+	links []link
+	top   int
+}
+
+func (p *linksProcessor) Tags() []string {
+	return append([]string{"001", "035"}, linkingTags...)
+}
+
+func (p *linksProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *linksProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.records++
+	id := r.ControlNum()
+	if id == "" {
+		id = fmt.Sprintf("record at byte %d", r.Pos)
+	} else {
+		p.ids[linkKey(id)] = id
+	}
+	for _, value := range r.Fields.GetValues("035", "a") {
+		if key := linkKey(value); key != "" {
+			p.ids[key] = id
+		}
+	}
+
+	for _, field := range r.Fields {
+		if !isLinkingTag(field.Tag) {
+			continue
+		}
+		l := link{Record: id, Tag: field.Tag, Title: strings.TrimSpace(field.SubFieldValue("t")), Status: "no $w"}
+		controls := []string{}
+		for _, value := range field.SubFieldValues("w") {
+			if key := linkKey(value); key != "" {
+				controls = append(controls, strings.TrimSpace(value))
+				l.keys = append(l.keys, key)
+			}
+		}
+		l.Control = strings.Join(controls, " ")
+		p.links = append(p.links, l)
+	}
+	return nil
+}
+
+func isLinkingTag(tag string) bool {
+	for _, t := range linkingTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+var linkPrefix = regexp.MustCompile(`^\([^)]*\)\s*`)
+var oclcPrefix = regexp.MustCompile(`^(ocm|ocn|on)`)
+
+// linkKey returns the control number without the organization code
+// (e.g. "(OCoLC)"), the prefixes of the OCLC numbers (ocm, ocn, on), the
+// leading zeros, the spaces, and the final period, so that
+// "(OCoLC)ocm05432109" and "5432109" are the same.
+func linkKey(value string) string {
+	value = strings.ToLower(strings.TrimRight(strings.TrimSpace(value), " ."))
+	value = linkPrefix.ReplaceAllString(value, "")
+	value = oclcPrefix.ReplaceAllString(value, "")
+	value = strings.ReplaceAll(value, " ", "")
+	return strings.TrimLeft(value, "0")
+}
+
+// resolve sets the status of the links and checks if they are
+// reciprocal.
+func (p *linksProcessor) resolve() {
+	// The tags of the links from each record to each related record.
+	outgoing := map[string]map[string]bool{}
+	for i := range p.links {
+		l := &p.links[i]
+		if len(l.keys) == 0 {
+			continue
+		}
+		l.Status = "missing"
+		for _, key := range l.keys {
+			if related, ok := p.ids[key]; ok {
+				l.Status, l.Related = "found", related
+				if outgoing[l.Record] == nil {
+					outgoing[l.Record] = map[string]bool{}
+				}
+				outgoing[l.Record][l.Tag+" "+related] = true
+				break
+			}
+		}
+	}
+	for i := range p.links {
+		l := &p.links[i]
+		tag, ok := reciprocalLinks[l.Tag]
+		if l.Status != "found" || !ok {
+			continue
+		}
+		reciprocal := outgoing[l.Related][tag+" "+l.Record]
+		l.Reciprocal = &reciprocal
+	}
+}
+
+// linksReport is the JSON output.
+type linksReport struct {
+	Records int             `json:"records"`
+	Tags    []linkTagCounts `json:"tags"`
+	Links   []link          `json:"links"`
+}
+
+// linkTagCounts are the number of links of a tag by status.
+type linkTagCounts struct {
+	Tag           string `json:"tag"`
+	Label         string `json:"label"`
+	Links         int    `json:"links"`
+	Found         int    `json:"found"`
+	Missing       int    `json:"missing"`
+	NoControl     int    `json:"no_control_number"`
+	NotReciprocal int    `json:"not_reciprocal"`
+}
+
+func (p *linksProcessor) tagCounts() []linkTagCounts {
+	byTag := map[string]*linkTagCounts{}
+	for _, l := range p.links {
+		c, ok := byTag[l.Tag]
+		if !ok {
+			c = &linkTagCounts{Tag: l.Tag, Label: marc.TagLabel(l.Tag)}
+			byTag[l.Tag] = c
+		}
+		c.Links++
+		switch l.Status {
+		case "found":
+			c.Found++
+		case "missing":
+			c.Missing++
+		default:
+			c.NoControl++
+		}
+		if l.Reciprocal != nil && !*l.Reciprocal {
+			c.NotReciprocal++
+		}
+	}
+	counts := []linkTagCounts{}
+	for _, tag := range linkingTags {
+		if c, ok := byTag[tag]; ok {
+			counts = append(counts, *c)
+		}
+	}
+	return counts
+}
+
+func (p *linksProcessor) Footer(w io.Writer) error {
+	p.resolve()
+	switch {
+	case reportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"record", "tag", "control_number", "title", "status", "related", "reciprocal"})
+		for _, l := range p.links {
+			reciprocal := ""
+			if l.Reciprocal != nil {
+				reciprocal = fmt.Sprint(*l.Reciprocal)
+			}
+			cw.Write([]string{l.Record, l.Tag, l.Control, l.Title, l.Status, l.Related, reciprocal})
+		}
+		cw.Flush()
+		return cw.Error()
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(linksReport{Records: p.records, Tags: p.tagCounts(), Links: append([]link{}, p.links...)})
+	}
+
+	str := fmt.Sprintf("Records: %d\nLinking entries: %d\n", p.records, len(p.links))
+	counts := p.tagCounts()
+	if len(counts) > 0 {
+		str += fmt.Sprintf("\n  %-40s %7s %7s %7s %7s %14s\n", "", "links", "found", "missing", "no $w", "not reciprocal")
+		for _, c := range counts {
+			str += fmt.Sprintf("  %-40s %7d %7d %7d %7d %14d\n", c.Tag+" "+c.Label, c.Links, c.Found, c.Missing, c.NoControl, c.NotReciprocal)
+		}
+	}
+	missing, notReciprocal := []string{}, []string{}
+	for _, l := range p.links {
+		switch {
+		case l.Status == "missing":
+			missing = append(missing, fmt.Sprintf("%s: %s %s %s", l.Record, l.Tag, l.Control, l.Title))
+		case l.Reciprocal != nil && !*l.Reciprocal:
+			notReciprocal = append(notReciprocal, fmt.Sprintf("%s: %s to %s, no %s back", l.Record, l.Tag, l.Related, reciprocalLinks[l.Tag]))
+		}
+	}
+	str += linksList("Related records not in the file", missing, p.top)
+	str += linksList("Links without the reciprocal link", notReciprocal, p.top)
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// linksList returns the first top lines of a list, with its title.
+func linksList(title string, lines []string, top int) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	str := "\n" + title + "\n"
+	for i, line := range lines {
+		if top > 0 && i == top {
+			str += fmt.Sprintf("  (%d more)\n", len(lines)-top)
+			break
+		}
+		str += "  " + strings.TrimSpace(line) + "\n"
+	}
+	return str
+}
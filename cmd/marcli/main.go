@@ -1,78 +1,328 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/hectorcorrea/marcli/pkg/marc"
 )
 
 var fileName, search, searchFields, fields, exclude, format, hasFields string
-var start, count int
-var debug bool
-
-func init() {
-	flag.StringVar(&fileName, "file", "", "MARC file to process. Required.")
-	flag.StringVar(&search, "match", "", "String that must be present in the content of the record, case insensitive.")
-	flag.StringVar(&searchFields, "matchFields", "", "Comma delimited list of fields to search, used when match parameter is indicated, defaults to all fields.")
-	flag.StringVar(&fields, "fields", "", "Comma delimited list of fields to output.")
-	flag.StringVar(&exclude, "exclude", "", "Comma delimited list of fields to exclude from the output.")
-	flag.StringVar(&format, "format", "mrk", "Output format. Accepted values: mrk, mrc, xml, json, or solr.")
-	flag.IntVar(&start, "start", 1, "Number of first record to load")
-	flag.IntVar(&count, "count", -1, "Total number of records to load (-1 no limit)")
-	flag.StringVar(&hasFields, "hasFields", "", "Comma delimited list of fields that must be present in the record.")
-	flag.BoolVar(&debug, "debug", false, "When true it does not stop on errors")
-	flag.Parse()
+var configFile, preset, profile, deleteFields, reportFile, debugAddr string
+var indexFile, atRecords, atIDs, checkpointFile, controlTags, errorFile, invalidUTF8, normalize string
+var kafkaURL, kafkaTopic, kafkaKey, pgURL, pgTable, pgColumns, esURL, esIndex, esID, solrURL, solrCommit string
+var dbFile, dbSQL, webhookURL, oclcKey, oclcSecret, idlocCache, crossrefMailto, coversSource, googleKey, watchDir, pipelineFile, overviewLocation, highlight, concatFiles, outFile, generateType, blankFields, hashFields, hashKey, statsCompare, valuesSpec, valuesSort, barcodeSpec, barcodeBibID, priceSpec, priceGroup, priceCurrency, excelCodepage string
+var addFields, where stringList
+var start, count, workers, checkpointEvery, maxRecordSize, bufferSize, port, batchSize, webhookConcurrency, webhookRetries, overviewTop, sliceN, sliceFrom, sliceTo int
+var resumeFrom, randomSeed, maxRequestSize int64
+var statsMinChange float64
+var timeout, watchInterval time.Duration
+var debug, skipErrors, dryRun, showMetrics, showVersion, useMmap, strict, lenient, repair, keepEmptySubfields, dropEmptyFields, validateSchema, warnDuplicates, masterRecords, offline, crossref, missingCovers, watchOnce, overviewHTML, showPositions, matchedOnly, rawOutput, concatDedupe, ignoreDiacritics, reportCSV, reportJSON, datesSpan, priceDetails, excelBOM, excelCRLF bool
+
+// version is set when building a release, e.g.
+// go build -ldflags "-X main.version=v1.2.0"
+var version = "dev"
+
+// report is the summary of the run, nil unless requested with -report.
+var report *runReport
+
+// errorRecords is where the records with errors are written, nil
+// unless requested with -error-file.
+var errorRecords *errorLog
+
+// localSchema are the fields added to the MARC 21 schema in the
+// configuration file, see validate -schema.
+var localSchema map[string]string
+
+// duplicates keeps the control numbers seen, nil unless requested
+// with -warn-duplicates.
+var duplicates *duplicateTracker
+
+// metrics are the throughput metrics of the run, nil unless requested
+// with -metrics or -debug-addr.
+var metrics *runMetrics
+
+// commandArgs are the positional arguments of the commands that take
+// them (e.g. the files of concat).
+var commandArgs []string
+
+// acceptedFlags are the names of the flags accepted by each command,
+// see watch -pipeline.
+var acceptedFlags map[string]map[string]bool
+
+// parseFlags selects the command to run and parses its parameters,
+// the values in the configuration file are used for the parameters
+// not given in the command line.
+func parseFlags(args []string) (command, *flag.FlagSet) {
+	cmd, args, err := selectCommand(args)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	acceptedFlags = commandFlags()
+	known := knownFlags(acceptedFlags)
+	fs := cmd.flagSet()
+	fs.Usage = func() { showSyntax(cmd, fs) }
+	if cmd.args {
+		commandArgs = parseInterspersed(fs, args)
+	} else {
+		fs.Parse(args)
+	}
+
+	path := configFile
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	cfg, err := loadConfig(path, configFile != "")
+	if err == nil {
+		localSchema = cfg.Schema
+		err = cfg.apply(fs, preset, known)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+	return cmd, fs
+}
+
+// parseInterspersed parses the flags in args, which can be before,
+// between, or after the positional arguments (unlike fs.Parse, which
+// stops at the first one), and returns the positional arguments. The
+// arguments after "--" are all positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
 }
 
 func main() {
-	if fileName == "" {
-		showSyntax()
+	cmd, fs := parseFlags(os.Args[1:])
+	if showVersion {
+		fmt.Printf("marcli %s\n", version)
+		return
+	}
+	if fileName == "" && !cmd.noFile {
+		showSyntax(cmd, fs)
 		return
 	}
+	if strict && (lenient || repair || keepEmptySubfields || dropEmptyFields) {
+		exitWithError(errors.New("-strict cannot be used together with -lenient, -repair, -keep-empty-subfields, or -drop-empty-fields"))
+	}
+	if invalidUTF8 != "" {
+		if _, err := marc.ParseUTF8Policy(invalidUTF8); err != nil {
+			exitWithError(err)
+		}
+	}
+	if _, err := marc.ParseNormalization(normalize); err != nil {
+		exitWithError(err)
+	}
+	if _, err := parseExcelOptions(excelBOM, excelCRLF, excelCodepage); err != nil {
+		exitWithError(err)
+	}
+	if _, err := parsePositionFilters(where); err != nil {
+		exitWithError(fmt.Errorf("invalid -where: %w", err))
+	}
+	for name, value := range map[string]string{"fields": fields, "exclude": exclude, "hasFields": hasFields, "delete": deleteFields, "blank": blankFields, "hash": hashFields} {
+		if _, err := marc.ParseFieldFilters(value); err != nil {
+			exitWithError(fmt.Errorf("invalid -%s: %w", name, err))
+		}
+	}
+	if controlTags != "" {
+		marc.SetControlTags(strings.Split(controlTags, ","))
+	}
 
-	params := ProcessFileParams{
-		filename:     fileName,
-		searchValue:  strings.ToLower(search),
-		searchFields: searchFieldsFromString(searchFields),
-		filters:      marc.NewFieldFilters(fields),
-		exclude:      marc.NewFieldFilters(exclude),
-		start:        start,
-		count:        count,
-		hasFields:    marc.NewFieldFilters(hasFields),
-		debug:        debug,
-	}
-
-	if len(params.filters.Fields) > 0 && len(params.exclude.Fields) > 0 {
-		panic("Cannot specify fields and exclude at the same time.")
-	}
-
-	var err error
-	if format == "mrc" {
-		err = toMrc(params)
-	} else if format == "mrk" {
-		err = toMrk(params)
-	} else if format == "json" {
-		err = toJson(params)
-	} else if format == "solr" {
-		err = toSolr(params)
-	} else if format == "xml" {
-		err = toXML(params)
-	} else {
-		err = errors.New("Invalid format")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if reportFile != "" {
+		report = newRunReport()
+	}
+	if showMetrics || debugAddr != "" {
+		metrics = newRunMetrics()
+	}
+	if debugAddr != "" {
+		if err := serveDebug(debugAddr, metrics); err != nil {
+			exitWithError(err)
+		}
+	}
+	if errorFile != "" {
+		var err error
+		if errorRecords, err = openErrorLog(errorFile); err != nil {
+			exitWithError(err)
+		}
+	}
+	if warnDuplicates {
+		duplicates = newDuplicateTracker()
+	}
+	err := cmd.run(ctx)
+	duplicates.summary()
+	if closeErr := errorRecords.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if report != nil {
+		if reportErr := report.write(reportFile, err); reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	if showMetrics {
+		metrics.print(os.Stderr)
 	}
 	if err != nil {
-		panic(err)
+		exitWithError(err)
+	}
+}
+
+// parsePositionFilters parses the conditions given in -where.
+func parsePositionFilters(specs []string) ([]marc.PositionFilter, error) {
+	filters := []marc.PositionFilter{}
+	for _, spec := range specs {
+		filter, err := marc.ParsePositionFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// positionFilters returns the conditions in -where, already validated
+// in main.
+func positionFilters() []marc.PositionFilter {
+	filters, _ := parsePositionFilters(where)
+	return filters
+}
+
+// searchNormalization returns the normalization of the search given in
+// -normalize (already validated in main) or -ignore-diacritics.
+func searchNormalization() marc.Normalization {
+	n, _ := marc.ParseNormalization(normalize)
+	if n == marc.NormalizeNone && ignoreDiacritics {
+		return marc.NormalizeStrip
+	}
+	return n
+}
+
+// fileParams returns the parameters to process the file
+// from the values given in the command line.
+func fileParams() ProcessFileParams {
+	params := ProcessFileParams{
+		filename:        fileName,
+		searchValue:     strings.ToLower(search),
+		searchFields:    searchFieldsFromString(searchFields),
+		filters:         marc.NewFieldFilters(fields),
+		exclude:         marc.NewFieldFilters(exclude),
+		start:           start,
+		count:           count,
+		hasFields:       marc.NewFieldFilters(hasFields),
+		where:           positionFilters(),
+		debug:           debug,
+		skipErrors:      skipErrors,
+		workers:         workers,
+		mmap:            useMmap,
+		resumeFrom:      resumeFrom,
+		maxRecordSize:   maxRecordSize,
+		parseMode:       parseMode(),
+		bufferSize:      bufferSize,
+		checkpoint:      checkpointFile,
+		checkpointEvery: checkpointEvery,
+		positions:       showPositions,
+		matchedOnly:     matchedOnly && search != "",
+		raw:             rawOutput,
+		normalization:   searchNormalization(),
+		report:          report,
+		errorLog:        errorRecords,
+		duplicates:      duplicates,
+		metrics:         metrics,
+	}
+	// Already validated in main.
+	params.excel, _ = parseExcelOptions(excelBOM, excelCRLF, excelCodepage)
+	if invalidUTF8 != "" {
+		// Already validated in main.
+		params.fixUTF8 = true
+		params.utf8Policy, _ = marc.ParseUTF8Policy(invalidUTF8)
+	}
+	if params.matchedOnly {
+		// Only the 001 and the fields searched are output, so there is
+		// no need to output the leader or to parse the rest of fields.
+		params.filters = marc.NewFieldFilters("001")
+	}
+	params.plan = params.filterPlan()
+	return params
+}
+
+// parseMode returns the mode to parse the records indicated in the
+// command line.
+func parseMode() marc.ParseMode {
+	mode := marc.ParseDefault
+	if strict {
+		mode |= marc.ParseStrict
+	}
+	if lenient {
+		mode |= marc.ParseLenient
+	}
+	if repair {
+		mode |= marc.ParseRepair
+	}
+	if keepEmptySubfields {
+		mode |= marc.ParseKeepEmptySubfields
+	}
+	if dropEmptyFields {
+		mode |= marc.ParseDropEmptyFields
 	}
+	return mode
 }
 
-func showSyntax() {
-	fmt.Printf("marcli parameters:\r\n")
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "marcli: %s\n", err)
+	os.Exit(1)
+}
+
+func showSyntax(cmd command, fs *flag.FlagSet) {
+	fmt.Printf("marcli [command] parameters\r\n")
+	fmt.Printf("\r\n")
+	fmt.Printf("COMMANDS:\n")
+	for _, name := range commandNames() {
+		fmt.Printf("\t%-9s %s\n", name, commands[name].description)
+	}
+	fmt.Printf("\r\n")
+	fmt.Printf("When no command is given marcli runs the %s command.\r\n", defaultCommand)
 	fmt.Printf("\r\n")
-	flag.PrintDefaults()
+	fmt.Printf("marcli %s parameters:\r\n", cmd.name)
 	fmt.Printf("\r\n")
+	// The flags of each format are listed under FORMATS.
+	general := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	general.SetOutput(os.Stdout)
+	fs.VisitAll(func(f *flag.Flag) {
+		if !strings.Contains(f.Name, ".") {
+			general.Var(f.Value, f.Name, f.Usage)
+			general.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	general.PrintDefaults()
+	fmt.Printf("\r\n")
+	if cmd.formatFlags {
+		fmt.Printf("FORMATS:\n%s", formatsHelp())
+	}
 	fmt.Printf(`
 NOTES:
 	The match parameter is used to filter records based on their content.
@@ -84,6 +334,14 @@ are not supported in matchFields, i.e. 245 is OK, 245a is not)
 of certain fields on the record (regardless of their value).
 
 	You can only use the fields or exclude parameter, but not both.
+
+	Default values for the parameters, settings for each format, and named
+presets can be defined in a YAML configuration file (see the README).
+Parameters in the command line take precedence over the configuration file.
+
+	By default marcli stops on the first record that cannot be parsed. Use
+skip-errors to log those records (position and raw bytes) to stderr and
+continue with the next one.
 `)
 	fmt.Printf("\r\n")
 	fmt.Printf("\r\n")
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // registers the /debug/pprof handlers
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// runMetrics keeps track of the throughput of a run and of the time
+// spent in each stage of the processing: reading and parsing the
+// records, matching (and rendering when using -workers) them, and
+// writing them to the output, as well as of the memory allocated. All
+// the methods are safe to call on a
+// nil value so that the metrics are only collected when requested.
+type runMetrics struct {
+	bytes   int64
+	records int64
+	read    int64 // nanoseconds
+	match   int64 // nanoseconds
+	write   int64 // nanoseconds
+	started time.Time
+	memory  runtime.MemStats // at the start of the run
+}
+
+func newRunMetrics() *runMetrics {
+	m := &runMetrics{started: time.Now()}
+	runtime.ReadMemStats(&m.memory)
+	return m
+}
+
+func (m *runMetrics) addBytes(n int) {
+	if m != nil {
+		atomic.AddInt64(&m.bytes, int64(n))
+	}
+}
+
+// since returns the current time if metrics are being collected. The
+// value is passed to the add* methods to accumulate the time spent.
+func (m *runMetrics) since() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func (m *runMetrics) addRead(start time.Time) {
+	if m != nil {
+		atomic.AddInt64(&m.records, 1)
+		atomic.AddInt64(&m.read, int64(time.Since(start)))
+	}
+}
+
+func (m *runMetrics) addMatch(start time.Time) {
+	if m != nil {
+		atomic.AddInt64(&m.match, int64(time.Since(start)))
+	}
+}
+
+func (m *runMetrics) addWrite(start time.Time) {
+	if m != nil {
+		atomic.AddInt64(&m.write, int64(time.Since(start)))
+	}
+}
+
+// snapshot returns the current values, it is published via expvar
+// under the name "marcli".
+func (m *runMetrics) snapshot() map[string]interface{} {
+	elapsed := time.Since(m.started).Seconds()
+	records := atomic.LoadInt64(&m.records)
+	bytes := atomic.LoadInt64(&m.bytes)
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	allocs := memory.Mallocs - m.memory.Mallocs
+	perRecord := 0.0
+	if records > 0 {
+		perRecord = float64(allocs) / float64(records)
+	}
+	return map[string]interface{}{
+		"elapsed_seconds":  elapsed,
+		"records":          records,
+		"bytes":            bytes,
+		"records_per_sec":  float64(records) / elapsed,
+		"mb_per_sec":       float64(bytes) / 1024 / 1024 / elapsed,
+		"read_seconds":     time.Duration(atomic.LoadInt64(&m.read)).Seconds(),
+		"match_seconds":    time.Duration(atomic.LoadInt64(&m.match)).Seconds(),
+		"write_seconds":    time.Duration(atomic.LoadInt64(&m.write)).Seconds(),
+		"alloc_bytes":      memory.TotalAlloc - m.memory.TotalAlloc,
+		"allocs":           allocs,
+		"allocs_per_rec":   perRecord,
+		"gc_cycles":        memory.NumGC - m.memory.NumGC,
+		"gc_pause_seconds": time.Duration(memory.PauseTotalNs - m.memory.PauseTotalNs).Seconds(),
+	}
+}
+
+// print outputs a summary of the metrics.
+func (m *runMetrics) print(w io.Writer) {
+	s := m.snapshot()
+	fmt.Fprintf(w, "Records:     %d in %.2fs (%.0f records/sec)\n", s["records"], s["elapsed_seconds"], s["records_per_sec"])
+	fmt.Fprintf(w, "Bytes:       %d (%.2f MB/sec)\n", s["bytes"], s["mb_per_sec"])
+	fmt.Fprintf(w, "Read/parse:  %.3fs\n", s["read_seconds"])
+	fmt.Fprintf(w, "Match:       %.3fs\n", s["match_seconds"])
+	fmt.Fprintf(w, "Write:       %.3fs\n", s["write_seconds"])
+	fmt.Fprintf(w, "Allocated:   %d bytes in %d allocations (%.1f per record)\n", s["alloc_bytes"], s["allocs"], s["allocs_per_rec"])
+	fmt.Fprintf(w, "GC:          %d cycles, %.3fs paused\n", s["gc_cycles"], s["gc_pause_seconds"])
+}
+
+// serveDebug starts an HTTP server with the expvar (/debug/vars) and
+// pprof (/debug/pprof/) endpoints. The metrics of the run are available
+// in /debug/vars while it is running.
+func serveDebug(addr string, m *runMetrics) error {
+	expvar.Publish("marcli", expvar.Func(func() interface{} { return m.snapshot() }))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go http.Serve(listener, nil)
+	return nil
+}
+
+// countingReader counts the bytes read from the input.
+type countingReader struct {
+	io.ReadCloser
+	metrics *runMetrics
+}
+
+func (cr countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.metrics.addBytes(n)
+	return n, err
+}
@@ -1,50 +1,67 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/hectorcorrea/marcli/pkg/marc"
 )
 
-func toMrc(params ProcessFileParams) error {
-	if params.HasFilters() {
-		return errors.New("filters not supported for this format")
-	}
+func init() {
+	registerFormat(processorFormat{
+		name:        "mrc",
+		description: "MARC binary",
+		contentType: "application/marc",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			return NewProcessorMrc(params), nil
+		},
+	})
+}
 
-	if count == 0 {
-		return nil
-	}
+type ProcessorMrc struct {
+	filters marc.FieldFilters
+	exclude marc.FieldFilters
+	plan    *marc.FilterPlan
+	raw     bool
+}
 
-	file, err := os.Open(params.filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	var i, out int
-	marc := marc.NewMarcFile(file)
-	for marc.Scan() {
-		r, err := marc.Record()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
+func NewProcessorMrc(params ProcessFileParams) ProcessorMrc {
+	return ProcessorMrc{filters: params.filters, exclude: params.exclude, plan: params.plan, raw: params.raw}
+}
 
-		if i++; i < start {
-			continue
-		}
+func (p ProcessorMrc) Tags() []string {
+	return filterTags(p.filters)
+}
 
-		if r.Contains(params.searchValue, params.searchFields) && r.HasFields(params.hasFields) {
-			fmt.Printf("%s", r.Raw())
-			if out++; out == count {
-				break
-			}
+func (p ProcessorMrc) Header(w io.Writer) error {
+	return nil
+}
+
+func (p ProcessorMrc) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
+
+func (p ProcessorMrc) RenderRecord(r marc.Record) ([]byte, error) {
+	if p.raw {
+		b, err := r.Original()
+		if err != nil {
+			return nil, fmt.Errorf("-raw requires MARC binary input: %w", err)
 		}
+		return b, nil
 	}
-	return marc.Err()
+	if len(p.filters.Fields) > 0 || len(p.exclude.Fields) > 0 {
+		// Rebuild the record with only the fields requested
+		r.Fields = p.plan.Filter(r)
+		return r.Marshal()
+	}
+	return marc.EncodeMRC(r)
+}
+
+func (p ProcessorMrc) WriteRendered(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func (p ProcessorMrc) Footer(w io.Writer) error {
+	return nil
 }
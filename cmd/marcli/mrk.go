@@ -1,65 +1,67 @@
 package main
 
 import (
-	"fmt"
 	"io"
-	"os"
 
 	"github.com/hectorcorrea/marcli/pkg/marc"
 )
 
-func toMrk(params ProcessFileParams) error {
-	if count == 0 {
-		return nil
-	}
+func init() {
+	registerFormat(processorFormat{
+		name:        "mrk",
+		description: "MARC line delimited (mnemonic), the default",
+		contentType: "text/plain; charset=utf-8",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			return NewProcessorMrk(params), nil
+		},
+	})
+}
 
-	file, err := os.Open(params.filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+type ProcessorMrk struct {
+	filters marc.FieldFilters
+	exclude marc.FieldFilters
+	plan    *marc.FilterPlan
+}
 
-	var i, out int
-	marc := marc.NewMarcFile(file)
-	for marc.Scan() {
+func NewProcessorMrk(params ProcessFileParams) ProcessorMrk {
+	return ProcessorMrk{filters: params.filters, exclude: params.exclude, plan: params.plan}
+}
 
-		r, err := marc.Record()
-		if err == io.EOF {
-			break
-		}
+func (p ProcessorMrk) Tags() []string {
+	return filterTags(p.filters)
+}
 
-		if err != nil {
-			str := "== RECORD WITH ERROR STARTS HERE\n"
-			str += "ERROR:\n" + err.Error() + "\n"
-			str += r.DebugString() + "\n"
-			str += "== RECORD WITH ERROR ENDS HERE\n\n"
-			fmt.Print(str)
-			if params.debug {
-				continue
-			}
-			return err
-		}
+func (p ProcessorMrk) Header(w io.Writer) error {
+	return nil
+}
 
-		if i++; i < start {
-			continue
-		}
+func (p ProcessorMrk) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
 
-		if r.Contains(params.searchValue, params.searchFields) && r.HasFields(params.hasFields) {
-			str := ""
-			if params.filters.IncludeLeader() {
-				str += fmt.Sprintf("%s\r\n", r.Leader)
-			}
-			for _, field := range r.Filter(params.filters, params.exclude) {
-				str += fmt.Sprintf("%s\r\n", field)
-			}
-			if str != "" {
-				fmt.Printf("%s\r\n", str)
-				if out++; out == count {
-					break
-				}
-			}
-		}
+func (p ProcessorMrk) RenderRecord(r marc.Record) ([]byte, error) {
+	b := renderBuffer()
+	if p.filters.IncludeLeader() {
+		b = append(b, r.Leader.String()...)
+		b = append(b, "\r\n"...)
+	}
+	for _, field := range p.plan.Filter(r) {
+		b = field.AppendMRK(b)
+		b = append(b, "\r\n"...)
+	}
+	if len(b) == 0 {
+		releaseRendered(b)
+		return nil, errRecordSkipped
 	}
+	return append(b, "\r\n"...), nil
+}
+
+func (p ProcessorMrk) WriteRendered(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	releaseRendered(b)
+	return err
+}
 
-	return marc.Err()
+func (p ProcessorMrk) Footer(w io.Writer) error {
+	return nil
 }
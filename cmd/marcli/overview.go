@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "overview",
+		description: "Report an overview of the collection: record types, encodings, languages, dates, locations, and errors",
+		flags:       []string{"html", "location", "top"},
+		run:         runOverview,
+	})
+}
+
+// runOverview reads all the records in the file, including the ones
+// with errors, and outputs the overview as text or as an HTML page.
+func runOverview(ctx context.Context) error {
+	location, err := marc.NewFieldFilter(overviewLocation)
+	if err != nil || location.Subfields == "" {
+		return fmt.Errorf("invalid -location %q, indicate the item field and subfield, e.g. 945l or 852b", overviewLocation)
+	}
+	marcFile, file, err := openMarcFile(ProcessFileParams{filename: fileName, mmap: useMmap, maxRecordSize: maxRecordSize, parseMode: parseMode()})
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	c := newOverviewCounter(location, overviewLocation)
+	for {
+		r, err := marcFile.NextContext(ctx)
+		if err == io.EOF || ctx.Err() != nil {
+			break
+		}
+		if err != nil && marcFile.Err() != nil {
+			return err
+		}
+		c.add(r, err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	o := c.overview(fileName, overviewTop)
+	if overviewHTML {
+		return overviewTemplate.Execute(os.Stdout, o)
+	}
+	_, err = io.WriteString(os.Stdout, o.String())
+	return err
+}
+
+// overviewCounter counts the values of each record used in the
+// overview.
+type overviewCounter struct {
+	location    marc.FieldFilter
+	locationStr string
+	records     int
+	parseErrors int
+	invalid     int
+	warnings    int
+	noItems     int
+	types       map[string]int
+	bibLevels   map[string]int
+	encodings   map[string]int
+	levels      map[string]int
+	languages   map[string]int
+	decades     map[string]int
+	locations   map[string]int
+}
+
+func newOverviewCounter(location marc.FieldFilter, locationStr string) *overviewCounter {
+	return &overviewCounter{
+		location:    location,
+		locationStr: locationStr,
+		types:       map[string]int{},
+		bibLevels:   map[string]int{},
+		encodings:   map[string]int{},
+		levels:      map[string]int{},
+		languages:   map[string]int{},
+		decades:     map[string]int{},
+		locations:   map[string]int{},
+	}
+}
+
+// add counts a record, err is the error parsing it.
+func (c *overviewCounter) add(r marc.Record, err error) {
+	c.records++
+	if err != nil {
+		c.parseErrors++
+		return
+	}
+	if validateRecord(r, nil) != nil {
+		c.invalid++
+	}
+	if len(r.Warnings) > 0 {
+		c.warnings++
+	}
+
+	leader := r.Leader
+	c.types[leaderLabel(leader.Type, leader.TypeDescription())]++
+	c.bibLevels[leaderLabel(leader.BibLevel, leader.BibLevelDescription())]++
+	c.encodings[leaderLabel(leader.Encoding, leader.EncodingDescription())]++
+	c.levels[leaderLabel(leader.EncodingLevel, leader.EncodingLevelDescription())]++
+
+	language, decade := "(no 008)", "(no 008)"
+	if value := r.GetValue("008", ""); value != "" {
+		language, decade = "(blank)", "(unknown)"
+		if len(value) >= 38 && strings.TrimSpace(value[35:38]) != "" {
+			language = value[35:38]
+		}
+		if len(value) >= 11 && isYear(value[7:10]+"0") {
+			decade = value[7:10] + "0s"
+		}
+	}
+	c.languages[language]++
+	c.decades[decade]++
+
+	items := []marc.Field{}
+	for _, field := range r.Fields.GetAll(c.location.Tag) {
+		if c.location.Matches(field) {
+			items = append(items, field)
+		}
+	}
+	if len(items) == 0 {
+		c.noItems++
+	}
+	for _, item := range items {
+		value := strings.TrimSpace(strings.Join(item.SubFieldValues(c.location.Subfields), " "))
+		if value == "" {
+			value = "(blank)"
+		}
+		c.locations[value]++
+	}
+}
+
+// leaderLabel returns the value of a position of the leader and its
+// description, with # for blanks as in the MARC documentation.
+func leaderLabel(value byte, description string) string {
+	if value == ' ' {
+		value = '#'
+	}
+	return fmt.Sprintf("%c - %s", value, description)
+}
+
+// overviewCount is the number of records with a value, and the
+// percentage of the total.
+type overviewCount struct {
+	Value   string  `json:"value"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+type overviewSection struct {
+	Title  string
+	Counts []overviewCount
+	// Other is the number of distinct values not listed, when only the
+	// most common are.
+	Other int
+}
+
+// overview is the summary of the collection that is output.
+type overview struct {
+	File        string
+	Records     int
+	ParseErrors int
+	Invalid     int
+	Warnings    int
+	Sections    []overviewSection
+}
+
+func (c *overviewCounter) overview(file string, top int) overview {
+	parsed := c.records - c.parseErrors
+	items := 0
+	for _, count := range c.locations {
+		items += count
+	}
+	locations := topCounts(fmt.Sprintf("Top locations (%s, %d items, %d records without items)", c.locationStr, items, c.noItems), c.locations, items, top)
+	return overview{
+		File:        file,
+		Records:     c.records,
+		ParseErrors: c.parseErrors,
+		Invalid:     c.invalid,
+		Warnings:    c.warnings,
+		Sections: []overviewSection{
+			topCounts("Record types (leader/06)", c.types, parsed, 0),
+			topCounts("Bibliographic levels (leader/07)", c.bibLevels, parsed, 0),
+			topCounts("Character encoding (leader/09)", c.encodings, parsed, 0),
+			topCounts("Encoding levels (leader/17)", c.levels, parsed, 0),
+			topCounts("Top languages (008/35-37)", c.languages, parsed, top),
+			dateHistogram("Publication dates by decade (008/07-10)", c.decades, parsed),
+			locations,
+		},
+	}
+}
+
+// topCounts returns the values sorted from the most common to the least
+// common, only the first top values if top is not zero.
+func topCounts(title string, counts map[string]int, total int, top int) overviewSection {
+	section := overviewSection{Title: title, Counts: sortedCounts(counts, total)}
+	sort.SliceStable(section.Counts, func(i, j int) bool {
+		return section.Counts[i].Count > section.Counts[j].Count
+	})
+	if top > 0 && len(section.Counts) > top {
+		section.Other = len(section.Counts) - top
+		section.Counts = section.Counts[:top]
+	}
+	return section
+}
+
+// dateHistogram returns the decades in chronological order, with the
+// records without a date at the end.
+func dateHistogram(title string, counts map[string]int, total int) overviewSection {
+	return overviewSection{Title: title, Counts: sortedCounts(counts, total)}
+}
+
+// sortedCounts returns the counts sorted by value, with the values in
+// parentheses (e.g. "(blank)") at the end.
+func sortedCounts(counts map[string]int, total int) []overviewCount {
+	values := []overviewCount{}
+	for value, count := range counts {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(count) * 100 / float64(total)
+		}
+		values = append(values, overviewCount{Value: value, Count: count, Percent: percent})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := values[i].Value, values[j].Value
+		if strings.HasPrefix(a, "(") != strings.HasPrefix(b, "(") {
+			return !strings.HasPrefix(a, "(")
+		}
+		return a < b
+	})
+	return values
+}
+
+// overviewBarWidth is the width of the longest bar in the text output.
+const overviewBarWidth = 40
+
+func (o overview) String() string {
+	str := fmt.Sprintf("File: %s\n", o.File)
+	str += fmt.Sprintf("Records: %d\n", o.Records)
+	str += fmt.Sprintf("Parse errors: %d\n", o.ParseErrors)
+	str += fmt.Sprintf("Invalid leader or fixed fields: %d\n", o.Invalid)
+	str += fmt.Sprintf("Records with warnings: %d\n", o.Warnings)
+	for _, section := range o.Sections {
+		str += "\n" + section.String()
+	}
+	return str
+}
+
+// String returns the section as text, with a bar for each value.
+func (section overviewSection) String() string {
+	str := fmt.Sprintf("%s\n", section.Title)
+	max := 0
+	for _, count := range section.Counts {
+		if count.Count > max {
+			max = count.Count
+		}
+	}
+	for _, count := range section.Counts {
+		bar := strings.Repeat("#", count.Count*overviewBarWidth/max)
+		str += fmt.Sprintf("  %-40s %9d %6.1f%%  %s\n", count.Value, count.Count, count.Percent, bar)
+	}
+	if section.Other > 0 {
+		str += fmt.Sprintf("  (%d other values)\n", section.Other)
+	}
+	return str
+}
+
+var overviewTemplate = template.Must(template.New("overview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Overview of {{.File}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.2em 0.8em; text-align: left; }
+td.number { text-align: right; }
+.bar { background: #4a7ab5; height: 0.8em; }
+</style>
+</head>
+<body>
+<h1>Overview of {{.File}}</h1>
+<table>
+<tr><th>Records</th><td class="number">{{.Records}}</td></tr>
+<tr><th>Parse errors</th><td class="number">{{.ParseErrors}}</td></tr>
+<tr><th>Invalid leader or fixed fields</th><td class="number">{{.Invalid}}</td></tr>
+<tr><th>Records with warnings</th><td class="number">{{.Warnings}}</td></tr>
+</table>
+{{range .Sections}}<h2>{{.Title}}</h2>
+<table>
+{{range .Counts}}<tr><td>{{.Value}}</td><td class="number">{{.Count}}</td><td class="number">{{printf "%.1f" .Percent}}%</td><td style="width: 20em"><div class="bar" style="width: {{printf "%.1f" .Percent}}%"></div></td></tr>
+{{end}}{{if .Other}}<tr><td colspan="4">({{.Other}} other values)</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
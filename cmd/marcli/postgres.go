@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+	"github.com/lib/pq"
+)
+
+// postgresSources are the values of the flattened rows (one per
+// subfield, or per control field) that can be mapped to the columns of
+// the table, see -pg-columns.
+var postgresSources = map[string]bool{
+	"id":    true, // control number (001)
+	"pos":   true, // byte position of the record in the file
+	"seq":   true, // position of the field in the record, starting at 1
+	"tag":   true,
+	"ind1":  true,
+	"ind2":  true,
+	"code":  true, // subfield code, empty for control fields
+	"value": true,
+}
+
+type postgresColumn struct {
+	name   string
+	source string
+}
+
+// postgresProcessor copies the fields of the records into a table in
+// PostgreSQL with COPY. All the rows are copied in one transaction that
+// is committed once all the records have been processed, so the table
+// is left as it was if the run fails or is interrupted. The first error
+// copying a row rolls back the transaction and stops the processing,
+// even with -debug.
+type postgresProcessor struct {
+	ctx     context.Context
+	db      *sql.DB
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	schema  string
+	table   string
+	columns []postgresColumn
+	filters marc.FieldFilters
+	plan    *marc.FilterPlan
+	rows    int
+}
+
+func newPostgresProcessor(ctx context.Context, params ProcessFileParams, connection string, table string, columns string) (*postgresProcessor, error) {
+	p := &postgresProcessor{ctx: ctx, table: table, filters: params.filters, plan: params.plan}
+	if i := strings.Index(table, "."); i != -1 {
+		p.schema, p.table = table[:i], table[i+1:]
+	}
+	for _, value := range strings.Split(columns, ",") {
+		column, err := parsePostgresColumn(value)
+		if err != nil {
+			return nil, err
+		}
+		p.columns = append(p.columns, column)
+	}
+
+	db, err := sql.Open("postgres", connection)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot connect to PostgreSQL: %w", err)
+	}
+	p.db = db
+	return p, nil
+}
+
+// parsePostgresColumn parses a column as name:source, or just the
+// name when it is the same as the source (e.g. tag).
+func parsePostgresColumn(value string) (postgresColumn, error) {
+	value = strings.TrimSpace(value)
+	column := postgresColumn{name: value, source: value}
+	if i := strings.Index(value, ":"); i != -1 {
+		column.name, column.source = value[:i], value[i+1:]
+	}
+	if !postgresSources[column.source] {
+		return column, fmt.Errorf("invalid -pg-columns value %q, accepted sources: id, pos, seq, tag, ind1, ind2, code, value", value)
+	}
+	return column, nil
+}
+
+// Tags returns the tags of the fields requested in -fields, nil if all
+// the fields are copied.
+func (p *postgresProcessor) Tags() []string {
+	tags := filterTags(p.filters)
+	if tags == nil {
+		return nil
+	}
+	return append(tags, "001")
+}
+
+func (p *postgresProcessor) Header(w io.Writer) error {
+	names := make([]string, len(p.columns))
+	for i, column := range p.columns {
+		names[i] = column.name
+	}
+	var err error
+	if p.tx, err = p.db.Begin(); err != nil {
+		return err
+	}
+	query := pq.CopyIn(p.table, names...)
+	if p.schema != "" {
+		query = pq.CopyInSchema(p.schema, p.table, names...)
+	}
+	if p.stmt, err = p.tx.Prepare(query); err != nil {
+		p.rollback()
+		return err
+	}
+	return nil
+}
+
+func (p *postgresProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	id := r.ControlNum()
+	for i, field := range p.plan.Filter(r) {
+		if field.IsControlField() {
+			if err := p.copyRow(r, id, i+1, field, marc.SubField{Value: field.Value}); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, sub := range field.SubFields {
+			if err := p.copyRow(r, id, i+1, field, sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyRow adds the row for a subfield (or control field) to the COPY.
+func (p *postgresProcessor) copyRow(r marc.Record, id string, seq int, field marc.Field, sub marc.SubField) error {
+	if _, err := p.stmt.Exec(p.rowValues(r, id, seq, field, sub)...); err != nil {
+		p.rollback()
+		return fatalError{fmt.Errorf("error copying to PostgreSQL table %s: %w", p.table, err)}
+	}
+	p.rows++
+	return nil
+}
+
+// rowValues returns the values of the columns of the row for a subfield
+// (or control field).
+func (p *postgresProcessor) rowValues(r marc.Record, id string, seq int, field marc.Field, sub marc.SubField) []interface{} {
+	values := make([]interface{}, len(p.columns))
+	for i, column := range p.columns {
+		switch column.source {
+		case "id":
+			values[i] = id
+		case "pos":
+			values[i] = r.Pos
+		case "seq":
+			values[i] = seq
+		case "tag":
+			values[i] = field.Tag
+		case "ind1":
+			values[i] = field.Indicator1
+		case "ind2":
+			values[i] = field.Indicator2
+		case "code":
+			values[i] = sub.Code
+		case "value":
+			values[i] = sub.Value
+		}
+	}
+	return values
+}
+
+func (p *postgresProcessor) Footer(w io.Writer) error {
+	defer p.db.Close()
+	// processFile calls Footer after an interruption (e.g. -timeout),
+	// only some of the rows were copied.
+	if err := p.ctx.Err(); err != nil {
+		p.rollback()
+		return err
+	}
+	// Exec without values completes the COPY.
+	if _, err := p.stmt.Exec(); err != nil {
+		p.rollback()
+		return err
+	}
+	if err := p.stmt.Close(); err != nil {
+		p.rollback()
+		return err
+	}
+	err := p.tx.Commit()
+	p.tx = nil
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d rows copied to PostgreSQL table %s\n", p.rows, p.table)
+	return nil
+}
+
+// rollback discards the rows copied so far after an error, see
+// rollbacker.
+func (p *postgresProcessor) rollback() {
+	if p.tx != nil {
+		p.tx.Rollback()
+		p.tx = nil
+	}
+}
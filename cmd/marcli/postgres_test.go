@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// fakeCopyDB is a database/sql driver that records the rows of a COPY
+// in memory instead of sending them to PostgreSQL. The name of the data
+// source is the key of the fakeCopyConn in fakeCopyConns.
+type fakeCopyDB struct{}
+
+var (
+	fakeCopyMu    sync.Mutex
+	fakeCopyConns = map[string]*fakeCopyConn{}
+)
+
+func init() {
+	sql.Register("fake-copy", fakeCopyDB{})
+}
+
+func (fakeCopyDB) Open(name string) (driver.Conn, error) {
+	fakeCopyMu.Lock()
+	defer fakeCopyMu.Unlock()
+	return fakeCopyConns[name], nil
+}
+
+// fakeCopyConn fails the COPY of the row failAt (1-based), and calls
+// cancel before copying the row cancelAt, 0 for none.
+type fakeCopyConn struct {
+	failAt     int
+	cancelAt   int
+	cancel     context.CancelFunc
+	rows       [][]driver.Value
+	completed  bool
+	committed  bool
+	rolledBack bool
+}
+
+func (c *fakeCopyConn) Prepare(query string) (driver.Stmt, error) { return fakeCopyStmt{c}, nil }
+func (c *fakeCopyConn) Close() error                              { return nil }
+func (c *fakeCopyConn) Begin() (driver.Tx, error)                 { return fakeCopyTx{c}, nil }
+
+type fakeCopyTx struct{ c *fakeCopyConn }
+
+func (tx fakeCopyTx) Commit() error   { tx.c.committed = true; return nil }
+func (tx fakeCopyTx) Rollback() error { tx.c.rolledBack = true; return nil }
+
+type fakeCopyStmt struct{ c *fakeCopyConn }
+
+func (s fakeCopyStmt) Close() error  { return nil }
+func (s fakeCopyStmt) NumInput() int { return -1 }
+
+func (s fakeCopyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if len(args) == 0 {
+		s.c.completed = true
+		return driver.RowsAffected(len(s.c.rows)), nil
+	}
+	if len(s.c.rows)+1 == s.c.cancelAt {
+		s.c.cancel()
+	}
+	if len(s.c.rows)+1 == s.c.failAt {
+		return nil, errors.New(`invalid byte sequence for encoding "UTF8"`)
+	}
+	s.c.rows = append(s.c.rows, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeCopyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+// newFakePostgres returns a postgresProcessor that copies the rows to
+// a fakeCopyConn.
+func newFakePostgres(t *testing.T, ctx context.Context, columns string, failAt int) (*postgresProcessor, *fakeCopyConn) {
+	t.Helper()
+	conn := &fakeCopyConn{failAt: failAt}
+	fakeCopyMu.Lock()
+	fakeCopyConns[t.Name()] = conn
+	fakeCopyMu.Unlock()
+	db, err := sql.Open("fake-copy", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &postgresProcessor{ctx: ctx, db: db, table: "marc_fields", plan: marc.FilterSpec{}.Compile()}
+	for _, value := range strings.Split(columns, ",") {
+		column, err := parsePostgresColumn(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.columns = append(p.columns, column)
+	}
+	return p, conn
+}
+
+func TestPostgresRows(t *testing.T) {
+	t.Parallel()
+
+	r := marc.NewRecord()
+	r.Pos = 1024
+	r.AddControlField("001", "ocm123")
+	r.AddDataField("245", "1", "0", marc.SubField{Code: "a", Value: "Coal"}, marc.SubField{Code: "c", Value: "by someone"})
+
+	p, conn := newFakePostgres(t, context.Background(), "record_id:id,pos,field_seq:seq,tag,ind1,ind2,code,value", 0)
+	if err := p.Header(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ProcessRecord(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Footer(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]driver.Value{
+		{"ocm123", int64(1024), int64(1), "001", "", "", "", "ocm123"},
+		{"ocm123", int64(1024), int64(2), "245", "1", "0", "a", "Coal"},
+		{"ocm123", int64(1024), int64(2), "245", "1", "0", "c", "by someone"},
+	}
+	if !cmp.Equal(want, conn.rows) {
+		t.Errorf("unexpected rows: %s", cmp.Diff(want, conn.rows))
+	}
+	if !conn.completed || !conn.committed || conn.rolledBack {
+		t.Errorf("expected the COPY to be completed and committed, got %+v", conn)
+	}
+}
+
+func TestPostgresErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parsePostgresColumn("heading:title"); err == nil {
+		t.Errorf("expected an error for an invalid source")
+	}
+
+	dir := t.TempDir()
+	good := writeGenerated(t, dir, "bib", 5)
+	data, err := os.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The base address of data of the second record is not a number.
+	marcFile := marc.NewMarcFileBytes(data)
+	marcFile.Next()
+	bad := filepath.Join(dir, "bad.mrc")
+	copy(data[marcFile.Offset()+12:], "ZZZZZ")
+	if err := os.WriteFile(bad, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		failAt   int
+		cancelAt int
+		debug    bool
+		// rows is the number of rows copied before the error.
+		rows int
+	}{
+		// The COPY cannot continue after an error, not even with -debug.
+		{name: "copy", file: good, failAt: 3, debug: true, rows: 2},
+		{name: "parse", file: bad},
+		{name: "cancelled", file: good, cancelAt: 10},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			p, conn := newFakePostgres(t, ctx, "id,tag,value", tt.failAt)
+			conn.cancelAt, conn.cancel = tt.cancelAt, cancel
+			var errs bytes.Buffer
+			params := ProcessFileParams{filename: tt.file, count: -1, debug: tt.debug, plan: marc.FilterSpec{}.Compile(), errorOutput: &errs}
+			if err := processFile(ctx, params, p, io.Discard); err == nil {
+				t.Fatalf("expected an error")
+			}
+			if !conn.rolledBack || conn.committed {
+				t.Errorf("expected the transaction to be rolled back, got %+v", conn)
+			}
+			if tt.failAt > 0 && len(conn.rows) != tt.rows {
+				t.Errorf("expected %d rows before the error, got %d", tt.rows, len(conn.rows))
+			}
+			if n := strings.Count(errs.String(), "PROCESSING ERROR"); tt.failAt > 0 && n != 1 {
+				t.Errorf("expected to stop after the first error, got %d errors", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+var prettyLabels bool
+var prettyLabelWidth int
+
+func init() {
+	registerFormat(processorFormat{
+		name:        "pretty",
+		description: "Human readable, with the tag, indicators, and subfields in aligned columns",
+		contentType: "text/plain; charset=utf-8",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			return NewProcessorPretty(params), nil
+		},
+		setFlags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&prettyLabels, "labels", false, "Show the name of each field after its tag, e.g. 245 Title Statement.")
+			fs.IntVar(&prettyLabelWidth, "label-width", 36, "Width of the column with the names of the fields (see -pretty.labels), longer names are truncated. 0 shows the names in full without aligning the columns.")
+		},
+	})
+}
+
+// ProcessorPretty outputs each field in a line with the tag, the
+// indicators (blanks shown as #), and the subfields separated by
+// spaces, e.g.
+//
+//	245 10  $a Title : $b subtitle / $c author.
+type ProcessorPretty struct {
+	filters marc.FieldFilters
+	exclude marc.FieldFilters
+	plan    *marc.FilterPlan
+	labels  bool
+	width   int // of the labels column
+}
+
+func NewProcessorPretty(params ProcessFileParams) ProcessorPretty {
+	return ProcessorPretty{filters: params.filters, exclude: params.exclude, plan: params.plan, labels: prettyLabels, width: prettyLabelWidth}
+}
+
+func (p ProcessorPretty) Tags() []string {
+	return filterTags(p.filters)
+}
+
+func (p ProcessorPretty) Header(w io.Writer) error {
+	return nil
+}
+
+func (p ProcessorPretty) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
+
+func (p ProcessorPretty) RenderRecord(r marc.Record) ([]byte, error) {
+	b := renderBuffer()
+	if p.filters.IncludeLeader() {
+		b = p.appendLine(b, "LDR", "  ", r.Leader.Raw())
+	}
+	for _, field := range p.plan.Filter(r) {
+		if field.IsControlField() {
+			b = p.appendLine(b, field.Tag, "  ", field.Value)
+			continue
+		}
+		subfields := make([]string, 0, len(field.SubFields))
+		for _, sub := range field.SubFields {
+			subfields = append(subfields, "$"+sub.Code+" "+sub.Value)
+		}
+		indicators := prettyIndicator(field.Indicator1) + prettyIndicator(field.Indicator2)
+		b = p.appendLine(b, field.Tag, indicators, strings.Join(subfields, " "))
+	}
+	if len(b) == 0 {
+		releaseRendered(b)
+		return nil, errRecordSkipped
+	}
+	return append(b, '\n'), nil
+}
+
+// appendLine appends the line of a field, with its name after the tag
+// when requested.
+func (p ProcessorPretty) appendLine(b []byte, tag string, indicators string, value string) []byte {
+	b = append(b, tag...)
+	b = append(b, ' ')
+	if p.labels {
+		label := marc.TagLabel(tag)
+		if p.width > 3 && len(label) > p.width {
+			label = label[:p.width-3] + "..."
+		} else if p.width > 0 && len(label) > p.width {
+			label = label[:p.width]
+		}
+		b = append(b, label...)
+		padding := 1
+		if p.width > len(label) {
+			padding += p.width - len(label)
+		}
+		b = append(b, strings.Repeat(" ", padding)...)
+	}
+	b = append(b, indicators...)
+	b = append(b, "  "...)
+	b = append(b, value...)
+	return append(b, '\n')
+}
+
+func prettyIndicator(value string) string {
+	if value == " " || value == "" {
+		return "#"
+	}
+	return value
+}
+
+func (p ProcessorPretty) WriteRendered(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	releaseRendered(b)
+	return err
+}
+
+func (p ProcessorPretty) Footer(w io.Writer) error {
+	return nil
+}
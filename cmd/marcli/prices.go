@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "prices",
+		description: "Report the prices of the items (e.g. 945 $p) by location or fund, and the prices in the 020 $c and the 365, in each currency",
+		flags:       []string{"price", "group", "currency", "details", "bib-id", "top", "csv", "json", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runPrices,
+	})
+}
+
+func runPrices(ctx context.Context) error {
+	if reportCSV && reportJSON {
+		return errors.New("use either -csv or -json")
+	}
+	price, err := marc.NewFieldFilter(priceSpec)
+	if err != nil || price.Subfields == "" {
+		return fmt.Errorf("invalid -price %q, indicate the item field and subfield, e.g. 945p", priceSpec)
+	}
+	group, err := marc.NewFieldFilter(priceGroup)
+	if err != nil || group.Subfields == "" {
+		return fmt.Errorf("invalid -group %q, indicate the field and subfield, e.g. 945l", priceGroup)
+	}
+	bibID, err := marc.NewFieldFilter(barcodeBibID)
+	if err != nil {
+		return fmt.Errorf("invalid -bib-id %q: %w", barcodeBibID, err)
+	}
+	processor := &pricesProcessor{
+		price:    price,
+		group:    group,
+		bibID:    bibID,
+		currency: strings.ToUpper(priceCurrency),
+		details:  priceDetails,
+		top:      overviewTop,
+		totals:   map[priceKey]*priceTotal{},
+	}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// priceKey is what the prices are summed by.
+type priceKey struct {
+	Source   string `json:"source"`
+	Group    string `json:"group"`
+	Currency string `json:"currency"`
+}
+
+type priceTotal struct {
+	priceKey
+	Count int     `json:"count"`
+	Total float64 `json:"total"`
+}
+
+// invalidPrice is a price that could not be parsed.
+type invalidPrice struct {
+	Record string `json:"record"`
+	Source string `json:"source"`
+	Value  string `json:"value"`
+}
+
+// pricesProcessor sums the prices of the items by group (e.g. by
+// location) and the prices of the records (020 $c and 365) by currency.
+// With details it outputs every price instead.
+type pricesProcessor struct {
+	price    marc.FieldFilter
+	group    marc.FieldFilter
+	bibID    marc.FieldFilter
+	currency string
+	details  bool
+	top      int
+
+	records    int
+	items      int
+	noPrice    int
+	totals     map[priceKey]*priceTotal
+	invalid    []invalidPrice
+	invalidAll int
+}
+
+func (p *pricesProcessor) Tags() []string {
+	return []string{p.price.Tag, p.group.Tag, p.bibID.Tag, "020", "365"}
+}
+
+func (p *pricesProcessor) Header(w io.Writer) error {
+	if !p.details {
+		return nil
+	}
+	_, err := io.WriteString(w, "bib\tsource\tgroup\tvalue\tamount\tcurrency\r\n")
+	return err
+}
+
+func (p *pricesProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.records++
+	id := ""
+	if field, ok := firstMatch(r, p.bibID); ok {
+		if field.IsControlField() || p.bibID.Subfields == "" {
+			id = strings.TrimSpace(field.Value)
+		} else {
+			id = strings.TrimSpace(strings.Join(field.SubFieldValues(p.bibID.Subfields), " "))
+		}
+	}
+	if id == "" {
+		id = fmt.Sprintf("record at byte %d", r.Pos)
+	}
+
+	var sb strings.Builder
+	add := func(source, group, value string, price marc.Price, err error) {
+		if p.details {
+			amount, currency := "", ""
+			if err == nil {
+				amount, currency = strconv.FormatFloat(price.Amount, 'f', 2, 64), price.Currency
+			}
+			fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\t%s\t%s\r\n", id, source, itemsCleanValue(group), itemsCleanValue(value), amount, currency)
+			return
+		}
+		if err != nil {
+			p.invalidAll++
+			if p.top == 0 || len(p.invalid) < p.top {
+				p.invalid = append(p.invalid, invalidPrice{Record: id, Source: source, Value: value})
+			}
+			return
+		}
+		key := priceKey{Source: source, Group: group, Currency: price.Currency}
+		total, ok := p.totals[key]
+		if !ok {
+			total = &priceTotal{priceKey: key}
+			p.totals[key] = total
+		}
+		total.Count++
+		total.Total += price.Amount
+	}
+
+	// The prices of the items, with the group from the same item field
+	// or from the record.
+	itemSource := p.price.Tag + p.price.Subfields
+	for _, item := range p.price.Select(r.Fields) {
+		p.items++
+		group := ""
+		if p.group.Tag == p.price.Tag {
+			group = strings.Join(item.SubFieldValues(p.group.Subfields), " ")
+		} else if field, ok := firstMatch(r, p.group); ok {
+			group = strings.Join(field.SubFieldValues(p.group.Subfields), " ")
+		}
+		group = strings.TrimSpace(group)
+		if group == "" {
+			group = "(blank)"
+		}
+		values := item.SubFieldValues(p.price.Subfields)
+		if len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+			p.noPrice++
+			continue
+		}
+		price, err := marc.ParsePrice(values[0], p.currency)
+		add(itemSource, group, values[0], price, err)
+	}
+
+	// The prices of the records.
+	for _, value := range r.Fields.GetValues("020", "c") {
+		price, err := marc.ParsePrice(value, p.currency)
+		add("020c", "", value, price, err)
+	}
+	for _, field := range r.Fields.GetAll("365") {
+		amount := strings.TrimSpace(field.SubFieldValue("b"))
+		if amount == "" {
+			continue
+		}
+		value := amount
+		if currency := strings.TrimSpace(field.SubFieldValue("c")); currency != "" {
+			value = currency + " " + amount
+		}
+		price, err := marc.ParsePrice(value, p.currency)
+		add("365", "", value, price, err)
+	}
+
+	if p.details {
+		if sb.Len() == 0 {
+			return errRecordSkipped
+		}
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+	return nil
+}
+
+// sortedTotals returns the totals by source (the items first, then the
+// 020 and the 365), group, and currency.
+func (p *pricesProcessor) sortedTotals() []priceTotal {
+	totals := []priceTotal{}
+	for _, total := range p.totals {
+		totals = append(totals, *total)
+	}
+	rank := map[string]int{"020c": 1, "365": 2}
+	sort.Slice(totals, func(i, j int) bool {
+		a, b := totals[i].priceKey, totals[j].priceKey
+		if a.Source != b.Source {
+			return rank[a.Source] < rank[b.Source]
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.Currency < b.Currency
+	})
+	return totals
+}
+
+// pricesReport is the JSON output.
+type pricesReport struct {
+	Records      int            `json:"records"`
+	Items        int            `json:"items"`
+	ItemsNoPrice int            `json:"items_without_price"`
+	Totals       []priceTotal   `json:"totals"`
+	Invalid      []invalidPrice `json:"invalid"`
+	InvalidCount int            `json:"invalid_count"`
+}
+
+func (p *pricesProcessor) Footer(w io.Writer) error {
+	if p.details {
+		return nil
+	}
+	totals := p.sortedTotals()
+	switch {
+	case reportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"source", "group", "currency", "count", "total"})
+		for _, total := range totals {
+			cw.Write([]string{total.Source, total.Group, total.Currency, strconv.Itoa(total.Count), strconv.FormatFloat(total.Total, 'f', 2, 64)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pricesReport{
+			Records:      p.records,
+			Items:        p.items,
+			ItemsNoPrice: p.noPrice,
+			Totals:       totals,
+			Invalid:      append([]invalidPrice{}, p.invalid...),
+			InvalidCount: p.invalidAll,
+		})
+	}
+
+	itemSource := p.price.Tag + p.price.Subfields
+	str := fmt.Sprintf("Records: %d\n", p.records)
+	str += fmt.Sprintf("Items (%s): %d, %d without price\n", p.price.Tag, p.items, p.noPrice)
+	source := ""
+	for _, total := range totals {
+		if total.Source != source {
+			source = total.Source
+			if source == itemSource {
+				str += fmt.Sprintf("\nItem prices (%s) by %s\n", itemSource, p.group.Tag+p.group.Subfields)
+			} else {
+				str += fmt.Sprintf("\nRecord prices (%s)\n", source)
+			}
+		}
+		group := total.Group
+		if source != itemSource {
+			group = ""
+		}
+		str += fmt.Sprintf("  %-30s %-3s %9d %15.2f\n", group, total.Currency, total.Count, total.Total)
+	}
+	if p.invalidAll > 0 {
+		str += fmt.Sprintf("\nPrices that could not be parsed: %d\n", p.invalidAll)
+		for _, invalid := range p.invalid {
+			str += fmt.Sprintf("  %s: %s %q\n", invalid.Record, invalid.Source, invalid.Value)
+		}
+		if more := p.invalidAll - len(p.invalid); more > 0 {
+			str += fmt.Sprintf("  (%d more)\n", more)
+		}
+	}
+	_, err := io.WriteString(w, str)
+	return err
+}
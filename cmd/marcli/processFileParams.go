@@ -1,21 +1,65 @@
 package main
 
 import (
+	"io"
+
 	"github.com/hectorcorrea/marcli/pkg/marc"
 )
 
 type ProcessFileParams struct {
-	filename     string
-	searchValue  string
-	searchFields []string
-	filters      marc.FieldFilters
-	exclude      marc.FieldFilters
-	start        int
-	count        int
-	hasFields    marc.FieldFilters
-	debug        bool
+	filename        string
+	input           io.Reader // read the records from here instead of filename (see marcli serve)
+	searchValue     string
+	searchFields    []string
+	filters         marc.FieldFilters
+	exclude         marc.FieldFilters
+	start           int
+	count           int
+	hasFields       marc.FieldFilters
+	where           []marc.PositionFilter // conditions on the leader and control fields (see -where)
+	plan            *marc.FilterPlan      // compiled search criteria and filters
+	debug           bool
+	skipErrors      bool
+	workers         int
+	mmap            bool
+	offsets         []int64            // read only the records at these offsets (see -at and -id)
+	numbers         []int              // the record numbers of the offsets, from the index
+	positions       bool               // print the number and offset of each record (see -positions)
+	matchedOnly     bool               // output only the fields with the search value (see -matched-only)
+	raw             bool               // output the original bytes of the records (see -raw)
+	normalization   marc.Normalization // of the search (see -normalize)
+	highlight       *highlighter       // nil unless the matches are highlighted (see -highlight)
+	resumeFrom      int64
+	tags            []string // parse only the fields with these tags, nil for all
+	maxRecordSize   int
+	parseMode       marc.ParseMode
+	fixUTF8         bool            // handle invalid UTF-8 with utf8Policy
+	utf8Policy      marc.UTF8Policy // see -invalid-utf8
+	bufferSize      int             // size of the output buffer, 0 for no buffering
+	checkpoint      string          // file where to save the checkpoints, if any
+	checkpointEvery int
+	report          *runReport        // nil when no report was requested
+	errorLog        *errorLog         // nil when no -error-file was given
+	duplicates      *duplicateTracker // nil unless -warn-duplicates
+	metrics         *runMetrics       // nil when no metrics were requested
+	excel           excelOptions      // encoding of the tabular outputs (see -bom, -crlf, and -codepage)
+	errorOutput     io.Writer         // where the records with errors are printed, nil for the output
 }
 
 func (p ProcessFileParams) HasFilters() bool {
 	return len(p.filters.Fields) > 0 || len(p.exclude.Fields) > 0
 }
+
+// filterPlan compiles the search criteria and filters in the parameters.
+func (p ProcessFileParams) filterPlan() *marc.FilterPlan {
+	return marc.FilterSpec{
+		Search:        p.searchValue,
+		SearchFields:  p.searchFields,
+		HasFields:     p.hasFields,
+		Include:       p.filters,
+		Exclude:       p.exclude,
+		MatchedOnly:   p.matchedOnly,
+		Normalization: p.normalization,
+		Positions:     p.where,
+	}.Compile()
+}
@@ -0,0 +1,666 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// Processor is implemented by each of the output formats. Header and
+// Footer are called once per file, ProcessRecord is called for each
+// record that matches the search criteria.
+type Processor interface {
+	Header(w io.Writer) error
+	ProcessRecord(w io.Writer, r marc.Record) error
+	Footer(w io.Writer) error
+}
+
+// errRecordSkipped can be returned by a processor to indicate that
+// nothing was output for a record and therefore the record should not
+// count towards the total of records requested.
+var errRecordSkipped = errors.New("record skipped")
+
+// fatalError wraps the errors of a processor after which no more
+// records can be processed (e.g. the transaction where they are saved
+// was rolled back), processing stops even with -debug.
+type fatalError struct {
+	err error
+}
+
+func (e fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e fatalError) Unwrap() error {
+	return e.err
+}
+
+// rollbacker is implemented by the processors that save the records in
+// a database transaction, to roll it back when processing fails before
+// (or in) Footer.
+type rollbacker interface {
+	rollback()
+}
+
+// recordRenderer is implemented by processors that can render a record
+// independently of the records processed before it. This allows records
+// to be rendered concurrently (see -workers): RenderRecord must be safe
+// for concurrent use whereas WriteRendered is always called in the order
+// in which the records were read.
+type recordRenderer interface {
+	RenderRecord(r marc.Record) ([]byte, error)
+	WriteRendered(w io.Writer, b []byte) error
+}
+
+// processRendered implements ProcessRecord for a recordRenderer.
+func processRendered(p recordRenderer, w io.Writer, r marc.Record) error {
+	b, err := p.RenderRecord(r)
+	if err != nil {
+		return err
+	}
+	return p.WriteRendered(w, b)
+}
+
+// renderBuffers keeps the buffers where the records are rendered so
+// that they can be reused once the record has been written rather than
+// allocating a new one for each record.
+var renderBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 4*1024)
+		return &b
+	},
+}
+
+// maxPooledBuffer is the size of the largest buffer kept for reuse,
+// buffers grown by an unusually large record are left to the GC.
+const maxPooledBuffer = 256 * 1024
+
+// renderBuffer returns an empty buffer from the pool. Processors that
+// use it call releaseRendered after writing the record.
+func renderBuffer() []byte {
+	return (*renderBuffers.Get().(*[]byte))[:0]
+}
+
+// releaseRendered returns to the pool a buffer obtained with
+// renderBuffer. The buffer must not be used afterwards.
+func releaseRendered(b []byte) {
+	if cap(b) == 0 || cap(b) > maxPooledBuffer {
+		return
+	}
+	b = b[:0]
+	renderBuffers.Put(&b)
+}
+
+// fieldSelector is implemented by processors that only output some of
+// the fields of the records. Tags returns the tags of those fields, or
+// nil if the processor needs all the fields.
+type fieldSelector interface {
+	Tags() []string
+}
+
+// filterTags returns the tags in the filters (e.g. -fields), nil if
+// there are no filters and therefore all the fields are output.
+func filterTags(filters marc.FieldFilters) []string {
+	if len(filters.Fields) == 0 {
+		return nil
+	}
+	tags := []string{}
+	for _, filter := range filters.Fields {
+		if filter.Tag != "LDR" {
+			tags = append(tags, filter.Tag)
+		}
+	}
+	return tags
+}
+
+// neededTags returns the tags of the fields that must be parsed to
+// search the records and output them with the processor, nil if all
+// the fields are needed. Parsing only the fields that are needed is
+// much faster when extracting a few fields of large files.
+func neededTags(params ProcessFileParams, processor Processor) []string {
+	selector, ok := processor.(fieldSelector)
+	if !ok {
+		return nil
+	}
+	tags := selector.Tags()
+	if tags == nil {
+		return nil
+	}
+	if params.searchValue != "" {
+		if len(params.searchFields) == 0 {
+			return nil
+		}
+		tags = append(tags, params.searchFields...)
+	}
+	for _, filter := range params.hasFields.Fields {
+		tags = append(tags, filter.Tag)
+	}
+	for _, filter := range params.where {
+		tags = append(tags, filter.Tag)
+	}
+	// The control number is used in the error messages and reports.
+	return append(tags, "001")
+}
+
+// recordJob is a record on its way from the file to the output.
+type recordJob struct {
+	seq       int
+	r         marc.Record
+	parseErr  error
+	matched   bool
+	rendered  bool
+	output    []byte
+	renderErr error
+	end       int64 // position right after the record, -1 if unknown
+	// invalidUTF8 is the number of invalid UTF-8 bytes handled
+	invalidUTF8 int
+}
+
+// fileProcessor keeps the state of the processing of one file.
+type fileProcessor struct {
+	params          ProcessFileParams
+	processor       Processor
+	w               io.Writer
+	out             *bufio.Writer // buffer of w, nil when output is not buffered
+	seq             int           // records read (including the ones with errors)
+	read            int           // records consumed (excluding the ones with errors)
+	failed          int           // records that could not be parsed
+	matched         int           // records that matched the search criteria
+	written         int           // records written to the output
+	resume          int64         // position of the first record not processed yet
+	sinceCheckpoint int           // records processed since the last checkpoint
+}
+
+// processFile reads the records in the file indicated in params and
+// passes the ones that match the search criteria to the processor.
+//
+// If ctx is cancelled processing stops after the current record, the
+// footer is still written so that the output is well formed, and a
+// summary of what was processed is printed to stderr.
+func processFile(ctx context.Context, params ProcessFileParams, processor Processor, w io.Writer) error {
+	if params.count == 0 {
+		return nil
+	}
+
+	params.tags = neededTags(params, processor)
+	marcFile, file, err := openMarcFile(params)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var excel *excelWriter
+	if params.excel.enabled() {
+		excel = newExcelWriter(w, params.excel)
+		w = excel
+	}
+	p := fileProcessor{params: params, processor: processor, w: w, resume: params.resumeFrom}
+	if params.bufferSize > 0 {
+		p.out = bufio.NewWriterSize(w, params.bufferSize)
+		p.w = p.out
+	}
+	if err := processor.Header(p.w); err != nil {
+		return err
+	}
+
+	if params.start > 1 {
+		// The records before -start are skipped without parsing them.
+		if p.read, err = marcFile.Skip(params.start - 1); err != nil {
+			return err
+		}
+		if end := marcFile.Offset(); end >= 0 {
+			p.resume = end + params.resumeFrom
+		}
+	}
+	if params.workers > 1 {
+		err = p.runConcurrently(ctx, &marcFile)
+	} else {
+		err = p.run(ctx, &marcFile)
+	}
+	p.updateReport()
+	if err == nil {
+		err = processor.Footer(p.w)
+	}
+	if rb, ok := processor.(rollbacker); ok && err != nil {
+		rb.rollback()
+	}
+	// Flush even after an error, the error itself might be in the output.
+	if flushErr := p.flush(); err == nil {
+		err = flushErr
+	}
+	if excel != nil {
+		if closeErr := excel.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if params.checkpoint != "" {
+		completed := err == nil && ctx.Err() == nil
+		if cpErr := p.saveCheckpoint(completed); cpErr != nil && err == nil {
+			err = cpErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Stopped after reading %d records (%d written)\n", p.read, p.written)
+		if p.resume >= 0 {
+			fmt.Fprintf(os.Stderr, "Use -resume-from %d to continue where it stopped\n", p.resume)
+		}
+		return ctx.Err()
+	}
+	return nil
+}
+
+// run processes the records one at a time.
+func (p *fileProcessor) run(ctx context.Context, marcFile *marc.MarcFile) error {
+	for {
+		job, err := p.next(ctx, marcFile)
+		if job == nil || err != nil {
+			return err
+		}
+		p.evaluate(job, false)
+		done, err := p.consume(job)
+		if err != nil {
+			return err
+		}
+		if err := p.processed(job); done || err != nil {
+			return err
+		}
+	}
+}
+
+// runConcurrently evaluates (matches and renders) the records in a pool
+// of worker goroutines and then writes them in the order they were read.
+func (p *fileProcessor) runConcurrently(ctx context.Context, marcFile *marc.MarcFile) error {
+	ctx, cancel := context.WithCancel(ctx)
+	readerDone := make(chan struct{})
+	defer func() {
+		// Make sure we are done with the file before returning.
+		cancel()
+		<-readerDone
+	}()
+
+	jobs := make(chan *recordJob, p.params.workers*2)
+	results := make(chan *recordJob, p.params.workers*2)
+
+	var readErr error
+	go func() {
+		defer close(readerDone)
+		defer close(jobs)
+		for {
+			job, err := p.next(ctx, marcFile)
+			if job == nil || err != nil {
+				readErr = err
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	_, render := p.processor.(recordRenderer)
+	var wg sync.WaitGroup
+	for n := 0; n < p.params.workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				p.evaluate(job, render)
+				select {
+				case results <- job:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results arrive in any order, hold them until it's their turn.
+	pending := map[int]*recordJob{}
+	seq := 0
+	for job := range results {
+		pending[job.seq] = job
+		for {
+			next, ok := pending[seq]
+			if !ok {
+				break
+			}
+			delete(pending, seq)
+			seq++
+			done, err := p.consume(next)
+			if err != nil {
+				return err
+			}
+			if err := p.processed(next); done || err != nil {
+				return err
+			}
+		}
+	}
+	<-readerDone
+	return readErr
+}
+
+// next returns the next record to evaluate, nil when there are no more
+// records to read.
+func (p *fileProcessor) next(ctx context.Context, marcFile *marc.MarcFile) (*recordJob, error) {
+	started := p.params.metrics.since()
+	r, err := marcFile.NextContext(ctx)
+	if err == io.EOF || ctx.Err() != nil {
+		return nil, nil
+	}
+	p.params.metrics.addRead(started)
+	if err != nil && marcFile.Err() != nil {
+		// Error reading the file, not much we can do.
+		return nil, err
+	}
+	end := marcFile.Offset()
+	if end >= 0 {
+		end += p.params.resumeFrom
+	}
+	r.Pos += p.params.resumeFrom
+	job := &recordJob{seq: p.seq, r: r, parseErr: err, end: end}
+	p.seq++
+	return job, nil
+}
+
+// evaluate checks if the record matches the search criteria and,
+// optionally, renders it.
+func (p *fileProcessor) evaluate(job *recordJob, render bool) {
+	if job.parseErr != nil {
+		return
+	}
+	defer p.params.metrics.addMatch(p.params.metrics.since())
+	job.matched = p.params.plan.Match(job.r)
+	if job.matched && p.params.fixUTF8 {
+		var err error
+		if job.r, job.invalidUTF8, err = job.r.FixUTF8(p.params.utf8Policy); err != nil {
+			job.renderErr = err
+			job.rendered = true
+			return
+		}
+	}
+	if job.matched && p.params.highlight != nil {
+		job.r = p.params.highlight.record(job.r)
+	}
+	if job.matched && render {
+		job.output, job.renderErr = p.processor.(recordRenderer).RenderRecord(job.r)
+		job.rendered = true
+	}
+}
+
+// consume outputs an evaluated record. It returns true when no more
+// records need to be processed.
+func (p *fileProcessor) consume(job *recordJob) (bool, error) {
+	if job.parseErr != nil {
+		p.failed++
+		if p.params.errorLog != nil {
+			p.params.report.recordError(job.r, "PARSE ERROR", job.parseErr, true)
+			return false, p.params.errorLog.write(job.r, job.parseErr)
+		}
+		if p.params.skipErrors {
+			p.params.report.recordError(job.r, "PARSE ERROR", job.parseErr, true)
+			logSkippedRecord(job.r, job.parseErr)
+			return false, nil
+		}
+		p.params.report.recordError(job.r, "PARSE ERROR", job.parseErr, p.params.debug)
+		printError(p.errorOutput(), job.r, "PARSE ERROR", job.parseErr)
+		if p.params.debug {
+			return false, nil
+		}
+		return true, job.parseErr
+	}
+	// Counted here rather than when the record is read so that, with
+	// -workers, the records queued but not written yet are not counted
+	// (e.g. in the checkpoints).
+	p.read++
+	if len(job.r.Warnings) > 0 {
+		p.params.report.recordWarnings(job.r)
+		logWarnings(job.r)
+	}
+	p.params.duplicates.check(job.r, p.params.report)
+
+	if !job.matched {
+		return false, nil
+	}
+	p.matched++
+	if job.invalidUTF8 > 0 {
+		p.params.report.recordInvalidUTF8(job.r, job.invalidUTF8)
+	}
+
+	started := p.params.metrics.since()
+	if p.params.positions && !job.rendered {
+		// Render the record first to know whether it is output.
+		if renderer, ok := p.processor.(recordRenderer); ok {
+			job.output, job.renderErr = renderer.RenderRecord(job.r)
+			job.rendered = true
+		}
+	}
+	var err error
+	if job.rendered {
+		err = job.renderErr
+		if err == nil && p.params.positions {
+			_, err = fmt.Fprintf(p.w, "# record %d, byte %d\n", p.recordNumber(job), job.r.Pos)
+		}
+		if err == nil {
+			err = p.processor.(recordRenderer).WriteRendered(p.w, job.output)
+		}
+	} else {
+		err = p.processor.ProcessRecord(p.w, job.r)
+	}
+	p.params.metrics.addWrite(started)
+	if err == errRecordSkipped {
+		return false, nil
+	}
+	if err != nil {
+		var fatal fatalError
+		keepGoing := p.params.debug && !errors.As(err, &fatal)
+		p.params.report.recordError(job.r, "PROCESSING ERROR", err, keepGoing)
+		printError(p.errorOutput(), job.r, "PROCESSING ERROR", err)
+		if keepGoing {
+			return false, nil
+		}
+		return true, err
+	}
+
+	p.written++
+	return p.written == p.params.count, nil
+}
+
+// errorOutput returns where the records with errors are printed, see
+// printError.
+func (p *fileProcessor) errorOutput() io.Writer {
+	if p.params.errorOutput != nil {
+		return p.params.errorOutput
+	}
+	return p.w
+}
+
+// recordNumber returns the number of the record in the file (1-based),
+// counted from -resume-from if given.
+func (p *fileProcessor) recordNumber(job *recordJob) int {
+	if job.seq < len(p.params.numbers) {
+		return p.params.numbers[job.seq]
+	}
+	start := p.params.start
+	if start < 1 {
+		start = 1
+	}
+	return start + job.seq
+}
+
+// processed keeps track of the position to resume from once a record
+// has been processed and saves a checkpoint when it is time to do so.
+func (p *fileProcessor) processed(job *recordJob) error {
+	p.resume = job.end
+	if p.params.checkpoint == "" {
+		return nil
+	}
+	if job.end < 0 {
+		return errors.New("checkpoints are not supported for this format")
+	}
+	if p.sinceCheckpoint++; p.sinceCheckpoint < p.params.checkpointEvery {
+		return nil
+	}
+	p.sinceCheckpoint = 0
+	return p.saveCheckpoint(false)
+}
+
+func (p *fileProcessor) saveCheckpoint(completed bool) error {
+	// The records in the checkpoint must be in the output already.
+	if err := p.flush(); err != nil {
+		return err
+	}
+	cp := checkpoint{
+		File:           p.params.filename,
+		Offset:         p.resume,
+		RecordsRead:    p.read,
+		RecordsWritten: p.written,
+		Completed:      completed,
+	}
+	return cp.write(p.params.checkpoint)
+}
+
+// flush writes the output that is still in the buffer.
+func (p *fileProcessor) flush() error {
+	if p.out == nil {
+		return nil
+	}
+	return p.out.Flush()
+}
+
+// updateReport adds the totals for the file to the run report.
+func (p *fileProcessor) updateReport() {
+	report := p.params.report
+	if report == nil {
+		return
+	}
+	report.Files = append(report.Files, p.params.filename)
+	report.RecordsRead += p.read + p.failed
+	report.RecordsMatched += p.matched
+	report.RecordsWritten += p.written
+}
+
+// openMarcFile opens the file to process, the returned io.Closer must
+// be closed once the records are no longer needed.
+func openMarcFile(params ProcessFileParams) (marc.MarcFile, io.Closer, error) {
+	marcFile, closer, err := openReader(params)
+	if err != nil {
+		return marc.MarcFile{}, nil, err
+	}
+	marcFile.SetTags(params.tags)
+	if params.maxRecordSize > 0 {
+		marcFile.SetMaxRecordSize(params.maxRecordSize)
+	}
+	marcFile.SetParseMode(params.parseMode)
+	return marcFile, closer, nil
+}
+
+// openReader opens the file with the reader indicated in params.
+func openReader(params ProcessFileParams) (marc.MarcFile, io.Closer, error) {
+	if params.offsets != nil {
+		file, err := os.Open(params.filename)
+		if err != nil {
+			return marc.MarcFile{}, nil, err
+		}
+		reader := &offsetReader{file: file, offsets: params.offsets}
+		return marc.NewMarcFileFromReader(reader), file, nil
+	}
+
+	if params.mmap && params.filename != "-" {
+		m, err := marc.OpenMmap(params.filename)
+		if err != nil {
+			return marc.MarcFile{}, nil, err
+		}
+		data := m.Bytes()
+		if params.resumeFrom > int64(len(data)) {
+			m.Close()
+			return marc.MarcFile{}, nil, fmt.Errorf("cannot resume from %d, the file has %d bytes", params.resumeFrom, len(data))
+		}
+		data = data[params.resumeFrom:]
+		params.metrics.addBytes(len(data))
+		return marc.NewMarcFileBytes(data), m, nil
+	}
+
+	var file io.ReadCloser
+	if params.input != nil {
+		file = io.NopCloser(params.input)
+	} else {
+		var err error
+		if file, err = openInput(params.filename); err != nil {
+			return marc.MarcFile{}, nil, err
+		}
+	}
+	if err := skipTo(file, params.resumeFrom); err != nil {
+		file.Close()
+		return marc.MarcFile{}, nil, err
+	}
+	if params.metrics != nil {
+		file = countingReader{ReadCloser: file, metrics: params.metrics}
+	}
+	if params.workers > 1 {
+		// Parse the records in parallel too.
+		marcFile := marc.NewMarcFileParallel(file, params.workers)
+		return marcFile, closers{&marcFile, file}, nil
+	}
+	return marc.NewMarcFile(file), file, nil
+}
+
+// closers closes several things in order.
+type closers []io.Closer
+
+func (cs closers) Close() error {
+	var err error
+	for _, c := range cs {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// openInput opens the file to process, "-" means stdin.
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filename)
+}
+
+// logSkippedRecord reports to stderr a record that could not be parsed.
+func logSkippedRecord(r marc.Record, err error) {
+	fmt.Fprintf(os.Stderr, "Skipped record at byte %d: %s\n", r.Pos, err)
+	if len(r.Data) > 0 {
+		fmt.Fprintf(os.Stderr, "%q\n", r.Data)
+	}
+}
+
+// logWarnings logs to stderr the problems fixed when parsing a record
+// (see -lenient, -repair, -keep-empty-subfields, and -drop-empty-fields).
+func logWarnings(r marc.Record) {
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: record at byte %d: %s\n", r.Pos, warning)
+	}
+}
+
+func printError(w io.Writer, r marc.Record, errType string, err error) {
+	str := "== RECORD WITH ERROR STARTS HERE\n"
+	str += fmt.Sprintf("%s:\n%s\n", errType, err.Error())
+	str += r.DebugString() + "\n"
+	str += "== RECORD WITH ERROR ENDS HERE\n\n"
+	fmt.Fprint(w, str)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// TestCheckpointWithWorkers checks that the checkpoints saved with
+// -workers count the records written, not the ones read ahead by the
+// workers. Run with -race to check the access to the counters.
+func TestCheckpointWithWorkers(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	params := ProcessFileParams{
+		filename:        writeGenerated(t, dir, "bib", 200),
+		start:           1,
+		count:           50,
+		workers:         4,
+		plan:            marc.FilterSpec{}.Compile(),
+		checkpoint:      filepath.Join(dir, "cp.json"),
+		checkpointEvery: 1,
+	}
+	if err := processFile(context.Background(), params, NewProcessorMrc(params), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(params.checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		t.Fatal(err)
+	}
+	if cp.RecordsRead != 50 || cp.RecordsWritten != 50 {
+		t.Errorf("expected 50 records read and written, got %+v", cp)
+	}
+
+	// The offset is right after the last record written.
+	data, err := os.ReadFile(params.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marcFile := marc.NewMarcFileBytes(data)
+	for i := 0; i < 50; i++ {
+		if _, err := marcFile.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cp.Offset != marcFile.Offset() {
+		t.Errorf("expected the offset %d, got %d", marcFile.Offset(), cp.Offset)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// itemsProfiles are the settings of the items format for the item
+// fields exported by each ILS, selected with -profile. The profiles in
+// the configuration file (under profiles) replace the settings of the
+// built-in ones with the same name or add new ones, e.g.
+//
+//	profiles:
+//	  alma:
+//	    field: "950"
+//	    columns: bib:bib,barcode:$b,library:$c,callnumber
+var itemsProfiles = map[string]map[string]string{
+	// Sierra (and Millennium) item fields, the defaults of the items
+	// format.
+	"sierra": {
+		"bib-id":     "907a",
+		"field":      "945",
+		"callnumber": "$a$b,099a,090ab,050ab",
+		"columns":    "bib:bib,callnumber,barcode:$i,location:$l,item:$y",
+	},
+	// Koha items (952) with the biblionumber in 999 $c.
+	"koha": {
+		"bib-id":     "999c",
+		"field":      "952",
+		"callnumber": "$o,082a,050ab",
+		"columns":    "bib:bib,callnumber,barcode:$p,library:$a,location:$c,itemtype:$y,item:$9",
+	},
+	// Items added to the records by an Alma publishing profile (in ITM)
+	// with the MMS ID in the 001.
+	"alma": {
+		"bib-id":     "001",
+		"field":      "ITM",
+		"callnumber": "$h$i,090ab,050ab",
+		"columns":    "bib:bib,callnumber,barcode:$b,library:$l,location:$c,item:$a",
+	},
+	// Voyager exports with the holdings (852) and items (876) embedded
+	// in the bibliographic records.
+	"voyager": {
+		"bib-id":     "001",
+		"field":      "876",
+		"callnumber": "852hi,090ab,050ab",
+		"columns":    "bib:bib,callnumber,barcode:$p,location:852b,copy:$t,item:$a",
+	},
+	// Records exported from FOLIO (source record storage) with the
+	// instance UUID in 999 $i, and the holdings and items mapped to 852
+	// and 876 in the data export mapping profile.
+	"folio": {
+		"bib-id":     "999i",
+		"field":      "876",
+		"callnumber": "852hi,090ab,050ab",
+		"columns":    "bib:bib,callnumber,barcode:$p,location:852b,copy:$t,item:$a",
+	},
+}
+
+// profileSettings returns the settings of the profile as flags of the
+// items format (e.g. items.field) and selects the items format.
+func profileSettings(name string, custom map[string]map[string]string) (map[string]string, error) {
+	builtin, isBuiltin := itemsProfiles[name]
+	values, isCustom := custom[name]
+	if !isBuiltin && !isCustom {
+		return nil, fmt.Errorf("profile %q not found, accepted values: %s", name, strings.Join(profileNames(custom), ", "))
+	}
+	settings := map[string]string{"format": "items"}
+	for _, profile := range []map[string]string{builtin, values} {
+		for setting, value := range profile {
+			settings["items."+strings.TrimPrefix(setting, "items.")] = value
+		}
+	}
+	return settings, nil
+}
+
+// profileNames returns the names of the built-in profiles and of the
+// profiles in the configuration file, sorted.
+func profileNames(custom map[string]map[string]string) []string {
+	names := []string{}
+	for name := range itemsProfiles {
+		names = append(names, name)
+	}
+	for name := range custom {
+		if _, ok := itemsProfiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
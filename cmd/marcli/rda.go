@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "rda",
+		description: "Report the combinations of content, media, and carrier types (336/337/338) and the GMDs (245 $h), and the records without 33X fields",
+		flags:       []string{"top", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runRDA,
+	})
+}
+
+func runRDA(ctx context.Context) error {
+	processor := &rdaProcessor{
+		combinations: map[string]int{},
+		gmds:         map[string]int{},
+		status:       map[string]int{},
+		top:          overviewTop,
+	}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// rdaTags are the fields with the content, media, and carrier types.
+var rdaTags = []string{"336", "337", "338"}
+
+// rdaProcessor counts the combinations of 33X values and GMDs, and
+// keeps the records that do not have all the 33X fields.
+type rdaProcessor struct {
+	total        int
+	combinations map[string]int
+	gmds         map[string]int
+	status       map[string]int
+	missing      map[string]int
+	// incomplete are the records without some of the 33X fields, only
+	// the first top are kept.
+	incomplete      []string
+	incompleteCount int
+	top             int
+}
+
+func (p *rdaProcessor) Tags() []string {
+	return append([]string{"245"}, rdaTags...)
+}
+
+func (p *rdaProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *rdaProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.total++
+	types := []string{}
+	missing := []string{}
+	for _, tag := range rdaTags {
+		values := rdaTypes(r.Fields.GetAll(tag))
+		if len(values) == 0 {
+			missing = append(missing, tag)
+			values = []string{"-"}
+		}
+		types = append(types, strings.Join(values, "; "))
+	}
+	gmd := rdaGMD(r)
+
+	has33X := len(missing) < len(rdaTags)
+	switch {
+	case has33X && gmd != "":
+		p.status["33X and GMD"]++
+	case has33X:
+		p.status["33X only"]++
+	case gmd != "":
+		p.status["GMD only"]++
+	default:
+		p.status["Neither 33X nor GMD"]++
+	}
+	if has33X {
+		p.combinations[strings.Join(types, " / ")]++
+	}
+	if gmd != "" {
+		p.gmds[gmd]++
+	}
+	if len(missing) > 0 {
+		if p.missing == nil {
+			p.missing = map[string]int{}
+		}
+		for _, tag := range missing {
+			p.missing["No "+tag]++
+		}
+		p.incompleteCount++
+		if p.top == 0 || len(p.incomplete) < p.top {
+			id := r.ControlNum()
+			if id == "" {
+				id = fmt.Sprintf("record at byte %d", r.Pos)
+			}
+			p.incomplete = append(p.incomplete, fmt.Sprintf("%s: no %s", id, strings.Join(missing, ", ")))
+		}
+	}
+	return nil
+}
+
+func (p *rdaProcessor) Footer(w io.Writer) error {
+	str := fmt.Sprintf("Records: %d\n", p.total)
+	sections := []overviewSection{
+		topCounts("RDA status", p.status, p.total, 0),
+		topCounts("Content / media / carrier types (336 / 337 / 338)", p.combinations, p.total, p.top),
+		topCounts("General material designations (245 $h)", p.gmds, p.total, p.top),
+	}
+	if p.missing != nil {
+		sections = append(sections, sortedSection("Missing 33X fields", p.missing, p.total))
+	}
+	for _, section := range sections {
+		if len(section.Counts) > 0 {
+			str += "\n" + section.String()
+		}
+	}
+	if len(p.incomplete) > 0 {
+		str += "\nRecords missing 33X fields\n"
+		for _, record := range p.incomplete {
+			str += "  " + record + "\n"
+		}
+		if more := p.incompleteCount - len(p.incomplete); more > 0 {
+			str += fmt.Sprintf("  (%d more records)\n", more)
+		}
+	}
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// sortedSection returns the values in alphabetical order.
+func sortedSection(title string, counts map[string]int, total int) overviewSection {
+	return overviewSection{Title: title, Counts: sortedCounts(counts, total)}
+}
+
+// rdaTypes returns the terms ($a) of the 33X fields, or their codes ($b)
+// when there are no terms.
+func rdaTypes(fields []marc.Field) []string {
+	values := []string{}
+	for _, field := range fields {
+		terms := field.SubFieldValues("a")
+		if len(terms) == 0 {
+			terms = field.SubFieldValues("b")
+		}
+		for _, term := range terms {
+			if term = strings.TrimSpace(term); term != "" {
+				values = append(values, term)
+			}
+		}
+	}
+	return values
+}
+
+// rdaGMD returns the general material designation in the 245 $h without
+// the brackets and the punctuation, e.g. "electronic resource" for
+// "[electronic resource] /".
+func rdaGMD(r marc.Record) string {
+	gmd := r.GetValue("245", "h")
+	gmd = strings.TrimRight(strings.TrimSpace(gmd), " /:;=.,")
+	return strings.ToLower(strings.Trim(gmd, "[] "))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "redact",
+		description: "Blank or hash the fields with private data (e.g. patron notes, barcodes, prices) to share the records",
+		flags:       []string{"blank", "hash", "hash-key", "dry-run", "format", "start", "count", "skip-errors", "error-file", "buffer-size", "invalid-utf8"},
+		formatFlags: true,
+		defaults:    map[string]string{"format": "mrc"},
+		run:         runRedact,
+	})
+}
+
+// redactHashLength is the number of hex characters of the hashes.
+const redactHashLength = 16
+
+func runRedact(ctx context.Context) error {
+	params := fileParams()
+	redactor := redactProcessor{
+		blank:  marc.NewFieldFilters(blankFields),
+		hash:   marc.NewFieldFilters(hashFields),
+		dryRun: dryRun,
+	}
+	if len(redactor.blank.Fields) == 0 && len(redactor.hash.Fields) == 0 {
+		return errors.New("no fields to redact, use the blank or hash parameters")
+	}
+	for _, filter := range append(redactor.blank.Fields, redactor.hash.Fields...) {
+		if filter.Occurrence != 0 {
+			return fmt.Errorf("invalid field %q, all the occurrences of the fields are redacted", filter)
+		}
+	}
+	for _, filter := range redactor.hash.Fields {
+		for _, tag := range []string{"006", "007", "008"} {
+			if tagMatches(filter.Tag, tag) {
+				return fmt.Errorf("cannot hash %s, the fixed fields can only be blanked", tag)
+			}
+		}
+	}
+
+	redactor.key = []byte(hashKey)
+	if hashKey == "" {
+		// A different key in each run, the hashes can only be matched
+		// within the output.
+		redactor.key = make([]byte, 32)
+		if _, err := rand.Read(redactor.key); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	redactor.output, err = newProcessor(format, params)
+	if err != nil {
+		return err
+	}
+	return processFile(ctx, params, redactor, os.Stdout)
+}
+
+// redactProcessor redacts the fields of each record and passes the
+// result to the output processor. In dry-run mode it outputs the
+// differences between the original and the redacted records instead.
+type redactProcessor struct {
+	output Processor
+	blank  marc.FieldFilters
+	hash   marc.FieldFilters
+	key    []byte
+	dryRun bool
+}
+
+func (p redactProcessor) Header(w io.Writer) error {
+	if p.dryRun {
+		return nil
+	}
+	return p.output.Header(w)
+}
+
+func (p redactProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	redacted := p.redact(r)
+	if !p.dryRun {
+		return p.output.ProcessRecord(w, redacted)
+	}
+
+	diff := marc.Diff(r, redacted)
+	if diff == "" {
+		return errRecordSkipped
+	}
+	_, err := io.WriteString(w, diff+"\n")
+	return err
+}
+
+func (p redactProcessor) Footer(w io.Writer) error {
+	if p.dryRun {
+		return nil
+	}
+	return p.output.Footer(w)
+}
+
+// redact returns a copy of the record with the values of the fields to
+// hash replaced with their hash, and the fields to blank blanked: the
+// control fields are filled with blanks (keeping their length, so the
+// fixed fields are still valid) and the subfields are removed, as are
+// the data fields left without subfields.
+func (p redactProcessor) redact(r marc.Record) marc.Record {
+	redacted := r.Clone()
+	fields := redacted.Fields[:0]
+	for _, field := range redacted.Fields {
+		if subfields, ok := redactSubfields(p.hash, field); ok {
+			if field.IsControlField() {
+				field.Value = p.hashValue(field.Value)
+			}
+			for i, sub := range field.SubFields {
+				if subfields == "" || strings.Contains(subfields, sub.Code) {
+					field.SubFields[i].Value = p.hashValue(sub.Value)
+				}
+			}
+		}
+		if subfields, ok := redactSubfields(p.blank, field); ok {
+			if field.IsControlField() {
+				field.Value = strings.Repeat(" ", len(field.Value))
+			} else if subfields == "" {
+				continue
+			} else {
+				kept := field.SubFields[:0]
+				for _, sub := range field.SubFields {
+					if !strings.Contains(subfields, sub.Code) {
+						kept = append(kept, sub)
+					}
+				}
+				if len(kept) == 0 {
+					continue
+				}
+				field.SubFields = kept
+			}
+		}
+		fields = append(fields, field)
+	}
+	redacted.Fields = fields
+	// The original bytes must not be output.
+	redacted.Data = nil
+	return redacted
+}
+
+// hashValue returns the first characters of the HMAC of the value, the
+// same values get the same hash so that they can still be matched
+// (e.g. the records with the same barcode).
+func (p redactProcessor) hashValue(value string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:redactHashLength]
+}
+
+// redactSubfields returns the subfields of the field to redact (empty
+// for all of them) if one of the filters matches the field. Unlike in
+// other commands the tags can have X for any digit, e.g. 9XX.
+func redactSubfields(filters marc.FieldFilters, field marc.Field) (string, bool) {
+	subfields, found := "", false
+	for _, filter := range filters.Fields {
+		if !tagMatches(filter.Tag, field.Tag) {
+			continue
+		}
+		filter.Tag = field.Tag
+		if !filter.Matches(field) {
+			continue
+		}
+		if filter.Subfields == "" || field.IsControlField() {
+			return "", true
+		}
+		subfields += filter.Subfields
+		found = true
+	}
+	return subfields, found
+}
+
+// tagMatches returns true if the tag matches the pattern, where X (or x)
+// is any character.
+func tagMatches(pattern, tag string) bool {
+	if len(pattern) != len(tag) {
+		return false
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != tag[i] && pattern[i] != 'X' && pattern[i] != 'x' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// processorFormat describes an output format. Each format registers
+// itself (usually in the init() of its own file) so that adding a new
+// format does not require changes anywhere else.
+type processorFormat struct {
+	name        string
+	description string
+	// contentType is the media type of the output (see marcli serve),
+	// plain text when empty.
+	contentType string
+	// newProcessor creates the processor for the given parameters.
+	newProcessor func(params ProcessFileParams) (Processor, error)
+	// setFlags (optional) defines the flags that are specific
+	// to this format. The flags are registered with the name of the
+	// format as a prefix, e.g. "indent" in the XML format is given in
+	// the command line as -xml.indent.
+	setFlags func(fs *flag.FlagSet)
+}
+
+var processorFormats = map[string]processorFormat{}
+
+func registerFormat(format processorFormat) {
+	if _, ok := processorFormats[format.name]; ok {
+		panic(fmt.Sprintf("format %s registered twice", format.name))
+	}
+	processorFormats[format.name] = format
+}
+
+// formatNames returns the names of the registered formats, sorted.
+func formatNames() []string {
+	names := []string{}
+	for name := range processorFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setFormatFlags defines the flags of all the registered formats,
+// each under the prefix of its format.
+func setFormatFlags(fs *flag.FlagSet) {
+	for _, name := range formatNames() {
+		formatFlags(name).VisitAll(func(f *flag.Flag) {
+			fs.Var(f.Value, name+"."+f.Name, f.Usage)
+		})
+	}
+}
+
+// formatFlags returns the flags of a format (without the prefix).
+func formatFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if format := processorFormats[name]; format.setFlags != nil {
+		format.setFlags(fs)
+	}
+	return fs
+}
+
+func newProcessor(name string, params ProcessFileParams) (Processor, error) {
+	format, ok := processorFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid format %q, accepted values: %s", name, strings.Join(formatNames(), ", "))
+	}
+	return format.newProcessor(params)
+}
+
+// formatsHelp returns the description of the registered formats
+// and of their flags.
+func formatsHelp() string {
+	str := ""
+	for _, name := range formatNames() {
+		str += fmt.Sprintf("\t%-6s %s\n", name, processorFormats[name].description)
+		formatFlags(name).VisitAll(func(f *flag.Flag) {
+			str += fmt.Sprintf("\t         -%s.%s  %s\n", name, f.Name, f.Usage)
+		})
+	}
+	return str
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "repair",
+		description: "Fix the structural problems of MARC binary records and report what was changed in each record",
+		flags:       []string{"o", "dry-run", "skip-errors", "error-file"},
+		run:         runRepair,
+	})
+}
+
+// repairStats are the totals reported at the end of the repair.
+type repairStats struct {
+	records  int
+	repaired int
+	failed   int
+}
+
+// runRepair repairs each record of the file (see marc.Repair) and
+// writes them to -o, the records without problems are written as they
+// are. The changes made to each record are reported to stderr, with
+// -dry-run only the report is output.
+func runRepair(ctx context.Context) error {
+	marcFile, file, err := openMarcFile(ProcessFileParams{filename: fileName, mmap: useMmap, maxRecordSize: maxRecordSize, parseMode: parseMode()})
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var out *outputFile
+	if !dryRun {
+		if out, err = createOutput(outFile); err != nil {
+			return err
+		}
+		defer out.remove()
+	}
+
+	stats := repairStats{}
+	for {
+		r, err := marcFile.NextContext(ctx)
+		if err == io.EOF {
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && marcFile.Err() != nil {
+			return err
+		}
+		stats.records++
+
+		// The records are repaired from their bytes, whether the reader
+		// could parse them or not. Only the last record of a truncated
+		// file has no record terminator.
+		data := r.Data
+		if !errors.Is(err, marc.ErrTruncated) {
+			data = r.Raw()
+		}
+		repaired, err := marc.Repair(data, parseMode())
+		if err != nil {
+			stats.failed++
+			if !(skipErrors || errorRecords != nil) {
+				return fmt.Errorf("record %d at byte %d: %w", stats.records, r.Pos, err)
+			}
+			if errorRecords != nil {
+				if err := errorRecords.write(r, err); err != nil {
+					return err
+				}
+				continue
+			}
+			logSkippedRecord(r, err)
+			continue
+		}
+
+		if len(repaired.Changes) > 0 {
+			stats.repaired++
+			fmt.Fprintf(os.Stderr, "Record %d at byte %d (001 %q):\n", stats.records, r.Pos, repaired.Record.ControlNum())
+			for _, change := range repaired.Changes {
+				fmt.Fprintf(os.Stderr, "  %s\n", change)
+			}
+		}
+		if out != nil {
+			if _, err := out.Write(repaired.Bytes); err != nil {
+				return err
+			}
+		}
+	}
+
+	if out != nil {
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d records, %d repaired, %d could not be repaired\n", stats.records, stats.repaired, stats.failed)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// runReport is the summary of a run written to the file indicated
+// in the -report parameter, e.g. for auditing in data pipelines.
+type runReport struct {
+	Files          []string      `json:"files"`
+	RecordsRead    int           `json:"records_read"`
+	RecordsMatched int           `json:"records_matched"`
+	RecordsWritten int           `json:"records_written"`
+	RecordsSkipped int           `json:"records_skipped"`
+	Errors         []reportError `json:"errors"`
+	Warnings       []reportError `json:"warnings,omitempty"`
+	InvalidUTF8    []reportCount `json:"invalid_utf8,omitempty"`
+	Elapsed        float64       `json:"elapsed_seconds"`
+	Completed      bool          `json:"completed"`
+	Error          string        `json:"error,omitempty"`
+	started        time.Time
+}
+
+// reportError is a record that could not be parsed or processed, or
+// a problem fixed when parsing a record (see -lenient).
+type reportError struct {
+	Position  int64  `json:"position"`
+	ControlNo string `json:"control_number,omitempty"`
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+}
+
+// reportCount is the number of times something was found in a record,
+// e.g. the invalid UTF-8 bytes handled (see -invalid-utf8).
+type reportCount struct {
+	Position  int64  `json:"position"`
+	ControlNo string `json:"control_number,omitempty"`
+	Count     int    `json:"count"`
+}
+
+func newRunReport() *runReport {
+	return &runReport{Files: []string{}, Errors: []reportError{}, started: time.Now()}
+}
+
+// recordError adds a record with errors to the report. It is safe
+// to call on a nil report.
+func (rr *runReport) recordError(r marc.Record, errType string, err error, skipped bool) {
+	if rr == nil {
+		return
+	}
+	if skipped {
+		rr.RecordsSkipped++
+	}
+	rr.Errors = append(rr.Errors, reportError{
+		Position:  r.Pos,
+		ControlNo: r.ControlNum(),
+		Type:      errType,
+		Reason:    err.Error(),
+	})
+}
+
+// recordWarnings adds the warnings of a record to the report. It is
+// safe to call on a nil report.
+func (rr *runReport) recordWarnings(r marc.Record) {
+	if rr == nil {
+		return
+	}
+	for _, warning := range r.Warnings {
+		rr.Warnings = append(rr.Warnings, reportError{
+			Position:  r.Pos,
+			ControlNo: r.ControlNum(),
+			Type:      "WARNING",
+			Reason:    warning.String(),
+		})
+	}
+}
+
+// recordInvalidUTF8 adds to the report the number of invalid UTF-8
+// bytes handled in a record. It is safe to call on a nil report.
+func (rr *runReport) recordInvalidUTF8(r marc.Record, count int) {
+	if rr == nil {
+		return
+	}
+	rr.InvalidUTF8 = append(rr.InvalidUTF8, reportCount{Position: r.Pos, ControlNo: r.ControlNum(), Count: count})
+}
+
+// recordWarning adds a warning about a record to the report. It is
+// safe to call on a nil report.
+func (rr *runReport) recordWarning(r marc.Record, warnType string, msg string) {
+	if rr == nil {
+		return
+	}
+	rr.Warnings = append(rr.Warnings, reportError{
+		Position:  r.Pos,
+		ControlNo: r.ControlNum(),
+		Type:      warnType,
+		Reason:    msg,
+	})
+}
+
+// write saves the report to a file. err is the error that stopped
+// the run, if any.
+func (rr *runReport) write(filename string, err error) error {
+	rr.Elapsed = time.Since(rr.started).Seconds()
+	rr.Completed = err == nil
+	if err != nil {
+		rr.Error = err.Error()
+	}
+
+	b, err := json.MarshalIndent(rr, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0644)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "series",
+		description: "Check the series statements (490) against the series added entries (8XX) and report the distinct series with their counts",
+		flags:       []string{"top", "csv", "json", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runSeries,
+	})
+}
+
+func runSeries(ctx context.Context) error {
+	if reportCSV && reportJSON {
+		return errors.New("use either -csv or -json")
+	}
+	processor := &seriesProcessor{
+		statements: map[string]*heading{},
+		entries:    map[string]*heading{},
+		problems:   map[string]int{},
+		top:        overviewTop,
+	}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// seriesTags are the series added entries.
+var seriesTags = []string{"800", "810", "811", "830"}
+
+// seriesProblem is a record whose series statements and added entries
+// do not agree.
+type seriesProblem struct {
+	Record  string `json:"record"`
+	Problem string `json:"problem"`
+	Series  string `json:"series"`
+}
+
+// seriesProcessor counts the series in the 490 and in the 8XX (the same
+// series with different case or punctuation is counted once, see
+// headingsProcessor) and checks each record:
+//
+//   - a 490 with the first indicator 1 (series traced) must have an 8XX,
+//   - a record with 8XX should not only have 490 with the first
+//     indicator 0 (series not traced), or no 490,
+//   - the obsolete 440 should be replaced with a 490 and an 830.
+type seriesProcessor struct {
+	records    int
+	statements map[string]*heading
+	entries    map[string]*heading
+	problems   map[string]int
+	// list are the first top records with problems.
+	list      []seriesProblem
+	listCount int
+	top       int
+}
+
+func (p *seriesProcessor) Tags() []string {
+	return append([]string{"001", "440", "490"}, seriesTags...)
+}
+
+func (p *seriesProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *seriesProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.records++
+	traced, untraced := []string{}, []string{}
+	for _, field := range r.Fields.GetAll("490") {
+		title := trimHeading(strings.Join(field.SubFieldValues("a"), " "))
+		if title == "" {
+			continue
+		}
+		countSeries(p.statements, "490", title)
+		if field.Indicator1 == "1" {
+			traced = append(traced, title)
+		} else {
+			untraced = append(untraced, title)
+		}
+	}
+	entries := []string{}
+	for _, field := range r.Fields {
+		if !isSeriesTag(field.Tag) {
+			continue
+		}
+		if title := headingValue(field); title != "" {
+			countSeries(p.entries, field.Tag, title)
+			entries = append(entries, title)
+		}
+	}
+
+	obsolete := []string{}
+	for _, field := range r.Fields.GetAll("440") {
+		if title := trimHeading(strings.Join(field.SubFieldValues("a"), " ")); title != "" {
+			countSeries(p.statements, "440", title)
+			obsolete = append(obsolete, title)
+		}
+	}
+	if len(obsolete) > 0 {
+		p.problem(r, "440 (obsolete, now 490 and 830)", obsolete)
+		return nil
+	}
+	switch {
+	case len(traced) > 0 && len(entries) == 0:
+		p.problem(r, "490 traced (ind1 1) without 8XX", traced)
+	case len(traced) > len(entries):
+		p.problem(r, "Fewer 8XX than traced 490", traced)
+	case len(entries) > 0 && len(traced) == 0 && len(untraced) > 0:
+		p.problem(r, "8XX with the 490 not traced (ind1 0)", untraced)
+	case len(entries) > 0 && len(traced) == 0:
+		p.problem(r, "8XX without 490", entries)
+	}
+	return nil
+}
+
+func (p *seriesProcessor) problem(r marc.Record, problem string, series []string) {
+	p.problems[problem]++
+	p.listCount++
+	if p.top > 0 && len(p.list) >= p.top {
+		return
+	}
+	id := r.ControlNum()
+	if id == "" {
+		id = fmt.Sprintf("record at byte %d", r.Pos)
+	}
+	p.list = append(p.list, seriesProblem{Record: id, Problem: problem, Series: strings.Join(series, "; ")})
+}
+
+func isSeriesTag(tag string) bool {
+	for _, t := range seriesTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// countSeries counts the series in the tag, the first form found is
+// kept.
+func countSeries(counts map[string]*heading, tag string, title string) {
+	key := marc.SortKey(title)
+	h, ok := counts[key]
+	if !ok {
+		h = &heading{value: title, key: key, tags: map[string]bool{}}
+		counts[key] = h
+	}
+	h.count++
+	h.tags[tag] = true
+}
+
+// seriesCount is a series with the number of times it is used.
+type seriesCount struct {
+	Source string `json:"source"`
+	Series string `json:"series"`
+	Count  int    `json:"count"`
+}
+
+// sortedSeries returns the series from the most common to the least
+// common, and in alphabetical order for the same count.
+func sortedSeries(source string, counts map[string]*heading) []seriesCount {
+	series := []seriesCount{}
+	for _, h := range counts {
+		series = append(series, seriesCount{Source: source, Series: h.value, Count: h.count})
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Count != series[j].Count {
+			return series[i].Count > series[j].Count
+		}
+		return marc.SortKey(series[i].Series) < marc.SortKey(series[j].Series)
+	})
+	return series
+}
+
+// seriesReport is the JSON output.
+type seriesReport struct {
+	Records        int             `json:"records"`
+	Problems       []overviewCount `json:"problems"`
+	ProblemRecords []seriesProblem `json:"records_with_problems"`
+	Statements     []seriesCount   `json:"series_490"`
+	Entries        []seriesCount   `json:"series_8xx"`
+}
+
+func (p *seriesProcessor) Footer(w io.Writer) error {
+	statements := sortedSeries("490/440", p.statements)
+	entries := sortedSeries("8XX", p.entries)
+	switch {
+	case reportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"source", "series", "count"})
+		for _, series := range append(statements, entries...) {
+			cw.Write([]string{series.Source, series.Series, strconv.Itoa(series.Count)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(seriesReport{
+			Records:        p.records,
+			Problems:       sortedCounts(p.problems, p.records),
+			ProblemRecords: append([]seriesProblem{}, p.list...),
+			Statements:     statements,
+			Entries:        entries,
+		})
+	}
+
+	str := fmt.Sprintf("Records: %d\n", p.records)
+	if section := sortedSection("Problems", p.problems, p.records); len(section.Counts) > 0 {
+		str += "\n" + section.String()
+	}
+	if len(p.list) > 0 {
+		str += "\nRecords with problems\n"
+		for _, problem := range p.list {
+			str += fmt.Sprintf("  %s: %s: %s\n", problem.Record, problem.Problem, problem.Series)
+		}
+		if more := p.listCount - len(p.list); more > 0 {
+			str += fmt.Sprintf("  (%d more records)\n", more)
+		}
+	}
+	str += seriesList("Series statements (490 $a and 440 $a)", statements, p.top)
+	str += seriesList("Series added entries (800, 810, 811, 830)", entries, p.top)
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// seriesList returns the first top series with their counts.
+func seriesList(title string, series []seriesCount, top int) string {
+	if len(series) == 0 {
+		return ""
+	}
+	str := "\n" + title + "\n"
+	for i, s := range series {
+		if top > 0 && i == top {
+			str += fmt.Sprintf("  (%d other series)\n", len(series)-top)
+			break
+		}
+		str += fmt.Sprintf("  %9d  %s\n", s.Count, s.Series)
+	}
+	return str
+}
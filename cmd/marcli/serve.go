@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "serve",
+		description: "Run an HTTP server to filter, convert, and validate the files posted to it",
+		flags:       []string{"port", "max-request-size", "schema", "profile"},
+		formatFlags: true,
+		noFile:      true,
+		run:         runServe,
+	})
+}
+
+// runServe serves the endpoints to process the records posted (as the
+// body of the request or as the "file" field of a multipart form) until
+// it is interrupted:
+//
+//	POST /filter    the records that match the criteria in the query string
+//	POST /convert   same as /filter
+//	POST /validate  a JSON summary of the invalid records
+//
+// The parse mode and the settings of the formats are the ones given
+// when starting the server.
+//
+// The records are processed while they are read, so the whole request
+// can take as long as serveReadTimeout and its body is limited to
+// -max-request-size bytes.
+func runServe(ctx context.Context) error {
+	var schema marc.Schema
+	if validateSchema {
+		var err error
+		if schema, err = newSchema(); err != nil {
+			return err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/filter", serveFilter)
+	mux.HandleFunc("/convert", serveFilter)
+	mux.HandleFunc("/validate", func(w http.ResponseWriter, r *http.Request) {
+		serveValidate(w, r, schema)
+	})
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           limitRequestSize(mux, maxRequestSize),
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "Listening on port %d\n", port)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Give the requests in progress some time to finish.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// Timeouts of the server, to not keep the connections of the clients
+// that stop sending data open forever.
+const (
+	serveReadHeaderTimeout = 10 * time.Second
+	serveReadTimeout       = 30 * time.Minute
+)
+
+// limitRequestSize limits the body of the requests to max bytes.
+func limitRequestSize(h http.Handler, max int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveFilter outputs the records posted that match the search criteria
+// in the query string (same names as the command line parameters, e.g.
+// ?format=json&match=wildlife&fields=245).
+func serveFilter(w http.ResponseWriter, r *http.Request) {
+	input, err := requestInput(r)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	query := r.URL.Query()
+	params, err := requestParams(query, input)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	name := query.Get("format")
+	if name == "" {
+		name = "mrk"
+	}
+	processor, err := newProcessor(name, params)
+	if err != nil {
+		httpError(w, badRequest(err))
+		return
+	}
+
+	contentType := processorFormats[name].contentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", serveErrorTrailer)
+	out := &responseOutput{w: w}
+	if err := processFile(r.Context(), params, processor, out); err != nil {
+		if !out.written {
+			w.Header().Del("Trailer")
+			httpError(w, err)
+			return
+		}
+		// Too late to change the status, the output is cut short and
+		// the error is given in the trailer.
+		w.Header().Set(serveErrorTrailer, err.Error())
+		fmt.Fprintf(os.Stderr, "Error processing %s: %s\n", r.URL, err)
+	}
+}
+
+// serveErrorTrailer is the trailer with the error that stopped the
+// processing of the records once the output had started.
+const serveErrorTrailer = "X-Marcli-Error"
+
+// validationResult is the response of /validate.
+type validationResult struct {
+	RecordsRead int              `json:"records_read"`
+	Invalid     int              `json:"invalid"`
+	Records     []recordProblems `json:"records"`
+}
+
+// recordProblems are the problems found in a record, the records
+// without problems are not included in the response.
+type recordProblems struct {
+	Record   int      `json:"record"`
+	Pos      int64    `json:"pos"`
+	ID       string   `json:"id"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// serveValidate reports the records posted that cannot be parsed or
+// are not valid, see marcli validate.
+func serveValidate(w http.ResponseWriter, r *http.Request, schema marc.Schema) {
+	input, err := requestInput(r)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	marcFile, file, err := openMarcFile(ProcessFileParams{input: input, maxRecordSize: maxRecordSize, parseMode: parseMode()})
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	defer file.Close()
+
+	result := validationResult{Records: []recordProblems{}}
+	for {
+		rec, err := marcFile.NextContext(r.Context())
+		if err == io.EOF || r.Context().Err() != nil {
+			break
+		}
+		if err != nil && marcFile.Err() != nil {
+			httpError(w, err)
+			return
+		}
+
+		result.RecordsRead++
+		if err == nil {
+			err = validateRecord(rec, schema)
+		}
+		problems := recordProblems{Record: result.RecordsRead, Pos: rec.Pos, ID: rec.ControlNum()}
+		for _, warning := range rec.Warnings {
+			problems.Warnings = append(problems.Warnings, warning.String())
+		}
+		if err != nil {
+			result.Invalid++
+			problems.Error = err.Error()
+		}
+		if err != nil || len(problems.Warnings) > 0 {
+			result.Records = append(result.Records, problems)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+}
+
+// requestInput returns the records posted in the request.
+func requestInput(r *http.Request) (io.Reader, error) {
+	if r.Method != http.MethodPost {
+		return nil, errMethodNotAllowed
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, badRequest(errors.New("missing the file field in the form"))
+		}
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// requestParams returns the parameters to process the records posted
+// from the query string of the request.
+func requestParams(query url.Values, input io.Reader) (ProcessFileParams, error) {
+	params := ProcessFileParams{
+		filename:      "request",
+		input:         input,
+		searchValue:   strings.ToLower(query.Get("match")),
+		searchFields:  searchFieldsFromString(query.Get("matchFields")),
+		filters:       marc.NewFieldFilters(query.Get("fields")),
+		exclude:       marc.NewFieldFilters(query.Get("exclude")),
+		hasFields:     marc.NewFieldFilters(query.Get("hasFields")),
+		start:         1,
+		count:         -1,
+		skipErrors:    query.Get("skip-errors") == "true",
+		workers:       1,
+		maxRecordSize: maxRecordSize,
+		parseMode:     parseMode(),
+		errorOutput:   io.Discard, // the errors go in the status or in the trailer of the response
+	}
+	for _, name := range []string{"fields", "exclude", "hasFields"} {
+		if _, err := marc.ParseFieldFilters(query.Get(name)); err != nil {
+			return params, badRequest(fmt.Errorf("invalid %s: %w", name, err))
+		}
+	}
+	if len(params.filters.Fields) > 0 && len(params.exclude.Fields) > 0 {
+		return params, badRequest(errors.New("cannot specify fields and exclude at the same time"))
+	}
+	for name, value := range map[string]*int{"start": &params.start, "count": &params.count} {
+		if query.Get(name) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(query.Get(name))
+		if err != nil {
+			return params, badRequest(fmt.Errorf("invalid %s %q", name, query.Get(name)))
+		}
+		*value = n
+	}
+	if policy := query.Get("invalid-utf8"); policy != "" {
+		var err error
+		if params.utf8Policy, err = marc.ParseUTF8Policy(policy); err != nil {
+			return params, badRequest(err)
+		}
+		params.fixUTF8 = true
+	}
+	var err error
+	if params.where, err = parsePositionFilters(query["where"]); err != nil {
+		return params, badRequest(fmt.Errorf("invalid where: %w", err))
+	}
+	if normalization := query.Get("normalize"); normalization != "" {
+		if params.normalization, err = marc.ParseNormalization(normalization); err != nil {
+			return params, badRequest(err)
+		}
+	} else if query.Get("ignore-diacritics") == "true" {
+		params.normalization = marc.NormalizeStrip
+	}
+	params.plan = params.filterPlan()
+	return params, nil
+}
+
+// responseOutput is the output of a request, it keeps track of whether
+// anything has been written to the response.
+type responseOutput struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (ro *responseOutput) Write(p []byte) (int, error) {
+	ro.written = true
+	return ro.w.Write(p)
+}
+
+var errMethodNotAllowed = errors.New("use POST to send the records")
+
+// requestError is an error in the parameters of a request.
+type requestError struct {
+	err error
+}
+
+func (e requestError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return requestError{err: err}
+}
+
+// httpError replies to the request with the error and the status that
+// corresponds to it.
+func httpError(w http.ResponseWriter, err error) {
+	status := http.StatusUnprocessableEntity
+	var reqErr requestError
+	var sizeErr *http.MaxBytesError
+	switch {
+	case err == errMethodNotAllowed:
+		status = http.StatusMethodNotAllowed
+	case errors.As(err, &reqErr):
+		status = http.StatusBadRequest
+	case errors.As(err, &sizeErr):
+		status = http.StatusRequestEntityTooLarge
+	}
+	http.Error(w, err.Error(), status)
+}
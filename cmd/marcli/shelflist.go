@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "shelflist",
+		description: "Output the items (see -format items) sorted by call number in shelf order, LC and Dewey call numbers are sorted by their parts rather than as text",
+		flags:       []string{"profile", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		formatFlags: true,
+		run:         runShelflist,
+	})
+}
+
+func runShelflist(ctx context.Context) error {
+	if itemsNoItems || itemsNoItemsFile != "" {
+		return errors.New("the shelflist only has the items, -items.no-items and -items.no-items-file are not supported")
+	}
+	params := fileParams()
+	items, err := NewProcessorItems(params)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, column := range items.columns {
+		found = found || column.source == "callnumber"
+	}
+	if !found {
+		return errors.New("the shelflist needs a callnumber column in -items.columns")
+	}
+	return processFile(ctx, params, &shelflistProcessor{items: items}, os.Stdout)
+}
+
+// shelflistRow is the row of an item with the sort key of its call
+// number.
+type shelflistRow struct {
+	key string
+	row string
+}
+
+// shelflistProcessor keeps the rows of the items to output them sorted
+// in the footer, the items without call number go at the end. The
+// items with the same call number stay in the order of the file.
+type shelflistProcessor struct {
+	items *ProcessorItems
+	rows  []shelflistRow
+}
+
+func (p *shelflistProcessor) Tags() []string {
+	return p.items.Tags()
+}
+
+func (p *shelflistProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *shelflistProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	items := r.Fields.GetAll(p.items.field)
+	if len(items) == 0 {
+		return errRecordSkipped
+	}
+	bibID := p.items.value(r, marc.Field{}, p.items.bibID)
+	for _, item := range items {
+		key := "3"
+		if callNumber := p.items.itemCallNumber(r, item); callNumber != "" {
+			key, _ = marc.CallNumberSortKey(callNumber)
+		}
+		p.rows = append(p.rows, shelflistRow{key: key, row: p.items.row(r, bibID, item)})
+	}
+	return nil
+}
+
+func (p *shelflistProcessor) Footer(w io.Writer) error {
+	if err := p.items.Header(w); err != nil {
+		return err
+	}
+	sort.SliceStable(p.rows, func(i, j int) bool {
+		return p.rows[i].key < p.rows[j].key
+	})
+	for _, row := range p.rows {
+		if _, err := io.WriteString(w, row.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "shuffle",
+		description: "Output the records of the file in random order, in MARC binary by default",
+		flags:       []string{"seed", "format", "skip-errors", "error-file", "invalid-utf8", "buffer-size"},
+		formatFlags: true,
+		defaults:    map[string]string{"format": "mrc"},
+		run:         runShuffle,
+	})
+}
+
+// runShuffle reads the file once to get the position of each record,
+// shuffles the positions, and then reads the records directly at their
+// positions (like -at) so that only one record is in memory at a time.
+// Because of that it cannot read from stdin.
+func runShuffle(ctx context.Context) error {
+	if fileName == "-" {
+		return errors.New("shuffle cannot read from stdin")
+	}
+	offsets, err := recordOffsets(ctx)
+	if err != nil {
+		return err
+	}
+
+	seed := randomSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		fmt.Fprintf(os.Stderr, "Shuffled with -seed %d\n", seed)
+	}
+	numbers := make([]int, len(offsets))
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	rand.New(rand.NewSource(seed)).Shuffle(len(offsets), func(i, j int) {
+		offsets[i], offsets[j] = offsets[j], offsets[i]
+		numbers[i], numbers[j] = numbers[j], numbers[i]
+	})
+
+	params := fileParams()
+	params.offsets, params.numbers = offsets, numbers
+	processor, err := newProcessor(format, params)
+	if err != nil {
+		return err
+	}
+	return processFile(ctx, params, processor, os.Stdout)
+}
+
+// recordOffsets returns the position of each record in the file,
+// including the records that cannot be parsed.
+func recordOffsets(ctx context.Context) ([]int64, error) {
+	marcFile, file, err := openMarcFile(ProcessFileParams{filename: fileName, mmap: useMmap, maxRecordSize: maxRecordSize, parseMode: parseMode(), tags: []string{"001"}})
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	offsets := []int64{}
+	for {
+		r, err := marcFile.NextContext(ctx)
+		if err == io.EOF {
+			return offsets, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil && marcFile.Err() != nil {
+			return nil, err
+		}
+		offsets = append(offsets, r.Pos)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+// sinkFlags are the flags to send the records to an external system,
+// accepted by the commands that output records.
+var sinkFlags = []string{"batch-size", "kafka-url", "kafka-topic", "kafka-key", "pg-url", "pg-table", "pg-columns",
+	"es-url", "es-index", "es-id", "solr-url", "solr-commit", "webhook-url", "webhook-concurrency", "webhook-retries"}
+
+// sinkMessage is a record rendered in the output format on its way to
+// a sink, key identifies the record (see -kafka-key and -es-id).
+type sinkMessage struct {
+	key   string
+	value []byte
+}
+
+// sink is an external system (e.g. Kafka) where the records are sent
+// instead of writing them to the output.
+type sink interface {
+	// send delivers a batch of records, it returns an error if any of
+	// them could not be delivered.
+	send(batch []sinkMessage) error
+	// String describes the destination in the messages to the user.
+	String() string
+}
+
+// sinkFinisher is implemented by the sinks that need to do something
+// once all the records have been sent (e.g. commit them).
+type sinkFinisher interface {
+	finish() error
+}
+
+// sinkProcessor renders the records with the output processor and
+// sends them to the sink in batches of batchSize records. Nothing is
+// written to the output.
+type sinkProcessor struct {
+	output    recordRenderer
+	sink      sink
+	key       recordKey
+	batch     []sinkMessage
+	batchSize int
+	sent      int
+}
+
+// newSinkProcessor returns a processor that sends the records rendered
+// by output to the sink.
+func newSinkProcessor(output Processor, s sink, key recordKey, batchSize int) (*sinkProcessor, error) {
+	renderer, ok := output.(recordRenderer)
+	if !ok {
+		return nil, fmt.Errorf("the output format cannot be sent to %s", s)
+	}
+	if batchSize < 1 {
+		return nil, errors.New("the batch size must be at least 1")
+	}
+	return &sinkProcessor{output: renderer, sink: s, key: key, batchSize: batchSize}, nil
+}
+
+// Tags returns the tags needed by the output processor (and the key).
+func (p *sinkProcessor) Tags() []string {
+	selector, ok := p.output.(fieldSelector)
+	if !ok {
+		return nil
+	}
+	tags := selector.Tags()
+	if tags == nil {
+		return nil
+	}
+	return append(tags, p.key.tag)
+}
+
+func (p *sinkProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *sinkProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	b, err := p.output.RenderRecord(r)
+	if err != nil {
+		return err
+	}
+	p.batch = append(p.batch, sinkMessage{key: p.key.value(r), value: b})
+	if len(p.batch) < p.batchSize {
+		return nil
+	}
+	return p.flush()
+}
+
+func (p *sinkProcessor) Footer(w io.Writer) error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	if finisher, ok := p.sink.(sinkFinisher); ok {
+		if err := finisher.finish(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d records sent to %s\n", p.sent, p.sink)
+	return nil
+}
+
+// flush sends the records in the batch.
+func (p *sinkProcessor) flush() error {
+	if len(p.batch) == 0 {
+		return nil
+	}
+	if err := p.sink.send(p.batch); err != nil {
+		return err
+	}
+	p.sent += len(p.batch)
+	p.batch = p.batch[:0]
+	return nil
+}
+
+// withSink returns the processor that sends the records to the external
+// system indicated in the command line (e.g. -kafka-url) instead of
+// writing them to the output, or processor itself when there is none.
+func withSink(ctx context.Context, params ProcessFileParams, processor Processor) (Processor, error) {
+	given := 0
+	for _, value := range []string{kafkaURL, pgURL, esURL, solrURL, webhookURL} {
+		if value != "" {
+			given++
+		}
+	}
+	if given > 1 {
+		return nil, errors.New("only one of -kafka-url, -pg-url, -es-url, -solr-url, and -webhook-url can be used")
+	}
+
+	switch {
+	case pgURL != "":
+		return newPostgresProcessor(ctx, params, pgURL, pgTable, pgColumns)
+	case kafkaURL != "":
+		key, err := newRecordKey(kafkaKey)
+		if err != nil {
+			return nil, err
+		}
+		kafka, err := newKafkaSink(ctx, kafkaURL, kafkaTopic)
+		if err != nil {
+			return nil, err
+		}
+		return newSinkProcessor(processor, kafka, key, batchSize)
+	case esURL != "":
+		key, err := newRecordKey(esID)
+		if err != nil {
+			return nil, err
+		}
+		es, err := newESSink(ctx, esURL, esIndex)
+		if err != nil {
+			return nil, err
+		}
+		return newSinkProcessor(processor, es, key, batchSize)
+	case solrURL != "":
+		solr, err := newSolrSink(solrURL, solrCommit)
+		if err != nil {
+			return nil, err
+		}
+		return newSinkProcessor(processor, solr, recordKey{tag: "001"}, batchSize)
+	case webhookURL != "":
+		webhook, err := newWebhookSink(ctx, webhookURL, processorFormats[format].contentType, webhookConcurrency, webhookRetries)
+		if err != nil {
+			return nil, err
+		}
+		return newSinkProcessor(processor, webhook, recordKey{tag: "001"}, batchSize)
+	}
+	return processor, nil
+}
+
+// sleepContext waits for d, or until ctx is cancelled, in which case it
+// returns the error of ctx.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// recordKey is the value of a record used as its key, e.g. the 001 or
+// the 035 $a.
+type recordKey struct {
+	tag      string
+	subfield string
+}
+
+// newRecordKey parses a key given as a tag and an optional subfield,
+// e.g. 001 or 035a. The subfield defaults to $a and is ignored for
+// control fields.
+func newRecordKey(spec string) (recordKey, error) {
+	if len(spec) != 3 && len(spec) != 4 {
+		return recordKey{}, fmt.Errorf("invalid key %q, expected a tag and an optional subfield, e.g. 001 or 035a", spec)
+	}
+	key := recordKey{tag: spec[:3], subfield: spec[3:]}
+	if key.subfield == "" {
+		key.subfield = "a"
+	}
+	return key, nil
+}
+
+func (k recordKey) value(r marc.Record) string {
+	return r.GetValue(k.tag, k.subfield)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+func init() {
+	sliceFlags := []string{"format", "skip-errors", "error-file", "invalid-utf8", "buffer-size"}
+	registerCommand(command{
+		name:        "head",
+		description: "Output the first -n records of the file, in MARC binary by default",
+		flags:       append([]string{"n"}, sliceFlags...),
+		formatFlags: true,
+		defaults:    map[string]string{"format": "mrc"},
+		run:         runHead,
+	})
+	registerCommand(command{
+		name:        "tail",
+		description: "Output the last -n records of the file, in MARC binary by default",
+		flags:       append([]string{"n"}, sliceFlags...),
+		formatFlags: true,
+		defaults:    map[string]string{"format": "mrc"},
+		run:         runTail,
+	})
+	registerCommand(command{
+		name:        "slice",
+		description: "Output the records from -from to -to of the file, in MARC binary by default",
+		flags:       append([]string{"from", "to"}, sliceFlags...),
+		formatFlags: true,
+		defaults:    map[string]string{"format": "mrc"},
+		run:         runSlice,
+	})
+}
+
+func runHead(ctx context.Context) error {
+	if sliceN < 0 {
+		return errors.New("-n must not be negative")
+	}
+	return outputSlice(ctx, 1, sliceN)
+}
+
+// runTail counts the records in the file first, skipping them without
+// parsing them, and then outputs the last ones. Because of that it
+// cannot read from stdin.
+func runTail(ctx context.Context) error {
+	if sliceN < 0 {
+		return errors.New("-n must not be negative")
+	}
+	if fileName == "-" {
+		return errors.New("tail cannot read from stdin")
+	}
+	total, err := countRecords()
+	if err != nil {
+		return err
+	}
+	start := total - sliceN + 1
+	if start < 1 {
+		start = 1
+	}
+	return outputSlice(ctx, start, sliceN)
+}
+
+func runSlice(ctx context.Context) error {
+	if sliceFrom < 1 {
+		return errors.New("-from must be 1 or greater")
+	}
+	count := -1
+	if sliceTo != 0 {
+		if sliceTo < sliceFrom {
+			return fmt.Errorf("-to (%d) must not be less than -from (%d)", sliceTo, sliceFrom)
+		}
+		count = sliceTo - sliceFrom + 1
+	}
+	return outputSlice(ctx, sliceFrom, count)
+}
+
+// outputSlice outputs count records (-1 for all) starting at the record
+// number start (1-based). The records before start are skipped without
+// parsing them.
+func outputSlice(ctx context.Context, start int, count int) error {
+	params := fileParams()
+	params.start, params.count = start, count
+	processor, err := newProcessor(format, params)
+	if err != nil {
+		return err
+	}
+	return processFile(ctx, params, processor, os.Stdout)
+}
+
+// countRecords returns the number of records in the file.
+func countRecords() (int, error) {
+	marcFile, file, err := openMarcFile(ProcessFileParams{filename: fileName, maxRecordSize: maxRecordSize, parseMode: parseMode(), tags: []string{"001"}})
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return marcFile.Skip(math.MaxInt32)
+}
@@ -5,12 +5,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"github.com/hectorcorrea/marcli/pkg/marc"
 )
 
+func init() {
+	registerFormat(processorFormat{
+		name:        "solr",
+		description: "JSON documents ready to be loaded into Solr",
+		contentType: "application/json",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			return NewProcessorSolr(params)
+		},
+	})
+}
+
 type SolrDocument struct {
 	Id              string   `json:"id"`
 	Author          string   `json:"author_txt_en,omitempty"`
@@ -67,56 +77,37 @@ func NewSolrDocument(r marc.Record) SolrDocument {
 	return doc
 }
 
-func toSolr(params ProcessFileParams) error {
+type ProcessorSolr struct {
+	out int
+}
+
+func NewProcessorSolr(params ProcessFileParams) (*ProcessorSolr, error) {
 	if params.HasFilters() {
-		return errors.New("filters not supported for this format")
+		return nil, errors.New("filters not supported for this format")
 	}
+	return &ProcessorSolr{}, nil
+}
 
-	if count == 0 {
-		return nil
-	}
+func (p *ProcessorSolr) Header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "[")
+	return err
+}
 
-	file, err := os.Open(params.filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	var i, out int
-	marc := marc.NewMarcFile(file)
-
-	fmt.Printf("[")
-	for marc.Scan() {
-		r, err := marc.Record()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-		if i++; i < start {
-			continue
-		}
-		if r.Contains(params.searchValue, params.searchFields) && r.HasFields(params.hasFields) {
-			if out > 0 {
-				fmt.Printf(",\r\n")
-			} else {
-				fmt.Printf("\r\n")
-			}
-			doc := NewSolrDocument(r)
-			b, err := json.Marshal(doc)
-			if err != nil {
-				fmt.Printf("%s\r\n", err)
-			}
-			fmt.Printf("%s", b)
-			if out++; out == count {
-				break
-			}
-		}
-	}
-	fmt.Printf("\r\n]\r\n")
+func (p *ProcessorSolr) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
+
+func (p *ProcessorSolr) RenderRecord(r marc.Record) ([]byte, error) {
+	return json.Marshal(NewSolrDocument(r))
+}
+
+func (p *ProcessorSolr) WriteRendered(w io.Writer, b []byte) error {
+	return writeJsonElement(w, b, &p.out)
+}
 
-	return marc.Err()
+func (p *ProcessorSolr) Footer(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "\r\n]\r\n")
+	return err
 }
 
 func subjects(r marc.Record, subfield string) []string {
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// solrSink posts the records to the update handler of a Solr core (or
+// collection). The documents are the records as rendered in the output
+// format, usually -format solr.
+//
+// commit indicates when the documents are committed: "end" (once all
+// the records have been posted), "batch" (after each batch), "none"
+// (left to the autoCommit settings of Solr), or a duration for Solr to
+// commit within that time of receiving the documents (e.g. 30s).
+type solrSink struct {
+	endpoint     string
+	commit       string
+	commitWithin time.Duration
+	client       *http.Client
+}
+
+func newSolrSink(solrURL string, commit string) (*solrSink, error) {
+	if _, err := url.ParseRequestURI(solrURL); err != nil {
+		return nil, fmt.Errorf("invalid -solr-url: %w", err)
+	}
+	ss := &solrSink{
+		endpoint: strings.TrimRight(solrURL, "/") + "/update",
+		commit:   commit,
+		client:   &http.Client{Timeout: 2 * time.Minute},
+	}
+	switch commit {
+	case "end", "batch", "none":
+	default:
+		within, err := time.ParseDuration(commit)
+		if err != nil || within <= 0 {
+			return nil, fmt.Errorf("invalid -solr-commit %q, accepted values: end, batch, none, or a duration (e.g. 30s)", commit)
+		}
+		ss.commitWithin = within
+	}
+	return ss, nil
+}
+
+func (ss *solrSink) String() string {
+	return "Solr " + strings.TrimSuffix(ss.endpoint, "/update")
+}
+
+func (ss *solrSink) send(batch []sinkMessage) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, msg := range batch {
+		doc := bytes.TrimSpace(msg.value)
+		if !bytes.HasPrefix(doc, []byte("{")) {
+			return errors.New("the documents for Solr must be JSON objects, use -format solr")
+		}
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(doc)
+	}
+	body.WriteByte(']')
+
+	query := url.Values{}
+	switch {
+	case ss.commit == "batch":
+		query.Set("commit", "true")
+	case ss.commitWithin > 0:
+		query.Set("commitWithin", fmt.Sprint(ss.commitWithin.Milliseconds()))
+	}
+	return ss.post(query, &body)
+}
+
+// finish commits the documents posted when the commit is at the end.
+func (ss *solrSink) finish() error {
+	if ss.commit != "end" {
+		return nil
+	}
+	return ss.post(url.Values{"commit": {"true"}}, strings.NewReader("[]"))
+}
+
+func (ss *solrSink) post(query url.Values, body io.Reader) error {
+	endpoint := ss.endpoint
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	resp, err := ss.client.Post(endpoint, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var solrErr struct {
+			Error struct {
+				Msg string `json:"msg"`
+			} `json:"error"`
+		}
+		msg := string(bytes.TrimSpace(data))
+		if json.Unmarshal(data, &solrErr) == nil && solrErr.Error.Msg != "" {
+			msg = solrErr.Error.Msg
+		}
+		return fmt.Errorf("error posting to %s: %s: %s", ss, resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "stats",
+		description: "Count the records and the fields used in them, or compare the fields used in two files",
+		flags:       []string{"match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file", "metrics", "debug-addr", "warn-duplicates", "compare", "min-change"},
+		run:         runStats,
+	})
+}
+
+func runStats(ctx context.Context) error {
+	params := fileParams()
+	processor := newStatsProcessor()
+	if statsCompare == "" {
+		return processFile(ctx, params, processor, os.Stdout)
+	}
+
+	// Both files are counted with the same criteria, only the totals
+	// are used.
+	processor.quiet = true
+	if err := processFile(ctx, params, processor, os.Stdout); err != nil {
+		return err
+	}
+	other := newStatsProcessor()
+	other.quiet = true
+	params.filename = statsCompare
+	if err := processFile(ctx, params, other, os.Stdout); err != nil {
+		return err
+	}
+	_, err := io.WriteString(os.Stdout, compareStats(fileName, processor, statsCompare, other, statsMinChange))
+	return err
+}
+
+// statsProcessor counts, for each tag, the number of records with the
+// field and the total number of occurrences of the field. It also counts
+// the records with each subfield (e.g. 245$a) to compare files.
+type statsProcessor struct {
+	total       int
+	records     map[string]int
+	occurrences map[string]int
+	subfields   map[string]int
+	// quiet does not output the counts at the end.
+	quiet bool
+}
+
+func newStatsProcessor() *statsProcessor {
+	return &statsProcessor{
+		records:     map[string]int{},
+		occurrences: map[string]int{},
+		subfields:   map[string]int{},
+	}
+}
+
+func (p *statsProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *statsProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.total++
+	seen := map[string]bool{}
+	for _, field := range r.Fields {
+		p.occurrences[field.Tag]++
+		if !seen[field.Tag] {
+			seen[field.Tag] = true
+			p.records[field.Tag]++
+		}
+		for _, sub := range field.SubFields {
+			key := field.Tag + "$" + sub.Code
+			if !seen[key] {
+				seen[key] = true
+				p.subfields[key]++
+			}
+		}
+	}
+	return nil
+}
+
+func (p *statsProcessor) Footer(w io.Writer) error {
+	if p.quiet {
+		return nil
+	}
+	tags := []string{}
+	for tag := range p.records {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	str := fmt.Sprintf("Records: %d\n\n", p.total)
+	str += fmt.Sprintf("%-5s %10s %12s\n", "Tag", "Records", "Occurrences")
+	for _, tag := range tags {
+		str += fmt.Sprintf("%-5s %10d %12d\n", tag, p.records[tag], p.occurrences[tag])
+	}
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// recordCounts returns the number of records with each field and with
+// each subfield.
+func (p *statsProcessor) recordCounts() map[string]int {
+	counts := map[string]int{}
+	for key, count := range p.records {
+		counts[key] = count
+	}
+	for key, count := range p.subfields {
+		counts[key] = count
+	}
+	return counts
+}
+
+// compareStats returns the fields and subfields that are only used in
+// one of the files, or whose share of the records with them changed at
+// least minChange percent. The shares are compared rather than the
+// counts so that files with a different number of records (e.g. two
+// deliveries from a vendor) can be compared too.
+func compareStats(nameA string, a *statsProcessor, nameB string, b *statsProcessor, minChange float64) string {
+	countsA, countsB := a.recordCounts(), b.recordCounts()
+	keys := []string{}
+	for key := range countsA {
+		keys = append(keys, key)
+	}
+	for key := range countsB {
+		if _, ok := countsA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	rows := []string{}
+	for _, key := range keys {
+		countA, countB := countsA[key], countsB[key]
+		change := ""
+		switch {
+		case countB == 0:
+			change = "removed"
+		case countA == 0:
+			change = "added"
+		default:
+			shareA := float64(countA) / float64(a.total)
+			shareB := float64(countB) / float64(b.total)
+			percent := (shareB - shareA) * 100 / shareA
+			if math.Abs(percent) < minChange || percent == 0 {
+				continue
+			}
+			change = fmt.Sprintf("%+.1f%%", percent)
+		}
+		rows = append(rows, fmt.Sprintf("%-7s %10d %10d %9s\n", key, countA, countB, change))
+	}
+
+	str := fmt.Sprintf("A: %s (%d records)\nB: %s (%d records)\n\n", nameA, a.total, nameB, b.total)
+	if len(rows) == 0 {
+		return str + fmt.Sprintf("No differences in the fields used (changes under %g%%)\n", minChange)
+	}
+	str += fmt.Sprintf("%-7s %10s %10s %9s\n", "Field", "Records A", "Records B", "Change")
+	return str + strings.Join(rows, "")
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "uris",
+		description: "Report how many headings (1XX, 6XX, 7XX, 8XX) have authority URIs ($0) or real world object URIs ($1), by field and vocabulary",
+		flags:       []string{"csv", "json", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runURIs,
+	})
+}
+
+func runURIs(ctx context.Context) error {
+	if reportCSV && reportJSON {
+		return errors.New("use either -csv or -json")
+	}
+	processor := &urisProcessor{counts: map[uriKey]*uriCounts{}, sources: map[string]int{}}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// subjectVocabularies are the thesaurus of the subject headings by
+// their second indicator, 7 is for the source in $2.
+var subjectVocabularies = map[string]string{
+	"0": "lcsh",
+	"1": "lcshac",
+	"2": "mesh",
+	"3": "nal",
+	"4": "(not specified)",
+	"5": "cash",
+	"6": "rvm",
+}
+
+// uriKey is what the headings are counted by.
+type uriKey struct {
+	Tag        string `json:"tag"`
+	Vocabulary string `json:"vocabulary"`
+}
+
+type uriCounts struct {
+	uriKey
+	Headings int `json:"headings"`
+	// Authority are the headings with a $0, AuthorityURI the ones where
+	// it is a URI rather than a control number like (DLC)sh85.
+	Authority    int `json:"with_0"`
+	AuthorityURI int `json:"with_0_uri"`
+	Object       int `json:"with_1"`
+}
+
+func (c *uriCounts) add(other uriCounts) {
+	c.Headings += other.Headings
+	c.Authority += other.Authority
+	c.AuthorityURI += other.AuthorityURI
+	c.Object += other.Object
+}
+
+// urisProcessor counts the headings with $0 and $1 by tag and
+// vocabulary, and the sources of the identifiers (the host of the URIs,
+// e.g. id.loc.gov, or the organization code of the control numbers).
+type urisProcessor struct {
+	records int
+	counts  map[uriKey]*uriCounts
+	sources map[string]int
+}
+
+func (p *urisProcessor) Tags() []string {
+	return headingTags
+}
+
+func (p *urisProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *urisProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	p.records++
+	for _, field := range r.Fields {
+		if !isHeadingTag(field.Tag) {
+			continue
+		}
+		key := uriKey{Tag: field.Tag, Vocabulary: headingVocabulary(field)}
+		c, ok := p.counts[key]
+		if !ok {
+			c = &uriCounts{uriKey: key}
+			p.counts[key] = c
+		}
+		c.Headings++
+		authority := field.SubFieldValues("0")
+		if len(authority) > 0 {
+			c.Authority++
+		}
+		for _, value := range authority {
+			if isURI(value) {
+				c.AuthorityURI++
+				break
+			}
+		}
+		objects := field.SubFieldValues("1")
+		if len(objects) > 0 {
+			c.Object++
+		}
+		for _, value := range append(authority, objects...) {
+			p.sources[identifierSource(value)]++
+		}
+	}
+	return nil
+}
+
+// headingVocabulary returns the thesaurus of a subject heading, or the
+// source in $2 of the other headings if they have it.
+func headingVocabulary(field marc.Field) string {
+	source := strings.TrimSpace(field.SubFieldValue("2"))
+	if field.Tag[0] == '6' && field.Indicator2 != "7" {
+		if vocabulary, ok := subjectVocabularies[field.Indicator2]; ok {
+			return vocabulary
+		}
+	}
+	if source == "" {
+		return "(not specified)"
+	}
+	return source
+}
+
+func isURI(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
+
+// identifierSource returns the host of a URI or the organization code of
+// a control number, e.g. "(DLC)".
+func identifierSource(value string) string {
+	value = strings.TrimSpace(value)
+	if isURI(value) {
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+	if strings.HasPrefix(value, "(") {
+		if i := strings.Index(value, ")"); i != -1 {
+			return value[:i+1]
+		}
+	}
+	return "(other)"
+}
+
+// sortedCounts returns the counts by tag and vocabulary.
+func (p *urisProcessor) sortedCounts() []uriCounts {
+	counts := []uriCounts{}
+	for _, c := range p.counts {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Tag != counts[j].Tag {
+			return counts[i].Tag < counts[j].Tag
+		}
+		return counts[i].Vocabulary < counts[j].Vocabulary
+	})
+	return counts
+}
+
+// urisReport is the JSON output.
+type urisReport struct {
+	Records  int             `json:"records"`
+	Headings []uriCounts     `json:"headings"`
+	Sources  []overviewCount `json:"sources"`
+}
+
+func (p *urisProcessor) Footer(w io.Writer) error {
+	counts := p.sortedCounts()
+	switch {
+	case reportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"tag", "vocabulary", "headings", "with_0", "with_0_uri", "with_1"})
+		for _, c := range counts {
+			cw.Write([]string{c.Tag, c.Vocabulary, strconv.Itoa(c.Headings), strconv.Itoa(c.Authority), strconv.Itoa(c.AuthorityURI), strconv.Itoa(c.Object)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case reportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(urisReport{Records: p.records, Headings: counts, Sources: p.sourceCounts()})
+	}
+
+	byTag, byVocabulary := map[string]*uriCounts{}, map[string]*uriCounts{}
+	total := uriCounts{}
+	for _, c := range counts {
+		for _, group := range []struct {
+			m   map[string]*uriCounts
+			key string
+		}{{byTag, c.Tag}, {byVocabulary, c.Vocabulary}} {
+			if group.m[group.key] == nil {
+				group.m[group.key] = &uriCounts{}
+			}
+			group.m[group.key].add(c)
+		}
+		total.add(c)
+	}
+
+	str := fmt.Sprintf("Records: %d\n", p.records)
+	str += fmt.Sprintf("Headings: %d\n", total.Headings)
+	str += fmt.Sprintf("  with $0: %s\n", uriPercent(total.Authority, total.Headings))
+	str += fmt.Sprintf("  with a URI in $0: %s\n", uriPercent(total.AuthorityURI, total.Headings))
+	str += fmt.Sprintf("  with $1: %s\n", uriPercent(total.Object, total.Headings))
+	str += uriTable("By field", byTag)
+	str += uriTable("By vocabulary", byVocabulary)
+	if section := topCounts("Sources of the identifiers in $0 and $1", p.sources, 0, 0); len(section.Counts) > 0 {
+		str += "\n" + section.Title + "\n"
+		for _, count := range section.Counts {
+			str += fmt.Sprintf("  %-40s %9d\n", count.Value, count.Count)
+		}
+	}
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+func (p *urisProcessor) sourceCounts() []overviewCount {
+	return topCounts("", p.sources, 0, 0).Counts
+}
+
+// uriTable returns the counts of each group with the percentage of the
+// headings with $0 and $1.
+func uriTable(title string, groups map[string]*uriCounts) string {
+	if len(groups) == 0 {
+		return ""
+	}
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	str := fmt.Sprintf("\n%s\n  %-20s %9s %17s %17s %17s\n", title, "", "headings", "$0", "$0 URI", "$1")
+	for _, name := range names {
+		c := groups[name]
+		str += fmt.Sprintf("  %-20s %9d %17s %17s %17s\n", name, c.Headings,
+			uriPercent(c.Authority, c.Headings), uriPercent(c.AuthorityURI, c.Headings), uriPercent(c.Object, c.Headings))
+	}
+	return str
+}
+
+// uriPercent returns the count with its percentage of the total.
+func uriPercent(count, total int) string {
+	percent := 0.0
+	if total > 0 {
+		percent = float64(count) * 100 / float64(total)
+	}
+	return fmt.Sprintf("%d (%.1f%%)", count, percent)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "validate",
+		description: "Report the records that cannot be parsed or have an invalid leader or fixed-length field",
+		flags:       []string{"error-file", "schema", "warn-duplicates"},
+		run:         runValidate,
+	})
+}
+
+// runValidate reads all the records in the file and reports the
+// problems found in each of them. It returns an error if any of the
+// records is invalid.
+func runValidate(ctx context.Context) error {
+	marcFile, file, err := openMarcFile(ProcessFileParams{filename: fileName, mmap: useMmap, maxRecordSize: maxRecordSize, parseMode: parseMode()})
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var schema marc.Schema
+	if validateSchema {
+		if schema, err = newSchema(); err != nil {
+			return err
+		}
+	}
+
+	read, invalid := 0, 0
+	for {
+		r, err := marcFile.NextContext(ctx)
+		if err == io.EOF || ctx.Err() != nil {
+			break
+		}
+		if err != nil && marcFile.Err() != nil {
+			return err
+		}
+
+		read++
+		if err == nil {
+			duplicates.check(r, report)
+		}
+		report.recordWarnings(r)
+		for _, warning := range r.Warnings {
+			fmt.Printf("Record %d (byte %d, %s): warning: %s\n", read, r.Pos, r.ControlNum(), warning)
+		}
+		if err == nil {
+			err = validateRecord(r, schema)
+		}
+		if err != nil {
+			invalid++
+			report.recordError(r, "INVALID RECORD", err, false)
+			fmt.Printf("Record %d (byte %d, %s): %s\n", read, r.Pos, r.ControlNum(), err)
+			if errorRecords != nil {
+				if err := errorRecords.write(r, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if report != nil {
+		report.Files = append(report.Files, fileName)
+		report.RecordsRead += read
+	}
+	fmt.Fprintf(os.Stderr, "%d records read, %d invalid\n", read, invalid)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if invalid > 0 {
+		return fmt.Errorf("found %d invalid records", invalid)
+	}
+	return nil
+}
+
+// validateRecord checks the leader and the fixed-length fields of a
+// record that was parsed and, if schema is not nil, its fields.
+func validateRecord(r marc.Record, schema marc.Schema) error {
+	if err := r.Leader.Validate(); err != nil {
+		return err
+	}
+	if err := r.ValidateFixedFields(); err != nil {
+		return err
+	}
+	if schema != nil {
+		return schema.Validate(r)
+	}
+	return nil
+}
+
+// newSchema returns the MARC 21 schema with the local fields in the
+// configuration file.
+func newSchema() (marc.Schema, error) {
+	schema := marc.MARC21Schema()
+	for tag, spec := range localSchema {
+		if err := schema.AddField(tag, spec); err != nil {
+			return nil, fmt.Errorf("invalid schema in the configuration file: %w", err)
+		}
+	}
+	return schema, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "values",
+		description: "Output the distinct values of the fields or subfields in -spec and the number of times each one is used",
+		flags:       []string{"spec", "sort", "bom", "crlf", "codepage", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "start", "count", "skip-errors", "error-file"},
+		run:         runValues,
+	})
+}
+
+func runValues(ctx context.Context) error {
+	spec, err := marc.ParseFieldFilters(valuesSpec)
+	if err != nil {
+		return fmt.Errorf("invalid -spec: %w", err)
+	}
+	if len(spec.Fields) == 0 {
+		return errors.New("indicate the fields or subfields with -spec, e.g. 650a")
+	}
+	if valuesSort != "count" && valuesSort != "value" {
+		return fmt.Errorf("invalid -sort %q, valid values: count, value", valuesSort)
+	}
+	processor := &valuesProcessor{spec: spec, sort: valuesSort, counts: map[string]int{}}
+	return processFile(ctx, fileParams(), processor, os.Stdout)
+}
+
+// valuesProcessor counts the values of the fields in the spec. A spec
+// with one subfield (e.g. 650x) counts each occurrence of the subfield,
+// with several subfields (e.g. 650ax) their values in each field are
+// joined with a space, and without subfields (e.g. 650) all the
+// subfields of the field are.
+type valuesProcessor struct {
+	spec   marc.FieldFilters
+	sort   string
+	counts map[string]int
+}
+
+func (p *valuesProcessor) Tags() []string {
+	return filterTags(p.spec)
+}
+
+func (p *valuesProcessor) Header(w io.Writer) error {
+	return nil
+}
+
+func (p *valuesProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	for _, filter := range p.spec.Fields {
+		if filter.Tag == "LDR" {
+			p.add(r.Leader.Raw())
+			continue
+		}
+		for _, field := range filter.Select(r.Fields) {
+			switch {
+			case field.IsControlField():
+				p.add(field.Value)
+			case len(filter.Subfields) == 1:
+				for _, value := range field.SubFieldValues(filter.Subfields) {
+					p.add(value)
+				}
+			case filter.Subfields == "":
+				values := []string{}
+				for _, sub := range field.SubFields {
+					values = append(values, sub.Value)
+				}
+				p.add(strings.Join(values, " "))
+			default:
+				p.add(strings.Join(field.SubFieldValues(filter.Subfields), " "))
+			}
+		}
+	}
+	return nil
+}
+
+func (p *valuesProcessor) add(value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		p.counts[value]++
+	}
+}
+
+// Footer outputs the count and the value separated by a tab, from the
+// most common value to the least common (alphabetically for the same
+// count) or, with -sort value, alphabetically.
+func (p *valuesProcessor) Footer(w io.Writer) error {
+	values := make([]string, 0, len(p.counts))
+	for value := range p.counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if p.sort == "count" && p.counts[a] != p.counts[b] {
+			return p.counts[a] > p.counts[b]
+		}
+		return a < b
+	})
+	var sb strings.Builder
+	for _, value := range values {
+		fmt.Fprintf(&sb, "%d\t%s\n", p.counts[value], value)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "watch",
+		description: "Process the files that arrive to a directory through a pipeline of commands",
+		flags:       []string{"dir", "pipeline", "interval", "once"},
+		noFile:      true,
+		run:         runWatch,
+	})
+}
+
+// pipeline are the steps to process each file, in a YAML file, e.g.
+//
+//	done: processed
+//	failed: rejected
+//	steps:
+//	  - command: validate
+//	    schema: true
+//	  - command: filter
+//	    hasFields: "856"
+//	  - command: convert
+//	    format: xml
+//	    output: out/{name}.xml
+//
+// The settings of a step are the parameters of its command. The records
+// output by filter, convert, and edit are the input of the next step,
+// the rest of the commands (e.g. validate or stats) only check the file.
+// The output of a step is discarded unless it is saved in output, where
+// {name} is the name of the file without extension, {file} the name of
+// the file, and {date} the date (YYYYMMDD).
+type pipeline struct {
+	Done   string              `yaml:"done"`
+	Failed string              `yaml:"failed"`
+	Steps  []map[string]string `yaml:"steps"`
+}
+
+// pipelineTransforms are the commands that output records to be
+// processed by the next step.
+var pipelineTransforms = map[string]bool{"filter": true, "convert": true, "edit": true}
+
+// loadPipeline reads the pipeline and checks that its steps are
+// commands that can be run with the parameters given.
+func loadPipeline(path string) (pipeline, error) {
+	p := pipeline{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("invalid pipeline %s: %w", path, err)
+	}
+	if len(p.Steps) == 0 {
+		return p, fmt.Errorf("invalid pipeline %s: no steps", path)
+	}
+	for i, step := range p.Steps {
+		cmd, ok := commands[step["command"]]
+		if !ok || cmd.noFile {
+			return p, fmt.Errorf("invalid pipeline %s: step %d: invalid command %q", path, i+1, step["command"])
+		}
+		for name := range step {
+			if name == "command" || name == "output" {
+				continue
+			}
+			if name == "file" || !acceptedFlags[cmd.name][name] {
+				return p, fmt.Errorf("invalid pipeline %s: step %d: invalid parameter %q for %s", path, i+1, name, cmd.name)
+			}
+		}
+	}
+	return p, nil
+}
+
+func runWatch(ctx context.Context) error {
+	if watchDir == "" || pipelineFile == "" {
+		return errors.New("-dir and -pipeline are required")
+	}
+	if watchInterval <= 0 {
+		return errors.New("-interval must be greater than zero")
+	}
+	w := &watcher{dir: watchDir, interval: watchInterval, once: watchOnce, config: configFile, sizes: map[string]int64{}}
+	var err error
+	if w.pipeline, err = loadPipeline(pipelineFile); err != nil {
+		return err
+	}
+	if w.exe, err = os.Executable(); err != nil {
+		return err
+	}
+	return w.run(ctx)
+}
+
+// watcher checks the directory every interval for new files and runs
+// the pipeline on them, one at a time. A file is processed once its
+// size does not change between two checks, i.e. it has been copied
+// completely. Then it is moved to the done directory, or to the failed
+// directory with a log of the errors (the file name plus .log).
+type watcher struct {
+	dir      string
+	pipeline pipeline
+	interval time.Duration
+	once     bool
+	config   string
+	exe      string
+	sizes    map[string]int64
+}
+
+func (w *watcher) run(ctx context.Context) error {
+	done := w.subdir(w.pipeline.Done, "done")
+	failed := w.subdir(w.pipeline.Failed, "failed")
+	for _, dir := range []string{done, failed} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if !w.once {
+		fmt.Fprintf(os.Stderr, "Watching %s, press Ctrl+C to stop\n", w.dir)
+	}
+	for {
+		files, err := w.readyFiles()
+		if err != nil {
+			return err
+		}
+		for _, name := range files {
+			if ctx.Err() != nil {
+				return nil
+			}
+			path := filepath.Join(w.dir, name)
+			fmt.Fprintf(os.Stderr, "Processing %s\n", path)
+			log, err := w.process(path)
+			target := done
+			if err != nil {
+				target = failed
+				fmt.Fprintf(os.Stderr, "%s failed: %s\n", path, err)
+				fmt.Fprintf(log, "%s\n", err)
+				if logErr := os.WriteFile(filepath.Join(failed, name+".log"), log.Bytes(), 0644); logErr != nil {
+					return logErr
+				}
+			}
+			if err := os.Rename(path, filepath.Join(target, name)); err != nil {
+				return err
+			}
+			delete(w.sizes, name)
+		}
+		if w.once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// subdir returns the path of the done or failed directory, relative to
+// the directory watched unless it is an absolute path.
+func (w *watcher) subdir(dir string, defaultDir string) string {
+	if dir == "" {
+		dir = defaultDir
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(w.dir, dir)
+}
+
+// readyFiles returns the files in the directory (sorted by name) that
+// have the same size as in the previous check, or all of them with
+// -once. Hidden files and the files being copied with a temporary
+// extension (.tmp or .part) are ignored.
+func (w *watcher) readyFiles() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	ready := []string{}
+	sizes := map[string]int64{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".part") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// The file was moved or deleted since the directory was read.
+			continue
+		}
+		size, seen := w.sizes[name]
+		if w.once || (seen && size == info.Size()) {
+			ready = append(ready, name)
+		}
+		sizes[name] = info.Size()
+	}
+	w.sizes = sizes
+	sort.Strings(ready)
+	return ready, nil
+}
+
+// process runs the steps of the pipeline on the file and returns the
+// log of the errors reported by them.
+func (w *watcher) process(path string) (*bytes.Buffer, error) {
+	log := &bytes.Buffer{}
+	tmp, err := os.MkdirTemp("", "marcli-watch")
+	if err != nil {
+		return log, err
+	}
+	defer os.RemoveAll(tmp)
+
+	input := path
+	for i, step := range w.pipeline.Steps {
+		name := step["command"]
+		output := filepath.Join(tmp, fmt.Sprintf("step%d", i+1))
+		if step["output"] != "" {
+			output = expandOutput(step["output"], path)
+			if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+				return log, err
+			}
+		}
+		fmt.Fprintf(log, "Step %d (%s):\n", i+1, name)
+		if err := w.runStep(step, input, output, log); err != nil {
+			if !pipelineTransforms[name] && step["output"] == "" {
+				// The report of the step (e.g. the invalid records found
+				// by validate) explains why it failed.
+				if report, readErr := os.ReadFile(output); readErr == nil {
+					log.Write(report)
+				}
+			}
+			return log, fmt.Errorf("step %d (%s): %w", i+1, name, err)
+		}
+		if pipelineTransforms[name] {
+			input = output
+		}
+	}
+	return log, nil
+}
+
+// runStep runs marcli with the command and parameters of the step, its
+// output is written to the output file and its errors to stderr and to
+// the log.
+func (w *watcher) runStep(step map[string]string, input string, output string, log io.Writer) error {
+	args := strings.Fields(step["command"])
+	args = append(args, "-file", input)
+	if w.config != "" {
+		args = append(args, "-config", w.config)
+	}
+	names := []string{}
+	for name := range step {
+		if name != "command" && name != "output" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-"+name+"="+step[name])
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	// The step is not canceled with the context so that the file being
+	// processed is completed when stopping.
+	cmd := exec.Command(w.exe, args...)
+	cmd.Stdout = out
+	cmd.Stderr = io.MultiWriter(os.Stderr, log)
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// expandOutput returns the path of the output of a step for the file.
+func expandOutput(output string, path string) string {
+	file := filepath.Base(path)
+	return strings.NewReplacer(
+		"{name}", strings.TrimSuffix(file, filepath.Ext(file)),
+		"{file}", file,
+		"{date}", time.Now().Format("20060102"),
+	).Replace(output)
+}
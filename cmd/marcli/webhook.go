@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// webhookSink posts each record (as rendered in the output format, e.g.
+// -format json) to a URL, with up to concurrency requests at the same
+// time. Requests that fail because of the network or the server (HTTP
+// 429 and 5xx) are retried up to retries times with an exponential
+// backoff.
+type webhookSink struct {
+	ctx         context.Context
+	url         string
+	contentType string
+	concurrency int
+	retries     int
+	client      *http.Client
+	// backoff is the first wait between attempts, webhookFirstBackoff.
+	backoff time.Duration
+}
+
+const webhookFirstBackoff = time.Second
+
+func newWebhookSink(ctx context.Context, webhookURL string, contentType string, concurrency int, retries int) (*webhookSink, error) {
+	if _, err := url.ParseRequestURI(webhookURL); err != nil {
+		return nil, fmt.Errorf("invalid -webhook-url: %w", err)
+	}
+	if concurrency < 1 {
+		return nil, errors.New("-webhook-concurrency must be at least 1")
+	}
+	if retries < 0 {
+		return nil, errors.New("-webhook-retries cannot be negative")
+	}
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return &webhookSink{
+		ctx:         ctx,
+		url:         webhookURL,
+		contentType: contentType,
+		concurrency: concurrency,
+		retries:     retries,
+		client:      &http.Client{Timeout: time.Minute},
+		backoff:     webhookFirstBackoff,
+	}, nil
+}
+
+func (ws *webhookSink) String() string {
+	return "webhook " + ws.url
+}
+
+// send posts the records in the batch and returns the first error, if
+// any, once all of them have been posted.
+func (ws *webhookSink) send(batch []sinkMessage) error {
+	messages := make(chan sinkMessage)
+	errs := make(chan error, len(batch))
+	var wg sync.WaitGroup
+	for n := 0; n < ws.concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range messages {
+				if err := ws.post(msg); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for _, msg := range batch {
+		messages <- msg
+	}
+	close(messages)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// post sends one record, retrying when it fails.
+func (ws *webhookSink) post(msg sinkMessage) error {
+	backoff := ws.backoff
+	for attempt := 0; ; attempt++ {
+		err := ws.postOnce(msg)
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt == ws.retries {
+			return err
+		}
+		if ctxErr := ws.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s, retrying in %s\n", err, backoff)
+		if err := sleepContext(ws.ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func (ws *webhookSink) postOnce(msg sinkMessage) error {
+	req, err := http.NewRequestWithContext(ws.ctx, http.MethodPost, ws.url, bytes.NewReader(msg.value))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ws.contentType)
+	if msg.key != "" {
+		req.Header.Set("X-Record-Id", msg.key)
+	}
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return retryableError{err}
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return retryableError{fmt.Errorf("error posting record %q to %s: %s", msg.key, ws, resp.Status)}
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("error posting record %q to %s: %s: %s", msg.key, ws, resp.Status, bytes.TrimSpace(data))
+	}
+	return nil
+}
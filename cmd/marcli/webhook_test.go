@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookTestServer answers the requests of each record (by its
+// X-Record-Id) with the statuses in order, the last one is repeated, and
+// counts the requests of each record.
+func webhookTestServer(t *testing.T, statuses map[string][]int) (*httptest.Server, func(id string) int) {
+	var mu sync.Mutex
+	counts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Record-Id")
+		mu.Lock()
+		n := counts[id]
+		counts[id]++
+		mu.Unlock()
+		status := http.StatusOK
+		if list := statuses[id]; len(list) > 0 {
+			if n >= len(list) {
+				n = len(list) - 1
+			}
+			status = list[n]
+		}
+		w.WriteHeader(status)
+		if status >= 300 {
+			w.Write([]byte("rejected " + id))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func(id string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[id]
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+
+	batch := []sinkMessage{
+		{key: "a", value: []byte("one")},
+		{key: "b", value: []byte("two")},
+		{key: "c", value: []byte("three")},
+	}
+	tests := []struct {
+		name       string
+		statuses   map[string][]int
+		wantErr    string
+		wantCounts map[string]int
+	}{
+		{
+			name:       "overloaded",
+			statuses:   map[string][]int{"b": {http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK}},
+			wantCounts: map[string]int{"a": 1, "b": 3, "c": 1},
+		},
+		{
+			name:       "server error",
+			statuses:   map[string][]int{"c": {http.StatusInternalServerError}},
+			wantErr:    `error posting record "c"`,
+			wantCounts: map[string]int{"a": 1, "b": 1, "c": 4},
+		},
+		{
+			name:       "rejected record",
+			statuses:   map[string][]int{"a": {http.StatusBadRequest}},
+			wantErr:    "rejected a",
+			wantCounts: map[string]int{"a": 1, "b": 1, "c": 1},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			server, count := webhookTestServer(t, tt.statuses)
+			ws, err := newWebhookSink(context.Background(), server.URL, "", 2, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ws.backoff = time.Millisecond
+
+			err = ws.send(batch)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error with %q, got %v", tt.wantErr, err)
+			}
+			for id, want := range tt.wantCounts {
+				if got := count(id); got != want {
+					t.Errorf("expected %d requests for record %q, got %d", want, id, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookSinkCancel(t *testing.T) {
+	t.Parallel()
+
+	server, count := webhookTestServer(t, map[string][]int{"a": {http.StatusServiceUnavailable}})
+	ctx, cancel := context.WithCancel(context.Background())
+	ws, err := newWebhookSink(ctx, server.URL, "", 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.backoff = time.Hour
+
+	go func() {
+		for count("a") == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	err = ws.send([]sinkMessage{{key: "a", value: []byte("one")}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the send to be cancelled while waiting to retry, got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hectorcorrea/marcli/pkg/marc"
+)
+
+func init() {
+	registerCommand(command{
+		name:        "worldcat",
+		description: "Compare the records with the WorldCat master records (OCLC Metadata API)",
+		flags:       []string{"oclc-key", "oclc-secret", "master", "match", "matchFields", "ignore-diacritics", "normalize", "hasFields", "where", "fields", "exclude", "format", "start", "count", "skip-errors", "error-file", "invalid-utf8"},
+		formatFlags: true,
+		run:         runWorldCat,
+	})
+}
+
+// The endpoints of the OCLC APIs, they are variables to use a test
+// server, e.g. go build -ldflags "-X main.worldcatAPI=http://localhost:8080"
+var (
+	oclcTokenURL = "https://oauth.oclc.org/token"
+	worldcatAPI  = "https://metadata.api.oclc.org/worldcat"
+)
+
+// worldcatMaxRetries is how many times a request is sent again when
+// the API is too busy (HTTP 429) or fails, waiting worldcatFirstBackoff
+// the first time and twice as long each time after that.
+const (
+	worldcatMaxRetries   = 3
+	worldcatFirstBackoff = time.Second
+)
+
+// errNotInWorldCat is returned when there is no master record with the
+// OCLC number (or ISBN) of a record.
+var errNotInWorldCat = errors.New("not found in WorldCat")
+
+func runWorldCat(ctx context.Context) error {
+	if oclcKey == "" || oclcSecret == "" {
+		return errors.New("-oclc-key and -oclc-secret are required, they are the credentials of your WSKey for the WorldCat Metadata API")
+	}
+	params := fileParams()
+	if len(params.filters.Fields) > 0 && len(params.exclude.Fields) > 0 {
+		return errors.New("cannot specify fields and exclude at the same time")
+	}
+	p := &worldcatProcessor{
+		client: newWorldCatClient(ctx, oclcKey, oclcSecret),
+		master: masterRecords,
+		plan:   params.plan,
+	}
+	if p.master {
+		var err error
+		if p.output, err = newProcessor(format, params); err != nil {
+			return err
+		}
+	}
+	err := processFile(ctx, params, p, os.Stdout)
+	p.summary()
+	return err
+}
+
+// worldcatProcessor looks up the master record of each record in
+// WorldCat, by the OCLC number or else by the ISBN, and outputs the
+// differences between them, or the master records themselves to
+// compare them with other tools. The fields selected with -fields or
+// -exclude are the only ones compared (e.g. -exclude 001,003,005,945 to
+// ignore the local fields).
+type worldcatProcessor struct {
+	client    *worldcatClient
+	master    bool
+	output    Processor
+	plan      *marc.FilterPlan
+	found     int
+	different int
+	notFound  int
+	noNumber  int
+}
+
+func (p *worldcatProcessor) Header(w io.Writer) error {
+	if !p.master {
+		return nil
+	}
+	return p.output.Header(w)
+}
+
+func (p *worldcatProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	number := oclcNumber(r)
+	if number == "" {
+		isbns := recordISBNs(r)
+		if len(isbns) == 0 {
+			p.noNumber++
+			p.warn(r, "no OCLC number or ISBN to look up in WorldCat")
+			return errRecordSkipped
+		}
+		isbn := isbns[0]
+		var err error
+		number, err = p.client.searchISBN(isbn)
+		if err == errNotInWorldCat {
+			p.notFound++
+			p.warn(r, fmt.Sprintf("ISBN %s %s", isbn, err))
+			return errRecordSkipped
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	master, err := p.client.bib(number)
+	if err == errNotInWorldCat {
+		p.notFound++
+		p.warn(r, fmt.Sprintf("OCLC number %s %s", number, err))
+		return errRecordSkipped
+	}
+	if err != nil {
+		return err
+	}
+	p.found++
+	if p.master {
+		return p.output.ProcessRecord(w, master)
+	}
+
+	local := r
+	local.Fields = p.plan.Filter(r)
+	master.Fields = p.plan.Filter(master)
+	// The length of the records and the base address of their data are
+	// not relevant to compare them.
+	master.Leader.SetRecordLength(local.Leader.RecordLength())
+	master.Leader.SetDataOffset(local.Leader.DataOffset())
+	diff := marc.DiffWithLabels(local, master, "local", "WorldCat "+number)
+	if diff == "" {
+		return errRecordSkipped
+	}
+	p.different++
+	_, err = io.WriteString(w, diff+"\n")
+	return err
+}
+
+func (p *worldcatProcessor) Footer(w io.Writer) error {
+	if !p.master {
+		return nil
+	}
+	return p.output.Footer(w)
+}
+
+// summary prints to stderr how many records were found in WorldCat.
+func (p *worldcatProcessor) summary() {
+	found := fmt.Sprintf("%d records found in WorldCat", p.found)
+	if !p.master {
+		found += fmt.Sprintf(" (%d different)", p.different)
+	}
+	fmt.Fprintf(os.Stderr, "%s, %d not found, %d without OCLC number or ISBN\n", found, p.notFound, p.noNumber)
+}
+
+func (p *worldcatProcessor) warn(r marc.Record, msg string) {
+	fmt.Fprintf(os.Stderr, "Warning: record at byte %d: %s\n", r.Pos, msg)
+	report.recordWarning(r, "WORLDCAT", msg)
+}
+
+// oclcNumber returns the OCLC number of the record, from the 035 with
+// the (OCoLC) prefix or from the 001 when it has one of the prefixes
+// used by OCLC (ocm, ocn, on) or the 003 is OCoLC.
+func oclcNumber(r marc.Record) string {
+	for _, value := range r.GetValues("035", "a") {
+		if strings.HasPrefix(value, "(OCoLC)") {
+			if number := trimOCLCNumber(strings.TrimPrefix(value, "(OCoLC)")); number != "" {
+				return number
+			}
+		}
+	}
+	id := strings.TrimSpace(r.ControlNum())
+	for _, prefix := range []string{"ocm", "ocn", "on"} {
+		if strings.HasPrefix(id, prefix) {
+			return trimOCLCNumber(strings.TrimPrefix(id, prefix))
+		}
+	}
+	if strings.TrimSpace(r.GetValue("003", "")) == "OCoLC" {
+		return trimOCLCNumber(id)
+	}
+	return ""
+}
+
+// trimOCLCNumber returns the digits of an OCLC number without the
+// prefixes or leading zeros, empty if it is not a number.
+func trimOCLCNumber(value string) string {
+	value = strings.TrimSpace(value)
+	for _, prefix := range []string{"ocm", "ocn", "on"} {
+		value = strings.TrimPrefix(value, prefix)
+	}
+	value = strings.TrimLeft(value, "0")
+	if value == "" || strings.Trim(value, "0123456789") != "" {
+		return ""
+	}
+	return value
+}
+
+// worldcatClient calls the WorldCat Metadata API with an access token
+// requested with the client credentials of a WSKey, a new token is
+// requested when it is about to expire.
+type worldcatClient struct {
+	ctx     context.Context
+	key     string
+	secret  string
+	token   string
+	expires time.Time
+	client  *http.Client
+}
+
+func newWorldCatClient(ctx context.Context, key string, secret string) *worldcatClient {
+	return &worldcatClient{
+		ctx:    ctx,
+		key:    key,
+		secret: secret,
+		client: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// accessToken returns the current token or requests a new one.
+func (wc *worldcatClient) accessToken() (string, error) {
+	if wc.token != "" && time.Now().Before(wc.expires) {
+		return wc.token, nil
+	}
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {"WorldCatMetadataAPI"}}
+	req, err := http.NewRequestWithContext(wc.ctx, http.MethodPost, oclcTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(wc.key, wc.secret)
+	resp, err := wc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot get an access token from OCLC: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid access token from OCLC: %s", bytes.TrimSpace(data))
+	}
+	wc.token = token.AccessToken
+	// Renew the token a minute before it expires.
+	wc.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return wc.token, nil
+}
+
+// bib returns the master record with the OCLC number given.
+func (wc *worldcatClient) bib(number string) (marc.Record, error) {
+	data, err := wc.get("/manage/bibs/"+url.PathEscape(number), "application/marcxml+xml")
+	if err != nil {
+		return marc.Record{}, err
+	}
+	file := marc.NewMarcFile(bytes.NewReader(data))
+	r, err := file.Next()
+	if err == io.EOF {
+		return marc.Record{}, errNotInWorldCat
+	}
+	if err != nil {
+		return marc.Record{}, fmt.Errorf("invalid master record %s from WorldCat: %w", number, err)
+	}
+	return r, nil
+}
+
+// searchISBN returns the OCLC number of the first record in WorldCat
+// with the ISBN given.
+func (wc *worldcatClient) searchISBN(isbn string) (string, error) {
+	query := url.Values{"q": {"bn:" + isbn}, "limit": {"1"}}
+	data, err := wc.get("/search/brief-bibs?"+query.Encode(), "application/json")
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		BriefRecords []struct {
+			OCLCNumber string `json:"oclcNumber"`
+		} `json:"briefRecords"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("invalid response from WorldCat: %w", err)
+	}
+	if len(result.BriefRecords) == 0 || result.BriefRecords[0].OCLCNumber == "" {
+		return "", errNotInWorldCat
+	}
+	return result.BriefRecords[0].OCLCNumber, nil
+}
+
+// get requests a path of the API, retrying when the API is too busy.
+func (wc *worldcatClient) get(path string, accept string) ([]byte, error) {
+	backoff := worldcatFirstBackoff
+	for attempt := 0; ; attempt++ {
+		data, err := wc.getOnce(path, accept)
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt == worldcatMaxRetries {
+			return data, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s, retrying in %s\n", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-wc.ctx.Done():
+			return nil, wc.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (wc *worldcatClient) getOnce(path string, accept string) ([]byte, error) {
+	token, err := wc.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(wc.ctx, http.MethodGet, worldcatAPI+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", accept)
+	resp, err := wc.client.Do(req)
+	if err != nil {
+		if wc.ctx.Err() != nil {
+			return nil, wc.ctx.Err()
+		}
+		return nil, retryableError{err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, retryableError{err}
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errNotInWorldCat
+	case resp.StatusCode == http.StatusUnauthorized:
+		// The token was revoked or expired early, request a new one.
+		wc.token = ""
+		return nil, retryableError{fmt.Errorf("error calling WorldCat: %s", resp.Status)}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, retryableError{fmt.Errorf("error calling WorldCat: %s", resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("error calling WorldCat: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
@@ -1,124 +1,162 @@
 package main
 
 import (
-	"encoding/xml"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/hectorcorrea/marcli/pkg/marc"
 )
 
-type controlField struct {
-	Tag   string `xml:"tag,attr"`
-	Value string `xml:",chardata"`
+var xmlIndent, xmlCollection bool
+var xmlDir string
+
+func init() {
+	registerFormat(processorFormat{
+		name:        "xml",
+		description: "MARC XML",
+		contentType: "application/xml",
+		newProcessor: func(params ProcessFileParams) (Processor, error) {
+			if xmlDir != "" {
+				return newXMLDirProcessor(params, xmlDir)
+			}
+			return NewProcessorXML(params)
+		},
+		setFlags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&xmlIndent, "indent", false, "Indent the elements in the XML output.")
+			fs.BoolVar(&xmlCollection, "collection", true, "Wrap the records in a <collection> element, when false only the <record> elements are output.")
+			fs.StringVar(&xmlDir, "dir", "", "Directory where to write each record as a standalone MARC XML document named by its 001 (e.g. ocm57175940.xml) instead of writing the records to the output.")
+		},
+	})
 }
 
-type subfield struct {
-	Code  string `xml:"code,attr"`
-	Value string `xml:",chardata"`
-}
-type dataField struct {
-	Tag       string     `xml:"tag,attr"`
-	Ind1      string     `xml:"ind1,attr"`
-	Ind2      string     `xml:"ind2,attr"`
-	Subfields []subfield `xml:"subfield"`
+// ProcessorXML outputs the records in a <collection> document, or as a
+// stream of <record> elements (-xml.collection=false).
+type ProcessorXML struct {
+	filters    marc.FieldFilters
+	exclude    marc.FieldFilters
+	plan       *marc.FilterPlan
+	indent     string
+	collection bool
 }
 
-type xmlRecord struct {
-	XMLName       xml.Name       `xml:"record"`
-	Leader        string         `xml:"leader"`
-	ControlFields []controlField `xml:"controlfield"`
-	DataFields    []dataField    `xml:"datafield"`
+func NewProcessorXML(params ProcessFileParams) (ProcessorXML, error) {
+	p := ProcessorXML{filters: params.filters, exclude: params.exclude, plan: params.plan, collection: xmlCollection}
+	if xmlIndent || params.debug {
+		p.indent = " "
+	}
+	return p, nil
 }
 
-const xmlProlog = `<?xml version="1.0" encoding="UTF-8"?>`
-const xmlRootBegin = `<collection xmlns="http://www.loc.gov/MARC21/slim" xmlns:marc="http://www.loc.gov/MARC21/slim">`
-const xmlRootEnd = `</collection>`
+func (p ProcessorXML) Tags() []string {
+	return filterTags(p.filters)
+}
 
-func toXML(params ProcessFileParams) error {
-	if count == 0 {
+func (p ProcessorXML) Header(w io.Writer) error {
+	if !p.collection {
 		return nil
 	}
+	_, err := fmt.Fprintf(w, "%s\n%s\n", marc.XMLProlog, marc.XMLCollectionBegin)
+	return err
+}
+
+func (p ProcessorXML) ProcessRecord(w io.Writer, r marc.Record) error {
+	return processRendered(p, w, r)
+}
 
-	file, err := os.Open(params.filename)
+func (p ProcessorXML) RenderRecord(r marc.Record) ([]byte, error) {
+	r.Fields = p.plan.Filter(r)
+	b, err := marc.AppendXML(renderBuffer(), r, p.indent)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
-
-	fmt.Printf("%s\n%s\n", xmlProlog, xmlRootBegin)
-
-	var i, out int
-	marc := marc.NewMarcFile(file)
-	for marc.Scan() {
+	return append(b, "\r\n"...), nil
+}
 
-		r, err := marc.Record()
-		if err == io.EOF {
-			break
-		}
+func (p ProcessorXML) WriteRendered(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	releaseRendered(b)
+	return err
+}
 
-		if err != nil {
-			printError(r, "PARSE ERROR", err)
-			if params.debug {
-				continue
-			}
-			return err
-		}
-
-		if i++; i < start {
-			continue
-		}
-
-		if r.Contains(params.searchValue, params.searchFields) && r.HasFields(params.hasFields) {
-			str, err := recordToXML(r, params)
-			if err != nil {
-				if params.debug {
-					printError(r, "XML PARSE ERROR", err)
-					continue
-				}
-				panic(err)
-			}
-			fmt.Printf("%s\r\n", str)
-			if out++; out == count {
-				break
-			}
-		}
+func (p ProcessorXML) Footer(w io.Writer) error {
+	if !p.collection {
+		return nil
 	}
-	fmt.Printf("%s\n", xmlRootEnd)
+	_, err := fmt.Fprintf(w, "%s\n", marc.XMLCollectionEnd)
+	return err
+}
 
-	return marc.Err()
+// xmlDirProcessor writes each record as a standalone MARC XML document
+// in -xml.dir instead of writing it to the output. The name of the file
+// depends on the records written before, so the records are written
+// when they are processed rather than rendered concurrently (see
+// recordRenderer).
+type xmlDirProcessor struct {
+	filters marc.FieldFilters
+	plan    *marc.FilterPlan
+	indent  string
+	dir     string
+	// names are the files written to dir, to add a suffix to the
+	// records with the same 001.
+	names map[string]int
 }
 
-func recordToXML(r marc.Record, params ProcessFileParams) (string, error) {
-	x := xmlRecord{
-		Leader: r.Leader.Raw(),
+func newXMLDirProcessor(params ProcessFileParams, dir string) (*xmlDirProcessor, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	p := &xmlDirProcessor{filters: params.filters, plan: params.plan, dir: dir, names: map[string]int{}}
+	if xmlIndent || params.debug {
+		p.indent = " "
 	}
+	return p, nil
+}
 
-	for _, f := range r.Filter(params.filters, params.exclude) {
-		if f.IsControlField() {
-			x.ControlFields = append(x.ControlFields, controlField{Tag: f.Tag, Value: f.Value})
-		} else {
-			df := dataField{Tag: f.Tag, Ind1: f.Indicator1, Ind2: f.Indicator2}
-			for _, s := range f.SubFields {
-				df.Subfields = append(df.Subfields, subfield{Code: s.Code, Value: s.Value})
-			}
-			x.DataFields = append(x.DataFields, df)
-		}
+func (p *xmlDirProcessor) Tags() []string {
+	tags := filterTags(p.filters)
+	if tags == nil {
+		return nil
 	}
+	// For the names of the files.
+	return append(tags, "001")
+}
+
+func (p *xmlDirProcessor) Header(w io.Writer) error {
+	return nil
+}
 
-	indent := ""
-	if params.debug {
-		indent = " "
+func (p *xmlDirProcessor) ProcessRecord(w io.Writer, r marc.Record) error {
+	name := xmlFileName(r)
+	p.names[name]++
+	if n := p.names[name]; n > 1 {
+		name = fmt.Sprintf("%s-%d", name, n)
 	}
-	b, err := xml.MarshalIndent(x, indent, indent)
-	return string(b), err
+	r.Fields = p.plan.Filter(r)
+	doc, err := marc.EncodeXMLDocument(r, p.indent)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(p.dir, name+".xml"), doc, 0644)
 }
 
-func printError(r marc.Record, errType string, err error) {
-	str := "== RECORD WITH ERROR STARTS HERE\n"
-	str += fmt.Sprintf("%s:\n%s\n", errType, err.Error())
-	str += r.DebugString() + "\n"
-	str += "== RECORD WITH ERROR ENDS HERE\n\n"
-	fmt.Print(str)
+func (p *xmlDirProcessor) Footer(w io.Writer) error {
+	return nil
+}
+
+var xmlFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// xmlFileName returns the name of the file of a record in -xml.dir: its
+// 001 with the characters that are not safe in file names replaced, or
+// its position in the file if it does not have an 001.
+func xmlFileName(r marc.Record) string {
+	name := xmlFileNameChars.ReplaceAllString(strings.TrimSpace(r.ControlNum()), "_")
+	if strings.Trim(name, "._") == "" {
+		return fmt.Sprintf("record-%d", r.Pos)
+	}
+	return name
 }
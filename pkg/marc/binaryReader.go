@@ -0,0 +1,466 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// truncatedError is the error for a record that is cut short by the
+// end of the data, pos is where the data ends and records the number
+// of complete records before it.
+func truncatedError(pos int64, records int) error {
+	return fmt.Errorf("%w at byte %d after record %d", ErrTruncated, pos, records)
+}
+
+// paddingLength returns the number of bytes of padding at the beginning
+// of the data: whitespace (e.g. the CR/LF added by some exports), NUL
+// bytes, and blank records that are nothing but padding followed by a
+// record terminator (seen in exports with fixed size blocks). Records
+// start with their length in digits so padding is never part of them.
+func paddingLength(data []byte) int {
+	n := 0
+	for n < len(data) {
+		switch data[n] {
+		case '\n', '\r', ' ', '\t', 0, rt:
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// BinaryReader reads records in MARC binary (ISO 2709) format.
+type BinaryReader struct {
+	scanner *bufio.Scanner
+	start   int64 // position of the current record
+	next    int64 // position of the next record
+	max     int   // see SetMaxRecordSize
+	tags    tagSet
+	mode    ParseMode
+	skip    bool // the records are being skipped, see Skip
+	records int  // number of records read (or skipped)
+	// truncated indicates that the last record read has no record
+	// terminator, i.e. the data ended in the middle of it.
+	truncated bool
+}
+
+// NewBinaryReader creates a reader for MARC binary data.
+func NewBinaryReader(r io.Reader) *BinaryReader {
+	// For MARC binary files uses a Scanner() to read the
+	// contents of the file (stolen from https://github.com/MITLibraries/fml)
+	br := &BinaryReader{scanner: bufio.NewScanner(r)}
+	br.SetMaxRecordSize(DefaultMaxRecordSize)
+
+	br.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		split := splitFunc
+		if br.skip {
+			split = skipSplitFunc
+		}
+		// Skip the padding between records.
+		ws := paddingLength(data)
+		br.next += int64(ws)
+		advance, token, err := split(data[ws:], atEOF)
+		if token != nil {
+			br.start = br.next
+			br.records++
+			br.truncated = advance == len(token)
+		}
+		br.next += int64(advance)
+		return ws + advance, token, err
+	})
+	return br
+}
+
+func splitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, rt); i >= 0 {
+		return i + 1, data[0:i], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		// The last record has no record terminator.
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+// skipSplitFunc is like splitFunc but uses the length of the record in
+// the leader to jump to the end of the record, as long as the record
+// terminator is where the length says. This avoids looking at the data
+// of records that are going to be skipped anyway.
+func skipSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if length, ok := recordLength(data); ok && len(data) >= length && data[length-1] == rt {
+		return length, data[0 : length-1], nil
+	}
+	// Records that are not in the buffer yet (or have a wrong length)
+	// are found as usual.
+	return splitFunc(data, atEOF)
+}
+
+// recordLength returns the length of the record at the beginning of
+// the data according to its leader, ok is false if the leader does not
+// have a valid length.
+func recordLength(data []byte) (length int, ok bool) {
+	if len(data) < 5 {
+		return 0, false
+	}
+	length, ok = parseDigits(string(data[:5]))
+	return length, ok && length > leaderLength
+}
+
+// Skip skips the next n records without parsing them. It returns the
+// number of records skipped, which is less than n at the end of the
+// data.
+func (br *BinaryReader) Skip(n int) (int, error) {
+	br.skip = true
+	defer func() { br.skip = false }()
+	for i := 0; i < n; i++ {
+		if !br.scanner.Scan() {
+			return i, br.Err()
+		}
+	}
+	return n, nil
+}
+
+// Next returns the next record, io.EOF when there are no more records.
+func (br *BinaryReader) Next() (Record, error) {
+	recBytes, pos, err := br.nextRaw()
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{Pos: pos}
+	if err := br.truncatedError(); err != nil {
+		rec.Data = recBytes
+		return rec, err
+	}
+	err = makeRecordFromBinary(&rec, recBytes, br.tags, br.mode)
+	return rec, err
+}
+
+// truncatedError returns an error if the data ended in the middle of
+// the last record read.
+func (br *BinaryReader) truncatedError() error {
+	if !br.truncated {
+		return nil
+	}
+	return truncatedError(br.next, br.records-1)
+}
+
+// SetMaxRecordSize sets the size of the largest record accepted, larger
+// records stop the reading with ErrRecordTooLarge. It must be called
+// before reading the first record.
+func (br *BinaryReader) SetMaxRecordSize(max int) {
+	// By default Scanner.Scan() returns "bufio.Scanner: token too long" if
+	// the block to read is longer than 64K. Since MARC records can be up to
+	// 100K we use a custom value. See https://stackoverflow.com/a/37455465/446681
+	initial := 64 * 1024
+	if max < initial {
+		initial = max
+	}
+	br.max = max
+	// The record terminator is part of the block read.
+	br.scanner.Buffer(make([]byte, 0, initial+1), max+1)
+}
+
+// SetTags limits the fields parsed to the ones with the given tags,
+// nil parses all the fields. See MarcFile.SetTags.
+func (br *BinaryReader) SetTags(tags []string) {
+	br.tags = newTagSet(tags)
+}
+
+// SetParseMode sets how the records are parsed, see ParseMode.
+func (br *BinaryReader) SetParseMode(mode ParseMode) {
+	br.mode = mode
+}
+
+// nextRaw returns the bytes of the next record (without parsing them)
+// and its position, io.EOF when there are no more records.
+func (br *BinaryReader) nextRaw() ([]byte, int64, error) {
+	if !br.scanner.Scan() {
+		if err := br.Err(); err != nil {
+			return nil, 0, err
+		}
+		return nil, 0, io.EOF
+	}
+	// Use our own copy of the bytes since the scanner reuses its
+	// buffer on the next call to Scan().
+	return append([]byte(nil), br.scanner.Bytes()...), br.start, nil
+}
+
+// Offset returns the position where the next record starts.
+func (br *BinaryReader) Offset() int64 {
+	return br.next
+}
+
+// Err returns the error reading the data (if any).
+func (br *BinaryReader) Err() error {
+	err := br.scanner.Err()
+	if err == bufio.ErrTooLong {
+		// The scanner stops at the beginning of the record.
+		return recordTooLargeError(br.next, br.max)
+	}
+	return err
+}
+
+// makeRecordFromBinary parses the bytes of a record (without the record
+// terminator). The record keeps a reference to recBytes in rec.Data,
+// unless the parse mode fixed problems in the record (see Warnings).
+// If tags is not nil only the fields with those tags are parsed.
+func makeRecordFromBinary(rec *Record, recBytes []byte, tags tagSet, mode ParseMode) error {
+	// Parse the bytes from the scanner to create the MARC Record.
+	err := parseBytesIntoRecord(rec, recBytes, mode)
+	if err != nil {
+		return err
+	}
+
+	start := rec.Leader.dataOffset
+	if mode.strict() {
+		if err := checkRecordStructure(rec, recBytes); err != nil {
+			return err
+		}
+	}
+	if mode.repair() {
+		start = repairBaseAddress(rec, recBytes, start)
+	}
+	// TODO: make this magic number a constant
+	if start <= 25 {
+		return ErrBadDataOffset
+	} else if start > len(recBytes) {
+		return ErrBadRecordLength
+	}
+	dirs, err := checkDirectory(rec, recBytes[leaderLength:start-1], recBytes[start:], mode)
+	if err != nil {
+		return err
+	}
+	if tags != nil {
+		err = processSelectedFields(recBytes[start:], dirs, tags, mode, rec)
+	} else {
+		// Convert the data to a string once, the values of the fields
+		// are substrings of it.
+		err = processDataIntoRecord(string(recBytes[start:]), dirs, mode, rec)
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkFixedFields(rec, mode); err != nil {
+		return err
+	}
+	if len(rec.Warnings) > 0 {
+		// The problems were fixed in the fields or the leader.
+		rec.dropData()
+	}
+	return nil
+}
+
+// checkRecordStructure returns an error if the leader is not valid or
+// the record length and the base address of data in the leader do not
+// match the data, see ParseStrict.
+func checkRecordStructure(rec *Record, recBytes []byte) error {
+	if err := rec.Leader.Validate(); err != nil {
+		return err
+	}
+	// recBytes does not include the record terminator.
+	if length := rec.Leader.RecordLength(); length != len(recBytes)+1 {
+		return fmt.Errorf("%w: the leader says %d bytes, the record has %d", ErrBadRecordLength, length, len(recBytes)+1)
+	}
+	if start := rec.Leader.dataOffset; start <= leaderLength || start > len(recBytes) || recBytes[start-1] != ft {
+		return fmt.Errorf("%w: %d is not the end of the directory", ErrBadDataOffset, start)
+	}
+	return nil
+}
+
+func parseBytesIntoRecord(rec *Record, recBytes []byte, mode ParseMode) error {
+	rec.Data = recBytes
+	leaderBytes := rec.Data
+	if len(leaderBytes) > leaderLength {
+		leaderBytes = leaderBytes[:leaderLength]
+	}
+	leader, err := NewLeader(leaderBytes)
+	if err != nil && mode.repair() && len(leaderBytes) == leaderLength {
+		leader, err = repairLeader(rec, leaderBytes, recBytes)
+	}
+	if err != nil {
+		return err
+	}
+	rec.Leader = leader
+
+	return nil
+}
+
+// processDataIntoRecord parses the fields from the bytes of the record
+// (which are already in memory) using the directory to locate them.
+func processDataIntoRecord(data string, dirs []byte, mode ParseMode, rec *Record) error {
+	if n := len(dirs) / directoryEntry; n > 0 {
+		rec.Fields = make(Fields, 0, n)
+	}
+	for len(dirs) >= directoryEntry {
+		tag := tagString(dirs[:tagEnd])
+		length, ok := parseDigits(string(dirs[lengthOfFieldStart:lengthOfFieldEnd]))
+		if !ok {
+			return ErrUnknownFieldLength
+		}
+		begin, ok := parseDigits(string(dirs[startCharPosStart:startCharPosEnd]))
+		if !ok {
+			return ErrUnknownFieldStart
+		}
+		if len(data) <= begin+length-1 {
+			details := fmt.Sprintf("Tag: %s, len(data): %d, begin: %d, field length: %d",
+				tag, len(data), begin, length)
+			return newIncorrectFieldLengthError(details)
+		}
+		fdata := fieldData(data, begin, length)
+		if keepFieldData(rec, tag, fdata, mode) {
+			df, err := parseField(rec, tag, fdata, mode)
+			if err != nil {
+				return err
+			}
+			rec.Fields = append(rec.Fields, df)
+		}
+		dirs = dirs[directoryEntry:]
+	}
+	if len(rec.Fields) == 0 {
+		rec.Fields = nil
+	}
+	return nil
+}
+
+// processSelectedFields is like processDataIntoRecord but only parses
+// the fields with the given tags. Only the bytes of those fields are
+// converted, the rest of the data is never looked at.
+func processSelectedFields(data []byte, dirs []byte, tags tagSet, mode ParseMode, rec *Record) error {
+	for ; len(dirs) >= directoryEntry; dirs = dirs[directoryEntry:] {
+		if !tags[string(dirs[:tagEnd])] {
+			continue
+		}
+		tag := tagString(dirs[:tagEnd])
+		length, ok := parseDigits(string(dirs[lengthOfFieldStart:lengthOfFieldEnd]))
+		if !ok {
+			return ErrUnknownFieldLength
+		}
+		begin, ok := parseDigits(string(dirs[startCharPosStart:startCharPosEnd]))
+		if !ok {
+			return ErrUnknownFieldStart
+		}
+		if len(data) <= begin+length-1 {
+			details := fmt.Sprintf("Tag: %s, len(data): %d, begin: %d, field length: %d",
+				tag, len(data), begin, length)
+			return newIncorrectFieldLengthError(details)
+		}
+		// length includes field terminator
+		var fdata string
+		if length > 0 {
+			fdata = string(data[begin : begin+length-1])
+		}
+		if keepFieldData(rec, tag, fdata, mode) {
+			df, err := parseField(rec, tag, fdata, mode)
+			if err != nil {
+				return err
+			}
+			rec.Fields = append(rec.Fields, df)
+		}
+	}
+	return nil
+}
+
+// fieldData returns the data of the field at begin with the length in
+// the directory, which includes the field terminator. A length of zero
+// is a field without data (and without terminator).
+func fieldData(data string, begin int, length int) string {
+	if length == 0 {
+		return ""
+	}
+	return data[begin : begin+length-1]
+}
+
+// keepFieldData returns true if the field with the given data must be
+// parsed. Control fields are parsed even if they are empty (unless
+// the mode says otherwise, see ParseDropEmptyFields). Data fields that
+// are too short to have indicators and a subfield are illegal data and
+// are ignored.
+func keepFieldData(rec *Record, tag string, fdata string, mode ParseMode) bool {
+	if isControlTag(tag) {
+		return fdata != "" || keepEmptyControlField(rec, tag, mode)
+	}
+	// TODO: make this magic number a constant
+	return len(fdata) > 4
+}
+
+// parseField creates a field from its data in MARC binary, in strict
+// mode the data is checked and in lenient mode malformed indicators
+// are fixed first.
+func parseField(rec *Record, tag string, data string, mode ParseMode) (Field, error) {
+	if !isControlTag(tag) {
+		switch {
+		case mode.strict():
+			if err := checkDataField(tag, data, st, ' '); err != nil {
+				return Field{}, err
+			}
+		case mode.lenient():
+			data = fixIndicators(rec, tag, data, st, ' ')
+		}
+	}
+	return makeField(rec, tag, data, mode)
+}
+
+// numericTags has the strings for the tags 000 to 999 so that the tags
+// of the fields do not need to be allocated for each record.
+var numericTags = func() (tags [1000]string) {
+	for i := range tags {
+		tags[i] = fmt.Sprintf("%03d", i)
+	}
+	return tags
+}()
+
+// tagString returns the tag in the directory as a string.
+func tagString(b []byte) string {
+	if n, ok := parseDigits(string(b)); ok && len(b) == 3 {
+		return numericTags[n]
+	}
+	return string(b)
+}
+
+// tagSet is the set of tags of the fields to parse, nil means all.
+type tagSet map[string]bool
+
+func newTagSet(tags []string) tagSet {
+	if tags == nil {
+		return nil
+	}
+	set := tagSet{}
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return set
+}
+
+// filter returns the fields with the tags in the set.
+func (tags tagSet) filter(fields Fields) Fields {
+	var selected Fields
+	for _, field := range fields {
+		if tags[field.Tag] {
+			selected = append(selected, field)
+		}
+	}
+	return selected
+}
+
+// parseDigits parses a number in the directory or the leader, which
+// must have only ASCII digits (strconv.Atoi also accepts a sign). The
+// callers pass string(b[i:j]), which does not allocate since the string
+// does not escape.
+func parseDigits(b string) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
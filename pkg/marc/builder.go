@@ -0,0 +1,123 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// DefaultLeader is the leader used for new records. The record length
+// and base address of data are calculated when the record is marshaled.
+const DefaultLeader = "00000nam a2200000 i 4500"
+
+const (
+	maxRecordLength = 99999
+	maxFieldLength  = 9999
+	directoryEntry  = 12 // length of a directory entry
+)
+
+var ErrRecordTooLong = errors.New("record is too long for MARC binary")
+
+// NewRecord creates an empty record with the default leader.
+func NewRecord() Record {
+	leader, _ := NewLeader([]byte(DefaultLeader))
+	return Record{Leader: leader}
+}
+
+// SetLeader replaces the leader of the record.
+func (r *Record) SetLeader(value string) error {
+	leader, err := NewLeader([]byte(value))
+	if err != nil {
+		return err
+	}
+	r.Leader = leader
+	return nil
+}
+
+// AddControlField appends a control field (e.g. 001) to the record.
+func (r *Record) AddControlField(tag, value string) {
+	r.Fields = append(r.Fields, Field{Tag: tag, Value: value})
+}
+
+// AddDataField appends a data field to the record. An empty indicator
+// is stored as a blank.
+//
+//	r.AddDataField("650", " ", "0",
+//		SubField{Code: "a", Value: "Coal"},
+//		SubField{Code: "x", Value: "Analysis."})
+func (r *Record) AddDataField(tag, ind1, ind2 string, subfields ...SubField) {
+	field := Field{
+		Tag:        tag,
+		Indicator1: blankIfEmpty(ind1),
+		Indicator2: blankIfEmpty(ind2),
+		SubFields:  subfields,
+	}
+	r.Fields = append(r.Fields, field)
+}
+
+// Marshal returns the record in MARC binary format (including the record
+// terminator). The directory, the record length, and the base address of
+// data in the leader are calculated from the fields in the record.
+func (r Record) Marshal() ([]byte, error) {
+	var dirs, data bytes.Buffer
+	for _, f := range r.Fields {
+		if len(f.Tag) != 3 {
+			return nil, fmt.Errorf("invalid tag %q", f.Tag)
+		}
+
+		start := data.Len()
+		if f.IsControlField() {
+			data.WriteString(f.Value)
+		} else {
+			data.WriteString(blankIfEmpty(f.Indicator1))
+			data.WriteString(blankIfEmpty(f.Indicator2))
+			for _, sub := range f.SubFields {
+				data.WriteByte(st)
+				data.WriteString(sub.Code)
+				data.WriteString(sub.Value)
+			}
+		}
+		data.WriteByte(ft)
+
+		length := data.Len() - start
+		if length > maxFieldLength || start > maxRecordLength {
+			return nil, fmt.Errorf("%w: field %s", ErrRecordTooLong, f.Tag)
+		}
+		fmt.Fprintf(&dirs, "%s%04d%05d", f.Tag, length, start)
+	}
+	dirs.WriteByte(ft)
+
+	leader := r.Leader
+	if len(leader.raw) != leaderLength {
+		leader, _ = NewLeader([]byte(DefaultLeader))
+	}
+	base := leaderLength + dirs.Len()
+	if err := leader.SetRecordLength(base + data.Len() + 1); err != nil {
+		return nil, ErrRecordTooLong
+	}
+	if err := leader.SetDataOffset(base); err != nil {
+		return nil, ErrRecordTooLong
+	}
+
+	out := make([]byte, 0, base+data.Len()+1)
+	out = append(out, leader.raw...)
+	out = append(out, dirs.Bytes()...)
+	out = append(out, data.Bytes()...)
+	return append(out, rt), nil
+}
+
+// hasBinaryData returns true if the record has the original MARC binary
+// data, i.e. it was read from a MARC binary file rather than from XML,
+// MRK, or JSON, or built from scratch.
+func (r Record) hasBinaryData() bool {
+	return len(r.Data) > leaderLength &&
+		string(r.Data[:leaderLength]) == r.Leader.Raw() &&
+		r.Data[len(r.Data)-1] == ft
+}
+
+func blankIfEmpty(value string) string {
+	if value == "" {
+		return " "
+	}
+	return value
+}
@@ -0,0 +1,84 @@
+package marc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordBuilder(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "test001")
+	r.AddDataField("245", "1", "0",
+		SubField{Code: "a", Value: "A title :"},
+		SubField{Code: "b", Value: "with a subtitle."})
+	r.AddDataField("650", "", "0", SubField{Code: "a", Value: "Coal"})
+
+	b, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	got, err := NewBinaryReader(bytes.NewReader(b)).Next()
+	if err != nil {
+		t.Fatalf("error reading marshaled record: %v", err)
+	}
+
+	if !cmp.Equal(r.Fields, got.Fields) {
+		t.Error(cmp.Diff(r.Fields, got.Fields))
+	}
+	if got.Leader.RecordLength() != len(b) {
+		t.Errorf("expected record length %d, got %d", len(b), got.Leader.RecordLength())
+	}
+	if got.Leader.DataOffset() != leaderLength+3*directoryEntry+1 {
+		t.Errorf("unexpected base address %d", got.Leader.DataOffset())
+	}
+	if got.Fields.GetValue("650", "a") != "Coal" || got.Fields[2].Indicator1 != " " {
+		t.Errorf("unexpected 650 field %v", got.Fields[2])
+	}
+}
+
+func TestMarshal_SameAsOriginal(t *testing.T) {
+	t.Parallel()
+
+	record := setUpTestRecord("testdata/test_1a.mrc", t)
+
+	got, err := record.Marshal()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(record.Raw(), got) {
+		t.Errorf("expected %q\n\ngot %q", record.Raw(), got)
+	}
+}
+
+func TestMarshal_InvalidTag(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("1", "bad tag")
+	if _, err := r.Marshal(); err == nil {
+		t.Error("expected error for invalid tag")
+	}
+}
+
+func TestSetLeader(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	if r.Leader.Raw() != DefaultLeader {
+		t.Errorf("expected default leader, got %q", r.Leader.Raw())
+	}
+	if err := r.SetLeader("00000cas a2200000 a 4500"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if r.Leader.BibLevel != 's' {
+		t.Errorf("expected bib level s, got %c", r.Leader.BibLevel)
+	}
+	if err := r.SetLeader("short"); err == nil {
+		t.Error("expected error for short leader")
+	}
+}
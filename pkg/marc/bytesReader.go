@@ -0,0 +1,109 @@
+package marc
+
+import (
+	"bytes"
+	"io"
+)
+
+// BytesReader reads records in MARC binary format from data that is
+// already in memory (e.g. a memory-mapped file, see OpenMmap). Records
+// are parsed directly from the data without copying it: Record.Data
+// references the data given to NewBytesReader, so the data must not
+// be modified (or unmapped) while the records are in use.
+type BytesReader struct {
+	data []byte
+	pos  int
+	max  int
+	tags tagSet
+	mode ParseMode
+	// records is the number of records read (or skipped)
+	records int
+}
+
+// NewBytesReader creates a reader for MARC binary data in memory.
+func NewBytesReader(data []byte) *BytesReader {
+	return &BytesReader{data: data, max: DefaultMaxRecordSize}
+}
+
+// Next returns the next record, io.EOF when there are no more records.
+func (br *BytesReader) Next() (Record, error) {
+	br.pos += paddingLength(br.data[br.pos:])
+	if br.pos >= len(br.data) {
+		return Record{}, io.EOF
+	}
+
+	start := br.pos
+	end := len(br.data)
+	br.records++
+	if i := bytes.IndexByte(br.data[start:], rt); i >= 0 {
+		end = start + i
+		br.pos = end + 1
+	} else {
+		br.pos = end
+		rec := Record{Pos: int64(start), Data: br.data[start:end:end]}
+		return rec, truncatedError(int64(end), br.records-1)
+	}
+
+	if end-start > br.max {
+		// Since the data is already in memory we can skip the record
+		// and continue with the next one.
+		return Record{Pos: int64(start)}, recordTooLargeError(int64(start), br.max)
+	}
+
+	// Limit the capacity so that appending to rec.Data (e.g. in
+	// Record.Raw) does not overwrite the next record.
+	recBytes := br.data[start:end:end]
+	rec := Record{Pos: int64(start)}
+	err := makeRecordFromBinary(&rec, recBytes, br.tags, br.mode)
+	return rec, err
+}
+
+// SetMaxRecordSize sets the size of the largest record parsed, larger
+// records are reported with ErrRecordTooLarge.
+func (br *BytesReader) SetMaxRecordSize(max int) {
+	br.max = max
+}
+
+// SetTags limits the fields parsed to the ones with the given tags,
+// nil parses all the fields. See MarcFile.SetTags.
+func (br *BytesReader) SetTags(tags []string) {
+	br.tags = newTagSet(tags)
+}
+
+// SetParseMode sets how the records are parsed, see ParseMode.
+func (br *BytesReader) SetParseMode(mode ParseMode) {
+	br.mode = mode
+}
+
+// Skip skips the next n records without parsing them. It returns the
+// number of records skipped, which is less than n at the end of the
+// data. The length in the leader is used to jump to the next record
+// when the record terminator is where the length says.
+func (br *BytesReader) Skip(n int) (int, error) {
+	for i := 0; i < n; i++ {
+		br.pos += paddingLength(br.data[br.pos:])
+		if br.pos >= len(br.data) {
+			return i, nil
+		}
+		data := br.data[br.pos:]
+		br.records++
+		if length, ok := recordLength(data); ok && length <= len(data) && data[length-1] == rt {
+			br.pos += length
+		} else if j := bytes.IndexByte(data, rt); j >= 0 {
+			br.pos += j + 1
+		} else {
+			br.pos = len(br.data)
+		}
+	}
+	return n, nil
+}
+
+// Offset returns the position where the next record starts.
+func (br *BytesReader) Offset() int64 {
+	return int64(br.pos)
+}
+
+// Err always returns nil since all the data is already in memory.
+func (br *BytesReader) Err() error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package marc
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// CallNumberScheme is the classification of a call number.
+type CallNumberScheme int
+
+const (
+	// CallNumberOther is a call number that is not LC or Dewey (e.g. a
+	// local scheme or an accession number).
+	CallNumberOther CallNumberScheme = iota
+	// CallNumberLC is a Library of Congress call number, e.g. QA76.73.C15
+	// S55 1999.
+	CallNumberLC
+	// CallNumberDewey is a Dewey Decimal call number, e.g. 813.54 K58s.
+	CallNumberDewey
+)
+
+func (s CallNumberScheme) String() string {
+	switch s {
+	case CallNumberLC:
+		return "lc"
+	case CallNumberDewey:
+		return "dewey"
+	}
+	return "other"
+}
+
+var lcCallNumber = regexp.MustCompile(`^([A-Z]{1,3}) ?(\d{1,4})(\.\d+)?(.*)$`)
+var deweyCallNumber = regexp.MustCompile(`^(\d{3})(\.\d+)?(.*)$`)
+
+// CallNumberSortKey returns a key to sort call numbers in shelf order
+// with a plain string comparison, and the scheme of the call number.
+// The class numbers are compared as numbers (QA9 before QA76 and 330
+// before 330.1), the Cutter numbers as decimals (.C65 before .C7), and
+// the numbers after them (years, volumes, and copies) as integers (v.9
+// before v.10). Dewey call numbers go before LC call numbers, and these
+// before the rest, which are compared ignoring case and punctuation.
+func CallNumberSortKey(value string) (string, CallNumberScheme) {
+	value = strings.ToUpper(strings.Join(strings.Fields(value), " "))
+	if m := deweyCallNumber.FindStringSubmatch(value); m != nil {
+		key := "0 " + m[1] + m[2]
+		return appendCallNumberKey(key, m[3]), CallNumberDewey
+	}
+	if m := lcCallNumber.FindStringSubmatch(value); m != nil {
+		// The letters of the class are padded so that Q goes before QA,
+		// and the number so that QA9 goes before QA76.
+		key := "1 " + m[1] + strings.Repeat(" ", 3-len(m[1])) + " "
+		key += strings.Repeat("0", 4-len(m[2])) + m[2] + m[3]
+		return appendCallNumberKey(key, m[4]), CallNumberLC
+	}
+	return appendCallNumberKey("2", value), CallNumberOther
+}
+
+// appendCallNumberKey appends the keys of the parts of the call number
+// after the class to the key, separated with spaces so that a call
+// number goes before the ones that start with it. A letter followed by
+// digits is a Cutter number and the digits are a decimal, other numbers
+// are padded to be compared as integers.
+func appendCallNumberKey(key string, value string) string {
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, part := range parts {
+		for part != "" {
+			i := strings.IndexFunc(part, unicode.IsDigit)
+			if i == -1 {
+				key += " " + part
+				break
+			}
+			j := strings.IndexFunc(part[i:], func(r rune) bool { return !unicode.IsDigit(r) })
+			if j == -1 {
+				j = len(part)
+			} else {
+				j += i
+			}
+			letters, digits := part[:i], part[i:j]
+			switch {
+			case len(letters) == 1:
+				key += " " + letters + digits
+			case letters != "":
+				key += " " + letters + " " + padCallNumber(digits)
+			default:
+				key += " " + padCallNumber(digits)
+			}
+			part = part[j:]
+		}
+	}
+	return key
+}
+
+// padCallNumber pads the number with zeros to compare it as an integer.
+func padCallNumber(digits string) string {
+	digits = strings.TrimLeft(digits, "0")
+	if len(digits) >= 9 {
+		return digits
+	}
+	return strings.Repeat("0", 9-len(digits)) + digits
+}
@@ -0,0 +1,61 @@
+package marc
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCallNumberSortKey(t *testing.T) {
+	t.Parallel()
+
+	// In shelf order.
+	callNumbers := []string{
+		"330 .B12",
+		"330.1 A12",
+		"330.12 A12",
+		"330.9 A12",
+		"813.54 K58s",
+		"813.54 K6",
+		"Q180.A1 B2",
+		"QA9 .C3",
+		"QA76 .C65",
+		"QA76.C65 1999",
+		"QA76.C651",
+		"QA76.C7",
+		"QA76.73.C15 S55 1999",
+		"QA76.73.C15 S55 1999 v.2",
+		"QA76.73.C15 S55 1999 v.10",
+		"qa 76.8 .A1",
+		"QA760 .B3",
+		"QD1 .A1",
+		"Folio 12",
+		"Microfilm 9",
+		"Microfilm 10",
+	}
+	schemes := map[string]CallNumberScheme{
+		"330 .B12":    CallNumberDewey,
+		"813.54 K58s": CallNumberDewey,
+		"QA76.C7":     CallNumberLC,
+		"qa 76.8 .A1": CallNumberLC,
+		"Folio 12":    CallNumberOther,
+	}
+
+	shuffled := append([]string{}, callNumbers...)
+	sort.Strings(shuffled)
+	sort.SliceStable(shuffled, func(i, j int) bool {
+		a, _ := CallNumberSortKey(shuffled[i])
+		b, _ := CallNumberSortKey(shuffled[j])
+		return a < b
+	})
+	for i := range callNumbers {
+		if shuffled[i] != callNumbers[i] {
+			t.Fatalf("wrong order, got:\n%q\nwant:\n%q", shuffled, callNumbers)
+		}
+	}
+
+	for value, want := range schemes {
+		if _, got := CallNumberSortKey(value); got != want {
+			t.Errorf("CallNumberSortKey(%q) scheme = %v, want %v", value, got, want)
+		}
+	}
+}
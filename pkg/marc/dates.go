@@ -0,0 +1,125 @@
+package marc
+
+import "strconv"
+
+// PublicationDates are the type of date/publication status (008/06) and
+// the dates (008/07-10 and 008/11-14) of a bibliographic record, see
+// https://www.loc.gov/marc/bibliographic/bd008a.html
+type PublicationDates struct {
+	Type  byte
+	Date1 string
+	Date2 string
+}
+
+var dateTypeDescriptions = map[byte]string{
+	'b': "No dates given; B.C. date involved",
+	'c': "Continuing resource currently published",
+	'd': "Continuing resource ceased publication",
+	'e': "Detailed date",
+	'i': "Inclusive dates of collection",
+	'k': "Range of years of bulk of collection",
+	'm': "Multiple dates",
+	'n': "Dates unknown",
+	'p': "Date of distribution/release/issue and production/recording session when different",
+	'q': "Questionable date",
+	'r': "Reprint/reissue date and original date",
+	's': "Single known date/probable date",
+	't': "Publication date and copyright date",
+	'u': "Continuing resource status unknown",
+	'|': "No attempt to code",
+}
+
+// PublicationDates returns the dates in the 008 of the record. It
+// returns false for records without an 008 long enough and for records
+// that are not bibliographic (e.g. authority or holdings records).
+func (r Record) PublicationDates() (PublicationDates, bool) {
+	switch r.Leader.Type {
+	case 'q', 'u', 'v', 'w', 'x', 'y', 'z':
+		return PublicationDates{}, false
+	}
+	value := r.GetValue("008", "")
+	if len(value) < 15 {
+		return PublicationDates{}, false
+	}
+	return PublicationDates{Type: value[6], Date1: value[7:11], Date2: value[11:15]}, true
+}
+
+// TypeDescription returns the description of the type of date.
+func (d PublicationDates) TypeDescription() string {
+	if description, ok := dateTypeDescriptions[d.Type]; ok {
+		return description
+	}
+	return "Invalid value"
+}
+
+// Decade returns the decade of the Date1 (e.g. 1960 for 1965 or 196u),
+// or false if the decade is not known: the type is b (B.C.), n
+// (unknown), or | (not coded), or the date has more than the last digit
+// unknown (e.g. 19uu).
+func (d PublicationDates) Decade() (int, bool) {
+	switch d.Type {
+	case 'b', 'n', '|':
+		return 0, false
+	}
+	year, ok := parseYear(d.Date1[:3] + "0")
+	if !ok || (d.Date1[3] != 'u' && !isDigit(d.Date1[3])) {
+		return 0, false
+	}
+	return year, true
+}
+
+// Span returns the first and the last year of the resource: the years
+// between Date1 and Date2 for the types with a range (continuing
+// resources, collections, multiple dates, and questionable dates) and
+// Date1 for the rest. The unknown digits are the lowest possible value
+// in the first year and the highest one in the last year (e.g.
+// 1960-1969 for 196u). The last year is 9999 for the continuing
+// resources that are still published. It returns false if Date1 is not
+// a year or the type is b, n, or |.
+func (d PublicationDates) Span() (first int, last int, ok bool) {
+	switch d.Type {
+	case 'b', 'n', '|':
+		return 0, 0, false
+	}
+	first, ok = parseYear(replaceUnknown(d.Date1, '0'))
+	if !ok {
+		return 0, 0, false
+	}
+	last, _ = parseYear(replaceUnknown(d.Date1, '9'))
+	switch d.Type {
+	case 'c', 'd', 'i', 'k', 'm', 'q', 'u':
+		if end, ok := parseYear(replaceUnknown(d.Date2, '9')); ok && end >= first {
+			last = end
+		}
+	}
+	return first, last, true
+}
+
+// parseYear returns the year in a date of four digits.
+func parseYear(value string) (int, bool) {
+	if len(value) != 4 {
+		return 0, false
+	}
+	for i := 0; i < len(value); i++ {
+		if !isDigit(value[i]) {
+			return 0, false
+		}
+	}
+	year, err := strconv.Atoi(value)
+	return year, err == nil
+}
+
+// replaceUnknown replaces the unknown digits (u) of a date.
+func replaceUnknown(value string, digit byte) string {
+	b := []byte(value)
+	for i := range b {
+		if b[i] == 'u' {
+			b[i] = digit
+		}
+	}
+	return string(b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
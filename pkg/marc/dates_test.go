@@ -0,0 +1,49 @@
+package marc
+
+import "testing"
+
+func TestPublicationDates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value       string
+		decade      int
+		first, last int
+		ok          bool
+	}{
+		{value: "041207s2004    dcu", decade: 2000, first: 2004, last: 2004, ok: true},
+		{value: "041207s196u    dcu", decade: 1960, first: 1960, last: 1969, ok: true},
+		{value: "041207q19uu    dcu", decade: 0, first: 1900, last: 1999, ok: true},
+		{value: "041207c19859999dcu", decade: 1980, first: 1985, last: 9999, ok: true},
+		{value: "041207d19701985dcu", decade: 1970, first: 1970, last: 1985, ok: true},
+		{value: "041207m19501949dcu", decade: 1950, first: 1950, last: 1950, ok: true},
+		{value: "041207r19991850dcu", decade: 1990, first: 1999, last: 1999, ok: true},
+		{value: "041207nuuuuuuuudcu"},
+		{value: "041207b        dcu"},
+		{value: "041207s    1999dcu"},
+	}
+	for _, tt := range tests {
+		r := Record{Fields: Fields{{Tag: "008", Value: tt.value}}}
+		dates, ok := r.PublicationDates()
+		if !ok {
+			t.Fatalf("%q: expected the dates", tt.value)
+		}
+		decade, ok := dates.Decade()
+		if decade != tt.decade || ok != (tt.decade != 0) {
+			t.Errorf("%q: Decade() = %d, %v, want %d", tt.value, decade, ok, tt.decade)
+		}
+		first, last, ok := dates.Span()
+		if first != tt.first || last != tt.last || ok != tt.ok {
+			t.Errorf("%q: Span() = %d, %d, %v, want %d, %d, %v", tt.value, first, last, ok, tt.first, tt.last, tt.ok)
+		}
+	}
+
+	auth := Record{Leader: Leader{Type: 'z'}, Fields: Fields{{Tag: "008", Value: "041207n| azannaabn          |a aaa      "}}}
+	if _, ok := auth.PublicationDates(); ok {
+		t.Errorf("expected no dates for an authority record")
+	}
+	short := Record{Fields: Fields{{Tag: "008", Value: "041207s"}}}
+	if _, ok := short.PublicationDates(); ok {
+		t.Errorf("expected no dates for a short 008")
+	}
+}
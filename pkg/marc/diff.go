@@ -0,0 +1,178 @@
+package marc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+// in a unified diff.
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ' unchanged, '-' removed, '+' added
+	line string
+}
+
+// Diff returns a unified diff between two records in mnemonic (MRK) form.
+// The result is an empty string when both records render identically.
+//
+// This is used to preview the changes that an editing operation would
+// make to a record before writing it out.
+func Diff(before, after Record) string {
+	return DiffWithLabels(before, after, "before", "after")
+}
+
+// DiffWithLabels is like Diff but with the labels given to identify each
+// record in the header of the diff, e.g. when comparing a local record
+// with the one from another catalog.
+func DiffWithLabels(before, after Record, beforeLabel, afterLabel string) string {
+	a := mnemonicLines(before)
+	b := mnemonicLines(after)
+	ops := diffLines(a, b)
+
+	changed := false
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	id := strings.TrimSpace(before.ControlNum())
+	str := fmt.Sprintf("--- %s (%s)\n", id, beforeLabel)
+	str += fmt.Sprintf("+++ %s (%s)\n", id, afterLabel)
+	for _, h := range diffHunks(ops) {
+		str += h
+	}
+	return str
+}
+
+// mnemonicLines returns the lines of the record as they are
+// displayed in MRK format.
+func mnemonicLines(r Record) []string {
+	lines := []string{r.Leader.String()}
+	for _, field := range r.Fields {
+		lines = append(lines, field.String())
+	}
+	return lines
+}
+
+// diffLines calculates the edit script between a and b using the
+// longest common subsequence of lines.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		} else {
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// diffHunks groups the edit script into unified diff hunks, each one
+// with up to diffContext lines of unchanged context around the changes.
+func diffHunks(ops []diffOp) []string {
+	hunks := []string{}
+	i := 0
+	for i < len(ops) {
+		// Find the next change
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk until we find more than 2*diffContext
+		// unchanged lines in a row (or the end of the script).
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		hunks = append(hunks, formatHunk(ops, start, end))
+		i = end
+	}
+	return hunks
+}
+
+func formatHunk(ops []diffOp, start, end int) string {
+	// Line numbers (1-based) of the first line of the hunk in each version.
+	aLine, bLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			aLine++
+		}
+		if op.kind != '-' {
+			bLine++
+		}
+	}
+
+	aCount, bCount := 0, 0
+	body := ""
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			aCount++
+		}
+		if op.kind != '-' {
+			bCount++
+		}
+		body += fmt.Sprintf("%c%s\n", op.kind, op.line)
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount) + body
+}
@@ -0,0 +1,70 @@
+package marc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	before := setUpTestRecord("testdata/test_1a.mrc", t)
+
+	if got := Diff(before, before); got != "" {
+		t.Errorf("expected no differences, got %q", got)
+	}
+
+	after := before
+	after.Fields = append([]Field{}, before.Fields...)
+	for i, field := range after.Fields {
+		if field.Tag == "245" {
+			after.Fields[i] = Field{
+				Tag:        "245",
+				Indicator1: "1",
+				Indicator2: "0",
+				SubFields:  []SubField{{Code: "a", Value: "New title"}},
+			}
+		}
+	}
+
+	got := Diff(before, after)
+	wantLines := []string{
+		"--- ocm57175940 (before)",
+		"+++ ocm57175940 (after)",
+		"@@ -10,7 +10,7 @@",
+		"-=245  10$aGuidelines for sample collecting",
+		"+=245  10$aNew title",
+	}
+	for _, want := range wantLines {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected diff to contain %q, got\n%s", want, got)
+		}
+	}
+}
+
+func TestDiffWithLabels(t *testing.T) {
+	t.Parallel()
+
+	local := setUpTestRecord("testdata/test_1a.mrc", t)
+	master := local
+	master.Fields = append([]Field{}, local.Fields...)
+	master.Fields = append(master.Fields, Field{Tag: "500", Indicator1: " ", Indicator2: " ", SubFields: []SubField{{Code: "a", Value: "Note"}}})
+
+	got := DiffWithLabels(local, master, "local", "WorldCat")
+	if !strings.HasPrefix(got, "--- ocm57175940 (local)\n+++ ocm57175940 (WorldCat)\n") {
+		t.Errorf("expected the diff to use the labels given, got\n%s", got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	t.Parallel()
+
+	a := []string{"1", "2", "3"}
+	b := []string{"1", "3", "4"}
+	want := "@@ -1,3 +1,3 @@\n 1\n-2\n 3\n+4\n"
+
+	got := strings.Join(diffHunks(diffLines(a, b)), "")
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
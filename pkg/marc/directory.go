@@ -0,0 +1,114 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrDirectoryMismatch is returned when the directory of a MARC binary
+// record does not match its data, i.e. a field does not end with a field
+// terminator where the directory says. Parsing such a record would
+// produce values shifted by some bytes, see ParseRepair.
+var ErrDirectoryMismatch = errors.New("directory does not match the data")
+
+// checkDirectory verifies that each field in the directory ends with a
+// field terminator in the data. In ParseRepair mode a directory that
+// does not match is rebuilt from the data, the directory to use is
+// returned. Entries that point outside of the data are reported later
+// on when parsing the fields.
+func checkDirectory(rec *Record, dirs []byte, data []byte, mode ParseMode) ([]byte, error) {
+	for entry := dirs; len(entry) >= directoryEntry; entry = entry[directoryEntry:] {
+		length, okLength := parseDigits(string(entry[lengthOfFieldStart:lengthOfFieldEnd]))
+		begin, okBegin := parseDigits(string(entry[startCharPosStart:startCharPosEnd]))
+		end := begin + length - 1 // position of the field terminator
+		if okLength && okBegin && length > 0 && end < len(data) && data[end] == ft {
+			continue
+		}
+		if okLength && okBegin && length == 0 && begin <= len(data) {
+			// A field without data, see fieldData.
+			continue
+		}
+		if mode.repair() {
+			return rebuildDirectory(rec, dirs, data)
+		}
+		if okLength && okBegin && length > 0 && end < len(data) {
+			return nil, fmt.Errorf("%w: field %s (position %d, length %d) does not end with a field terminator",
+				ErrDirectoryMismatch, entry[:tagEnd], begin, length)
+		}
+	}
+	return dirs, nil
+}
+
+// rebuildDirectory returns a directory with the tags of the original
+// one and the position and length of the fields as delimited by the
+// field terminators in the data. The directory cannot be rebuilt if the
+// number of fields in the data does not match the number of entries.
+func rebuildDirectory(rec *Record, dirs []byte, data []byte) ([]byte, error) {
+	entries := len(dirs) / directoryEntry
+	if fields := bytes.Count(data, []byte{ft}); fields != entries {
+		return nil, fmt.Errorf("%w: cannot rebuild the directory, it has %d entries but the data has %d fields",
+			ErrDirectoryMismatch, entries, fields)
+	}
+
+	rebuilt := make([]byte, 0, len(dirs))
+	begin := 0
+	for i := 0; i < entries; i++ {
+		length := bytes.IndexByte(data[begin:], ft) + 1
+		if length > maxFieldLength || begin > maxRecordLength {
+			return nil, fmt.Errorf("%w: cannot rebuild the directory, the data is too long", ErrDirectoryMismatch)
+		}
+		rebuilt = append(rebuilt, dirs[i*directoryEntry:i*directoryEntry+tagEnd]...)
+		rebuilt = append(rebuilt, fmt.Sprintf("%04d%05d", length, begin)...)
+		begin += length
+	}
+	rec.addWarning("", "the directory does not match the data, rebuilt from the field terminators")
+	return rebuilt, nil
+}
+
+// repairLeader returns a leader with sane defaults in the positions of
+// the leader that are needed to parse the record and are not valid:
+// the record length and the base address of data are calculated from
+// the data, and the indicator count, subfield code count, and entry map
+// get their only valid values. The leader cannot be repaired if the end
+// of the directory cannot be found.
+func repairLeader(rec *Record, leaderBytes []byte, recBytes []byte) (Leader, error) {
+	raw := append([]byte(nil), leaderBytes...)
+	fix := func(start int, value string, what string) {
+		if string(raw[start:start+len(value)]) == value {
+			return
+		}
+		rec.addWarning("", "invalid %s (%02d-%02d) %q in the leader replaced with %q",
+			what, start, start+len(value)-1, raw[start:start+len(value)], value)
+		copy(raw[start:], value)
+	}
+
+	if _, ok := parseDigits(string(raw[offsetStart:offsetEnd])); !ok {
+		i := bytes.IndexByte(recBytes[leaderLength:], ft)
+		if i < 0 {
+			return NewLeader(leaderBytes)
+		}
+		fix(offsetStart, fmt.Sprintf("%05d", leaderLength+i+1), "base address of data")
+	}
+	if _, ok := parseDigits(string(raw[0:5])); !ok && len(recBytes) < maxRecordLength {
+		fix(0, fmt.Sprintf("%05d", len(recBytes)+1), "record length")
+	}
+	fix(10, "22", "indicator and subfield code counts")
+	fix(20, "4500", "entry map")
+	return NewLeader(raw)
+}
+
+// repairBaseAddress returns the position where the data of the record
+// starts (right after the directory) when the base address in the
+// leader does not point there.
+func repairBaseAddress(rec *Record, recBytes []byte, start int) int {
+	if start > leaderLength+1 && start <= len(recBytes) && recBytes[start-1] == ft {
+		return start
+	}
+	i := bytes.IndexByte(recBytes[leaderLength:], ft)
+	if i < 0 {
+		return start
+	}
+	rec.addWarning("", "base address of data %d does not match the end of the directory, using %d", start, leaderLength+i+1)
+	return leaderLength + i + 1
+}
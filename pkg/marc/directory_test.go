@@ -0,0 +1,114 @@
+package marc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckDirectory(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "The title"})
+	r.AddDataField("650", " ", "0", SubField{Code: "a", Value: "Coal"})
+	good, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+
+	// corrupt returns a copy of the record with the bytes at pos
+	// replaced with value.
+	corrupt := func(pos int, value string) []byte {
+		data := append([]byte(nil), good...)
+		copy(data[pos:], value)
+		return data
+	}
+	// The 245 is the second entry in the directory, its length is
+	// at 39-42.
+	shifted := corrupt(leaderLength+directoryEntry+lengthOfFieldStart, "0011")
+	badBase := corrupt(offsetStart, "00030")
+	// A field without an entry in the directory, the directory cannot
+	// be rebuilt.
+	extraField := append(append([]byte(nil), shifted[:len(shifted)-1]...), []byte("  \x1faExtra\x1e\x1d")...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mode     ParseMode
+		wantErr  error
+		warnings int
+	}{
+		{name: "good", data: good},
+		{name: "good repair", data: good, mode: ParseRepair},
+		{name: "shifted", data: shifted, wantErr: ErrDirectoryMismatch},
+		{name: "shifted repair", data: shifted, mode: ParseRepair, warnings: 1},
+		{name: "bad base repair", data: badBase, mode: ParseRepair, warnings: 1},
+		{name: "extra field repair", data: extraField, mode: ParseRepair, wantErr: ErrDirectoryMismatch},
+	}
+
+	for _, tt := range tests {
+		file := NewMarcFileBytes(tt.data)
+		file.SetParseMode(tt.mode)
+		got, err := file.Next()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if diff := cmp.Diff(r.Fields, got.Fields); diff != "" {
+			t.Errorf("%s: fields mismatch (-want +got):\n%s", tt.name, diff)
+		}
+		if len(got.Warnings) != tt.warnings {
+			t.Errorf("%s: expected %d warnings, got %v", tt.name, tt.warnings, got.Warnings)
+		}
+	}
+
+	// The repaired records are encoded with the new directory and base
+	// address, they can be read without repairing them again.
+	for name, data := range map[string][]byte{"shifted": shifted, "bad base": badBase} {
+		encoded := encodeAndRead(t, NewMarcFileBytes(data), ParseRepair)
+		if diff := cmp.Diff(r.Fields, encoded.Fields); diff != "" {
+			t.Errorf("%s: encoded fields mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestRepairLeader(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "The title"})
+	good, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+	bad := append([]byte(nil), good...)
+	copy(bad, "ZZZZZnam a22ZZZZZ i 0000")
+
+	file := NewMarcFileBytes(bad)
+	if _, err := file.Next(); !errors.Is(err, ErrInvalidLeader) {
+		t.Errorf("expected error %v, got %v", ErrInvalidLeader, err)
+	}
+
+	file = NewMarcFileBytes(bad)
+	file.SetParseMode(ParseRepair)
+	got, err := file.Next()
+	if err != nil {
+		t.Fatalf("error reading record: %v", err)
+	}
+	if want := string(good[:leaderLength]); got.Leader.Raw() != want {
+		t.Errorf("expected leader %q, got %q", want, got.Leader.Raw())
+	}
+	if diff := cmp.Diff(r.Fields, got.Fields); diff != "" {
+		t.Errorf("fields mismatch (-want +got):\n%s", diff)
+	}
+	if len(got.Warnings) != 3 {
+		t.Errorf("expected 3 warnings, got %v", got.Warnings)
+	}
+}
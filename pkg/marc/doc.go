@@ -0,0 +1,28 @@
+// Package marc parses MARC records in MARC binary (ISO 2709) or MARC XML
+// format.
+//
+// The command line tool in cmd/marcli is built on top of this package but
+// the package can be used on its own. For example, to print the control
+// number of every record in a file:
+//
+//	file, err := os.Open("data/test_10.mrc")
+//	if err != nil {
+//		return err
+//	}
+//	defer file.Close()
+//
+//	marcFile := marc.NewMarcFile(file)
+//	for marcFile.Scan() {
+//		r, err := marcFile.Record()
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(r.ControlNum())
+//	}
+//	return marcFile.Err()
+//
+// The exported API of this package follows semantic versioning: it does
+// not change in incompatible ways within a major version. Identifiers
+// that are superseded are marked as Deprecated (with their replacement)
+// and are kept until the next major version, see CHANGELOG.md.
+package marc
@@ -1,20 +1,19 @@
 package marc
 
 import (
-	"bytes"
 	"errors"
-	"fmt"
 	"strings"
 )
 
 var (
 	ErrInvalidIndicators  = errors.New("invalid Indicators detected")
 	ErrBadSubfieldsLength = errors.New("bad SubFields length")
+	ErrInvalidSubfield    = errors.New("invalid subfield")
 )
 
 // Field represents a field inside a MARC record. Notice that the
-// field could be a "control" field (tag 001-009) or a "data" field
-// (any other tag)
+// field could be a "control" field (tag 001-009, or a local tag set
+// with SetControlTags) or a "data" field (any other tag)
 //
 // For example in:
 //
@@ -37,6 +36,9 @@ type Field struct {
 	SubFields  []SubField // for Data fields
 }
 
+// Fields is a list of fields, typically the fields of a record.
+type Fields []Field
+
 // SubField contains a Code and a Value.
 // For example in:
 //
@@ -53,20 +55,30 @@ type SubField struct {
 	Value string
 }
 
-// MakeField creates a field object with the data received.
+// MakeField creates a field object with the data received. Empty
+// subfields are dropped.
 func MakeField(tag string, data []byte) (Field, error) {
+	return makeField(nil, tag, string(data), ParseDefault)
+}
+
+// makeField creates a field from data that is already a string. The
+// values of the field (indicators, subfield codes and values) are
+// substrings of data, i.e. they share its memory instead of allocating
+// a new string for each of them. Empty subfields are handled as
+// indicated by the mode, see keepEmptySubfield.
+func makeField(rec *Record, tag string, data string, mode ParseMode) (Field, error) {
 	f := Field{}
 	f.Tag = tag
 
 	// It's a control field
-	if strings.HasPrefix(tag, "00") {
-		f.Value = string(data)
+	if isControlTag(tag) {
+		f.Value = data
 		return f, nil
 	}
 
 	if len(data) > 2 {
-		f.Indicator1 = string(data[0])
-		f.Indicator2 = string(data[1])
+		f.Indicator1 = data[0:1]
+		f.Indicator2 = data[1:2]
 	} else {
 		return f, ErrInvalidIndicators
 	}
@@ -75,28 +87,80 @@ func MakeField(tag string, data []byte) (Field, error) {
 		return f, ErrBadSubfieldsLength
 	}
 
-	for _, sf := range bytes.Split(data[3:], []byte{st}) {
-		if len(sf) > 1 {
-			f.SubFields = append(f.SubFields, SubField{string(sf[0]), string(sf[1:])})
+	subfields := data[2:]
+	f.SubFields = make([]SubField, 0, strings.Count(subfields, string(rune(st))))
+	if i := strings.IndexByte(subfields, st); i != 0 {
+		// Data before the first subfield delimiter (or no delimiter).
+		if i < 0 {
+			i = len(subfields)
 		}
+		dropDataBeforeSubfields(rec, tag, subfields[:i], mode)
+		subfields = subfields[i:]
+	}
+	for len(subfields) > 0 {
+		subfields = subfields[1:] // skip the delimiter
+		sf := subfields
+		i := strings.IndexByte(subfields, st)
+		if i >= 0 {
+			sf = subfields[:i]
+		}
+		switch {
+		case len(sf) > 1:
+			f.SubFields = append(f.SubFields, SubField{Code: sf[:1], Value: sf[1:]})
+		case keepEmptySubfield(rec, tag, sf, mode):
+			f.SubFields = append(f.SubFields, SubField{Code: sf})
+		}
+		if i < 0 {
+			break
+		}
+		subfields = subfields[i:]
+	}
+	if len(f.SubFields) == 0 {
+		f.SubFields = nil
 	}
 	return f, nil
 }
 
-// IsControlField returns true if the field is a control field (tag 001-009)
+// IsControlField returns true if the field is a control field (tag 001-009
+// or one of the local tags set with SetControlTags)
 func (f Field) IsControlField() bool {
-	return strings.HasPrefix(f.Tag, "00")
+	return isControlTag(f.Tag)
+}
+
+// controlTags are the local tags of the fields handled as control
+// fields, see SetControlTags.
+var controlTags = map[string]bool{}
+
+// SetControlTags sets the local (non-numeric) tags of the fields that
+// are control fields, i.e. that have a value instead of indicators and
+// subfields. For example, Aleph exports include "FMT" and "SYS" control
+// fields next to "CAT" data fields. Fields with other local tags are
+// data fields, and tags 001-009 are always control fields.
+//
+// The tags apply to all the records parsed afterwards. It is not safe
+// to call SetControlTags while records are being read or created.
+func SetControlTags(tags []string) {
+	set := map[string]bool{}
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	controlTags = set
 }
 
-// Contains returns true if the field contains the passed string.
+func isControlTag(tag string) bool {
+	return strings.HasPrefix(tag, "00") || controlTags[tag]
+}
+
+// Contains returns true if the field contains the passed string,
+// ignoring case (see FoldCase).
 func (f Field) Contains(str string) bool {
-	str = strings.ToLower(str)
+	str = FoldCase(str)
 	if f.IsControlField() {
-		return strings.Contains(strings.ToLower(f.Value), str)
+		return containsFold(f.Value, str)
 	}
 
 	for _, sub := range f.SubFields {
-		if strings.Contains(strings.ToLower(sub.Value), str) {
+		if containsFold(sub.Value, str) {
 			return true
 		}
 	}
@@ -104,14 +168,41 @@ func (f Field) Contains(str string) bool {
 }
 
 func (f Field) String() string {
+	return string(f.AppendMRK(nil))
+}
+
+// AppendMRK appends the field in MRK format (the same value that String
+// returns) to dst and returns the extended buffer. The dollar signs in
+// the values are escaped as {dollar}, like MarcEdit does.
+func (f Field) AppendMRK(dst []byte) []byte {
+	dst = append(dst, '=')
+	dst = append(dst, f.Tag...)
+	dst = append(dst, "  "...)
 	if f.IsControlField() {
-		return fmt.Sprintf("=%s  %s", f.Tag, f.Value)
+		return appendMRKValue(dst, f.Value)
 	}
-	str := fmt.Sprintf("=%s  %s%s", f.Tag, formatIndicator(f.Indicator1), formatIndicator(f.Indicator2))
+	dst = append(dst, formatIndicator(f.Indicator1)...)
+	dst = append(dst, formatIndicator(f.Indicator2)...)
 	for _, sub := range f.SubFields {
-		str += fmt.Sprintf("$%s%s", sub.Code, sub.Value)
+		dst = append(dst, '$')
+		dst = append(dst, sub.Code...)
+		dst = appendMRKValue(dst, sub.Value)
+	}
+	return dst
+}
+
+// appendMRKValue appends the value with the dollar signs, the subfield
+// delimiter in MRK, escaped as {dollar} (see mrkUnescape).
+func appendMRKValue(dst []byte, value string) []byte {
+	for {
+		i := strings.IndexByte(value, '$')
+		if i == -1 {
+			return append(dst, value...)
+		}
+		dst = append(dst, value[:i]...)
+		dst = append(dst, "{dollar}"...)
+		value = value[i+1:]
 	}
-	return str
 }
 
 // GetSubFields returns an array of subfields that match the set of subfields
@@ -131,6 +222,145 @@ func (f Field) GetSubFields(filter string) []SubField {
 	return values
 }
 
+// Clone returns a deep copy of the field, i.e. the copy does not share
+// its subfields with the original.
+func (f Field) Clone() Field {
+	if f.SubFields != nil {
+		f.SubFields = append([]SubField(nil), f.SubFields...)
+	}
+	return f
+}
+
+// Equal returns true if both fields have the same tag and content.
+// For control fields only the value is compared. For data fields the
+// subfields must be in the same order, since the order is significant
+// in MARC (e.g. $a Coal $x History is not $x History $a Coal), and an
+// empty indicator is considered the same as a blank one. Notice that
+// cmp.Equal uses this method to compare fields.
+func (f Field) Equal(other Field) bool {
+	if f.Tag != other.Tag {
+		return false
+	}
+	if f.IsControlField() {
+		return f.Value == other.Value
+	}
+	if blankIfEmpty(f.Indicator1) != blankIfEmpty(other.Indicator1) ||
+		blankIfEmpty(f.Indicator2) != blankIfEmpty(other.Indicator2) ||
+		len(f.SubFields) != len(other.SubFields) {
+		return false
+	}
+	for i, sub := range f.SubFields {
+		if sub != other.SubFields[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SubFieldValue returns the value of the first subfield with the given
+// code, or an empty string if the field has no such subfield.
+func (f Field) SubFieldValue(code string) string {
+	for _, sub := range f.SubFields {
+		if sub.Code == code {
+			return sub.Value
+		}
+	}
+	return ""
+}
+
+// SubFieldValues returns the values of the subfields whose code is in
+// codes (e.g. "abc"), in the order in which they appear in the field.
+// For control fields it returns the value of the field.
+func (f Field) SubFieldValues(codes string) []string {
+	if f.IsControlField() {
+		return []string{f.Value}
+	}
+	values := []string{}
+	for _, sub := range f.SubFields {
+		if strings.Contains(codes, sub.Code) {
+			values = append(values, sub.Value)
+		}
+	}
+	return values
+}
+
+// HasIndicators returns true if the field has the given indicators.
+// An empty string or "*" matches any value. A blank indicator can be
+// given either as a space or as a backslash (like in MRK).
+func (f Field) HasIndicators(ind1, ind2 string) bool {
+	return indicatorMatches(f.Indicator1, ind1) && indicatorMatches(f.Indicator2, ind2)
+}
+
+func indicatorMatches(value, want string) bool {
+	if want == "" || want == "*" {
+		return true
+	}
+	if want == "\\" {
+		want = " "
+	}
+	return value == want
+}
+
+// GetAll returns all the fields with the given tag.
+func (fs Fields) GetAll(tag string) Fields {
+	var fields Fields
+	for _, field := range fs {
+		if field.Tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// GetOne returns the first field with the given tag, false if there
+// is no field with that tag.
+func (fs Fields) GetOne(tag string) (Field, bool) {
+	for _, field := range fs {
+		if field.Tag == tag {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// GetAllWithIndicators returns all the fields with the given tag and
+// indicators (see Field.HasIndicators for the accepted values).
+func (fs Fields) GetAllWithIndicators(tag, ind1, ind2 string) Fields {
+	var fields Fields
+	for _, field := range fs.GetAll(tag) {
+		if field.HasIndicators(ind1, ind2) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// GetValue returns the value of the first subfield with the given code
+// in the fields with the given tag. For control fields the subfield
+// code is ignored and the value of the field is returned.
+func (fs Fields) GetValue(tag, code string) string {
+	for _, field := range fs.GetAll(tag) {
+		if field.IsControlField() {
+			return field.Value
+		}
+		if value := field.SubFieldValue(code); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// GetValues returns the values of all the subfields whose code is in
+// codes (e.g. "ab") in the fields with the given tag. For control fields
+// the subfield codes are ignored and the value of the field is returned.
+func (fs Fields) GetValues(tag, codes string) []string {
+	values := []string{}
+	for _, field := range fs.GetAll(tag) {
+		values = append(values, field.SubFieldValues(codes)...)
+	}
+	return values
+}
+
 func formatIndicator(value string) string {
 	if value == " " {
 		return "\\"
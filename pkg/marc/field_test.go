@@ -5,7 +5,6 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 const dirs = "001001200000005001700012006001900029007000700048008004100055040002300096042000800119043001200127074002000139086001700159100005500176245021100231260008500442336002200527337002400549338003300573440003900606500005400645504004100699538015700740650002000897650002000917700002100937776020100958856006501159907003501224998004501259910001201304910002801316945007501344"
@@ -296,6 +295,222 @@ func TestGetSubFields(t *testing.T) {
 	}
 }
 
+func TestSubFieldValues(t *testing.T) {
+	t.Parallel()
+
+	field := Field{
+		Tag:        "245",
+		Indicator1: "1",
+		Indicator2: "0",
+		SubFields: []SubField{
+			{Code: "a", Value: "Title :"},
+			{Code: "b", Value: "subtitle /"},
+			{Code: "c", Value: "author."},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		field Field
+		codes string
+		want  []string
+	}{
+		{name: "multiple subfields in field order", field: field, codes: "ca", want: []string{"Title :", "author."}},
+		{name: "no matching subfields", field: field, codes: "z", want: []string{}},
+		{name: "control field", field: Field{Tag: "001", Value: "ocm57175940"}, codes: "a", want: []string{"ocm57175940"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.field.SubFieldValues(tt.codes)
+
+			if !cmp.Equal(tt.want, got) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := field.SubFieldValue("b"); got != "subtitle /" {
+		t.Errorf("expected %q, got %q", "subtitle /", got)
+	}
+}
+
+// TestSetControlTags is not parallel since it changes the control tags
+// for all the tests.
+func TestSetControlTags(t *testing.T) {
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddControlField("SYS", "000123456")
+	r.AddDataField("CAT", " ", " ", SubField{Code: "a", Value: "BATCH"})
+	SetControlTags([]string{"FMT", "SYS"})
+	defer SetControlTags(nil)
+	data, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+
+	read := func() (Record, error) {
+		file := NewMarcFileBytes(data)
+		defer file.Close()
+		return file.Next()
+	}
+	got, err := read()
+	if err != nil {
+		t.Fatalf("error reading record: %v", err)
+	}
+	if diff := cmp.Diff(r.Fields, got.Fields); diff != "" {
+		t.Errorf("fields mismatch (-want +got):\n%s", diff)
+	}
+	if field, _ := ParseMrkField("=FMT  BK"); !field.IsControlField() || field.Value != "BK" {
+		t.Errorf("expected FMT control field, got %v", field)
+	}
+
+	// Without SYS as a control tag its value is taken as indicators
+	// and subfields.
+	SetControlTags(nil)
+	got, err = read()
+	if err != nil {
+		t.Fatalf("error reading record: %v", err)
+	}
+	if sys := got.Fields[1]; sys.IsControlField() || sys.Value != "" {
+		t.Errorf("expected SYS data field, got %v", sys)
+	}
+}
+
+func TestFieldString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		field Field
+		want  string
+	}{
+		{field: Field{Tag: "001", Value: "ocm1"}, want: "=001  ocm1"},
+		{field: Field{Tag: "650", Indicator1: " ", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "Coal"}, {Code: "x", Value: "Analysis."}}},
+			want: "=650  \\0$aCoal$xAnalysis."},
+		{field: Field{Tag: "650", Indicator1: " ", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "US$ 5 Coal"}}},
+			want: "=650  \\0$aUS{dollar} 5 Coal"},
+		{field: Field{Tag: "009", Value: "$$"}, want: "=009  {dollar}{dollar}"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.field.String(); got != tt.want {
+			t.Errorf("String(): expected %q, got %q", tt.want, got)
+		}
+		if got := string(tt.field.AppendMRK([]byte("x"))); got != "x"+tt.want {
+			t.Errorf("AppendMRK(): expected %q, got %q", "x"+tt.want, got)
+		}
+	}
+}
+
+func TestHasIndicators(t *testing.T) {
+	t.Parallel()
+
+	field := Field{Tag: "650", Indicator1: " ", Indicator2: "0"}
+
+	tests := []struct {
+		ind1 string
+		ind2 string
+		want bool
+	}{
+		{ind1: "", ind2: "", want: true},
+		{ind1: "*", ind2: "0", want: true},
+		{ind1: " ", ind2: "0", want: true},
+		{ind1: "\\", ind2: "0", want: true},
+		{ind1: "*", ind2: "7", want: false},
+		{ind1: "1", ind2: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := field.HasIndicators(tt.ind1, tt.ind2); got != tt.want {
+			t.Errorf("HasIndicators(%q, %q): expected %t, got %t", tt.ind1, tt.ind2, tt.want, got)
+		}
+	}
+}
+
+func TestFieldsAccessors(t *testing.T) {
+	t.Parallel()
+
+	fields := setUpTestRecord("testdata/test_1a.mrc", t).Fields
+
+	if got := len(fields.GetAll("650")); got != 2 {
+		t.Errorf("expected 2 fields, got %d", got)
+	}
+
+	field, ok := fields.GetOne("245")
+	if !ok || field.SubFieldValue("h") != "[electronic resource] /" {
+		t.Errorf("unexpected 245 field %v", field)
+	}
+	if _, ok := fields.GetOne("999"); ok {
+		t.Errorf("expected no 999 field")
+	}
+
+	if got := len(fields.GetAllWithIndicators("776", "1", "*")); got != 1 {
+		t.Errorf("expected 1 field, got %d", got)
+	}
+	if got := len(fields.GetAllWithIndicators("776", "0", "*")); got != 0 {
+		t.Errorf("expected no fields, got %d", got)
+	}
+
+	if got := fields.GetValue("001", "a"); got != "ocm57175940" {
+		t.Errorf("expected %q, got %q", "ocm57175940", got)
+	}
+	if got := fields.GetValue("100", "d"); got != "1922-1992." {
+		t.Errorf("expected %q, got %q", "1922-1992.", got)
+	}
+
+	want := []string{"Coal", "Analysis.", "Coal", "Sampling."}
+	if got := fields.GetValues("650", "ax"); !cmp.Equal(want, got) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMakeField_Subfields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want []SubField
+	}{
+		{name: "one subfield", data: "10\x1faTitle", want: []SubField{{Code: "a", Value: "Title"}}},
+		{name: "empty subfields are ignored", data: "10\x1fa\x1f\x1fbPart", want: []SubField{{Code: "b", Value: "Part"}}},
+		{name: "no subfields", data: "10\x1fa", want: nil},
+		{name: "data before the first subfield is ignored", data: "10Title\x1fbPart", want: []SubField{{Code: "b", Value: "Part"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MakeField("245", []byte(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !cmp.Equal(tt.want, got.SubFields) {
+				t.Error(cmp.Diff(tt.want, got.SubFields))
+			}
+		})
+	}
+}
+
+// BenchmarkProcessData measures parsing all the fields of a record.
+func BenchmarkProcessData(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rec := Record{}
+		if err := processDataIntoRecord(data, []byte(dirs), ParseDefault, &rec); err != nil {
+			b.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+func BenchmarkMakeField(b *testing.B) {
+	field := []byte("10\x1faGuidelines for sample collecting\x1fh[electronic resource] /\x1fcby Vernon E. Swanson.")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MakeField("245", field); err != nil {
+			b.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
 func setUpDirsAndData(dirs []byte, data []byte, offset int, t *testing.T) (string, []byte) {
 	t.Helper()
 
@@ -308,14 +523,6 @@ func setUpDirsAndData(dirs []byte, data []byte, offset int, t *testing.T) (strin
 	return tag, fdata
 }
 
-func (a Field) Equal(b Field) bool {
-	less := func(a, b string) bool { return a < b }
-	subfieldsEqual := cmp.Equal(a.SubFields, b.SubFields, cmpopts.SortSlices(less))
-	return a.Tag == b.Tag && a.Value == b.Value && a.Indicator1 == b.Indicator1 &&
-		a.Indicator2 == b.Indicator2 &&
-		subfieldsEqual
-}
-
 func compareFields(want, got Field, t *testing.T) {
 	t.Helper()
 
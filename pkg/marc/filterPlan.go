@@ -0,0 +1,403 @@
+package marc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// FilterSpec describes the search criteria and the fields to output.
+// Record.Contains, Record.HasFields, and Record.Filter compile a plan
+// with the values they take.
+type FilterSpec struct {
+	Search       string       // value to search, case insensitive (see FoldCase)
+	SearchFields []string     // tags of the fields to search, all if empty
+	HasFields    FieldFilters // fields that must be present
+	Include      FieldFilters // fields to output, all if empty
+	Exclude      FieldFilters // fields (or subfields) to leave out
+	// MatchedOnly outputs only the fields that contain the search value
+	// and the 001 (see Record.MatchedFields) instead of Include and
+	// Exclude.
+	MatchedOnly bool
+	// Normalization is applied to the search value and to the values
+	// searched, e.g. with NormalizeStrip Gödel is found when searching
+	// godel.
+	Normalization Normalization
+	// Positions are conditions on the leader and the control fields
+	// that the records must meet, all of them.
+	Positions []PositionFilter
+}
+
+// FilterPlan is a compiled FilterSpec. The search value is case folded
+// and the tags and subfield codes are put in sets only once so that
+// evaluating the plan for each record (usually millions of them) does
+// not repeat that work for every field.
+type FilterPlan struct {
+	search      string
+	searchTags  *tagBits // nil to search all the fields
+	hasFields   []compiledFilter
+	include     []compiledFilter
+	exclude     []compiledFilter
+	excludeTag  *tagBits
+	matchedOnly bool
+	// normalization is applied to the values searched, search is
+	// already normalized.
+	normalization Normalization
+	positions     []PositionFilter
+}
+
+type compiledFilter struct {
+	tag        string
+	ind1       string // empty for any value
+	ind2       string
+	occurrence int     // zero for all the fields, see FieldFilter
+	codes      codeSet // empty to select the whole field
+}
+
+// matches is the same as FieldFilter.Matches.
+func (filter *compiledFilter) matches(field Field) bool {
+	if field.Tag != filter.tag {
+		return false
+	}
+	if filter.ind1 != "" && (field.IsControlField() || field.Indicator1 != filter.ind1) {
+		return false
+	}
+	return filter.ind2 == "" || (!field.IsControlField() && field.Indicator2 == filter.ind2)
+}
+
+// selected returns the position of the field that the filter selects
+// when it has an occurrence, or -1 if there is no such field. It returns
+// -1 too for filters without occurrence, see selects.
+func (filter *compiledFilter) selected(fields []Field) int {
+	if filter.occurrence == 0 {
+		return -1
+	}
+	found, last := 0, -1
+	for i, field := range fields {
+		if !filter.matches(field) {
+			continue
+		}
+		found++
+		if found == filter.occurrence {
+			return i
+		}
+		last = i
+	}
+	if filter.occurrence == LastOccurrence {
+		return last
+	}
+	return -1
+}
+
+// selects returns true if the field at position i, which matches the
+// filter, is selected by it given the position returned by selected.
+func (filter *compiledFilter) selects(i int, selected int) bool {
+	return filter.occurrence == 0 || i == selected
+}
+
+// Compile creates the plan for the spec.
+func (spec FilterSpec) Compile() *FilterPlan {
+	plan := &FilterPlan{
+		search:        FoldCase(spec.Normalization.Apply(spec.Search)),
+		hasFields:     compileFilters(spec.HasFields),
+		include:       compileFilters(spec.Include),
+		exclude:       compileFilters(spec.Exclude),
+		matchedOnly:   spec.MatchedOnly,
+		normalization: spec.Normalization,
+		positions:     spec.Positions,
+	}
+	if len(spec.SearchFields) > 0 {
+		plan.searchTags = newTagBits(spec.SearchFields)
+	}
+	tags := []string{}
+	for _, filter := range spec.Exclude.Fields {
+		tags = append(tags, filter.Tag)
+	}
+	plan.excludeTag = newTagBits(tags)
+	return plan
+}
+
+func compileFilters(filters FieldFilters) []compiledFilter {
+	compiled := []compiledFilter{}
+	for _, filter := range filters.Fields {
+		compiled = append(compiled, compiledFilter{
+			tag:        filter.Tag,
+			ind1:       filter.Indicator1,
+			ind2:       filter.Indicator2,
+			occurrence: filter.Occurrence,
+			codes:      newCodeSet(filter.Subfields),
+		})
+	}
+	return compiled
+}
+
+// Match returns true if the record contains the search value (see
+// Record.Contains), has the fields indicated (see Record.HasFields), and
+// meets the conditions on the positions (see PositionFilter.Matches).
+func (plan *FilterPlan) Match(r Record) bool {
+	for _, position := range plan.positions {
+		if !position.Matches(r) {
+			return false
+		}
+	}
+	return plan.contains(r) && plan.has(r)
+}
+
+func (plan *FilterPlan) contains(r Record) bool {
+	if plan.search == "" {
+		return true
+	}
+	for _, field := range r.Fields {
+		if plan.fieldContains(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldContains returns true if the field is one of the fields to
+// search and contains the search value.
+func (plan *FilterPlan) fieldContains(field Field) bool {
+	if plan.searchTags != nil && !plan.searchTags.has(field.Tag) {
+		return false
+	}
+	if field.IsControlField() {
+		return plan.valueContains(field.Value)
+	}
+	for _, sub := range field.SubFields {
+		if plan.valueContains(sub.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (plan *FilterPlan) valueContains(value string) bool {
+	if plan.normalization != NormalizeNone {
+		value = plan.normalization.Apply(value)
+	}
+	return containsFold(value, plan.search)
+}
+
+func (plan *FilterPlan) has(r Record) bool {
+	if len(plan.hasFields) == 0 {
+		// Same as Record.HasFields
+		return len(r.Fields) > 0
+	}
+	for i := range plan.hasFields {
+		filter := &plan.hasFields[i]
+		selected := filter.selected(r.Fields)
+		for j, field := range r.Fields {
+			if !filter.matches(field) || !filter.selects(j, selected) {
+				continue
+			}
+			if filter.codes.empty() {
+				return true
+			}
+			for _, sub := range field.SubFields {
+				if filter.codes.has(sub.Code) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Filter returns the fields of the record to output, the same fields
+// that Record.Filter (or Record.MatchedFields) returns.
+func (plan *FilterPlan) Filter(r Record) []Field {
+	if plan.matchedOnly && plan.search != "" {
+		list := []Field{}
+		for _, field := range r.Fields {
+			if field.Tag == "001" || plan.fieldContains(field) {
+				list = append(list, field)
+			}
+		}
+		return list
+	}
+	if len(plan.include) > 0 {
+		return plan.filterInclude(r)
+	}
+	if len(plan.exclude) > 0 {
+		return plan.filterExclude(r)
+	}
+	return r.Fields
+}
+
+func (plan *FilterPlan) filterInclude(r Record) []Field {
+	list := []Field{}
+	for i := range plan.include {
+		filter := &plan.include[i]
+		selected := filter.selected(r.Fields)
+		for j, field := range r.Fields {
+			if !filter.matches(field) || !filter.selects(j, selected) {
+				continue
+			}
+			if filter.codes.empty() {
+				list = append(list, field)
+				continue
+			}
+			var subfields []SubField
+			for _, sub := range field.SubFields {
+				if filter.codes.has(sub.Code) {
+					subfields = append(subfields, sub)
+				}
+			}
+			if len(subfields) > 0 {
+				field.SubFields = subfields
+				list = append(list, field)
+			}
+		}
+	}
+	return list
+}
+
+func (plan *FilterPlan) filterExclude(r Record) []Field {
+	var selected []int
+	for i := range plan.exclude {
+		if plan.exclude[i].occurrence != 0 {
+			if selected == nil {
+				selected = make([]int, len(plan.exclude))
+			}
+			selected[i] = plan.exclude[i].selected(r.Fields)
+		}
+	}
+	list := []Field{}
+	for j, field := range r.Fields {
+		if !plan.excludeTag.has(field.Tag) {
+			list = append(list, field)
+			continue
+		}
+		include := true
+		for i := range plan.exclude {
+			filter := &plan.exclude[i]
+			if !filter.matches(field) || (selected != nil && !filter.selects(j, selected[i])) {
+				continue
+			}
+			if filter.codes.empty() || field.IsControlField() {
+				include = false
+				break
+			}
+			subfields := []SubField{}
+			for _, sub := range field.SubFields {
+				if !filter.codes.has(sub.Code) {
+					subfields = append(subfields, sub)
+				}
+			}
+			field.SubFields = subfields
+			if len(subfields) == 0 {
+				include = false
+				break
+			}
+		}
+		if include {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+// tagBits is a set of tags. Numeric tags, the vast majority, are kept
+// in a bitset.
+type tagBits struct {
+	numeric [16]uint64 // one bit for each tag from 000 to 999
+	other   map[string]bool
+}
+
+func newTagBits(tags []string) *tagBits {
+	bits := &tagBits{}
+	for _, tag := range tags {
+		if n, ok := tagNumber(tag); ok {
+			bits.numeric[n/64] |= 1 << (n % 64)
+		} else {
+			if bits.other == nil {
+				bits.other = map[string]bool{}
+			}
+			bits.other[tag] = true
+		}
+	}
+	return bits
+}
+
+func (bits *tagBits) has(tag string) bool {
+	if n, ok := tagNumber(tag); ok {
+		return bits.numeric[n/64]&(1<<(n%64)) != 0
+	}
+	return bits.other[tag]
+}
+
+func tagNumber(tag string) (uint, bool) {
+	if len(tag) != 3 {
+		return 0, false
+	}
+	n, ok := parseDigits(tag)
+	return uint(n), ok
+}
+
+// codeSet is a set of subfield codes (e.g. "abu").
+type codeSet struct {
+	codes string
+	ascii [128]bool
+}
+
+func newCodeSet(codes string) codeSet {
+	set := codeSet{codes: codes}
+	for i := 0; i < len(codes); i++ {
+		if codes[i] < utf8.RuneSelf {
+			set.ascii[codes[i]] = true
+		}
+	}
+	return set
+}
+
+func (set *codeSet) empty() bool {
+	return set.codes == ""
+}
+
+// has returns true if the code is in the set. Like strings.Contains,
+// used by Field.GetSubFields, an empty code is always in the set.
+func (set *codeSet) has(code string) bool {
+	if len(code) == 1 && code[0] < utf8.RuneSelf {
+		return set.ascii[code[0]]
+	}
+	return strings.Contains(set.codes, code)
+}
+
+// containsFold returns true if s contains substr, which must be case
+// folded (see FoldCase), ignoring case. ASCII values, the most common,
+// are compared without folding them first.
+func containsFold(s, substr string) bool {
+	if !isASCII(s) || !isASCII(substr) {
+		return strings.Contains(FoldCase(s), substr)
+	}
+	n := len(substr)
+	if n == 0 {
+		return true
+	}
+	for i := 0; i+n <= len(s); i++ {
+		j := 0
+		for j < n && lowerASCII(s[i+j]) == substr[j] {
+			j++
+		}
+		if j == n {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
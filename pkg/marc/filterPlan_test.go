@@ -0,0 +1,174 @@
+package marc
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestFilterPlan checks that the compiled plan gives the same results
+// as the Record methods.
+func TestFilterPlan(t *testing.T) {
+	t.Parallel()
+
+	records := readTestRecords("testdata/test_10.mrc", t)
+	records = append(records, readTestRecords("testdata/test_10.xml", t)...)
+	// A record with unusual values.
+	records = append(records, Record{Fields: Fields{
+		{Tag: "001", Value: "ÁBC123"},
+		{Tag: "LOC", SubFields: []SubField{{Code: "a", Value: "Local"}}},
+		{Tag: "650", SubFields: []SubField{{Code: "", Value: "No code"}, {Code: "ab", Value: "Long code"}, {Code: "ä", Value: "Ñandú"}}},
+	}})
+
+	specs := []FilterSpec{
+		{},
+		{Search: "coal"},
+		{Search: "COAL", SearchFields: []string{"650", "245"}},
+		{Search: "united states", SearchFields: []string{"651"}},
+		{Search: "ñandú"},
+		{Search: "ábc"},
+		{Search: "local", SearchFields: []string{"LOC"}},
+		{HasFields: NewFieldFilters("505")},
+		{HasFields: NewFieldFilters("650x,110")},
+		{HasFields: NewFieldFilters("001a")},
+		{Search: "report", HasFields: NewFieldFilters("245")},
+		{Include: NewFieldFilters("LDR,001,245a,650")},
+		{Include: NewFieldFilters("650xz,001,245ab")},
+		{Include: NewFieldFilters("001a,650ä")},
+		{Include: NewFieldFilters("650b")},
+		{Exclude: NewFieldFilters("945,650x")},
+		{Exclude: NewFieldFilters("001a,245abc,LOC")},
+		{Exclude: NewFieldFilters("650ab")},
+		{HasFields: NewFieldFilters("650{ind2=7}")},
+		{Include: NewFieldFilters("245{ind1=1}a,650{ind2=0}ax,001{ind1=#}")},
+		{Exclude: NewFieldFilters("650{ind2=0}x,245{ind1=0,ind2=0}")},
+		{HasFields: NewFieldFilters("650[3]")},
+		{HasFields: NewFieldFilters("650[last]x")},
+		{Include: NewFieldFilters("001,650[1]a,650{ind2=0}[last]x,856[2]u,500[first]")},
+		{Exclude: NewFieldFilters("650[2],650[last]x,945[1]")},
+	}
+
+	for _, spec := range specs {
+		plan := spec.Compile()
+		for i, r := range records {
+			want := r.Contains(spec.Search, spec.SearchFields) && r.HasFields(spec.HasFields)
+			if got := plan.Match(r); got != want {
+				t.Errorf("%+v: expected match %v for record %d, got %v", spec, want, i, got)
+			}
+			wantFields := r.Filter(spec.Include, spec.Exclude)
+			if gotFields := plan.Filter(r); !cmp.Equal(wantFields, gotFields) {
+				t.Errorf("%+v: unexpected fields for record %d: %s", spec, i, cmp.Diff(wantFields, gotFields))
+			}
+		}
+	}
+}
+
+func TestFilterPlan_MatchedOnly(t *testing.T) {
+	t.Parallel()
+
+	records := readTestRecords("testdata/test_10.mrc", t)
+	specs := []FilterSpec{
+		{Search: "coal", MatchedOnly: true},
+		{Search: "COAL", SearchFields: []string{"650", "245"}, MatchedOnly: true},
+		{Search: "ocm5717", MatchedOnly: true},
+		{Search: "http", SearchFields: []string{"856"}, MatchedOnly: true},
+	}
+	for _, spec := range specs {
+		plan := spec.Compile()
+		for i, r := range records {
+			if !plan.Match(r) {
+				continue
+			}
+			want := r.MatchedFields(spec.Search, spec.SearchFields)
+			if got := plan.Filter(r); !cmp.Equal(want, got) {
+				t.Errorf("%+v: unexpected fields for record %d: %s", spec, i, cmp.Diff(want, got))
+			}
+		}
+	}
+
+	// Only the 001 and the fields with the value are output.
+	r := records[0]
+	got := (FilterSpec{Search: "sampling", MatchedOnly: true}).Compile().Filter(r)
+	tags := []string{}
+	for _, field := range got {
+		tags = append(tags, field.Tag)
+	}
+	if want := []string{"001", "650"}; !cmp.Equal(want, tags) {
+		t.Errorf("expected tags %v, got %v", want, tags)
+	}
+}
+
+func TestContainsFold(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		s      string
+		substr string
+		want   bool
+	}{
+		{s: "Coal Analysis", substr: "coal", want: true},
+		{s: "Coal Analysis", substr: "analysis", want: true},
+		{s: "Coal Analysis", substr: "l a", want: true},
+		{s: "Coal", substr: "coals", want: false},
+		{s: "Coal", substr: "", want: true},
+		{s: "", substr: "a", want: false},
+		{s: "NÚMEROS de Seguro", substr: "números", want: true},
+		{s: "Numeros", substr: "números", want: false},
+		{s: "Große Fuge", substr: "grosse", want: true},
+		{s: "ΟΔΥΣΣΕΥΣ", substr: FoldCase("Οδυσσεύς"), want: false},
+		{s: "ΟΔΥΣΣΕΎΣ", substr: FoldCase("Οδυσσεύς"), want: true},
+	}
+	for _, tt := range tests {
+		if got := containsFold(tt.s, tt.substr); got != tt.want {
+			t.Errorf("containsFold(%q, %q) = %v, want %v", tt.s, tt.substr, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkMatch and BenchmarkMatchPlan compare the Record methods
+// with the compiled plan.
+func BenchmarkMatch(b *testing.B) {
+	records := benchmarkRecords(b)
+	include := NewFieldFilters("001,245a,650")
+	hasFields := NewFieldFilters("650")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range records {
+			if r.Contains("wildlife", []string{"245", "650"}) && r.HasFields(hasFields) {
+				r.Filter(include, FieldFilters{})
+			}
+		}
+	}
+}
+
+func BenchmarkMatchPlan(b *testing.B) {
+	records := benchmarkRecords(b)
+	plan := FilterSpec{
+		Search:       "wildlife",
+		SearchFields: []string{"245", "650"},
+		HasFields:    NewFieldFilters("650"),
+		Include:      NewFieldFilters("001,245a,650"),
+	}.Compile()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range records {
+			if plan.Match(r) {
+				plan.Filter(r)
+			}
+		}
+	}
+}
+
+func benchmarkRecords(b *testing.B) []Record {
+	file := NewMarcFileBytes(benchmarkData(b))
+	var records []Record
+	for {
+		r, err := file.Next()
+		if err != nil {
+			return records
+		}
+		records = append(records, r)
+	}
+}
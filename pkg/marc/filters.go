@@ -3,6 +3,7 @@ package marc
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -10,60 +11,278 @@ type FieldFilters struct {
 	Fields []FieldFilter
 }
 
+// FieldFilter selects the fields with a tag, and optionally with the
+// indicators given or only one occurrence of them, and the subfields to
+// use from them.
 type FieldFilter struct {
-	Tag       string
-	Subfields string
+	Tag        string
+	Subfields  string
+	Indicator1 string // value of the first indicator, any if empty
+	Indicator2 string // value of the second indicator, any if empty
+	// Occurrence selects the nth field (starting at 1) of the ones that
+	// match the tag and indicators, or the last one (LastOccurrence).
+	// All the fields are selected when it is zero.
+	Occurrence int
 }
 
+// LastOccurrence is the Occurrence of the filters that select the last
+// field, e.g. "856[last]u".
+const LastOccurrence = -1
+
 var ErrInvalidFieldString = errors.New("invalid field string (too short)")
 
+// ErrInvalidFieldSpec is wrapped by the errors for field strings with
+// invalid indicator conditions or occurrences.
+var ErrInvalidFieldSpec = errors.New("invalid field spec")
+
 // fieldsStr is a comma delimited string in the format NNNabc,NNNabc
 // where NNN represents the MARC field to output and abc...z represents
 // a set of subfields to include. If no subfields are indicated all
-// subfields for the field are assummed.
+// subfields for the field are assummed. The values of the indicators
+// can be given in braces after the tag (see NewFieldFilter).
 // Example:
 //
 //	"700a" represents MARC field 700, subfield a.
 //	"700ag" represents MARC field 700, subfields a and g.
 //	"700" represents field 700 and all its subfields.
+//	"650{ind2=0}a" represents subfield a of the 650 fields with a second
+//	indicator 0 (i.e. LC subject headings).
+//	"020[1]a" represents subfield a of the first 020 field.
+//
+// Invalid strings result in no filters, use ParseFieldFilters to get
+// the error.
 func NewFieldFilters(fieldsStr string) FieldFilters {
-	if fieldsStr == "" {
+	filters, err := ParseFieldFilters(fieldsStr)
+	if err != nil {
 		return FieldFilters{}
 	}
+	return filters
+}
+
+// ParseFieldFilters is like NewFieldFilters but returns an error when
+// one of the fields in the string is not valid.
+func ParseFieldFilters(fieldsStr string) (FieldFilters, error) {
+	if fieldsStr == "" {
+		return FieldFilters{}, nil
+	}
 	filters := FieldFilters{}
-	for _, value := range strings.Split(fieldsStr, ",") {
+	for _, value := range SplitFieldSpecs(fieldsStr) {
 		filter, err := NewFieldFilter(value)
 		if err != nil {
-			// TODO: handle error
-			return FieldFilters{}
+			return FieldFilters{}, err
 		}
 		filters.Fields = append(filters.Fields, filter)
 	}
-	return filters
+	return filters, nil
 }
 
-// fieldStr is a string in the format NNNabc
+// SplitFieldSpecs splits a comma delimited list of field strings at the
+// commas that are not inside braces, e.g. "245a,650{ind1=#,ind2=0}a" is
+// split in "245a" and "650{ind1=#,ind2=0}a".
+func SplitFieldSpecs(fieldsStr string) []string {
+	values := []string{}
+	depth, start := 0, 0
+	for i := 0; i < len(fieldsStr); i++ {
+		switch fieldsStr[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				values = append(values, fieldsStr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(values, fieldsStr[start:])
+}
+
+// fieldStr is a string in the format NNNabc, optionally followed by the
+// conditions on the indicators (separated by commas) in braces and by
+// the occurrence in brackets after the tag, e.g. "650{ind2=0}a",
+// "245{ind1=1,ind2=0}ab", "856[last]u", or "650{ind2=0}[1]a". A blank
+// indicator can be given as #, _, or \ (as in MRK). The occurrence is a
+// number starting at 1, or first or last.
 func NewFieldFilter(fieldStr string) (FieldFilter, error) {
 	if len(fieldStr) < 3 {
 		return FieldFilter{}, ErrInvalidFieldString
 	}
-	tag := fieldStr[:3]
-	subfields := ""
-	if len(fieldStr) > 3 {
-		subfields = fieldStr[3:]
+	filter := FieldFilter{Tag: fieldStr[:3]}
+	rest := fieldStr[3:]
+	conditions, occurrence := false, false
+	for strings.HasPrefix(rest, "{") || strings.HasPrefix(rest, "[") {
+		closing := "}"
+		if rest[0] == '[' {
+			closing = "]"
+		}
+		end := strings.Index(rest, closing)
+		if end == -1 {
+			return FieldFilter{}, fmt.Errorf("%w %q: missing %s", ErrInvalidFieldSpec, fieldStr, closing)
+		}
+		if rest[0] == '{' {
+			if conditions {
+				return FieldFilter{}, fmt.Errorf("%w %q: conditions given twice", ErrInvalidFieldSpec, fieldStr)
+			}
+			conditions = true
+			for _, condition := range strings.Split(rest[1:end], ",") {
+				if err := filter.setCondition(strings.TrimSpace(condition)); err != nil {
+					return FieldFilter{}, fmt.Errorf("%w %q: %s", ErrInvalidFieldSpec, fieldStr, err)
+				}
+			}
+		} else {
+			if occurrence {
+				return FieldFilter{}, fmt.Errorf("%w %q: occurrence given twice", ErrInvalidFieldSpec, fieldStr)
+			}
+			occurrence = true
+			if err := filter.setOccurrence(strings.TrimSpace(rest[1:end])); err != nil {
+				return FieldFilter{}, fmt.Errorf("%w %q: %s", ErrInvalidFieldSpec, fieldStr, err)
+			}
+		}
+		rest = rest[end+1:]
+	}
+	if strings.ContainsAny(rest, "{}[]") {
+		return FieldFilter{}, fmt.Errorf("%w %q: the conditions and occurrence must be right after the tag", ErrInvalidFieldSpec, fieldStr)
 	}
-	filter := FieldFilter{Tag: tag, Subfields: subfields}
+	filter.Subfields = rest
 	return filter, nil
 }
 
+func (filter *FieldFilter) setOccurrence(value string) error {
+	switch value {
+	case "first":
+		filter.Occurrence = 1
+	case "last":
+		filter.Occurrence = LastOccurrence
+	default:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid occurrence %q, expected a number starting at 1, first, or last", value)
+		}
+		filter.Occurrence = n
+	}
+	return nil
+}
+
+func (filter *FieldFilter) setCondition(condition string) error {
+	i := strings.Index(condition, "=")
+	if i == -1 {
+		return fmt.Errorf("invalid condition %q, expected ind1=X or ind2=X", condition)
+	}
+	name, value := strings.TrimSpace(condition[:i]), condition[i+1:]
+	if value == "#" || value == "_" || value == "\\" {
+		value = " "
+	}
+	if len(value) != 1 {
+		return fmt.Errorf("invalid indicator value %q, indicators are one character", condition[i+1:])
+	}
+	switch name {
+	case "ind1":
+		filter.Indicator1 = value
+	case "ind2":
+		filter.Indicator2 = value
+	default:
+		return fmt.Errorf("invalid condition %q, expected ind1=X or ind2=X", condition)
+	}
+	return nil
+}
+
+// Matches returns true if the field has the tag and the indicators of
+// the filter. Control fields have no indicators, so they never match a
+// filter with indicators.
+func (filter FieldFilter) Matches(field Field) bool {
+	if field.Tag != filter.Tag {
+		return false
+	}
+	if filter.Indicator1 != "" && (field.IsControlField() || field.Indicator1 != filter.Indicator1) {
+		return false
+	}
+	if filter.Indicator2 != "" && (field.IsControlField() || field.Indicator2 != filter.Indicator2) {
+		return false
+	}
+	return true
+}
+
+// Select returns the fields that match the filter, only the one in the
+// occurrence indicated if any.
+func (filter FieldFilter) Select(fields Fields) Fields {
+	if filter.Occurrence != 0 {
+		if i := filter.occurrenceIndex(fields); i != -1 {
+			return Fields{fields[i]}
+		}
+		return Fields{}
+	}
+	list := Fields{}
+	for _, field := range fields {
+		if filter.Matches(field) {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+// occurrenceIndex returns the position in fields of the occurrence
+// selected by the filter, or -1 if there are not as many fields that
+// match the filter.
+func (filter FieldFilter) occurrenceIndex(fields Fields) int {
+	found, last := 0, -1
+	for i, field := range fields {
+		if !filter.Matches(field) {
+			continue
+		}
+		found++
+		if found == filter.Occurrence {
+			return i
+		}
+		last = i
+	}
+	if filter.Occurrence == LastOccurrence {
+		return last
+	}
+	return -1
+}
+
+// String returns the filter in the format accepted by NewFieldFilter.
+func (filter FieldFilter) String() string {
+	conditions := []string{}
+	for i, value := range []string{filter.Indicator1, filter.Indicator2} {
+		if value == " " {
+			value = "#"
+		}
+		if value != "" {
+			conditions = append(conditions, fmt.Sprintf("ind%d=%s", i+1, value))
+		}
+	}
+	s := filter.Tag
+	if len(conditions) > 0 {
+		s += "{" + strings.Join(conditions, ",") + "}"
+	}
+	switch {
+	case filter.Occurrence == LastOccurrence:
+		s += "[last]"
+	case filter.Occurrence > 0:
+		s += fmt.Sprintf("[%d]", filter.Occurrence)
+	}
+	return s + filter.Subfields
+}
+
 func (filters FieldFilters) String() string {
 	s := "Filters {\r\n"
 	for _, field := range filters.Fields {
-		if field.Subfields == "" {
-			s += fmt.Sprintf("\tTag: %s\r\n", field.Tag)
-		} else {
-			s += fmt.Sprintf("\tTag: %s subfields: %s\r\n", field.Tag, field.Subfields)
+		s += fmt.Sprintf("\tTag: %s", field.Tag)
+		if field.Indicator1 != "" {
+			s += fmt.Sprintf(" ind1: %q", field.Indicator1)
+		}
+		if field.Indicator2 != "" {
+			s += fmt.Sprintf(" ind2: %q", field.Indicator2)
+		}
+		if field.Occurrence != 0 {
+			s += fmt.Sprintf(" occurrence: %d", field.Occurrence)
+		}
+		if field.Subfields != "" {
+			s += fmt.Sprintf(" subfields: %s", field.Subfields)
 		}
+		s += "\r\n"
 	}
 	s += "}\r\n"
 	return s
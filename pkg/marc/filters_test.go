@@ -130,3 +130,146 @@ func TestFieldFiltersString(t *testing.T) {
 		t.Errorf("expected %q, got %q", want, got)
 	}
 }
+
+func TestNewFieldFilter_Indicators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fieldStr string
+		filter   FieldFilter
+	}{
+		{fieldStr: "650{ind2=0}a", filter: FieldFilter{Tag: "650", Subfields: "a", Indicator2: "0"}},
+		{fieldStr: "245{ind1=1,ind2=0}ab", filter: FieldFilter{Tag: "245", Subfields: "ab", Indicator1: "1", Indicator2: "0"}},
+		{fieldStr: "246{ind2=#}", filter: FieldFilter{Tag: "246", Indicator2: " "}},
+		{fieldStr: "246{ind2=_}", filter: FieldFilter{Tag: "246", Indicator2: " "}},
+		{fieldStr: `246{ind2=\}`, filter: FieldFilter{Tag: "246", Indicator2: " "}},
+		{fieldStr: "856{ ind1=4 , ind2=1 }u", filter: FieldFilter{Tag: "856", Subfields: "u", Indicator1: "4", Indicator2: "1"}},
+		{fieldStr: "650[1]a", filter: FieldFilter{Tag: "650", Subfields: "a", Occurrence: 1}},
+		{fieldStr: "650[first]a", filter: FieldFilter{Tag: "650", Subfields: "a", Occurrence: 1}},
+		{fieldStr: "856[last]u", filter: FieldFilter{Tag: "856", Subfields: "u", Occurrence: LastOccurrence}},
+		{fieldStr: "650{ind2=0}[3]", filter: FieldFilter{Tag: "650", Indicator2: "0", Occurrence: 3}},
+		{fieldStr: "650[2]{ind2=0}ax", filter: FieldFilter{Tag: "650", Subfields: "ax", Indicator2: "0", Occurrence: 2}},
+	}
+	for _, tt := range tests {
+		got, err := NewFieldFilter(tt.fieldStr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.fieldStr, err)
+		}
+		if got != tt.filter {
+			t.Errorf("%s: expected %+v, got %+v", tt.fieldStr, tt.filter, got)
+		}
+	}
+
+	for _, fieldStr := range []string{"650{ind2=0a", "650{ind3=0}a", "650{ind2=00}", "650{ind2}", "650a{ind2=0}",
+		"650[0]a", "650[x]", "650[1", "650[1][2]", "650{ind2=0}{ind1=1}", "650a[1]"} {
+		if _, err := NewFieldFilter(fieldStr); !errors.Is(err, ErrInvalidFieldSpec) {
+			t.Errorf("%s: expected %q, got %v", fieldStr, ErrInvalidFieldSpec, err)
+		}
+	}
+}
+
+func TestParseFieldFilters(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseFieldFilters("001,245ab,650{ind1=#,ind2=0}a,856u")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := FieldFilters{Fields: []FieldFilter{
+		{Tag: "001"},
+		{Tag: "245", Subfields: "ab"},
+		{Tag: "650", Subfields: "a", Indicator1: " ", Indicator2: "0"},
+		{Tag: "856", Subfields: "u"},
+	}}
+	if !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(want, got))
+	}
+
+	if _, err := ParseFieldFilters("245a,650{ind2=x"); !errors.Is(err, ErrInvalidFieldSpec) {
+		t.Errorf("expected %q, got %v", ErrInvalidFieldSpec, err)
+	}
+	if filters := NewFieldFilters("245a,65"); len(filters.Fields) != 0 {
+		t.Errorf("expected no filters for an invalid string, got %q", filters)
+	}
+}
+
+func TestFieldFilterMatches(t *testing.T) {
+	t.Parallel()
+
+	lcsh := Field{Tag: "650", Indicator1: " ", Indicator2: "0"}
+	fast := Field{Tag: "650", Indicator1: " ", Indicator2: "7"}
+	control := Field{Tag: "001", Value: "123"}
+
+	tests := []struct {
+		filter FieldFilter
+		field  Field
+		want   bool
+	}{
+		{filter: FieldFilter{Tag: "650"}, field: fast, want: true},
+		{filter: FieldFilter{Tag: "650", Indicator2: "0"}, field: lcsh, want: true},
+		{filter: FieldFilter{Tag: "650", Indicator2: "0"}, field: fast, want: false},
+		{filter: FieldFilter{Tag: "650", Indicator1: " ", Indicator2: "7"}, field: fast, want: true},
+		{filter: FieldFilter{Tag: "651", Indicator2: "0"}, field: lcsh, want: false},
+		{filter: FieldFilter{Tag: "001"}, field: control, want: true},
+		{filter: FieldFilter{Tag: "001", Indicator1: " "}, field: control, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Matches(tt.field); got != tt.want {
+			t.Errorf("%s matches %+v: expected %v, got %v", tt.filter, tt.field, tt.want, got)
+		}
+	}
+}
+
+func TestFieldFilterString(t *testing.T) {
+	t.Parallel()
+
+	for _, fieldStr := range []string{"245", "245ab", "650{ind2=0}a", "246{ind1=1,ind2=#}", "020[1]a", "650{ind2=0}[last]"} {
+		filter, err := NewFieldFilter(fieldStr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", fieldStr, err)
+		}
+		if got := filter.String(); got != fieldStr {
+			t.Errorf("expected %q, got %q", fieldStr, got)
+		}
+	}
+}
+
+func TestFieldFilterSelect(t *testing.T) {
+	t.Parallel()
+
+	fields := Fields{
+		{Tag: "001", Value: "123"},
+		{Tag: "650", Indicator1: " ", Indicator2: "7", SubFields: []SubField{{Code: "a", Value: "Fast 1"}}},
+		{Tag: "650", Indicator1: " ", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "LCSH 1"}}},
+		{Tag: "856", Indicator1: "4", Indicator2: "0", SubFields: []SubField{{Code: "u", Value: "http://one"}}},
+		{Tag: "650", Indicator1: " ", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "LCSH 2"}}},
+		{Tag: "856", Indicator1: "4", Indicator2: "1", SubFields: []SubField{{Code: "u", Value: "http://two"}}},
+	}
+
+	tests := []struct {
+		fieldStr string
+		want     []string
+	}{
+		{fieldStr: "650", want: []string{"Fast 1", "LCSH 1", "LCSH 2"}},
+		{fieldStr: "650[1]", want: []string{"Fast 1"}},
+		{fieldStr: "650[last]", want: []string{"LCSH 2"}},
+		{fieldStr: "650{ind2=0}[1]", want: []string{"LCSH 1"}},
+		{fieldStr: "650[4]", want: []string{}},
+		{fieldStr: "856[last]", want: []string{"http://two"}},
+		{fieldStr: "856{ind2=0}[last]", want: []string{"http://one"}},
+		{fieldStr: "100[last]", want: []string{}},
+	}
+	for _, tt := range tests {
+		filter, err := NewFieldFilter(tt.fieldStr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.fieldStr, err)
+		}
+		got := []string{}
+		for _, field := range filter.Select(fields) {
+			got = append(got, field.SubFields[0].Value)
+		}
+		if !cmp.Equal(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.fieldStr, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrFixedFieldLength is wrapped by the errors for fixed-length control
+// fields (006, 007, and 008) with the wrong length.
+var ErrFixedFieldLength = errors.New("wrong length in fixed-length field")
+
+// length007 is the length of the 007 for each category of material
+// (007/00), see https://www.loc.gov/marc/bibliographic/bd007.html
+var length007 = map[byte]int{
+	'a': 8,  // map
+	'c': 14, // electronic resource
+	'd': 6,  // globe
+	'f': 10, // tactile material
+	'g': 9,  // projected graphic
+	'h': 13, // microform
+	'k': 6,  // nonprojected graphic
+	'm': 23, // motion picture
+	'o': 2,  // kit
+	'q': 2,  // notated music
+	'r': 11, // remote-sensing image
+	's': 14, // sound recording
+	't': 2,  // text
+	'v': 9,  // videorecording
+	'z': 2,  // unspecified
+}
+
+// fixedFieldLengths returns the lengths that a fixed-length control
+// field can have in the record, the first one is the full length. It
+// returns nil for other fields and for values whose length cannot be
+// determined (e.g. a 007 of an unknown category).
+func (r Record) fixedFieldLengths(f Field) []int {
+	switch f.Tag {
+	case "006":
+		return []int{18}
+	case "007":
+		if f.Value == "" {
+			return nil
+		}
+		length, ok := length007[f.Value[0]]
+		if !ok {
+			return nil
+		}
+		if f.Value[0] == 'c' {
+			// Records of electronic resources created before positions
+			// 06-13 were defined have only positions 00-05.
+			return []int{length, 6}
+		}
+		return []int{length}
+	case "008":
+		switch r.Leader.Type {
+		case 'u', 'v', 'x', 'y': // holdings
+			return []int{32}
+		case 'w': // classification
+			return []int{14}
+		case 'q': // community information
+			return []int{15}
+		}
+		return []int{40}
+	}
+	return nil
+}
+
+// hasValidLength returns true if the field is not a fixed-length field
+// or has one of the lengths allowed, length is the full length.
+func (r Record) hasValidLength(f Field) (length int, valid bool) {
+	lengths := r.fixedFieldLengths(f)
+	if lengths == nil {
+		return 0, true
+	}
+	for _, l := range lengths {
+		if len(f.Value) == l {
+			return l, true
+		}
+	}
+	return lengths[0], false
+}
+
+// ValidateFixedFields checks that the fixed-length control fields (006,
+// 007, and 008) have the length required for the material type of the
+// record. The error wraps ErrFixedFieldLength and lists all the fields
+// with the wrong length.
+func (r Record) ValidateFixedFields() error {
+	problems := []string{}
+	for _, f := range r.Fields {
+		if length, valid := r.hasValidLength(f); !valid {
+			problems = append(problems, fmt.Sprintf("%s must be %d characters long, found %d", f.Tag, length, len(f.Value)))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrFixedFieldLength, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkFixedFields handles the fixed-length control fields with the
+// wrong length as indicated by the mode: an error in strict mode, padded
+// with blanks or truncated in repair mode (with a warning), kept as they
+// are otherwise.
+func checkFixedFields(rec *Record, mode ParseMode) error {
+	if mode.strict() {
+		return rec.ValidateFixedFields()
+	}
+	if !mode.repair() {
+		return nil
+	}
+	for i, f := range rec.Fields {
+		length, valid := rec.hasValidLength(f)
+		if valid {
+			continue
+		}
+		if len(f.Value) < length {
+			rec.addWarning(f.Tag, "padded with blanks to %d characters, it had %d", length, len(f.Value))
+			rec.Fields[i].Value = f.Value + strings.Repeat(" ", length-len(f.Value))
+		} else {
+			rec.addWarning(f.Tag, "truncated to %d characters, it had %d (removed %q)", length, len(f.Value), f.Value[length:])
+			rec.Fields[i].Value = f.Value[:length]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package marc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateFixedFields(t *testing.T) {
+	t.Parallel()
+
+	book := strings.Repeat(" ", 40)
+	tests := []struct {
+		name    string
+		leader  string
+		fields  map[string]string
+		wantErr bool
+	}{
+		{name: "book", leader: "00000nam a2200000 i 4500", fields: map[string]string{"006": strings.Repeat(" ", 18), "008": book}},
+		{name: "short 008", leader: "00000nam a2200000 i 4500", fields: map[string]string{"008": book[:38]}, wantErr: true},
+		{name: "long 006", leader: "00000nam a2200000 i 4500", fields: map[string]string{"006": strings.Repeat(" ", 19)}, wantErr: true},
+		{name: "holdings", leader: "00000nx  a2200000 i 4500", fields: map[string]string{"008": book[:32]}},
+		{name: "holdings with 40", leader: "00000nx  a2200000 i 4500", fields: map[string]string{"008": book}, wantErr: true},
+		{name: "sound recording", leader: "00000njm a2200000 i 4500", fields: map[string]string{"007": "sd fsngnnmmned"}},
+		{name: "short sound recording", leader: "00000njm a2200000 i 4500", fields: map[string]string{"007": "sd fsn"}, wantErr: true},
+		{name: "electronic resource", leader: "00000nam a2200000 i 4500", fields: map[string]string{"007": "cr |||||||||||"}},
+		{name: "old electronic resource", leader: "00000nam a2200000 i 4500", fields: map[string]string{"007": "cr cn-"}},
+		{name: "unknown category", leader: "00000nam a2200000 i 4500", fields: map[string]string{"007": "x"}},
+	}
+	for _, tt := range tests {
+		r := NewRecord()
+		r.Leader, _ = NewLeader([]byte(tt.leader))
+		for _, tag := range []string{"006", "007", "008"} {
+			if value, ok := tt.fields[tag]; ok {
+				r.AddControlField(tag, value)
+			}
+		}
+		err := r.ValidateFixedFields()
+		if tt.wantErr != errors.Is(err, ErrFixedFieldLength) {
+			t.Errorf("%s: expected error %t, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestParseFixedFields(t *testing.T) {
+	t.Parallel()
+
+	mrk := "=LDR  00000nam a2200000 i 4500\n=007  ta\n=008  041206s1976    dcua\n=245  10$aThe title\n"
+	tests := []struct {
+		mode     ParseMode
+		want007  string
+		want008  string
+		warnings int
+		wantErr  error
+	}{
+		{mode: ParseDefault, want007: "ta", want008: "041206s1976    dcua"},
+		{mode: ParseStrict, wantErr: ErrFixedFieldLength},
+		{mode: ParseRepair, want007: "ta", want008: "041206s1976    dcua" + strings.Repeat(" ", 21), warnings: 1},
+	}
+	for _, tt := range tests {
+		file := NewMarcFile(strings.NewReader(mrk))
+		file.SetParseMode(tt.mode)
+		rec, err := file.Next()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%d: expected error %v, got %v", tt.mode, tt.wantErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := rec.GetValue("007", ""); got != tt.want007 {
+			t.Errorf("%d: expected 007 %q, got %q", tt.mode, tt.want007, got)
+		}
+		if got := rec.GetValue("008", ""); got != tt.want008 {
+			t.Errorf("%d: expected 008 %q, got %q", tt.mode, tt.want008, got)
+		}
+		if len(rec.Warnings) != tt.warnings {
+			t.Errorf("%d: expected %d warnings, got %v", tt.mode, tt.warnings, rec.Warnings)
+		}
+	}
+
+	// Values that are too long are truncated.
+	rec := Record{Leader: Leader{Type: 'a'}, Fields: Fields{{Tag: "006", Value: strings.Repeat("a", 20)}}}
+	if err := checkFixedFields(&rec, ParseRepair); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := rec.Fields[0].Value; got != strings.Repeat("a", 18) || len(rec.Warnings) != 1 {
+		t.Errorf("expected the 006 truncated to 18 characters, got %q %v", got, rec.Warnings)
+	}
+	// The padded value is in the MARC binary output.
+	r := NewRecord()
+	r.AddControlField("008", "041206s1976    dcua")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "The title"})
+	binary, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+	encoded := encodeAndRead(t, NewMarcFileBytes(binary), ParseRepair)
+	if got := encoded.GetValue("008", ""); len(got) != 40 {
+		t.Errorf("expected the 008 padded to 40 characters in MARC binary, got %q", got)
+	}
+}
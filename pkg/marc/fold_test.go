@@ -0,0 +1,44 @@
+package marc
+
+import "testing"
+
+func TestFoldCase(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"Coal Analysis": "coal analysis",
+		"NÚMEROS":       "números",
+		"ΟΔΥΣΣΕΥΣ":      "οδυσσευσ",
+		"Οδυσσεύς":      "οδυσσεύσ",
+		"Straße":        "strasse",
+		"İSTANBUL":      "istanbul",
+		"Diyarbakır":    "diyarbakir",
+		"ſcience":       "science",
+		"5 K":           "5 k",
+	}
+	for value, want := range tests {
+		if got := FoldCase(value); got != want {
+			t.Errorf("FoldCase(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestRemoveDiacritics(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"Gödel":                  "Godel",
+		"Números de Seguro":      "Numeros de Seguro",
+		"Ñandú":                  "Nandu",
+		"Dvořák":               "Dvorak",
+		"Nguyễn Văn Thiệu":       "Nguyen Van Thieu",
+		"Ἀθῆναι":                 "Αθηναι",
+		"Søren Kierkegaard":      "Søren Kierkegaard",
+		"plain ASCII, unchanged": "plain ASCII, unchanged",
+	}
+	for value, want := range tests {
+		if got := RemoveDiacritics(value); got != want {
+			t.Errorf("RemoveDiacritics(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
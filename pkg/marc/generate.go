@@ -0,0 +1,277 @@
+package marc
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeneratorTypes are the types of records that a Generator can create.
+var GeneratorTypes = []string{"bib", "auth", "holdings"}
+
+// Generator creates synthetic MARC records that are syntactically valid
+// and look plausible (titles, names, ISBNs, dates, and fixed fields with
+// the right length and values), e.g. for load testing or for tests. The
+// same seed always generates the same records.
+type Generator struct {
+	kind  string
+	rand  *rand.Rand
+	count int
+}
+
+// NewGenerator returns a generator of records of the given type, one
+// of GeneratorTypes.
+func NewGenerator(kind string, seed int64) (*Generator, error) {
+	for _, t := range GeneratorTypes {
+		if t == kind {
+			return &Generator{kind: kind, rand: rand.New(rand.NewSource(seed))}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid record type %q, valid types: %s", kind, strings.Join(GeneratorTypes, ", "))
+}
+
+// Next returns a new record. The 001 of the records are numbered in
+// order, e.g. bib00000001 for the first bibliographic record. The leader
+// has the record length and base address of data of the record in MARC
+// binary.
+func (g *Generator) Next() Record {
+	g.count++
+	var r Record
+	switch g.kind {
+	case "auth":
+		r = g.authority()
+	case "holdings":
+		r = g.holdings()
+	default:
+		r = g.bibliographic()
+	}
+	sort.SliceStable(r.Fields, func(i, j int) bool {
+		return r.Fields[i].Tag < r.Fields[j].Tag
+	})
+	// The generated records are always short enough for MARC binary.
+	data, _ := r.Marshal()
+	r.Leader, _ = NewLeader(data[:leaderLength])
+	return r
+}
+
+func (g *Generator) bibliographic() Record {
+	r := NewRecord()
+	g.setLeader(&r, "00000nam a2200000 i 4500")
+	if g.rand.Intn(10) == 0 {
+		r.Leader.Set(17, '7') // minimal level
+	}
+	year := 1900 + g.rand.Intn(125)
+	country := generatorCountries[g.rand.Intn(len(generatorCountries))]
+	language := g.pick(generatorLanguages)
+	author := g.name()
+	title := g.title()
+
+	g.addHeader(&r, "bib")
+	index := "0"
+	if g.rand.Intn(3) == 0 {
+		index = "1"
+	}
+	// Books: illustrations, audience, form of item, nature of contents,
+	// government publication, conference, festschrift, index, undefined,
+	// literary form, and biography.
+	books := g.pick([]string{"    ", "a   ", "ab  "}) + "  " + g.pick([]string{"    ", "b   "}) + " 00" + index + " 0 "
+	r.AddControlField("008", g.dateEntered()+"s"+strconv.Itoa(year)+"    "+country.code+books+language+" d")
+	r.AddDataField("020", " ", " ", SubField{Code: "a", Value: g.isbn()})
+	r.AddDataField("040", " ", " ", SubField{Code: "a", Value: "GEN"}, SubField{Code: "b", Value: "eng"}, SubField{Code: "e", Value: "rda"}, SubField{Code: "c", Value: "GEN"})
+	r.AddDataField("100", "1", " ", SubField{Code: "a", Value: author.inverted + ","}, SubField{Code: "d", Value: author.datesEnd})
+	titleSubfields := []SubField{{Code: "a", Value: title.main}}
+	if title.sub != "" {
+		titleSubfields[0].Value += " :"
+		titleSubfields = append(titleSubfields, SubField{Code: "b", Value: title.sub + " /"})
+	} else {
+		titleSubfields[0].Value += " /"
+	}
+	titleSubfields = append(titleSubfields, SubField{Code: "c", Value: author.direct + "."})
+	r.AddDataField("245", "1", nonfilingIndicator(title.main), titleSubfields...)
+	r.AddDataField("264", " ", "1",
+		SubField{Code: "a", Value: country.place + " :"},
+		SubField{Code: "b", Value: g.pick(generatorPublishers) + ","},
+		SubField{Code: "c", Value: strconv.Itoa(year) + "."})
+	r.AddDataField("300", " ", " ",
+		SubField{Code: "a", Value: fmt.Sprintf("%d pages ;", 48+g.rand.Intn(600))},
+		SubField{Code: "c", Value: fmt.Sprintf("%d cm", 18+g.rand.Intn(12))})
+	r.AddDataField("336", " ", " ", SubField{Code: "a", Value: "text"}, SubField{Code: "b", Value: "txt"}, SubField{Code: "2", Value: "rdacontent"})
+	r.AddDataField("337", " ", " ", SubField{Code: "a", Value: "unmediated"}, SubField{Code: "b", Value: "n"}, SubField{Code: "2", Value: "rdamedia"})
+	r.AddDataField("338", " ", " ", SubField{Code: "a", Value: "volume"}, SubField{Code: "b", Value: "nc"}, SubField{Code: "2", Value: "rdacarrier"})
+	if index == "1" {
+		r.AddDataField("500", " ", " ", SubField{Code: "a", Value: "Includes index."})
+	}
+	for i := 0; i < 1+g.rand.Intn(3); i++ {
+		subject := []SubField{{Code: "a", Value: g.pick(generatorSubjects)}}
+		if g.rand.Intn(2) == 0 {
+			subject = append(subject, SubField{Code: "x", Value: g.pick(generatorSubdivisions)})
+		}
+		subject[len(subject)-1].Value += "."
+		r.AddDataField("650", " ", "0", subject...)
+	}
+	return r
+}
+
+func (g *Generator) authority() Record {
+	r := NewRecord()
+	g.setLeader(&r, "00000nz  a2200000n  4500")
+	author := g.name()
+	g.addHeader(&r, "auth")
+	// Personal name heading established in AACR2/RDA, usable as main
+	// or added entry and as subject.
+	r.AddControlField("008", g.dateEntered()+"n| azannaabn          |a aaa      ")
+	r.AddDataField("040", " ", " ", SubField{Code: "a", Value: "GEN"}, SubField{Code: "b", Value: "eng"}, SubField{Code: "e", Value: "rda"}, SubField{Code: "c", Value: "GEN"})
+	r.AddDataField("100", "1", " ", SubField{Code: "a", Value: author.inverted + ","}, SubField{Code: "d", Value: author.dates})
+	if g.rand.Intn(2) == 0 {
+		r.AddDataField("400", "1", " ", SubField{Code: "a", Value: author.variant + ","}, SubField{Code: "d", Value: author.dates})
+	}
+	title := g.title()
+	r.AddDataField("670", " ", " ", SubField{Code: "a", Value: fmt.Sprintf("%s, %d:", title.main, 1950+g.rand.Intn(75))}, SubField{Code: "b", Value: "title page (" + author.direct + ")"})
+	return r
+}
+
+func (g *Generator) holdings() Record {
+	r := NewRecord()
+	g.setLeader(&r, "00000nx  a22000001n 4500")
+	g.addHeader(&r, "hld")
+	r.AddControlField("004", fmt.Sprintf("bib%08d", 1+g.rand.Intn(g.count*10)))
+	// Currently received, retained, complete, one copy, lending and
+	// reproduction allowed.
+	r.AddControlField("008", g.dateEntered()+"0u    8   4001aaeng0      ")
+	location := g.pick(generatorLocations)
+	r.AddDataField("852", "0", " ",
+		SubField{Code: "a", Value: "GEN"},
+		SubField{Code: "b", Value: location},
+		SubField{Code: "h", Value: g.callNumber()},
+		SubField{Code: "i", Value: fmt.Sprintf(".%c%d %d", 'A'+rune(g.rand.Intn(26)), 10+g.rand.Intn(90), 1900+g.rand.Intn(125))})
+	return r
+}
+
+// setLeader sets the leader, with a random record status.
+func (g *Generator) setLeader(r *Record, leader string) {
+	r.SetLeader(leader)
+	r.Leader.Set(5, g.pick([]string{"n", "n", "n", "c"})[0])
+}
+
+// addHeader adds the 001, 003, and 005 of the record.
+func (g *Generator) addHeader(r *Record, prefix string) {
+	r.AddControlField("001", fmt.Sprintf("%s%08d", prefix, g.count))
+	r.AddControlField("003", "GEN")
+	r.AddControlField("005", fmt.Sprintf("%04d%02d%02d%02d%02d%02d.0", 2000+g.rand.Intn(25), 1+g.rand.Intn(12), 1+g.rand.Intn(28), g.rand.Intn(24), g.rand.Intn(60), g.rand.Intn(60)))
+}
+
+// dateEntered returns the date entered on file (008/00-05, yymmdd).
+func (g *Generator) dateEntered() string {
+	return fmt.Sprintf("%02d%02d%02d", g.rand.Intn(100), 1+g.rand.Intn(12), 1+g.rand.Intn(28))
+}
+
+// isbn returns a random ISBN-13 with a valid check digit.
+func (g *Generator) isbn() string {
+	digits := "978" + strconv.Itoa(g.rand.Intn(2)) + fmt.Sprintf("%08d", g.rand.Intn(100000000))
+	sum := 0
+	for i, d := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(d-'0') * weight
+	}
+	return digits + strconv.Itoa((10-sum%10)%10)
+}
+
+// callNumber returns a Library of Congress style call number.
+func (g *Generator) callNumber() string {
+	class := g.pick([]string{"B", "D", "E", "F", "GV", "HD", "HQ", "N", "PR", "PS", "QA", "QH", "T", "Z"})
+	return fmt.Sprintf("%s%d", class, 1+g.rand.Intn(9999))
+}
+
+type generatedName struct {
+	inverted string // Surname, Forename
+	direct   string // Forename Surname
+	variant  string // Surname, F.
+	dates    string // e.g. 1950-
+	datesEnd string // dates with the final period of the heading, if closed
+}
+
+func (g *Generator) name() generatedName {
+	surname, forename := g.pick(generatorSurnames), g.pick(generatorForenames)
+	born := 1880 + g.rand.Intn(120)
+	dates := strconv.Itoa(born) + "-"
+	if born < 1950 && g.rand.Intn(3) > 0 {
+		dates += strconv.Itoa(born + 40 + g.rand.Intn(50))
+	}
+	datesEnd := dates
+	if !strings.HasSuffix(dates, "-") {
+		datesEnd += "."
+	}
+	return generatedName{
+		inverted: surname + ", " + forename,
+		direct:   forename + " " + surname,
+		variant:  surname + ", " + forename[:1] + ".",
+		dates:    dates,
+		datesEnd: datesEnd,
+	}
+}
+
+type generatedTitle struct {
+	main string
+	sub  string
+}
+
+func (g *Generator) title() generatedTitle {
+	noun := g.pick(generatorNouns)
+	var t generatedTitle
+	switch g.rand.Intn(4) {
+	case 0:
+		t.main = "The " + strings.ToLower(g.pick(generatorAdjectives)) + " " + strings.ToLower(noun)
+	case 1:
+		t.main = noun + " and " + strings.ToLower(g.pick(generatorNouns))
+	case 2:
+		t.main = "A history of " + strings.ToLower(g.pick(generatorSubjects))
+	default:
+		t.main = g.pick(generatorAdjectives) + " " + strings.ToLower(noun)
+	}
+	if g.rand.Intn(2) == 0 {
+		t.sub = g.pick([]string{"a study of ", "essays on ", "an introduction to ", "new perspectives on "}) + strings.ToLower(g.pick(generatorSubjects))
+	}
+	return t
+}
+
+// nonfilingIndicator returns the number of nonfiling characters of the
+// title (the second indicator of the 245).
+func nonfilingIndicator(title string) string {
+	for _, article := range []string{"The ", "A "} {
+		if strings.HasPrefix(title, article) {
+			return strconv.Itoa(len(article))
+		}
+	}
+	return "0"
+}
+
+func (g *Generator) pick(values []string) string {
+	return values[g.rand.Intn(len(values))]
+}
+
+type generatorCountry struct {
+	code  string // 008/15-17
+	place string
+}
+
+var (
+	generatorCountries = []generatorCountry{
+		{"nyu", "New York"}, {"mau", "Boston"}, {"ilu", "Chicago"}, {"cau", "Berkeley"},
+		{"enk", "London"}, {"enk", "Oxford"}, {"fr ", "Paris"}, {"sp ", "Madrid"},
+		{"gw ", "Berlin"}, {"it ", "Milano"}, {"mx ", "México"}, {"onc", "Toronto"},
+	}
+	generatorLanguages    = []string{"eng", "eng", "eng", "eng", "spa", "fre", "ger", "ita", "por"}
+	generatorPublishers   = []string{"Harbor Press", "Northfield Books", "University Press", "Lantern House", "Meridian Publishing", "Atlas & Sons", "Greenway Editions", "Quill and Ink"}
+	generatorSurnames     = []string{"Smith", "García", "Müller", "Rossi", "Dubois", "Kowalski", "Nakamura", "O'Brien", "Silva", "Andersen", "Novák", "Johnson", "Ibáñez", "Lefèvre", "Okafor"}
+	generatorForenames    = []string{"Anna", "John", "María", "Pierre", "Hiroshi", "Eleanor", "José", "Ingrid", "Samuel", "Chiara", "Tomasz", "Grace", "Ahmed", "Lucía", "David"}
+	generatorAdjectives   = []string{"Silent", "Forgotten", "Hidden", "Modern", "Ancient", "Restless", "Golden", "Northern", "Distant", "Invisible", "Early", "Broken"}
+	generatorNouns        = []string{"River", "City", "Garden", "Empire", "Machine", "Memory", "Island", "Letters", "Frontier", "Harvest", "Archive", "Journey", "Kingdom", "Mirror"}
+	generatorSubjects     = []string{"Coal", "Libraries", "Railroads", "Birds", "Agriculture", "Architecture", "Education", "Immigration", "Mathematics", "Music", "Photography", "Poetry", "Wildlife conservation", "Women", "World War, 1939-1945"}
+	generatorSubdivisions = []string{"History", "Juvenile literature", "Research", "Social aspects", "Economic aspects", "Study and teaching"}
+	generatorLocations    = []string{"main", "stacks", "ref", "spec", "annex", "juv"}
+)
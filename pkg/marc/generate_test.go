@@ -0,0 +1,136 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGenerator(t *testing.T) {
+	t.Parallel()
+
+	for _, kind := range GeneratorTypes {
+		g, err := NewGenerator(kind, 42)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", kind, err)
+		}
+		for i := 0; i < 200; i++ {
+			r := g.Next()
+			if err := r.Leader.Validate(); err != nil {
+				t.Fatalf("%s: record %d: %v", kind, i+1, err)
+			}
+			if err := r.ValidateFixedFields(); err != nil {
+				t.Fatalf("%s: record %d: %v", kind, i+1, err)
+			}
+			data, err := r.Marshal()
+			if err != nil {
+				t.Fatalf("%s: record %d: %v", kind, i+1, err)
+			}
+			file := NewMarcFileBytes(data)
+			file.SetParseMode(ParseStrict)
+			got, err := file.Next()
+			if err != nil {
+				t.Fatalf("%s: record %d does not parse: %v", kind, i+1, err)
+			}
+			if !got.Equal(r) {
+				t.Fatalf("%s: record %d changed when parsed:\n%s", kind, i+1, Diff(r, got))
+			}
+		}
+	}
+
+	if _, err := NewGenerator("serial", 1); err == nil {
+		t.Errorf("expected an error for an invalid type")
+	}
+}
+
+func TestGeneratorSeed(t *testing.T) {
+	t.Parallel()
+
+	a, _ := NewGenerator("bib", 7)
+	b, _ := NewGenerator("bib", 7)
+	c, _ := NewGenerator("bib", 8)
+	same, different := true, false
+	for i := 0; i < 10; i++ {
+		ra, rb, rc := a.Next(), b.Next(), c.Next()
+		same = same && ra.Equal(rb)
+		different = different || !ra.Equal(rc)
+	}
+	if !same {
+		t.Errorf("expected the same records with the same seed")
+	}
+	if !different {
+		t.Errorf("expected different records with a different seed")
+	}
+}
+
+func TestGeneratorISBN(t *testing.T) {
+	t.Parallel()
+
+	g, _ := NewGenerator("bib", 1)
+	for i := 0; i < 100; i++ {
+		isbn := g.Next().GetValue("020", "a")
+		if len(isbn) != 13 || !strings.HasPrefix(isbn, "978") {
+			t.Fatalf("invalid ISBN-13 %q", isbn)
+		}
+		sum := 0
+		for j, d := range isbn {
+			weight := 1
+			if j%2 == 1 {
+				weight = 3
+			}
+			sum += int(d-'0') * weight
+		}
+		if sum%10 != 0 {
+			t.Fatalf("wrong check digit in %q", isbn)
+		}
+	}
+}
+
+// TestWritersRoundTrip writes generated records with each writer and
+// checks that the readers return the same records. JSON is not included
+// since it does not have the leader.
+func TestWritersRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		newWriter func(io.Writer) Writer
+	}{
+		{name: "mrc", newWriter: func(w io.Writer) Writer { return NewMrcWriter(w) }},
+		{name: "mrk", newWriter: func(w io.Writer) Writer { return NewMrkWriter(w) }},
+		{name: "xml", newWriter: func(w io.Writer) Writer { return NewXMLWriter(w) }},
+	}
+
+	for _, tt := range tests {
+		g, _ := NewGenerator("bib", 3)
+		records := []Record{}
+		var buf bytes.Buffer
+		w := tt.newWriter(&buf)
+		for i := 0; i < 50; i++ {
+			r := g.Next()
+			records = append(records, r)
+			if err := w.WriteRecord(r); err != nil {
+				t.Fatalf("%s: error writing: %v", tt.name, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: error closing: %v", tt.name, err)
+		}
+
+		file := NewMarcFile(&buf)
+		for i, want := range records {
+			got, err := file.Next()
+			if err != nil {
+				t.Fatalf("%s: record %d: %v", tt.name, i+1, err)
+			}
+			if !got.Equal(want) {
+				t.Fatalf("%s: record %d changed:\n%s", tt.name, i+1, Diff(want, got))
+			}
+		}
+		if _, err := file.Next(); !errors.Is(err, io.EOF) {
+			t.Errorf("%s: expected io.EOF after the last record, got %v", tt.name, err)
+		}
+	}
+}
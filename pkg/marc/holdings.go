@@ -0,0 +1,226 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissingCaption is returned for the enumeration and chronology
+// fields (863-865) without the captions and pattern field (853-855) with
+// their link number.
+var ErrMissingCaption = errors.New("missing captions and pattern field")
+
+// HoldingsStatement is a holdings statement of a record: an enumeration
+// and chronology field (863-865) expanded with the captions of its
+// captions and pattern field (853-855), or a textual holdings field
+// (866-868) as is.
+type HoldingsStatement struct {
+	// Tag is the tag of the enumeration or textual holdings field.
+	Tag string
+	// Link is the link and sequence number in the $8, e.g. 1.1.
+	Link      string
+	Statement string
+	// Note is the public note ($z).
+	Note string
+	// Err is set when the statement cannot be expanded.
+	Err error
+}
+
+// captionTags are the captions and pattern fields of the basic
+// bibliographic unit, the supplements, and the indexes.
+var captionTags = map[string]string{
+	"863": "853",
+	"864": "854",
+	"865": "855",
+}
+
+var monthNames = map[string]string{
+	"01": "Jan.", "02": "Feb.", "03": "Mar.", "04": "Apr.",
+	"05": "May", "06": "June", "07": "July", "08": "Aug.",
+	"09": "Sept.", "10": "Oct.", "11": "Nov.", "12": "Dec.",
+	"21": "Spring", "22": "Summer", "23": "Autumn", "24": "Winter",
+}
+
+var seasonNames = map[string]string{
+	"21": "Spring", "22": "Summer", "23": "Autumn", "24": "Winter",
+}
+
+// HoldingsStatements returns the holdings statements of a holdings
+// record (or of a bibliographic record with embedded holdings) in the
+// order of the fields, see ExpandHoldings.
+func (r Record) HoldingsStatements() []HoldingsStatement {
+	statements := []HoldingsStatement{}
+	for _, field := range r.Fields {
+		switch field.Tag {
+		case "863", "864", "865":
+			link := strings.TrimSpace(field.SubFieldValue("8"))
+			s := HoldingsStatement{Tag: field.Tag, Link: link, Note: strings.Join(field.SubFieldValues("z"), " ")}
+			caption, ok := r.holdingsCaption(captionTags[field.Tag], link)
+			if !ok {
+				s.Err = fmt.Errorf("%w: no %s with link %q", ErrMissingCaption, captionTags[field.Tag], holdingsLink(link))
+			} else {
+				s.Statement = ExpandHoldings(caption, field)
+			}
+			statements = append(statements, s)
+		case "866", "867", "868":
+			statements = append(statements, HoldingsStatement{
+				Tag:       field.Tag,
+				Link:      strings.TrimSpace(field.SubFieldValue("8")),
+				Statement: strings.TrimSpace(field.SubFieldValue("a")),
+				Note:      strings.Join(field.SubFieldValues("z"), " "),
+			})
+		}
+	}
+	return statements
+}
+
+// holdingsLink returns the link number of a $8 without the sequence
+// number, e.g. 1 for 1.2.
+func holdingsLink(value string) string {
+	if i := strings.Index(value, "."); i != -1 {
+		return value[:i]
+	}
+	return value
+}
+
+// holdingsCaption returns the captions and pattern field with the link
+// number of the enumeration field, or the only one in the record when
+// the enumeration does not have a link number.
+func (r Record) holdingsCaption(tag string, link string) (Field, bool) {
+	captions := r.Fields.GetAll(tag)
+	if link == "" && len(captions) == 1 {
+		return captions[0], true
+	}
+	for _, caption := range captions {
+		if holdingsLink(strings.TrimSpace(caption.SubFieldValue("8"))) == holdingsLink(link) {
+			return caption, true
+		}
+	}
+	return Field{}, false
+}
+
+// ExpandHoldings returns the holdings statement of an enumeration and
+// chronology field (863-865) with the captions of the captions and
+// pattern field (853-855), following ANSI/NISO Z39.71, e.g. $a 1-5 $b
+// 1-12 $i 1990-1994 $j 01-12 with $a v. $b no. $i (year) $j (month) is
+// "v.1:no.1 (1990:Jan.)-v.5:no.12 (1994:Dec.)".
+//
+// The enumeration ($a-$f) is separated by colons, followed by the
+// alternative numbering ($g-$h) after an equal sign and the chronology
+// ($i-$m) in parentheses, or without the parentheses when there is no
+// enumeration. The captions in parentheses are not displayed, the months
+// and seasons are displayed by their name. A range with an open end
+// (e.g. "1-") ends with a hyphen.
+func ExpandHoldings(caption Field, enumeration Field) string {
+	isRange, open := false, false
+	for _, sub := range enumeration.SubFields {
+		if sub.Code >= "a" && sub.Code <= "m" && strings.Contains(sub.Value, "-") {
+			isRange = true
+			if strings.HasSuffix(strings.TrimSpace(sub.Value), "-") {
+				open = true
+			}
+		}
+	}
+	statement := holdingsPart(caption, enumeration, false)
+	switch {
+	case open:
+		statement += "-"
+	case isRange:
+		if end := holdingsPart(caption, enumeration, true); end != statement {
+			statement += "-" + end
+		}
+	}
+	return statement
+}
+
+// holdingsPart returns the start or the end of the range of an
+// enumeration and chronology field.
+func holdingsPart(caption Field, enumeration Field, end bool) string {
+	levels := func(codes string) []string {
+		values := []string{}
+		for _, code := range codes {
+			value := rangeValue(enumeration.SubFieldValue(string(code)), end)
+			if value == "" {
+				continue
+			}
+			values = append(values, captionValue(caption.SubFieldValue(string(code)), value))
+		}
+		return values
+	}
+
+	str := strings.Join(levels("abcdef"), ":")
+	if alternative := levels("gh"); len(alternative) > 0 {
+		if str != "" {
+			str += "="
+		}
+		str += strings.Join(alternative, ":")
+	}
+
+	chronology := ""
+	for _, code := range "ijklm" {
+		value := rangeValue(enumeration.SubFieldValue(string(code)), end)
+		if value == "" {
+			continue
+		}
+		label := caption.SubFieldValue(string(code))
+		value = captionValue(label, value)
+		switch {
+		case chronology == "":
+			chronology = value
+		case strings.EqualFold(strings.TrimSpace(label), "(day)"):
+			chronology += " " + value
+		default:
+			chronology += ":" + value
+		}
+	}
+	if chronology == "" {
+		return str
+	}
+	if str == "" {
+		return chronology
+	}
+	return str + " (" + chronology + ")"
+}
+
+// rangeValue returns the start or the end of a value like 1-5, a value
+// without a range is both the start and the end.
+func rangeValue(value string, end bool) string {
+	value = strings.TrimSpace(value)
+	i := strings.Index(value, "-")
+	if i == -1 {
+		return value
+	}
+	if end {
+		return strings.TrimSpace(value[i+1:])
+	}
+	return strings.TrimSpace(value[:i])
+}
+
+// captionValue returns the value with its caption, e.g. v.1 for the
+// caption v. and the value 1, or the name of the month or season for
+// their captions.
+func captionValue(caption string, value string) string {
+	caption = strings.TrimSpace(caption)
+	var names map[string]string
+	switch strings.ToLower(caption) {
+	case "(month)":
+		names = monthNames
+	case "(season)":
+		names = seasonNames
+	}
+	if names != nil {
+		// Combined issues, e.g. 01/02 for Jan./Feb.
+		parts := strings.Split(value, "/")
+		for i, part := range parts {
+			if name, ok := names[part]; ok {
+				parts[i] = name
+			}
+		}
+		return strings.Join(parts, "/")
+	}
+	if caption == "" || strings.HasPrefix(caption, "(") || strings.HasPrefix(caption, "[") {
+		return value
+	}
+	return caption + value
+}
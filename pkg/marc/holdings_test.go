@@ -0,0 +1,65 @@
+package marc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandHoldings(t *testing.T) {
+	t.Parallel()
+
+	sub := func(pairs ...string) []SubField {
+		subfields := []SubField{}
+		for i := 0; i < len(pairs); i += 2 {
+			subfields = append(subfields, SubField{Code: pairs[i], Value: pairs[i+1]})
+		}
+		return subfields
+	}
+	caption := Field{Tag: "853", SubFields: sub("8", "1", "a", "v.", "b", "no.", "i", "(year)", "j", "(month)")}
+
+	tests := []struct {
+		subfields []SubField
+		want      string
+	}{
+		{sub("8", "1.1", "a", "1-5", "b", "1-12", "i", "1990-1994", "j", "01-12"), "v.1:no.1 (1990:Jan.)-v.5:no.12 (1994:Dec.)"},
+		{sub("8", "1.2", "a", "6", "b", "1-6", "i", "1995", "j", "01-06"), "v.6:no.1 (1995:Jan.)-v.6:no.6 (1995:June)"},
+		{sub("8", "1.3", "a", "7", "b", "3", "i", "1996", "j", "05/06"), "v.7:no.3 (1996:May/June)"},
+		{sub("8", "1.4", "a", "8-", "i", "1997-"), "v.8 (1997)-"},
+		{sub("8", "1.5", "i", "1998-1999"), "1998-1999"},
+	}
+	for _, tt := range tests {
+		got := ExpandHoldings(caption, Field{Tag: "863", SubFields: tt.subfields})
+		if got != tt.want {
+			t.Errorf("ExpandHoldings(%v) = %q, want %q", tt.subfields, got, tt.want)
+		}
+	}
+
+	seasons := Field{Tag: "853", SubFields: sub("8", "2", "a", "no.", "i", "(year)", "j", "(season)")}
+	if got := ExpandHoldings(seasons, Field{Tag: "863", SubFields: sub("a", "1-4", "i", "2001", "j", "21-24")}); got != "no.1 (2001:Spring)-no.4 (2001:Winter)" {
+		t.Errorf("unexpected statement with seasons %q", got)
+	}
+}
+
+func TestHoldingsStatements(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddDataField("853", "2", "0", SubField{Code: "8", Value: "1"}, SubField{Code: "a", Value: "v."}, SubField{Code: "i", Value: "(year)"})
+	r.AddDataField("863", "4", "0", SubField{Code: "8", Value: "1.1"}, SubField{Code: "a", Value: "1-10"}, SubField{Code: "i", Value: "1980-1989"})
+	r.AddDataField("864", "4", "0", SubField{Code: "8", Value: "1.1"}, SubField{Code: "a", Value: "1"})
+	r.AddDataField("866", "3", "0", SubField{Code: "8", Value: "0"}, SubField{Code: "a", Value: "v.11-20 (1990-1999)"}, SubField{Code: "z", Value: "Lacks v.15"})
+
+	statements := r.HoldingsStatements()
+	if len(statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(statements))
+	}
+	if s := statements[0]; s.Statement != "v.1 (1980)-v.10 (1989)" || s.Link != "1.1" || s.Err != nil {
+		t.Errorf("unexpected statement %+v", s)
+	}
+	if s := statements[1]; !errors.Is(s.Err, ErrMissingCaption) {
+		t.Errorf("expected a missing caption error for the 864, got %+v", s)
+	}
+	if s := statements[2]; s.Tag != "866" || s.Statement != "v.11-20 (1990-1999)" || s.Note != "Lacks v.15" {
+		t.Errorf("unexpected textual statement %+v", s)
+	}
+}
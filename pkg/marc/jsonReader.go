@@ -0,0 +1,93 @@
+package marc
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// JSONReader reads records in the JSON format produced by JSONWriter,
+// a JSON array where each record is an array of fields. Records can
+// also be objects with "leader" and "fields" properties.
+type JSONReader struct {
+	decoder *json.Decoder
+	limiter *sizeLimiter
+	started bool
+	err     error
+}
+
+type jsonRecord struct {
+	Leader string  `json:"leader"`
+	Fields []Field `json:"fields"`
+}
+
+// NewJSONReader creates a reader for JSON data.
+func NewJSONReader(r io.Reader) *JSONReader {
+	limiter := newSizeLimiter(r)
+	return &JSONReader{decoder: json.NewDecoder(limiter), limiter: limiter}
+}
+
+// SetMaxRecordSize sets the size of the largest record accepted, larger
+// records stop the reading with ErrRecordTooLarge.
+func (jr *JSONReader) SetMaxRecordSize(max int) {
+	jr.limiter.max = max
+}
+
+// Next returns the next record, io.EOF when there are no more records.
+func (jr *JSONReader) Next() (Record, error) {
+	if jr.err != nil {
+		return Record{}, jr.err
+	}
+
+	if !jr.started {
+		// Skip the "[" at the beginning of the array
+		jr.started = true
+		if _, err := jr.decoder.Token(); err != nil {
+			return Record{}, jr.fail(err)
+		}
+	}
+
+	if !jr.decoder.More() {
+		return Record{}, io.EOF
+	}
+
+	jr.limiter.reset()
+	rec := Record{Pos: jr.decoder.InputOffset()}
+	var raw json.RawMessage
+	if err := jr.decoder.Decode(&raw); err != nil {
+		if errors.Is(err, ErrRecordTooLarge) {
+			err = recordTooLargeError(rec.Pos, jr.limiter.max)
+		}
+		return Record{}, jr.fail(err)
+	}
+
+	rec.Data = []byte("Raw data not supported in JSON format\n")
+	if len(raw) > 0 && raw[0] == '{' {
+		var jsonRec jsonRecord
+		if err := json.Unmarshal(raw, &jsonRec); err != nil {
+			return rec, err
+		}
+		// Ignore error because a bad data offset is not a problem
+		// in JSON records.
+		rec.Leader, _ = NewLeader([]byte(jsonRec.Leader))
+		rec.Fields = jsonRec.Fields
+		return rec, nil
+	}
+
+	err := json.Unmarshal(raw, &rec.Fields)
+	return rec, err
+}
+
+// Err returns the error reading the data (if any).
+func (jr *JSONReader) Err() error {
+	return jr.err
+}
+
+func (jr *JSONReader) fail(err error) error {
+	if err == io.EOF {
+		// empty input
+		return err
+	}
+	jr.err = err
+	return err
+}
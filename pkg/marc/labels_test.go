@@ -0,0 +1,30 @@
+package marc
+
+import "testing"
+
+func TestTagLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"LDR": "Leader",
+		"245": "Title Statement",
+		"650": "Subject Added Entry - Topical Term",
+		"945": "",
+		"999": "",
+	}
+	for tag, want := range tests {
+		if got := TagLabel(tag); got != want {
+			t.Errorf("label of %s: expected %q, got %q", tag, want, got)
+		}
+	}
+}
+
+func TestTagLabel_SchemaFields(t *testing.T) {
+	t.Parallel()
+
+	for tag := range MARC21Schema() {
+		if TagLabel(tag) == "" {
+			t.Errorf("field %s of the schema has no label", tag)
+		}
+	}
+}
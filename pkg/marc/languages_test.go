@@ -0,0 +1,31 @@
+package marc
+
+import "testing"
+
+func TestLanguageName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		code     string
+		name     string
+		ok       bool
+		obsolete bool
+	}{
+		{code: "eng", name: "English", ok: true},
+		{code: "fre", name: "French", ok: true},
+		{code: "zxx", name: "No linguistic content", ok: true},
+		{code: "scc", name: "Serbian", ok: true, obsolete: true},
+		{code: "fra", name: "", ok: false},
+		{code: "ENG", name: "", ok: false},
+		{code: "", name: "", ok: false},
+	}
+	for _, tt := range tests {
+		name, ok := LanguageName(tt.code)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("LanguageName(%q) = %q, %v, want %q, %v", tt.code, name, ok, tt.name, tt.ok)
+		}
+		if got := ObsoleteLanguage(tt.code); got != tt.obsolete {
+			t.Errorf("ObsoleteLanguage(%q) = %v, want %v", tt.code, got, tt.obsolete)
+		}
+	}
+}
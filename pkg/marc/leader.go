@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // Leader represents the leader of the MARC record.
@@ -14,23 +15,46 @@ type Leader struct {
 	Type          byte // 06
 	BibLevel      byte // 07
 	Control       byte // 08
+	Encoding      byte // 09
 	EncodingLevel byte // 17
 	Form          byte // 18
 	Multipart     byte // 19
 }
 
-// NewLeader creates a Leader from the data in the MARC record.
+// ErrInvalidLeader is wrapped by the errors for leaders that are not
+// valid, see LeaderError.
+var ErrInvalidLeader = errors.New("invalid leader")
+
+// LeaderError describes the problems found in a leader: the positions
+// that are not valid and what was found in them.
+type LeaderError struct {
+	Raw      []byte // the bytes of the leader
+	Problems []string
+}
+
+func (e *LeaderError) Error() string {
+	return fmt.Sprintf("invalid leader %q (hex % x): %s", e.Raw, e.Raw, strings.Join(e.Problems, "; "))
+}
+
+func (e *LeaderError) Unwrap() error {
+	return ErrInvalidLeader
+}
+
+// NewLeader creates a Leader from the data in the MARC record. It
+// returns a *LeaderError if the leader is incomplete or the base address
+// of data is not a number, the leader is returned anyway in the latter
+// case (with a DataOffset of -1).
 func NewLeader(bytes []byte) (Leader, error) {
 	if len(bytes) != leaderLength {
-		return Leader{}, errors.New("incomplete leader")
+		problem := fmt.Sprintf("incomplete leader, must be %d characters long, found %d", leaderLength, len(bytes))
+		return Leader{}, &LeaderError{Raw: bytes, Problems: []string{problem}}
 	}
 
 	// A typical good leader value is: "01848nam a2200385 i 4500"
 	// where as a bad value would be.: "ZZZZZnamZa22ZZZZZzZZ4500"
-	offset, err := strconv.Atoi(string(bytes[offsetStart:offsetEnd]))
-	if err != nil {
-		msg := fmt.Sprintf("Could not determine data offset from leader (%s)", string(bytes))
-		err = errors.New(msg)
+	var err error
+	offset, ok := parseDigits(string(bytes[offsetStart:offsetEnd]))
+	if !ok {
 		offset = -1
 	}
 
@@ -41,10 +65,14 @@ func NewLeader(bytes []byte) (Leader, error) {
 		Type:          bytes[6],
 		BibLevel:      bytes[7],
 		Control:       bytes[8],
+		Encoding:      bytes[9],
 		EncodingLevel: bytes[17],
 		Form:          bytes[18],
 		Multipart:     bytes[19],
 	}
+	if offset == -1 {
+		err = &LeaderError{Raw: bytes, Problems: leader.problems()}
+	}
 	return leader, err
 }
 
@@ -55,3 +83,278 @@ func (l Leader) String() string {
 func (l Leader) Raw() string {
 	return string(l.raw)
 }
+
+// Descriptions of the values in the leader positions, the record
+// status (05), the bibliographic level (07), and the encoding level
+// (17) depend on the type of record, see leaderFormat.
+// See https://www.loc.gov/marc/bibliographic/bdleader.html
+// https://www.loc.gov/marc/authority/adleader.html
+// https://www.loc.gov/marc/holdings/hdleader.html
+var (
+	leaderStatusValues = map[byte]string{
+		'a': "Increase in encoding level",
+		'c': "Corrected or revised",
+		'd': "Deleted",
+		'n': "New",
+		'p': "Increase in encoding level from prepublication",
+	}
+	leaderTypeValues = map[byte]string{
+		'a': "Language material",
+		'c': "Notated music",
+		'd': "Manuscript notated music",
+		'e': "Cartographic material",
+		'f': "Manuscript cartographic material",
+		'g': "Projected medium",
+		'i': "Nonmusical sound recording",
+		'j': "Musical sound recording",
+		'k': "Two-dimensional nonprojectable graphic",
+		'm': "Computer file",
+		'o': "Kit",
+		'p': "Mixed materials",
+		'q': "Community information",
+		'r': "Three-dimensional artifact or naturally occurring object",
+		't': "Manuscript language material",
+		'u': "Unknown (holdings)",
+		'v': "Multipart item holdings",
+		'w': "Classification data",
+		'x': "Single-part item holdings",
+		'y': "Serial item holdings",
+		'z': "Authority data",
+	}
+	leaderBibLevelValues = map[byte]string{
+		'a': "Monographic component part",
+		'b': "Serial component part",
+		'c': "Collection",
+		'd': "Subunit",
+		'i': "Integrating resource",
+		'm': "Monograph/Item",
+		's': "Serial",
+	}
+	leaderEncodingValues = map[byte]string{
+		' ': "MARC-8",
+		'a': "UCS/Unicode",
+	}
+	leaderEncodingLevelValues = map[byte]string{
+		' ': "Full level",
+		'1': "Full level, material not examined",
+		'2': "Less-than-full level, material not examined",
+		'3': "Abbreviated level",
+		'4': "Core level",
+		'5': "Partial (preliminary) level",
+		'7': "Minimal level",
+		'8': "Prepublication level",
+		'u': "Unknown",
+		'z': "Not applicable",
+	}
+
+	leaderAuthStatusValues = map[byte]string{
+		'a': "Increase in encoding level",
+		'c': "Corrected or revised",
+		'd': "Deleted",
+		'n': "New",
+		'o': "Obsolete",
+		's': "Deleted; heading split into two or more headings",
+		'x': "Deleted; heading replaced by another heading",
+	}
+	leaderHoldingsStatusValues = map[byte]string{
+		'c': "Corrected or revised",
+		'd': "Deleted",
+		'n': "New",
+	}
+	leaderClassificationStatusValues = map[byte]string{
+		'a': "Increase in encoding level",
+		'c': "Corrected or revised",
+		'd': "Deleted",
+		'n': "New",
+	}
+	leaderHoldingsEncodingLevelValues = map[byte]string{
+		'1': "Holdings level 1",
+		'2': "Holdings level 2",
+		'3': "Holdings level 3",
+		'4': "Holdings level 4",
+		'5': "Holdings level 4 with piece designation",
+		'm': "Mixed level",
+		'u': "Unknown",
+		'z': "Other level",
+	}
+	// leaderUndefinedValues are the values of the positions that are
+	// not defined in a format (e.g. 07 in authority records).
+	leaderUndefinedValues = map[byte]string{
+		' ': "Undefined",
+	}
+)
+
+// leaderFormat are the values of the positions of the leader that
+// depend on the type of record (06).
+type leaderFormat struct {
+	name           string
+	statuses       map[byte]string
+	bibLevels      map[byte]string
+	encodingLevels map[byte]string
+}
+
+// format returns the values of the leader positions for the type of
+// record, the bibliographic ones for the types that are not known.
+func (l Leader) format() leaderFormat {
+	switch l.Type {
+	case 'z':
+		return leaderFormat{name: "authority", statuses: leaderAuthStatusValues, bibLevels: leaderUndefinedValues,
+			encodingLevels: map[byte]string{'n': "Complete authority record", 'o': "Incomplete authority record"}}
+	case 'u', 'v', 'x', 'y':
+		return leaderFormat{name: "holdings", statuses: leaderHoldingsStatusValues, bibLevels: leaderUndefinedValues,
+			encodingLevels: leaderHoldingsEncodingLevelValues}
+	case 'w':
+		return leaderFormat{name: "classification", statuses: leaderClassificationStatusValues, bibLevels: leaderUndefinedValues,
+			encodingLevels: map[byte]string{'n': "Complete classification record", 'o': "Incomplete classification record"}}
+	case 'q':
+		return leaderFormat{name: "community information", statuses: leaderHoldingsStatusValues, bibLevels: leaderUndefinedValues,
+			encodingLevels: map[byte]string{'n': "Complete community information record", 'o': "Incomplete community information record"}}
+	}
+	return leaderFormat{name: "bibliographic", statuses: leaderStatusValues, bibLevels: leaderBibLevelValues,
+		encodingLevels: leaderEncodingLevelValues}
+}
+
+// StatusDescription returns the description of the record status (05),
+// for example "New".
+func (l Leader) StatusDescription() string {
+	return describeLeaderValue(l.format().statuses, l.Status)
+}
+
+// TypeDescription returns the description of the type of record (06),
+// for example "Language material".
+func (l Leader) TypeDescription() string {
+	return describeLeaderValue(leaderTypeValues, l.Type)
+}
+
+// BibLevelDescription returns the description of the bibliographic
+// level (07), for example "Monograph/Item", or "Undefined" for the
+// records other than bibliographic.
+func (l Leader) BibLevelDescription() string {
+	return describeLeaderValue(l.format().bibLevels, l.BibLevel)
+}
+
+// EncodingDescription returns the description of the character coding
+// scheme (09), for example "UCS/Unicode".
+func (l Leader) EncodingDescription() string {
+	return describeLeaderValue(leaderEncodingValues, l.Encoding)
+}
+
+// EncodingLevelDescription returns the description of the encoding
+// level (17), for example "Full level". Notice that OCLC uses
+// non-standard values (e.g. "I", "K") that are reported as unknown.
+func (l Leader) EncodingLevelDescription() string {
+	return describeLeaderValue(l.format().encodingLevels, l.EncodingLevel)
+}
+
+func describeLeaderValue(values map[byte]string, value byte) string {
+	if description, ok := values[value]; ok {
+		return description
+	}
+	return fmt.Sprintf("Unknown value (%q)", value)
+}
+
+// RecordLength returns the record length indicated in the leader
+// (00-04) or -1 if it is not a number.
+func (l Leader) RecordLength() int {
+	if len(l.raw) != leaderLength {
+		return -1
+	}
+	length, err := strconv.Atoi(string(l.raw[0:5]))
+	if err != nil {
+		return -1
+	}
+	return length
+}
+
+// DataOffset returns the base address of data (12-16) or -1 if it
+// is not a number.
+func (l Leader) DataOffset() int {
+	return l.dataOffset
+}
+
+// Validate checks that the leader is well formed: it has the right
+// length, the numeric positions are numbers, and the positions with
+// a fixed set of values have one of the values for the type of record
+// (e.g. a blank 07 in authority and holdings records). The error is a
+// *LeaderError with the problems found.
+func (l Leader) Validate() error {
+	if len(l.raw) != leaderLength {
+		return fmt.Errorf("%w: must be %d characters long, found %d", ErrInvalidLeader, leaderLength, len(l.raw))
+	}
+	if problems := l.problems(); len(problems) > 0 {
+		return &LeaderError{Raw: l.raw, Problems: problems}
+	}
+	return nil
+}
+
+// problems returns the description of each position of the leader
+// that is not valid, see Validate.
+func (l Leader) problems() []string {
+	problems := []string{}
+	if l.RecordLength() == -1 {
+		problems = append(problems, fmt.Sprintf("record length (00-04) is not a number: %q", l.raw[0:5]))
+	}
+	format := l.format()
+	if _, ok := format.statuses[l.Status]; !ok {
+		problems = append(problems, fmt.Sprintf("invalid record status (05) for %s records: %q", format.name, l.Status))
+	}
+	if _, ok := leaderTypeValues[l.Type]; !ok {
+		problems = append(problems, fmt.Sprintf("invalid type of record (06): %q", l.Type))
+	}
+	if _, ok := format.bibLevels[l.BibLevel]; !ok {
+		problems = append(problems, fmt.Sprintf("invalid bibliographic level (07) for %s records: %q", format.name, l.BibLevel))
+	}
+	if _, ok := leaderEncodingValues[l.Encoding]; !ok {
+		problems = append(problems, fmt.Sprintf("invalid character coding scheme (09): %q", l.Encoding))
+	}
+	if l.raw[10] != '2' || l.raw[11] != '2' {
+		problems = append(problems, fmt.Sprintf("indicator and subfield code counts (10-11) must be \"22\": %q", l.raw[10:12]))
+	}
+	if l.dataOffset == -1 {
+		problems = append(problems, fmt.Sprintf("base address of data (12-16) is not a number: %q", l.raw[offsetStart:offsetEnd]))
+	}
+	if string(l.raw[20:24]) != "4500" {
+		problems = append(problems, fmt.Sprintf("entry map (20-23) must be \"4500\": %q", l.raw[20:24]))
+	}
+	return problems
+}
+
+// Set sets the value of the given position (0-23) in the leader.
+func (l *Leader) Set(pos int, value byte) error {
+	if pos < 0 || pos >= leaderLength {
+		return fmt.Errorf("invalid leader position %d", pos)
+	}
+	if len(l.raw) != leaderLength {
+		return errors.New("incomplete leader")
+	}
+
+	// Make a copy since raw might be shared with the record data.
+	raw := append([]byte(nil), l.raw...)
+	raw[pos] = value
+	leader, err := NewLeader(raw)
+	*l = leader
+	return err
+}
+
+// SetRecordLength sets the record length (00-04).
+func (l *Leader) SetRecordLength(length int) error {
+	return l.setNumber(0, 5, length)
+}
+
+// SetDataOffset sets the base address of data (12-16).
+func (l *Leader) SetDataOffset(offset int) error {
+	return l.setNumber(offsetStart, offsetEnd, offset)
+}
+
+func (l *Leader) setNumber(start, end, value int) error {
+	digits := fmt.Sprintf("%0*d", end-start, value)
+	if value < 0 || len(digits) != end-start {
+		return fmt.Errorf("value %d does not fit in leader positions %02d-%02d", value, start, end-1)
+	}
+	for i := start; i < end; i++ {
+		if err := l.Set(i, digits[i-start]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package marc
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -18,6 +20,7 @@ func TestNewLeader(t *testing.T) {
 		Type:          byte('a'),
 		BibLevel:      byte('m'),
 		Control:       byte(' '),
+		Encoding:      byte('a'),
 		EncodingLevel: byte(' '),
 		Form:          byte('i'),
 		Multipart:     byte(' '),
@@ -42,6 +45,23 @@ func TestNewLeader_ErrorsOnBadOffset(t *testing.T) {
 	}
 }
 
+func TestNewLeader_LeaderError(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewLeader([]byte("01848nam a22ZZ385 i 4500"))
+	var leaderErr *LeaderError
+	if !errors.As(err, &leaderErr) || !errors.Is(err, ErrInvalidLeader) {
+		t.Fatalf("expected a LeaderError, got %v", err)
+	}
+	want := []string{`base address of data (12-16) is not a number: "ZZ385"`}
+	if !cmp.Equal(want, leaderErr.Problems) {
+		t.Errorf("unexpected problems: %s", cmp.Diff(want, leaderErr.Problems))
+	}
+	if !strings.Contains(err.Error(), "5a 5a 33 38 35") {
+		t.Errorf("expected the hex of the leader in the error, got %q", err)
+	}
+}
+
 func TestNewLeader_ErrorsOnShortLeader(t *testing.T) {
 	t.Parallel()
 
@@ -79,3 +99,127 @@ func TestRaw(t *testing.T) {
 		t.Errorf("expected %q, got %q", want, got)
 	}
 }
+
+func TestLeaderDescriptions(t *testing.T) {
+	t.Parallel()
+
+	l, _ := NewLeader([]byte("01848nam a2200385 i 4500"))
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "status", got: l.StatusDescription(), want: "New"},
+		{name: "type", got: l.TypeDescription(), want: "Language material"},
+		{name: "bib level", got: l.BibLevelDescription(), want: "Monograph/Item"},
+		{name: "encoding", got: l.EncodingDescription(), want: "UCS/Unicode"},
+		{name: "encoding level", got: l.EncodingLevelDescription(), want: "Full level"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, tt.got)
+		}
+	}
+
+	l, _ = NewLeader([]byte("01848nam a2200385Ii 4500"))
+	if got := l.EncodingLevelDescription(); got != "Unknown value ('I')" {
+		t.Errorf("expected unknown value, got %q", got)
+	}
+
+	// The values of 05, 07, and 17 depend on the type of record.
+	others := []struct {
+		leader        string
+		status        string
+		recordType    string
+		bibLevel      string
+		encodingLevel string
+	}{
+		{"00512sz  a2200169n  4500", "Deleted; heading split into two or more headings", "Authority data", "Undefined", "Complete authority record"},
+		{"00522cy  a22001694  4500", "Corrected or revised", "Serial item holdings", "Undefined", "Holdings level 4"},
+		{"00210nx  a22000851n 4500", "New", "Single-part item holdings", "Undefined", "Holdings level 1"},
+		{"00436nw  a2200133o  4500", "New", "Classification data", "Undefined", "Incomplete classification record"},
+	}
+	for _, tt := range others {
+		l, _ := NewLeader([]byte(tt.leader))
+		got := []string{l.StatusDescription(), l.TypeDescription(), l.BibLevelDescription(), l.EncodingLevelDescription()}
+		want := []string{tt.status, tt.recordType, tt.bibLevel, tt.encodingLevel}
+		if !cmp.Equal(want, got) {
+			t.Errorf("%s: unexpected descriptions: %s", tt.leader, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestLeaderValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		leader string
+		valid  bool
+	}{
+		{name: "valid", leader: "01848nam a2200385 i 4500", valid: true},
+		{name: "bad status", leader: "01848xam a2200385 i 4500", valid: false},
+		{name: "bad length", leader: "0184Xnam a2200385 i 4500", valid: false},
+		{name: "bad offset", leader: "ZZZZZnamZa22ZZZZZzZZ4500", valid: false},
+		{name: "bad entry map", leader: "01848nam a2200385 i 0000", valid: false},
+		{name: "authority", leader: "00863cz  a2200241n  4500", valid: true},
+		{name: "authority split heading", leader: "00512sz  a2200169n  4500", valid: true},
+		{name: "authority with bib level", leader: "00863czm a2200241n  4500", valid: false},
+		{name: "authority bib status", leader: "00863pz  a2200241n  4500", valid: false},
+		{name: "single-part holdings", leader: "00210nx  a22000851n 4500", valid: true},
+		{name: "serial holdings", leader: "00522cy  a22001694  4500", valid: true},
+		{name: "holdings status", leader: "00522ay  a22001694  4500", valid: false},
+		{name: "classification", leader: "00436nw  a2200133n  4500", valid: true},
+		{name: "bib with blank level", leader: "01848na  a2200385 i 4500", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := NewLeader([]byte(tt.leader))
+			err := l.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expected leader to be valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("expected leader to be invalid")
+			}
+		})
+	}
+}
+
+func TestLeaderSet(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("01848nam a2200385 i 4500")
+	l, _ := NewLeader(data)
+
+	if err := l.Set(5, 'c'); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := l.SetRecordLength(123); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := l.SetDataOffset(97); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := "00123cam a2200097 i 4500"
+	if got := l.Raw(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if l.Status != 'c' || l.DataOffset() != 97 || l.RecordLength() != 123 {
+		t.Errorf("decoded values not updated: %c %d %d", l.Status, l.DataOffset(), l.RecordLength())
+	}
+	if string(data) != "01848nam a2200385 i 4500" {
+		t.Errorf("original data should not be modified, got %q", data)
+	}
+
+	if err := l.SetRecordLength(123456); err == nil {
+		t.Errorf("expected error for a record length that does not fit")
+	}
+	if err := l.Set(24, 'a'); err == nil {
+		t.Errorf("expected error for an invalid position")
+	}
+}
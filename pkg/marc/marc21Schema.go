@@ -0,0 +1,246 @@
+package marc
+
+// marc21Bibliographic is the schema of the MARC 21 bibliographic
+// format (see https://www.loc.gov/marc/bibliographic/) in the format
+// described in Schema. Obsolete fields and the fields that can have
+// any subfield (880 and 886) are not included.
+const marc21Bibliographic = `
+# Control fields
+001 NR
+003 NR
+005 NR
+006 R
+007 R
+008 NR
+
+# Numbers and codes
+010 NR a b+ z+ 8+
+013 R a b c d+ e+ f+ 6 8+
+015 R a+ q+ z+ 2 6 8+
+016 R a z+ 2 8+
+017 R a+ b d i z+ 2 6 8+
+018 NR a 6 8+
+020 R a c q+ z+ 6 8+
+022 R a l m+ y+ z+ 0+ 1+ 2 6 8+
+024 R a c d q+ z+ 2 6 8+
+025 R a+ 8+
+026 R a+ b c d+ e 2 5+ 6 8+
+027 R a q+ z+ 6 8+
+028 R a b q+ 6 8+
+030 R a z+ 6 8+
+031 R a b c d+ e g+ m n o p q+ r s+ t+ u+ y+ z+ 2 6 8+
+032 R a b 6 8+
+033 R a+ b+ c+ p+ 0+ 1+ 2+ 3 6 8+
+034 R a b+ c+ d e f g h+ j k m n p r s+ t+ x y z 0+ 1+ 2 3 6 8+
+035 R a z+ 6 8+
+036 NR a b 6 8+
+037 R a b c+ f+ g+ n+ 3 5+ 6 8+
+038 NR a 6 8+
+040 NR a b c d+ e+ 6 8+
+041 R a+ b+ d+ e+ f+ g+ h+ i+ j+ k+ m+ n+ p+ q+ r+ t+ 2 3 6 8+
+042 NR a+
+043 NR a+ b+ c+ 0+ 1+ 2+ 6 8+
+044 NR a+ b+ c+ 2+ 6 8+
+045 NR a+ b+ c+ 6 8+
+046 R a b c d e j k l m n o p x+ z+ 2 3 6 8+
+047 R a+ 2 8+
+048 R a+ b+ 2 8+
+050 R a+ b 0+ 1+ 3 6 8+
+051 R a b c 8+
+052 R a b+ d+ 0+ 1+ 2 6 8+
+055 R a b 0+ 1+ 2 6 8+
+060 R a+ b 0+ 1+ 8+
+061 R a+ b c 8+
+066 NR a b c+
+070 R a+ b 0+ 1+ 8+
+071 R a b c+ 8+
+072 R a x+ 2 6 8+
+074 R a z+ 8+
+080 R a b x+ 0+ 1+ 2 6 8+
+082 R a+ b m q 0+ 1+ 2 6 8+
+083 R a+ c+ m q y+ z+ 0+ 1+ 2 6 8+
+084 R a+ b q 0+ 1+ 2 6 8+
+085 R a+ b+ c+ f+ r+ s+ t+ u+ v+ w+ y+ z+ 0+ 1+ 6 8+
+086 R a z+ 0+ 1+ 2 6 8+
+088 R a z+ 6 8+
+
+# Main entries
+100 NR a b c+ d e+ f g+ j+ k+ l n+ p+ q t u 0+ 1+ 2 4+ 6 8+
+110 NR a b+ c+ d+ e+ f g+ k+ l n+ p+ t u 0+ 1+ 2 4+ 6 8+
+111 NR a c+ d+ e+ f g+ j+ k+ l n+ p+ q t u 0+ 1+ 2 4+ 6 8+
+130 NR a d+ f g+ h k+ l m+ n+ o p+ r s t 0+ 1+ 2 6 8+
+
+# Titles
+210 R a b 2 6 8+
+222 R a b 6 8+
+240 NR a d+ f g+ h k+ l m+ n+ o p+ r s 0+ 1+ 2 6 8+
+242 R a b c h n+ p+ y 6 8+
+243 NR a d+ f g+ h k+ l m+ n+ o p+ r s 6 8+
+245 NR a b c f g h k+ n+ p+ s 6 8+
+246 R a b f g h i n+ p+ 5 6 8+
+247 R a b f g h n+ p+ x 6 8+
+
+# Edition and imprint
+250 R a b 3 6 8+
+251 R a+ 0+ 1+ 2 3 6 8+
+254 NR a 6 8+
+255 R a b c d e f g 6 8+
+256 NR a 6 8+
+257 R a+ 0+ 1+ 2 6 8+
+258 R a b 6 8+
+260 R a+ b+ c+ e+ f+ g+ 3 6 8+
+263 NR a 6 8+
+264 R a+ b+ c+ 3 6 8+
+270 R a+ b c d e f g h i j+ k+ l+ m+ n p q r z+ 4+ 6 8+
+
+# Physical description
+300 R a+ b c+ e f+ g+ 3 6 8+
+306 NR a+ 6 8+
+307 R a b 6 8+
+310 NR a b 0+ 1+ 6 8+
+321 R a b 0+ 1+ 6 8+
+334 R a+ b+ 0+ 1+ 2 6 8+
+335 R a+ b+ 0+ 1+ 2 3 6 8+
+336 R a+ b+ 0+ 1+ 2 3 6 8+
+337 R a+ b+ 0+ 1+ 2 3 6 8+
+338 R a+ b+ 0+ 1+ 2 3 6 8+
+340 R a+ b+ c+ d+ e+ f+ g+ h+ i+ j+ k+ m+ n+ o+ p+ q+ 0+ 1+ 2 3 6 8+
+344 R a+ b+ c+ d+ e+ f+ g+ h+ i+ j+ 0+ 1+ 2 3 6 8+
+345 R a+ b+ c+ d+ 0+ 1+ 2 3 6 8+
+346 R a+ b+ 0+ 1+ 2 3 6 8+
+347 R a+ b+ c+ d+ e+ f+ 0+ 1+ 2 3 6 8+
+348 R a+ b+ c+ 0+ 1+ 2 3 6 8+
+351 R a+ b+ c 3 6 8+
+352 R a b+ c+ d e f+ g i q 6 8+
+355 R a b+ c d e f g h j 6 8+
+357 NR a b+ c+ g+ 6 8+
+362 R a z 6 8+
+363 R a b c d e f g h i j k l m u v x+ z+ 6 8+
+365 R a b c d e f g h i j k m 2 6 8+
+366 R a b c d e f g j k m 2 6 8+
+370 R a b c+ f+ g+ i+ s t u+ v+ 0+ 1+ 2 3 4+ 6 8+
+377 R a+ l+ 0+ 1+ 2 3 6 8+
+380 R a+ 0+ 1+ 2 3 6 8+
+381 R a+ u+ v+ 0+ 1+ 2 3 6 8+
+382 R a+ b+ d+ e+ n+ p+ r s+ t v+ 0+ 1+ 2 3 6 8+
+383 R a+ b+ c+ d e 2 3 6 8+
+384 R a 3 6 8+
+385 R a+ b+ m n 0+ 1+ 2 3 6 8+
+386 R a+ b+ i+ m n 0+ 1+ 2 3 4+ 6 8+
+388 R a+ 0+ 1+ 2 3 6 8+
+
+# Series statement
+490 R a+ l+ v+ x+ y+ z+ 3 6 8+
+
+# Notes
+500 R a 3 5+ 6 8+
+501 R a 5 6 8+
+502 R a b c d g+ o+ 6 8+
+504 R a b 6 8+
+505 R a g+ r+ t+ u+ 6 8+
+506 R a+ b+ c+ d+ e+ f+ g+ q u+ 2 3 5 6 8+
+507 NR a b 6 8+
+508 R a 6 8+
+510 R a b c u+ x 3 6 8+
+511 R a 6 8+
+513 R a b 6 8+
+514 NR a b+ c+ d e+ f g+ h+ i j+ k+ m u+ z 6 8+
+515 R a 6 8+
+516 R a 6 8+
+518 R a d+ o+ p+ 0+ 1+ 2 3 6 8+
+520 R a b c u+ 2 3 6 8+
+521 R a+ b 3 6 8+
+522 R a 6 8+
+524 R a 2 3 6 8+
+525 R a 6 8+
+526 R a b c d i x+ z+ 5 6 8+
+530 R a b c d u+ 3 6 8+
+533 R a b+ c+ d e+ f+ m+ n+ 3 5+ 6 7 8+
+534 R a b c e f+ k+ l m n+ o+ p t x+ z+ 3 6 8+
+535 R a b+ c+ d+ g 3 6 8+
+536 R a b+ c+ d+ e+ f+ g+ h+ 6 8+
+538 R a i+ u+ 3 5 6 8+
+540 R a b c d f+ g+ q u+ 2 3 5 6 8+
+541 R a b c d e f h+ n+ o+ 3 5 6 8+
+542 R a b c d+ e+ f+ g h+ i j k+ l m n+ o p+ q r s u+ 3 6 8+
+544 R a+ b+ c+ d+ e+ n+ 3 6 8+
+545 R a b u+ 6 8+
+546 R a b+ 3 6 8+
+547 R a 6 8+
+550 R a 6 8+
+552 R a b c d e f g h i j k l m n o p u+ z+ 6 8+
+555 R a b+ c d u+ 3 6 8+
+556 R a z+ 6 8+
+561 R a u+ 3 5 6 8+
+562 R a+ b+ c+ d+ e+ 3 5 6 8+
+563 R a u+ 3 5 6 8+
+565 R a b+ c+ d+ e+ 3 6 8+
+567 R a b+ 0+ 1+ 2 6 8+
+580 R a 6 8+
+581 R a z+ 3 6 8+
+583 R a+ b+ c+ d+ e+ f+ h+ i+ j+ k+ l+ n+ o+ u+ x+ z+ 2 3 5 6 8+
+584 R a+ b+ 3 5 6 8+
+585 R a 3 5 6 8+
+586 R a 3 6 8+
+588 R a 5 6 8+
+
+# Subject access
+600 R a b c+ d e+ f g+ h j+ k+ l m+ n+ o p+ q r s t u v+ x+ y+ z+ 0+ 1+ 2 3 4+ 6 8+
+610 R a b+ c+ d+ e+ f g+ h k+ l m+ n+ o p+ r s t u v+ x+ y+ z+ 0+ 1+ 2 3 4+ 6 8+
+611 R a c+ d e+ f g+ h j+ k+ l n+ p+ q s t u v+ x+ y+ z+ 0+ 1+ 2 3 4+ 6 8+
+630 R a d+ e+ f g+ h k+ l m+ n+ o p+ r s t v+ x+ y+ z+ 0+ 1+ 2 3 4+ 6 8+
+647 R a c+ d g+ v+ x+ y+ z+ 0+ 1+ 2 3 6 8+
+648 R a v+ x+ y+ z+ 0+ 1+ 2 3 6 8+
+650 R a b c d e+ g+ v+ x+ y+ z+ 0+ 1+ 2 3 4+ 6 8+
+651 R a e+ g+ v+ x+ y+ z+ 0+ 1+ 2 3 4+ 6 8+
+653 R a+ 6 8+
+654 R a+ b+ c+ e+ v+ y+ z+ 0+ 1+ 2 3 4+ 6 8+
+655 R a b+ c+ v+ x+ y+ z+ 0+ 1+ 2 3 5 6 8+
+656 R a k v+ x+ y+ z+ 0+ 1+ 2 3 6 8+
+657 R a v+ x+ y+ z+ 0+ 1+ 2 3 6 8+
+658 R a b+ c d 2 6 8+
+662 R a+ b c+ d e+ f+ g+ h+ 0+ 1+ 2 4+ 6 8+
+688 R a g+ 0+ 1+ 2 6 8+
+
+# Added entries
+700 R a b c+ d e+ f g+ h i+ j+ k+ l m+ n+ o p+ q r s t u x 0+ 1+ 2 3 4+ 5 6 8+
+710 R a b+ c+ d+ e+ f g+ h i+ k+ l m+ n+ o p+ r s t u x 0+ 1+ 2 3 4+ 5 6 8+
+711 R a c+ d+ e+ f g+ h i+ j+ k+ l n+ p+ q s t u x 0+ 1+ 2 3 4+ 5 6 8+
+720 R a e+ 0+ 1+ 4+ 6 8+
+730 R a d+ f g+ h i+ k+ l m+ n+ o p+ r s t x 0+ 1+ 2 3 5 6 8+
+740 R a h n+ p+ 5 6 8+
+751 R a e+ 0+ 1+ 2 3 4+ 6 8+
+752 R a+ b c d+ e+ f+ g+ h+ 0+ 1+ 2 4+ 6 8+
+753 R a b c 0+ 1+ 2 6 8+
+754 R a+ c+ d+ x+ z+ 0+ 1+ 2 6 8+
+758 R a i+ 0+ 1+ 3 4+ 5 6 8+
+
+# Linking entries
+760 R a b c d g+ h i+ m n+ o+ s t w+ x y 4+ 6 7 8+
+762 R a b c d g+ h i+ m n+ o+ s t w+ x y 4+ 6 7 8+
+765 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+767 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+770 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+772 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+773 R a b d g+ h i+ k+ m n+ o+ p q r+ s t u w+ x y z+ 3 4+ 6 7 8+
+774 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+775 R a b c d e f g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+776 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+777 R a b c d g+ h i+ k+ m n+ o+ s t w+ x y 4+ 6 7 8+
+780 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+785 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+786 R a b c d g+ h i+ j k+ m n+ o+ p r+ s t u v w+ x y z+ 4+ 6 7 8+
+787 R a b c d g+ h i+ k+ m n+ o+ r+ s t u w+ x y z+ 4+ 6 7 8+
+
+# Series added entries
+800 R a b c+ d e+ f g+ h j+ k+ l m+ n+ o p+ q r s t u v w+ x 0+ 1+ 2 3 4+ 5 6 7 8+
+810 R a b+ c+ d+ e+ f g+ h k+ l m+ n+ o p+ r s t u v w+ x 0+ 1+ 2 3 4+ 5 6 7 8+
+811 R a c+ d e+ f g+ h j+ k+ l n+ p+ q s t u v w+ x 0+ 1+ 2 3 4+ 5 6 7 8+
+830 R a d+ f g+ h k+ l m+ n+ o p+ r s t v w+ x 0+ 1+ 2 3 5 6 7 8+
+
+# Holdings, location, and electronic access
+850 R a+ 8+
+852 R a b+ c+ d+ e+ f+ g+ h i+ j k+ l m+ n p q s+ t u+ x+ z+ 2 3 6 8+
+856 R a+ b+ c+ d+ f+ h i+ j k l m+ n o p q r s+ t+ u+ v+ w+ x+ y+ z+ 2 3 6 7 8+
+883 R a c d q u+ w+ x+ 0+ 1+ 8+
+`
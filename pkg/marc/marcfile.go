@@ -3,11 +3,9 @@ package marc
 import (
 	"bufio"
 	"bytes"
-	"encoding/xml"
+	"context"
 	"errors"
-	"fmt"
-	"os"
-	"strconv"
+	"io"
 )
 
 // See https://www.loc.gov/marc/specifications/specrecstruc.html
@@ -30,6 +28,7 @@ var (
 	ErrBadRecordLength    = errors.New("bad record length")
 	ErrUnknownFieldLength = errors.New("could not determine length of field")
 	ErrUnknownFieldStart  = errors.New("could not determine field start")
+	ErrTruncated          = errors.New("file appears truncated")
 )
 
 type IncorrectFieldLengthError struct {
@@ -49,199 +48,244 @@ func (e *IncorrectFieldLengthError) Error() string {
 // MarcFile represents a MARC file.
 // The public interface more or less mimic Go's native Scanner (Scan, Err)
 // but uses Record (instead of Text) to represent each MARC record.
+//
+// MarcFile detects the format of the file and uses the corresponding
+// RecordReader to read the records.
 type MarcFile struct {
-	scanner *bufio.Scanner
-	decoder *xml.Decoder
-	isXML   bool
-	element xml.StartElement
+	reader RecordReader
+	record Record
+	recErr error // error parsing the current record
+	err    error
+	tags   tagSet // fields to keep when the reader cannot select them
 }
 
-func isXML(file *os.File) bool {
-	buf := make([]byte, 5)
-	n, err := file.Read(buf)
+// Input formats detected by NewMarcFile.
+const (
+	formatBinary = "mrc"
+	formatXML    = "xml"
+	formatJSON   = "json"
+	formatMrk    = "mrk"
+)
+
+// detectFormat peeks at the beginning of the data to determine
+// its format.
+func detectFormat(reader *bufio.Reader) (string, error) {
+	buf, err := reader.Peek(5)
+	if err == io.EOF {
+		// empty or very short file
+		err = nil
+	}
 	if err != nil {
-		// hacky, probably a better way to do this
-		panic(err)
+		return "", err
 	}
-	// rewind file to get those 5 bytes back
-	file.Seek(0, 0)
-	return string(buf[:n]) == "<?xml"
+
+	start := bytes.TrimLeft(buf, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(start, []byte("<")):
+		return formatXML, nil
+	case bytes.HasPrefix(start, []byte("=")):
+		return formatMrk, nil
+	case bytes.HasPrefix(start, []byte("[")), bytes.HasPrefix(start, []byte("{")):
+		return formatJSON, nil
+	}
+	return formatBinary, nil
 }
 
-// NewMarcFile creates a struct to handle reading the MARC file.
-func NewMarcFile(file *os.File) MarcFile {
+// NewMarcFile creates a struct to handle reading the MARC data from
+// a file, stdin, a network stream, or a buffer in memory. The data can
+// be in MARC binary, MARC XML, MRK, or JSON format.
+// If the type of data cannot be determined the error is reported
+// by Err() and Scan() will not return any records.
+func NewMarcFile(r io.Reader) MarcFile {
+	// Buffer the data so that we can peek at the first bytes
+	// to determine the format without consuming them.
+	reader := bufio.NewReader(r)
+	format, err := detectFormat(reader)
+	if err != nil {
+		return MarcFile{err: err}
+	}
 
-	if isXML(file) {
-		// For MARC XML files it uses a Decoder() to read one
-		// MARC record at a time.
-		decoder := xml.NewDecoder(file)
-		return MarcFile{decoder: decoder, isXML: true}
+	var recordReader RecordReader
+	switch format {
+	case formatXML:
+		recordReader = NewXMLReader(reader)
+	case formatMrk:
+		recordReader = NewMrkReader(reader)
+	case formatJSON:
+		recordReader = NewJSONReader(reader)
+	default:
+		recordReader = NewBinaryReader(reader)
 	}
+	return MarcFile{reader: recordReader}
+}
 
-	// Assume MARC binary
-	//
-	// For MARC binary files uses a Scanner() to read the
-	// contents of the file (stolen from https://github.com/MITLibraries/fml)
-	scanner := bufio.NewScanner(file)
+// NewMarcFileFromReader creates a MarcFile that reads the records
+// with the given RecordReader.
+func NewMarcFileFromReader(reader RecordReader) MarcFile {
+	return MarcFile{reader: reader}
+}
 
-	// By default Scanner.Scan() returns "bufio.Scanner: token too long" if
-	// the block to read is longer than 64K. Since MARC records can be up to
-	// 100K we use a custom value. See https://stackoverflow.com/a/37455465/446681
-	initialBuffer := make([]byte, 0, 64*1024)
-	customMaxSize := 105 * 1024
-	scanner.Buffer(initialBuffer, customMaxSize)
+// ReadRecordAt reads the record that starts at the given offset (see
+// Record.Pos) in a file in MARC binary, MARC XML, or MRK format. It
+// returns io.ErrUnexpectedEOF if there is no record at the offset.
+func ReadRecordAt(r io.ReadSeeker, offset int64) (Record, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return Record{}, err
+	}
+	file := NewMarcFile(r)
+	rec, err := file.Next()
+	if err == io.EOF {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	rec.Pos += offset
+	return rec, err
+}
 
-	scanner.Split(splitFunc)
-	return MarcFile{scanner: scanner}
+// NewMarcFileParallel is like NewMarcFile but MARC binary records are
+// parsed in the given number of goroutines (see ParallelReader). Other
+// formats are parsed sequentially. Close must be called if the records
+// are not read until the end.
+func NewMarcFileParallel(r io.Reader, workers int) MarcFile {
+	reader := bufio.NewReader(r)
+	format, err := detectFormat(reader)
+	if err != nil {
+		return MarcFile{err: err}
+	}
+	if format != formatBinary || workers < 2 {
+		return NewMarcFile(reader)
+	}
+	return MarcFile{reader: NewParallelReader(reader, workers)}
+}
+
+// Offset returns the position in the data right after the last record
+// read, i.e. where the next record starts. This can be used to resume
+// reading later on (see ReadRecordAt). It returns -1 if the reader does
+// not keep track of it (e.g. for JSON).
+func (file *MarcFile) Offset() int64 {
+	if offsetter, ok := file.reader.(interface{ Offset() int64 }); ok {
+		return offsetter.Offset()
+	}
+	return -1
 }
 
-func splitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
+// SetTags limits the fields included in the records to the ones with
+// the given tags (the leader is always parsed), nil includes all the
+// fields. Readers of MARC binary skip the data of the other fields
+// without parsing them, which is much faster when only a few fields
+// are needed. Other readers parse the whole record and drop the fields
+// that were not requested. SetTags must be called before reading the
+// first record.
+func (file *MarcFile) SetTags(tags []string) {
+	if selector, ok := file.reader.(interface{ SetTags([]string) }); ok {
+		selector.SetTags(tags)
+		return
 	}
+	file.tags = newTagSet(tags)
+}
 
-	if atEOF {
-		return len(data), data, nil
+// SetMaxRecordSize sets the size (in bytes of the input) of the largest
+// record accepted, the default is DefaultMaxRecordSize. Larger records
+// are reported with an error that wraps ErrRecordTooLarge instead of
+// being loaded in memory. Except when the data is already in memory (see
+// NewMarcFileBytes) this error stops the reading. SetMaxRecordSize must
+// be called before reading the first record.
+func (file *MarcFile) SetMaxRecordSize(max int) {
+	if limiter, ok := file.reader.(interface{ SetMaxRecordSize(int) }); ok {
+		limiter.SetMaxRecordSize(max)
 	}
+}
 
-	if i := bytes.IndexByte(data, rt); i >= 0 {
-		return i + 1, data[0:i], nil
+// SetParseMode sets how the records are parsed, the default is
+// ParseDefault. In ParseLenient and ParseRepair modes the problems fixed
+// in each record are in Record.Warnings. Readers of JSON ignore the
+// mode. SetParseMode must be called before reading the first record.
+func (file *MarcFile) SetParseMode(mode ParseMode) {
+	if parser, ok := file.reader.(interface{ SetParseMode(ParseMode) }); ok {
+		parser.SetParseMode(mode)
 	}
+}
 
-	return 0, nil, nil
+// Close releases the resources used to read the records (e.g. the
+// goroutines of a ParallelReader). It does not close the underlying
+// io.Reader.
+func (file *MarcFile) Close() error {
+	if closer, ok := file.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
-// Err returns the error in the scanner (if any)
+// Err returns the error reading the file (if any)
 func (file *MarcFile) Err() error {
-	if file.isXML {
-		return nil
+	if file.err != nil {
+		return file.err
 	}
-	return file.scanner.Err()
+	return file.reader.Err()
 }
 
 // Scan moves the scanner to the next record.
 // Returns false when no more records can be read.
 func (file *MarcFile) Scan() bool {
-
-	if file.isXML {
-		for {
-			token, _ := file.decoder.Token()
-			if token == nil {
-				return false
-			}
-			// Find the next "<record>" element in the XML
-			// and store it.
-			element, ok := token.(xml.StartElement)
-			if ok && element.Name.Local == "record" {
-				file.element = element
-				return true
-			}
-		}
+	if file.err != nil {
+		return false
 	}
 
-	return file.scanner.Scan()
+	record, err := file.reader.Next()
+	if err == io.EOF || (err != nil && file.reader.Err() != nil) {
+		return false
+	}
+	if file.tags != nil {
+		record.Fields = file.tags.filter(record.Fields)
+	}
+	file.record = record
+	file.recErr = err
+	return true
 }
 
-// Record returns the current Record in the MarcFile.
-func (file *MarcFile) Record() (Record, error) {
-	rec := &Record{}
-
-	var err error
-	if file.isXML {
-		err = makeRecordFromXML(file, rec)
-	} else {
-		err = makeRecordFromBinary(file, rec)
-	}
-	return *rec, err
-}
-
-func makeRecordFromXML(file *MarcFile, rec *Record) error {
-	// Decode the last element found in Scan() into an XML Record...
-	var xmlRec XmlRecord
-	file.decoder.DecodeElement(&xmlRec, &file.element)
-
-	// Ignore error because a bad data offset is not a problem
-	// in XML records.
-	leader, _ := NewLeader([]byte(xmlRec.Leader))
-	rec.Leader = leader
-	rec.Data = []byte("Raw data not supported in XML format\n")
-
-	// ...and then into a MARC Record.
-	for _, control := range xmlRec.ControlFields {
-		field := Field{Tag: control.Tag, Value: control.Value}
-		rec.Fields = append(rec.Fields, field)
-	}
-	for _, data := range xmlRec.DataFields {
-		field := Field{Tag: data.Tag, Indicator1: data.Ind1, Indicator2: data.Ind2}
-		for _, sub := range data.SubFields {
-			subfield := SubField{Code: sub.Code, Value: sub.Value}
-			field.SubFields = append(field.SubFields, subfield)
+// Next reads the next record in the file. It returns io.EOF when there
+// are no more records to read.
+//
+// Next is an alternative to calling Scan() and Record() separately.
+func (file *MarcFile) Next() (Record, error) {
+	if !file.Scan() {
+		if err := file.Err(); err != nil {
+			return Record{}, err
 		}
-		rec.Fields = append(rec.Fields, field)
+		return Record{}, io.EOF
 	}
-	return nil
+	return file.Record()
 }
 
-func makeRecordFromBinary(file *MarcFile, rec *Record) error {
-	// Parse the bytes from the scanner to create the MARC Record.
-	recBytes := file.scanner.Bytes()
-	err := parseBytesIntoRecord(rec, recBytes)
-	if err != nil {
-		return err
-	}
-
-	start := rec.Leader.dataOffset
-	// TODO: make this magic number a constant
-	if start <= 25 {
-		return ErrBadDataOffset
-	} else if start > len(recBytes) {
-		return ErrBadRecordLength
+// NextContext is like Next but returns ctx.Err() without reading
+// anything once the context has been cancelled.
+func (file *MarcFile) NextContext(ctx context.Context) (Record, error) {
+	if err := ctx.Err(); err != nil {
+		return Record{}, err
 	}
-	data := recBytes[start:]
-	dirs := recBytes[leaderLength : start-1]
-
-	return processDataIntoRecord(data, dirs, rec)
+	return file.Next()
 }
 
-func parseBytesIntoRecord(rec *Record, recBytes []byte) error {
-	rec.Data = append([]byte(nil), recBytes...)
-	leader, err := NewLeader(recBytes[:leaderLength])
-	if err != nil {
-		return err
+// Skip skips the next n records, it returns the number of records
+// skipped which is less than n at the end of the file. Readers of MARC
+// binary skip the records without parsing them (using the length in the
+// leader to find the next record when possible), which is much faster
+// than reading them, but errors in the skipped records go unnoticed.
+func (file *MarcFile) Skip(n int) (int, error) {
+	if file.err != nil {
+		return 0, file.err
 	}
-	rec.Leader = leader
-
-	return nil
-}
-
-func processDataIntoRecord(data, dirs []byte, rec *Record) error {
-	// TODO: make this magic number a constant
-	for len(dirs) >= 12 {
-		tag := string(dirs[:tagEnd])
-		length, err := strconv.Atoi(string(dirs[lengthOfFieldStart:lengthOfFieldEnd]))
-		if err != nil {
-			return ErrUnknownFieldLength
-		}
-		begin, err := strconv.Atoi(string(dirs[startCharPosStart:startCharPosEnd]))
-		if err != nil {
-			return ErrUnknownFieldStart
-		}
-		if len(data) <= begin+length-1 {
-			details := fmt.Sprintf("Tag: %s, len(data): %d, begin: %d, field length: %d",
-				tag, len(data), begin, length)
-			return newIncorrectFieldLengthError(details)
-		}
-		fdata := data[begin : begin+length-1] // length includes field terminator
-		// TODO: make this magic number a constant
-		if len(fdata) > 4 { // ignore illegal data
-			df, err := MakeField(tag, fdata)
-			if err != nil {
-				return err
-			}
-			rec.Fields = append(rec.Fields, df)
+	if skipper, ok := file.reader.(interface{ Skip(int) (int, error) }); ok {
+		return skipper.Skip(n)
+	}
+	for i := 0; i < n; i++ {
+		if !file.Scan() {
+			return i, file.Err()
 		}
-		// TODO: make this magic number a constant
-		dirs = dirs[12:]
 	}
-	return nil
+	return n, nil
+}
+
+// Record returns the current Record in the MarcFile.
+func (file *MarcFile) Record() (Record, error) {
+	return file.record, file.recErr
 }
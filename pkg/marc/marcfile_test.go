@@ -1,8 +1,9 @@
 package marc
 
 import (
-	"bufio"
-	"encoding/xml"
+	"bytes"
+	"context"
+	"io"
 	"os"
 	"testing"
 
@@ -24,30 +25,12 @@ func TestNewMarcFile(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		var want MarcFile
 		file := setUpTestFile(tt.path, t)
 		got := NewMarcFile(file)
-		if tt.isXML {
-			want = MarcFile{
-				decoder: xml.NewDecoder(file),
-				isXML:   true,
-			}
-
-			if !(want.isXML && got.isXML) {
-				t.Error("expected struct field isXML to be true for both files")
-			}
-		} else {
-			want = MarcFile{
-				scanner: bufio.NewScanner(file),
-			}
-
-			opt := cmp.Comparer(func(a, b MarcFile) bool {
-				return cmp.Equal(a.scanner.Bytes(), b.scanner.Bytes())
-			})
-
-			if !cmp.Equal(want, got, opt) {
-				t.Errorf("expected %v, got %v", want, got)
-			}
+		_, isXML := got.reader.(*XMLReader)
+		_, isBinary := got.reader.(*BinaryReader)
+		if tt.isXML != isXML || tt.isXML == isBinary {
+			t.Errorf("%s: expected isXML to be %t, got reader %T", tt.name, tt.isXML, got.reader)
 		}
 	}
 }
@@ -66,6 +49,9 @@ func TestRecord(t *testing.T) {
 
 	for _, tt := range tests {
 		want := newRecord(tt.isXML, t)
+		if tt.isXML {
+			want.Pos = 137
+		}
 		file := setUpTestFile(tt.path, t)
 
 		f := NewMarcFile(file)
@@ -81,6 +67,409 @@ func TestRecord(t *testing.T) {
 			t.Errorf("expected %q, got %q", want, got)
 			t.Error(cmp.Diff(want, got, opt))
 		}
+		// Record.Equal ignores these, check them explicitly.
+		if !cmp.Equal(want.Leader, got.Leader, opt) {
+			t.Error(cmp.Diff(want.Leader, got.Leader, opt))
+		}
+		if !bytes.Equal(want.Data, got.Data) || want.Pos != got.Pos {
+			t.Errorf("expected data %q at %d, got %q at %d", want.Data, want.Pos, got.Data, got.Pos)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "binary", path: "testdata/test_10.mrc"},
+		{name: "XML", path: "testdata/test_10.xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewMarcFile(setUpTestFile(tt.path, t))
+
+			var ids []string
+			for {
+				r, err := f.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("problem calling Next on MarcFile: %s", err)
+				}
+				ids = append(ids, r.ControlNum())
+			}
+
+			if len(ids) != 10 {
+				t.Fatalf("expected 10 records, got %d", len(ids))
+			}
+			if ids[0] != "ocm57175940" {
+				t.Errorf("expected first record to be %q, got %q", "ocm57175940", ids[0])
+			}
+
+			if _, err := f.Next(); err != io.EOF {
+				t.Errorf("expected io.EOF after the last record, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNextContext(t *testing.T) {
+	t.Parallel()
+
+	f := NewMarcFile(setUpTestFile("testdata/test_10.mrc", t))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if _, err := f.NextContext(ctx); err != nil {
+		t.Fatalf("problem calling NextContext on MarcFile: %s", err)
+	}
+
+	cancel()
+	if _, err := f.NextContext(ctx); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestNext_RecordsAreNotOverwritten(t *testing.T) {
+	t.Parallel()
+
+	f := NewMarcFile(setUpTestFile("testdata/test_10.mrc", t))
+	first, err := f.Next()
+	if err != nil {
+		t.Fatalf("problem calling Next on MarcFile: %s", err)
+	}
+	want := first.Leader.Raw()
+
+	for {
+		if _, err := f.Next(); err != nil {
+			break
+		}
+	}
+
+	if got := first.Leader.Raw(); got != want {
+		t.Errorf("expected leader %q, got %q", want, got)
+	}
+}
+
+func TestNewMarcFile_Reader(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/test_10.mrc")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+
+	f := NewMarcFile(bytes.NewReader(data))
+	count := 0
+	for f.Scan() {
+		if _, err := f.Record(); err != nil {
+			t.Fatalf("problem calling Record on MarcFile: %s", err)
+		}
+		count++
+	}
+	if count != 10 {
+		t.Errorf("expected 10 records, got %d", count)
+	}
+}
+
+func TestRecordPos(t *testing.T) {
+	t.Parallel()
+
+	f := NewMarcFile(setUpTestFile("testdata/test_10.mrc", t))
+
+	var want int64
+	for {
+		r, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("problem calling Next on MarcFile: %s", err)
+		}
+		if r.Pos != want {
+			t.Errorf("expected record %s at position %d, got %d", r.ControlNum(), want, r.Pos)
+		}
+		want += int64(len(r.Raw()))
+	}
+}
+
+func TestReadRecordAt(t *testing.T) {
+	t.Parallel()
+
+	for _, path := range []string{"testdata/test_10.mrc", "testdata/test_10.xml"} {
+		want := readTestRecords(path, t)
+		file := setUpTestFile(path, t)
+		for _, i := range []int{3, 0, 9} {
+			got, err := ReadRecordAt(file, want[i].Pos)
+			if err != nil {
+				t.Fatalf("%s: error reading record %d: %v", path, i, err)
+			}
+			if !cmp.Equal(want[i], got) || got.Pos != want[i].Pos {
+				t.Errorf("%s: expected record %d (%s) got %s at %d", path, i, want[i].ControlNum(), got.ControlNum(), got.Pos)
+			}
+		}
+
+		info, _ := file.Stat()
+		if _, err := ReadRecordAt(file, info.Size()); err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: expected io.ErrUnexpectedEOF at the end of the file, got %v", path, err)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	t.Parallel()
+
+	for _, path := range []string{"testdata/test_10.mrc", "testdata/test_10.xml"} {
+		want := readTestRecords(path, t)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error reading file: %v", err)
+		}
+
+		// Read the first records and resume from the offset
+		// reported by the file.
+		file := NewMarcFile(bytes.NewReader(data))
+		for i := 0; i < 4; i++ {
+			if _, err := file.Next(); err != nil {
+				t.Fatalf("%s: error reading record: %v", path, err)
+			}
+		}
+		offset := file.Offset()
+		if offset <= want[3].Pos {
+			t.Fatalf("%s: expected offset after %d, got %d", path, want[3].Pos, offset)
+		}
+
+		resumed := NewMarcFile(bytes.NewReader(data[offset:]))
+		count := 0
+		for {
+			r, err := resumed.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: error reading record after resuming: %v", path, err)
+			}
+			if !cmp.Equal(want[4+count], r) {
+				t.Errorf("%s: expected %s, got %s", path, want[4+count].ControlNum(), r.ControlNum())
+			}
+			count++
+		}
+		if count != len(want)-4 {
+			t.Errorf("%s: expected %d records after resuming, got %d", path, len(want)-4, count)
+		}
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"001", "245", "650"}
+	for _, path := range []string{"testdata/test_10.mrc", "testdata/test_10.xml"} {
+		all := readTestRecords(path, t)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error reading file: %v", err)
+		}
+
+		files := map[string]MarcFile{
+			"reader": NewMarcFile(bytes.NewReader(data)),
+			"bytes":  NewMarcFileBytes(data),
+		}
+		for name, file := range files {
+			file.SetTags(tags)
+			for i, want := range all {
+				r, err := file.Next()
+				if err != nil {
+					t.Fatalf("%s %s: error reading record %d: %v", path, name, i, err)
+				}
+				wantFields := want.Fields.GetAll("001")
+				wantFields = append(wantFields, want.Fields.GetAll("245")...)
+				wantFields = append(wantFields, want.Fields.GetAll("650")...)
+				if got := len(r.Fields); got != len(wantFields) {
+					t.Errorf("%s %s: expected %d fields in record %d, got %d", path, name, i, len(wantFields), got)
+				}
+				for _, tag := range tags {
+					if !cmp.Equal(want.Fields.GetAll(tag), r.Fields.GetAll(tag)) {
+						t.Errorf("%s %s: unexpected %s fields in record %d", path, name, tag, i)
+					}
+				}
+				if !cmp.Equal(want.Leader, r.Leader, cmp.AllowUnexported(Leader{})) {
+					t.Errorf("%s %s: unexpected leader in record %d", path, name, i)
+				}
+			}
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/test_10.mrc")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	want := readTestRecords("testdata/test_10.mrc", t)
+	// Leaders with a wrong length must not confuse the skipping.
+	bad := append([]byte{}, data...)
+	copy(bad[want[1].Pos:], "01000")
+	copy(bad[want[2].Pos:], "99999")
+	xml, err := os.ReadFile("testdata/test_10.xml")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+
+	tests := map[string]func() MarcFile{
+		"reader":     func() MarcFile { return NewMarcFile(bytes.NewReader(data)) },
+		"bad leader": func() MarcFile { return NewMarcFile(bytes.NewReader(bad)) },
+		"bytes":      func() MarcFile { return NewMarcFileBytes(bad) },
+		"parallel":   func() MarcFile { return NewMarcFileParallel(bytes.NewReader(bad), 2) },
+		"xml":        func() MarcFile { return NewMarcFile(bytes.NewReader(xml)) },
+	}
+	for name, newFile := range tests {
+		for _, n := range []int{0, 1, 3, 9, 10, 20} {
+			file := newFile()
+			skipped, err := file.Skip(n)
+			if err != nil {
+				t.Fatalf("%s: error skipping %d records: %v", name, n, err)
+			}
+			if n > len(want) {
+				if skipped != len(want) {
+					t.Errorf("%s: expected %d records skipped, got %d", name, len(want), skipped)
+				}
+				n = len(want)
+			}
+			r, err := file.Next()
+			if n == len(want) {
+				if err != io.EOF {
+					t.Errorf("%s: expected io.EOF after skipping all records, got %v", name, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: error reading record after skipping %d: %v", name, n, err)
+			}
+			if r.ControlNum() != want[n].ControlNum() {
+				t.Errorf("%s: expected %s after skipping %d records, got %s", name, want[n].ControlNum(), n, r.ControlNum())
+			}
+			file.Close()
+		}
+	}
+}
+
+func TestNewMarcFile_EmptyFile(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "empty*.mrc")
+	if err != nil {
+		t.Fatalf("error creating file: %v", err)
+	}
+
+	f := NewMarcFile(file)
+	if f.Scan() {
+		t.Error("expected no records in an empty file")
+	}
+	if err := f.Err(); err != nil {
+		t.Errorf("expected no error for an empty file, got %v", err)
+	}
+}
+
+func TestParseDigits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{in: "00123", want: 123, ok: true},
+		{in: "0", want: 0, ok: true},
+		{in: "", ok: false},
+		{in: "12a", ok: false},
+		{in: "-12", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDigits(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseDigits(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestParseDigitsAllocs is not parallel, AllocsPerRun does not allow it.
+func TestParseDigitsAllocs(t *testing.T) {
+	entry := []byte("245004500120")
+	if allocs := testing.AllocsPerRun(100, func() { parseDigits(string(entry[3:7])) }); allocs != 0 {
+		t.Errorf("expected no allocations parsing the digits of a directory entry, got %v", allocs)
+	}
+}
+
+// BenchmarkNext measures reading and parsing MARC binary records from
+// memory (the test file repeated many times) through an io.Reader.
+func BenchmarkNext(b *testing.B) {
+	data := benchmarkData(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkRead(NewMarcFile(bytes.NewReader(data)), b)
+	}
+}
+
+// BenchmarkNextBytes is like BenchmarkNext but parses the records directly
+// from the data (as when using OpenMmap).
+func BenchmarkNextBytes(b *testing.B) {
+	data := benchmarkData(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkRead(NewMarcFileBytes(data), b)
+	}
+}
+
+// BenchmarkNextParallel is like BenchmarkNext but parses the records
+// in a pool of goroutines (see ParallelReader).
+func BenchmarkNextParallel(b *testing.B) {
+	data := benchmarkData(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchmarkRead(NewMarcFileParallel(bytes.NewReader(data), 4), b)
+	}
+}
+
+// BenchmarkNextTags is like BenchmarkNext but only parses two fields
+// of each record (see MarcFile.SetTags).
+func BenchmarkNextTags(b *testing.B) {
+	data := benchmarkData(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		file := NewMarcFile(bytes.NewReader(data))
+		file.SetTags([]string{"001", "245"})
+		benchmarkRead(file, b)
+	}
+}
+
+func benchmarkData(b *testing.B) []byte {
+	data, err := os.ReadFile("testdata/test_10.mrc")
+	if err != nil {
+		b.Fatalf("error reading file: %v", err)
+	}
+	return bytes.Repeat(data, 100)
+}
+
+func benchmarkRead(file MarcFile, b *testing.B) {
+	for {
+		_, err := file.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			b.Fatalf("error reading record: %v", err)
+		}
 	}
 }
 
@@ -537,6 +926,7 @@ func newRecord(isXML bool, t *testing.T) Record {
 			Type:          byte('a'),
 			BibLevel:      byte('m'),
 			Control:       byte(' '),
+			Encoding:      byte('a'),
 			EncodingLevel: byte(' '),
 			Form:          byte('i'),
 			Multipart:     byte(' '),
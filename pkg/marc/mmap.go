@@ -0,0 +1,65 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+)
+
+// MmapFile is a file mapped into memory, see OpenMmap.
+type MmapFile struct {
+	data  []byte
+	unmap func() error
+}
+
+// OpenMmap maps a file into memory (on systems that support it, on
+// other systems the file is read into memory). This avoids copying the
+// data through read system calls and, combined with NewMarcFileBytes,
+// MARC binary records are parsed directly from the mapped region. The
+// records read must not be used after calling Close.
+func OpenMmap(path string) (*MmapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return &MmapFile{unmap: func() error { return nil }}, nil
+	}
+
+	data, unmap, err := mmapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &MmapFile{data: data, unmap: unmap}, nil
+}
+
+// Bytes returns the content of the file.
+func (m *MmapFile) Bytes() []byte {
+	return m.data
+}
+
+// Close unmaps the file.
+func (m *MmapFile) Close() error {
+	m.data = nil
+	return m.unmap()
+}
+
+// NewMarcFileBytes is like NewMarcFile but for data that is already in
+// memory. MARC binary data is read with a BytesReader and therefore the
+// records reference the data instead of a copy of it.
+func NewMarcFileBytes(data []byte) MarcFile {
+	format, err := detectFormat(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return MarcFile{err: err}
+	}
+	if format == formatBinary {
+		return MarcFile{reader: NewBytesReader(data)}
+	}
+	return NewMarcFile(bytes.NewReader(data))
+}
@@ -0,0 +1,210 @@
+package marc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MrkReader reads records in mnemonic (MRK) format, like the one
+// produced by MrkWriter or MarcEdit. Each line has a field (or the
+// leader) and records are separated by empty lines:
+//
+//	=LDR  01805nam a2200385 i 4500
+//	=001  ocm57175940
+//	=650  \0$aCoal$xAnalysis.
+//
+// Notice that a dollar sign in a value is indistinguishable from a
+// subfield delimiter unless it is escaped as "{dollar}".
+type MrkReader struct {
+	reader *bufio.Reader
+	pos    int64
+	max    int
+	mode   ParseMode
+	err    error
+}
+
+var ErrInvalidMrkLine = errors.New("invalid MRK line")
+
+// NewMrkReader creates a reader for MRK data.
+func NewMrkReader(r io.Reader) *MrkReader {
+	return &MrkReader{reader: bufio.NewReader(r), max: DefaultMaxRecordSize}
+}
+
+// SetMaxRecordSize sets the size of the largest record accepted, larger
+// records stop the reading with ErrRecordTooLarge.
+func (mr *MrkReader) SetMaxRecordSize(max int) {
+	mr.max = max
+}
+
+// SetParseMode sets how the records are parsed, see ParseMode.
+func (mr *MrkReader) SetParseMode(mode ParseMode) {
+	mr.mode = mode
+}
+
+// Next returns the next record, io.EOF when there are no more records.
+func (mr *MrkReader) Next() (Record, error) {
+	if mr.err != nil {
+		return Record{}, mr.err
+	}
+
+	lines := []string{}
+	start := mr.pos
+	for {
+		line, err := mr.readLine(mr.max - int(mr.pos-start))
+		mr.pos += int64(len(line))
+		if err == ErrRecordTooLarge {
+			err = recordTooLargeError(start, mr.max)
+		}
+		if err != nil && err != io.EOF {
+			mr.err = err
+			return Record{}, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(lines) > 0 {
+				break
+			}
+			if err == io.EOF {
+				return Record{}, io.EOF
+			}
+			// skip empty lines between records
+			start = mr.pos
+			continue
+		}
+		lines = append(lines, line)
+		if err == io.EOF {
+			break
+		}
+	}
+
+	rec := Record{Pos: start}
+	rec.Data = []byte(strings.Join(lines, "\r\n"))
+	err := parseMrkLines(&rec, lines, mr.mode)
+	return rec, err
+}
+
+// readLine reads the next line like bufio.Reader.ReadString('\n') but
+// returns ErrRecordTooLarge without reading the whole line if it is
+// longer than max bytes.
+func (mr *MrkReader) readLine(max int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := mr.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return "", ErrRecordTooLarge
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
+
+// Offset returns the position right after the last record read.
+func (mr *MrkReader) Offset() int64 {
+	return mr.pos
+}
+
+// Err returns the error reading the data (if any).
+func (mr *MrkReader) Err() error {
+	return mr.err
+}
+
+func parseMrkLines(rec *Record, lines []string, mode ParseMode) error {
+	for _, line := range lines {
+		if strings.HasPrefix(line, "=LDR") {
+			if len(line) < 6 {
+				return fmt.Errorf("%w: %q", ErrInvalidMrkLine, line)
+			}
+			// Ignore error because a bad data offset is not a problem
+			// in MRK records.
+			rec.Leader, _ = NewLeader([]byte(line[6:]))
+			if mode.strict() {
+				if err := rec.Leader.Validate(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		field, err := parseMrkField(rec, line, mode)
+		if err != nil {
+			return err
+		}
+		if field.IsControlField() && field.Value == "" && !keepEmptyControlField(rec, field.Tag, mode) {
+			continue
+		}
+		rec.Fields = append(rec.Fields, field)
+	}
+	return checkFixedFields(rec, mode)
+}
+
+// ParseMrkField parses a field in mnemonic (MRK) format,
+// e.g. "=245  10$aThe title" or "=001  ocm57175940".
+func ParseMrkField(line string) (Field, error) {
+	return parseMrkField(nil, line, ParseDefault)
+}
+
+// parseMrkField parses a field of the record, in strict mode the data
+// is checked and in lenient mode malformed indicators are fixed first.
+func parseMrkField(rec *Record, line string, mode ParseMode) (Field, error) {
+	// "=TAG  value"
+	if len(line) < 4 || line[0] != '=' {
+		return Field{}, fmt.Errorf("%w: %q", ErrInvalidMrkLine, line)
+	}
+	field := Field{Tag: line[1:4]}
+	if field.IsControlField() {
+		// An empty control field might have lost the trailing blanks.
+		if len(line) > 6 {
+			field.Value = mrkUnescape(line[6:])
+		}
+		return field, nil
+	}
+
+	if len(line) < 6 {
+		return Field{}, fmt.Errorf("%w: %q", ErrInvalidMrkLine, line)
+	}
+	value := line[6:]
+
+	switch {
+	case mode.strict():
+		if err := checkDataField(field.Tag, value, '$', '\\'); err != nil {
+			return Field{}, err
+		}
+	case mode.lenient():
+		value = fixIndicators(rec, field.Tag, value, '$', '\\')
+	}
+	if len(value) < 2 {
+		return Field{}, fmt.Errorf("%w: %q", ErrInvalidIndicators, line)
+	}
+	field.Indicator1 = mrkIndicator(value[0])
+	field.Indicator2 = mrkIndicator(value[1])
+	subfields := strings.Split(value[2:], "$")
+	if subfields[0] != "" {
+		dropDataBeforeSubfields(rec, field.Tag, subfields[0], mode)
+	}
+	for _, sub := range subfields[1:] {
+		switch {
+		case len(sub) > 1:
+			field.SubFields = append(field.SubFields, SubField{Code: sub[:1], Value: mrkUnescape(sub[1:])})
+		case keepEmptySubfield(rec, field.Tag, sub, mode):
+			field.SubFields = append(field.SubFields, SubField{Code: sub})
+		}
+	}
+	return field, nil
+}
+
+func mrkIndicator(value byte) string {
+	if value == '\\' {
+		return " "
+	}
+	return string(value)
+}
+
+func mrkUnescape(value string) string {
+	return strings.ReplaceAll(value, "{dollar}", "$")
+}
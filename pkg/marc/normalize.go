@@ -0,0 +1,104 @@
+package marc
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Normalization indicates how the values are normalized before they are
+// compared or output, e.g. to match values from systems that drop the
+// diacritics or to generate sort keys.
+type Normalization int
+
+const (
+	// NormalizeNone leaves the values as they are.
+	NormalizeNone Normalization = iota
+	// NormalizeStrip removes the diacritics, see RemoveDiacritics.
+	NormalizeStrip
+	// NormalizeTransliterate removes the diacritics and replaces the
+	// letters that do not have a base letter with ASCII, see
+	// Transliterate.
+	NormalizeTransliterate
+	// NormalizeSortKey transliterates the value, folds the case, and
+	// replaces the punctuation with spaces, see SortKey.
+	NormalizeSortKey
+)
+
+var normalizationNames = map[string]Normalization{
+	"none":          NormalizeNone,
+	"strip":         NormalizeStrip,
+	"transliterate": NormalizeTransliterate,
+	"sortkey":       NormalizeSortKey,
+}
+
+// ParseNormalization returns the normalization with the given name:
+// "none", "strip", "transliterate", or "sortkey". An empty name is the
+// same as "none".
+func ParseNormalization(name string) (Normalization, error) {
+	if name == "" {
+		return NormalizeNone, nil
+	}
+	n, ok := normalizationNames[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid normalization %q, accepted values: none, strip, transliterate, sortkey", name)
+	}
+	return n, nil
+}
+
+// Apply returns the value normalized.
+func (n Normalization) Apply(s string) string {
+	switch n {
+	case NormalizeStrip:
+		return RemoveDiacritics(s)
+	case NormalizeTransliterate:
+		return Transliterate(s)
+	case NormalizeSortKey:
+		return SortKey(s)
+	}
+	return s
+}
+
+// Transliterate returns the value without diacritics (see
+// RemoveDiacritics) and with the Latin letters that are not a base
+// letter with diacritics replaced with ASCII letters (e.g. ø with o, æ
+// with ae, and þ with th). The modifier letters used in romanization
+// for the soft and hard signs, alif, and ayn are removed.
+func Transliterate(s string) string {
+	s = RemoveDiacritics(s)
+	if isASCII(s) {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for _, r := range s {
+		if value, ok := transliterations[r]; ok {
+			sb.WriteString(value)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+var transliterations = map[rune]string{
+	'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'Ø': "O", 'ø': "o",
+	'ß': "ss", 'ẞ': "SS", 'Þ': "Th", 'þ': "th", 'Ð': "D", 'ð': "d",
+	'Đ': "D", 'đ': "d", 'Ł': "L", 'ł': "l", 'Ħ': "H", 'ħ': "h",
+	'Ŋ': "Ng", 'ŋ': "ng", 'Ŀ': "L", 'ŀ': "l", 'ı': "i", 'ſ': "s",
+	'ƒ': "f", 'Ɨ': "I", 'ɨ': "i", 'Ʉ': "U", 'ʉ': "u",
+	'ﬀ': "ff", 'ﬁ': "fi", 'ﬂ': "fl", 'ﬃ': "ffi", 'ﬄ': "ffl",
+	// Soft and hard signs, alif, and ayn.
+	'ʹ': "", 'ʺ': "", 'ʻ': "", 'ʼ': "", 'ʾ': "", 'ʿ': "",
+}
+
+// SortKey returns the value transliterated (see Transliterate), case
+// folded (see FoldCase), and with the characters that are not letters
+// or digits replaced with a space, without repeated spaces, e.g. "godel
+// kurt 1906 1978" for "Gödel, Kurt, 1906-1978.".
+func SortKey(s string) string {
+	s = FoldCase(Transliterate(s))
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,91 @@
+package marc
+
+import "testing"
+
+func TestParseNormalization(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]Normalization{
+		"":              NormalizeNone,
+		"none":          NormalizeNone,
+		"strip":         NormalizeStrip,
+		"transliterate": NormalizeTransliterate,
+		"sortkey":       NormalizeSortKey,
+	}
+	for name, want := range tests {
+		got, err := ParseNormalization(name)
+		if err != nil || got != want {
+			t.Errorf("ParseNormalization(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseNormalization("ascii"); err == nil {
+		t.Errorf("expected an error for an unknown normalization")
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"Søren Kierkegaard":   "Soren Kierkegaard",
+		"Ærøskøbing":          "AEroskobing",
+		"Þórbergur Þórðarson": "Thorbergur Thordarson",
+		"Łódź":                "Lodz",
+		"Straße":              "Strasse",
+		"Dostoevskiĭ, Fedor":  "Dostoevskii, Fedor",
+		"Istoriia Rusi i ʹ":   "Istoriia Rusi i ",
+		"ʻAbd al-Raḥmān":      "Abd al-Rahman",
+		"plain":               "plain",
+	}
+	for value, want := range tests {
+		if got := Transliterate(value); got != want {
+			t.Errorf("Transliterate(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestSortKey(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"Gödel, Kurt, 1906-1978.":          "godel kurt 1906 1978",
+		"The Ørsted  effect / by J. Smith": "the orsted effect by j smith",
+		"  ...  ":                          "",
+		"Straße":                           "strasse",
+	}
+	for value, want := range tests {
+		if got := SortKey(value); got != want {
+			t.Errorf("SortKey(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestFilterPlan_Normalization(t *testing.T) {
+	t.Parallel()
+
+	r := Record{Fields: Fields{
+		{Tag: "100", SubFields: []SubField{{Code: "a", Value: "Gödel, Kurt."}}},
+		{Tag: "245", SubFields: []SubField{{Code: "a", Value: "Über formal unentscheidbare Sätze"}}},
+		{Tag: "700", SubFields: []SubField{{Code: "a", Value: "Kierkegaard, Søren,"}, {Code: "d", Value: "1813-1855."}}},
+	}}
+	tests := []struct {
+		spec FilterSpec
+		want bool
+	}{
+		{spec: FilterSpec{Search: "godel"}, want: false},
+		{spec: FilterSpec{Search: "GÖDEL"}, want: true},
+		{spec: FilterSpec{Search: "godel", Normalization: NormalizeStrip}, want: true},
+		{spec: FilterSpec{Search: "GÖDEL", Normalization: NormalizeStrip}, want: true},
+		{spec: FilterSpec{Search: "uber formal", Normalization: NormalizeStrip}, want: true},
+		{spec: FilterSpec{Search: "satze", SearchFields: []string{"100"}, Normalization: NormalizeStrip}, want: false},
+		{spec: FilterSpec{Search: "soren", Normalization: NormalizeStrip}, want: false},
+		{spec: FilterSpec{Search: "soren", Normalization: NormalizeTransliterate}, want: true},
+		{spec: FilterSpec{Search: "Kierkegaard Søren", Normalization: NormalizeTransliterate}, want: false},
+		{spec: FilterSpec{Search: "Kierkegaard Søren", Normalization: NormalizeSortKey}, want: true},
+	}
+	for _, tt := range tests {
+		if got := tt.spec.Compile().Match(r); got != tt.want {
+			t.Errorf("%+v: expected %v, got %v", tt.spec, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package marc
+
+import (
+	"io"
+	"sync"
+)
+
+// ParallelReader reads records in MARC binary format parsing them in
+// a pool of goroutines. One goroutine carves the bytes of each record
+// from the input and the workers parse them, records are still returned
+// in the order in which they are in the input.
+//
+// Close must be called if the reader is not read until the end.
+type ParallelReader struct {
+	reader  *BinaryReader
+	workers int
+	tags    tagSet
+	started sync.Once
+	running bool
+	pending chan chan parsedRecord
+	done    chan struct{}
+	once    sync.Once
+	offset  int64
+
+	mu  sync.Mutex
+	err error
+}
+
+type parsedRecord struct {
+	rec Record
+	end int64
+	err error
+}
+
+type rawRecord struct {
+	data   []byte
+	pos    int64
+	end    int64
+	err    error // the record is truncated, see BinaryReader.truncatedError
+	result chan parsedRecord
+}
+
+// NewParallelReader creates a reader for MARC binary data that parses
+// the records with the given number of goroutines.
+func NewParallelReader(r io.Reader, workers int) *ParallelReader {
+	if workers < 1 {
+		workers = 1
+	}
+	return &ParallelReader{
+		reader:  NewBinaryReader(r),
+		workers: workers,
+		pending: make(chan chan parsedRecord, workers*4),
+		done:    make(chan struct{}),
+	}
+}
+
+// SetMaxRecordSize sets the size of the largest record accepted, larger
+// records stop the reading with ErrRecordTooLarge. It must be called
+// before reading the first record.
+func (pr *ParallelReader) SetMaxRecordSize(max int) {
+	pr.reader.SetMaxRecordSize(max)
+}
+
+// SetTags limits the fields parsed to the ones with the given tags,
+// nil parses all the fields. It must be called before reading the
+// first record.
+func (pr *ParallelReader) SetTags(tags []string) {
+	pr.tags = newTagSet(tags)
+}
+
+// SetParseMode sets how the records are parsed, see ParseMode. It must
+// be called before reading the first record.
+func (pr *ParallelReader) SetParseMode(mode ParseMode) {
+	pr.reader.SetParseMode(mode)
+}
+
+// start starts the goroutines, this happens when the first record is
+// requested so that the settings above apply to all the records.
+func (pr *ParallelReader) start() {
+	pr.running = true
+	jobs := make(chan rawRecord, pr.workers*4)
+	for i := 0; i < pr.workers; i++ {
+		go func() {
+			for job := range jobs {
+				rec := Record{Pos: job.pos}
+				err := job.err
+				if err != nil {
+					rec.Data = job.data
+				} else {
+					err = makeRecordFromBinary(&rec, job.data, pr.tags, pr.reader.mode)
+				}
+				job.result <- parsedRecord{rec: rec, end: job.end, err: err}
+			}
+		}()
+	}
+	go pr.carve(pr.reader, jobs)
+}
+
+// carve reads the bytes of each record and queues them to be parsed.
+// The channel for the result of each record is queued in pending in
+// the same order so that Next() returns the records in order.
+func (pr *ParallelReader) carve(br *BinaryReader, jobs chan<- rawRecord) {
+	defer close(pr.pending)
+	defer close(jobs)
+	for {
+		data, pos, err := br.nextRaw()
+		if err != nil {
+			if err != io.EOF {
+				pr.mu.Lock()
+				pr.err = err
+				pr.mu.Unlock()
+			}
+			return
+		}
+
+		job := rawRecord{data: data, pos: pos, end: br.Offset(), err: br.truncatedError(), result: make(chan parsedRecord, 1)}
+		select {
+		case pr.pending <- job.result:
+		case <-pr.done:
+			return
+		}
+		select {
+		case jobs <- job:
+		case <-pr.done:
+			return
+		}
+	}
+}
+
+// Next returns the next record, io.EOF when there are no more records.
+func (pr *ParallelReader) Next() (Record, error) {
+	pr.started.Do(pr.start)
+	result, ok := <-pr.pending
+	if !ok {
+		if err := pr.Err(); err != nil {
+			return Record{}, err
+		}
+		return Record{}, io.EOF
+	}
+	parsed := <-result
+	pr.offset = parsed.end
+	return parsed.rec, parsed.err
+}
+
+// Skip skips the next n records. Before reading the first record they
+// are skipped without parsing them, see BinaryReader.Skip.
+func (pr *ParallelReader) Skip(n int) (int, error) {
+	if !pr.running {
+		skipped, err := pr.reader.Skip(n)
+		pr.offset = pr.reader.Offset()
+		return skipped, err
+	}
+	for i := 0; i < n; i++ {
+		if _, err := pr.Next(); err == io.EOF {
+			return i, nil
+		} else if err != nil && pr.Err() != nil {
+			return i, err
+		}
+	}
+	return n, nil
+}
+
+// Offset returns the position where the next record starts.
+func (pr *ParallelReader) Offset() int64 {
+	return pr.offset
+}
+
+// Err returns the error reading the data (if any).
+func (pr *ParallelReader) Err() error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return pr.err
+}
+
+// Close stops reading and parsing records.
+func (pr *ParallelReader) Close() error {
+	pr.once.Do(func() { close(pr.done) })
+	return nil
+}
@@ -0,0 +1,226 @@
+package marc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseMode indicates what the readers do when the data of a record
+// does not follow the MARC specification. The modes can be combined
+// (e.g. ParseLenient|ParseRepair) but ParseStrict takes precedence over
+// the others. The problems detected and what each mode does with them:
+//
+//	Problem                            Strict  Default    Lenient
+//	Missing or malformed indicators    error   kept       blanks
+//	Empty subfields (no code or value) error   dropped    dropped
+//	Data before the first subfield     error   dropped    dropped
+//	Directory does not match the data  error   error      rebuilt
+//	Base address of data is wrong      error   as is      fixed
+//	Record length is wrong             error   ignored    ignored
+//	Invalid leader (Leader.Validate)   error   ignored    ignored
+//	Empty control fields               kept    kept       kept
+//
+// The checks on the directory, base address, and record length only
+// apply to MARC binary. Readers of JSON ignore the mode.
+type ParseMode int
+
+const (
+	// ParseDefault reports the problems that prevent parsing a record
+	// as errors and accepts the rest of the data as it is.
+	ParseDefault ParseMode = 0
+	// ParseLenient fixes the problems it can instead of returning an
+	// error and records a Warning in the record for each of them.
+	// It includes ParseRepair.
+	ParseLenient ParseMode = 1 << 0
+	// ParseRepair rebuilds the directory of MARC binary records when it
+	// does not match the data (see ErrDirectoryMismatch) using the field
+	// terminators in the data, and records a Warning.
+	ParseRepair ParseMode = 1 << 1
+	// ParseStrict reports any deviation from the specification as an
+	// error, e.g. for validation workflows.
+	ParseStrict ParseMode = 1 << 2
+	// ParseKeepEmptySubfields keeps the subfields that have a code but
+	// no value (e.g. a trailing "$c") instead of dropping them, and
+	// records a Warning for each empty subfield kept or dropped.
+	// Subfields without a code cannot be kept.
+	ParseKeepEmptySubfields ParseMode = 1 << 3
+	// ParseDropEmptyFields drops the control fields without a value
+	// (e.g. a zero-length field in the directory of MARC binary) and
+	// records a Warning for each of them. By default they are kept
+	// with an empty value since some systems emit them on purpose.
+	ParseDropEmptyFields ParseMode = 1 << 4
+)
+
+func (mode ParseMode) strict() bool {
+	return mode&ParseStrict != 0
+}
+
+func (mode ParseMode) lenient() bool {
+	return !mode.strict() && mode&ParseLenient != 0
+}
+
+func (mode ParseMode) repair() bool {
+	return !mode.strict() && mode&(ParseLenient|ParseRepair) != 0
+}
+
+func (mode ParseMode) keepEmptySubfields() bool {
+	return !mode.strict() && mode&ParseKeepEmptySubfields != 0
+}
+
+func (mode ParseMode) dropEmptyFields() bool {
+	return !mode.strict() && mode&ParseDropEmptyFields != 0
+}
+
+// warnEmptySubfields returns true if the empty subfields that are
+// dropped are recorded as warnings.
+func (mode ParseMode) warnEmptySubfields() bool {
+	return mode.lenient() || mode.keepEmptySubfields()
+}
+
+// Warning is a problem found in a record that was fixed when parsing
+// it, see ParseLenient and ParseRepair.
+type Warning struct {
+	Tag     string // tag of the field, empty if not specific to a field
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Tag == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("field %s: %s", w.Tag, w.Message)
+}
+
+// addWarning records a warning in the record.
+func (r *Record) addWarning(tag string, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Warning{Tag: tag, Message: fmt.Sprintf(format, args...)})
+}
+
+// fixIndicators returns the data of a data field (indicators followed
+// by the subfields) with blanks instead of the missing or malformed
+// indicators. Indicators are missing when the data starts with the
+// subfield delimiter. blank is the character used for blanks in the
+// data (e.g. a backslash in MRK).
+func fixIndicators(rec *Record, tag string, data string, delimiter byte, blank byte) string {
+	n := 0 // number of indicators present
+	for n < 2 && n < len(data) && data[n] != delimiter {
+		n++
+	}
+	ind := []byte{blank, blank}
+	fixed := n < 2
+	for i := 0; i < n; i++ {
+		if data[i] == blank || validIndicator(data[i]) {
+			ind[i] = data[i]
+			continue
+		}
+		rec.addWarning(tag, "invalid indicator %q replaced with a blank", data[i:i+1])
+		fixed = true
+	}
+	if !fixed {
+		return data
+	}
+	switch n {
+	case 0:
+		rec.addWarning(tag, "missing indicators replaced with blanks")
+	case 1:
+		rec.addWarning(tag, "missing second indicator replaced with a blank")
+	}
+	return string(ind) + data[n:]
+}
+
+// fixIndicator returns the value of an indicator (e.g. the ind1
+// attribute in MARC XML) or a blank if it is malformed.
+func fixIndicator(rec *Record, tag string, value string) string {
+	if len(value) == 1 && validIndicator(value[0]) {
+		return value
+	}
+	rec.addWarning(tag, "invalid indicator %q replaced with a blank", value)
+	return " "
+}
+
+// checkDataField returns an error if the data of a data field (the
+// indicators followed by the subfields) does not follow the
+// specification, see ParseStrict. blank is the character used for
+// blanks in the data (e.g. a backslash in MRK).
+func checkDataField(tag string, data string, delimiter byte, blank byte) error {
+	for i := 0; i < 2; i++ {
+		if i >= len(data) || data[i] == delimiter {
+			return fmt.Errorf("%w: field %s: missing indicators", ErrInvalidIndicators, tag)
+		}
+		if data[i] != blank && !validIndicator(data[i]) {
+			return fmt.Errorf("%w: field %s: %q", ErrInvalidIndicators, tag, data[i:i+1])
+		}
+	}
+	subfields := data[2:]
+	if subfields == "" || subfields[0] != delimiter {
+		return fmt.Errorf("%w: field %s: data before the first subfield", ErrInvalidSubfield, tag)
+	}
+	for _, sub := range strings.Split(subfields[1:], string(delimiter)) {
+		if len(sub) < 2 {
+			return fmt.Errorf("%w: field %s: empty subfield %q", ErrInvalidSubfield, tag, sub)
+		}
+	}
+	return nil
+}
+
+// checkParsedField is like checkDataField for a data field that has
+// already been parsed, e.g. from MARC XML.
+func checkParsedField(f Field) error {
+	for _, ind := range []string{f.Indicator1, f.Indicator2} {
+		if len(ind) != 1 || !validIndicator(ind[0]) {
+			return fmt.Errorf("%w: field %s: %q", ErrInvalidIndicators, f.Tag, ind)
+		}
+	}
+	if len(f.SubFields) == 0 {
+		return fmt.Errorf("%w: field %s: no subfields", ErrInvalidSubfield, f.Tag)
+	}
+	for _, sub := range f.SubFields {
+		if len(sub.Code) != 1 || sub.Value == "" {
+			return fmt.Errorf("%w: field %s: empty subfield %q", ErrInvalidSubfield, f.Tag, sub.Code+sub.Value)
+		}
+	}
+	return nil
+}
+
+// keepEmptySubfield returns true if a subfield with the code received
+// but without a value is kept, see ParseKeepEmptySubfields. An empty
+// code is a subfield delimiter followed by another delimiter or by the
+// end of the field, those subfields are always dropped.
+func keepEmptySubfield(rec *Record, tag string, code string, mode ParseMode) bool {
+	switch {
+	case len(code) == 1 && mode.keepEmptySubfields():
+		rec.addWarning(tag, "subfield %s has no value", code)
+		return true
+	case !mode.warnEmptySubfields():
+	case code == "":
+		rec.addWarning(tag, "empty subfield dropped")
+	default:
+		rec.addWarning(tag, "subfield %s without a value dropped", code)
+	}
+	return false
+}
+
+// keepEmptyControlField returns true if a control field without a
+// value is kept, see ParseDropEmptyFields.
+func keepEmptyControlField(rec *Record, tag string, mode ParseMode) bool {
+	if !mode.dropEmptyFields() {
+		return true
+	}
+	rec.addWarning(tag, "empty control field dropped")
+	return false
+}
+
+// dropDataBeforeSubfields records a warning for the data of a field
+// that is not part of a subfield (it is before the first subfield
+// delimiter), that data is dropped.
+func dropDataBeforeSubfields(rec *Record, tag string, data string, mode ParseMode) {
+	if mode.warnEmptySubfields() {
+		rec.addWarning(tag, "data before the first subfield dropped: %q", data)
+	}
+}
+
+// validIndicator returns true for the values accepted in indicators:
+// a blank, a digit, or a lowercase letter.
+func validIndicator(c byte) bool {
+	return c == ' ' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z')
+}
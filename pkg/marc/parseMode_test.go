@@ -0,0 +1,311 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFixIndicators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		data     string
+		want     string
+		warnings int
+	}{
+		{data: "10\x1faTitle", want: "10\x1faTitle"},
+		{data: " 0\x1faTitle", want: " 0\x1faTitle"},
+		{data: "\x1faTitle", want: "  \x1faTitle", warnings: 1},
+		{data: "1\x1faTitle", want: "1 \x1faTitle", warnings: 1},
+		{data: "#0\x1faTitle", want: " 0\x1faTitle", warnings: 1},
+		{data: "A|\x1faTitle", want: "  \x1faTitle", warnings: 2},
+	}
+
+	for _, tt := range tests {
+		rec := Record{}
+		got := fixIndicators(&rec, "245", tt.data, st, ' ')
+		if got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.data, tt.want, got)
+		}
+		if len(rec.Warnings) != tt.warnings {
+			t.Errorf("%q: expected %d warnings, got %v", tt.data, tt.warnings, rec.Warnings)
+		}
+	}
+}
+
+// encodeAndRead reads the first record of the file with the parse mode,
+// encodes it with EncodeMRC, and reads it back with the default mode.
+func encodeAndRead(t *testing.T, file MarcFile, mode ParseMode) Record {
+	t.Helper()
+	file.SetParseMode(mode)
+	rec, err := file.Next()
+	if err != nil {
+		t.Fatalf("error reading record: %v", err)
+	}
+	if len(rec.Warnings) == 0 {
+		t.Fatalf("expected warnings for the record")
+	}
+	data, err := EncodeMRC(rec)
+	if err != nil {
+		t.Fatalf("error encoding record: %v", err)
+	}
+	if original, err := rec.Original(); err != nil || bytes.Equal(original, data) {
+		t.Errorf("expected the original bytes to be kept and to differ, got %v", err)
+	}
+	encodedFile := NewMarcFileBytes(data)
+	encoded, err := encodedFile.Next()
+	if err != nil {
+		t.Fatalf("error reading the encoded record: %v", err)
+	}
+	return encoded
+}
+
+func TestParseLenient(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddDataField("245", "#", "0", SubField{Code: "a", Value: "The title"})
+	binary, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+	mrk := "=LDR  00000nam a2200000 i 4500\n=001  ocm57175940\n=245  $aThe title\n"
+	xml := `<record><leader>00000nam a2200000 i 4500</leader><controlfield tag="001">ocm57175940</controlfield>` +
+		`<datafield tag="245" ind1="" ind2="10"><subfield code="a">The title</subfield></datafield></record>`
+
+	tests := []struct {
+		name string
+		file MarcFile
+		ind2 string
+	}{
+		{name: "binary", file: NewMarcFile(bytes.NewReader(binary)), ind2: "0"},
+		{name: "mmap", file: NewMarcFileBytes(binary), ind2: "0"},
+		{name: "mrk", file: NewMarcFile(strings.NewReader(mrk)), ind2: " "},
+		{name: "xml", file: NewMarcFile(strings.NewReader(xml)), ind2: " "},
+	}
+	for _, tt := range tests {
+		tt.file.SetParseMode(ParseLenient)
+		rec, err := tt.file.Next()
+		if err != nil {
+			t.Errorf("%s: error reading record: %v", tt.name, err)
+			continue
+		}
+		want := Field{Tag: "245", Indicator1: " ", Indicator2: tt.ind2, SubFields: []SubField{{Code: "a", Value: "The title"}}}
+		if got, _ := rec.Fields.GetOne("245"); !cmp.Equal(want, got) {
+			t.Errorf("%s: %s", tt.name, cmp.Diff(want, got))
+		}
+		if len(rec.Warnings) == 0 || rec.Warnings[0].Tag != "245" {
+			t.Errorf("%s: expected warnings for 245, got %v", tt.name, rec.Warnings)
+		}
+	}
+
+	// The fixed record is encoded again, not written as it was read.
+	encoded := encodeAndRead(t, NewMarcFileBytes(binary), ParseLenient)
+	if got, _ := encoded.Fields.GetOne("245"); got.Indicator1 != " " || len(encoded.Warnings) != 0 {
+		t.Errorf("expected the fixed indicator in MARC binary, got %q %v", got.Indicator1, encoded.Warnings)
+	}
+
+	// By default the indicators are not checked.
+	file := NewMarcFile(bytes.NewReader(binary))
+	rec, err := file.Next()
+	if err != nil {
+		t.Fatalf("error reading record: %v", err)
+	}
+	if got, _ := rec.Fields.GetOne("245"); got.Indicator1 != "#" || len(rec.Warnings) != 0 {
+		t.Errorf("expected indicator # without warnings, got %q %v", got.Indicator1, rec.Warnings)
+	}
+}
+
+func TestCheckDataField(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		data    string
+		wantErr error
+	}{
+		{data: "10\x1faTitle\x1fcAuthor"},
+		{data: "  \x1faTitle"},
+		{data: "\x1faTitle", wantErr: ErrInvalidIndicators},
+		{data: "#0\x1faTitle", wantErr: ErrInvalidIndicators},
+		{data: "10", wantErr: ErrInvalidSubfield},
+		{data: "10Title", wantErr: ErrInvalidSubfield},
+		{data: "10\x1faTitle\x1f\x1fcAuthor", wantErr: ErrInvalidSubfield},
+		{data: "10\x1faTitle\x1fc", wantErr: ErrInvalidSubfield},
+	}
+
+	for _, tt := range tests {
+		if err := checkDataField("245", tt.data, st, ' '); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%q: expected error %v, got %v", tt.data, tt.wantErr, err)
+		}
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "The title"})
+	good, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+	badLength := append([]byte(nil), good...)
+	copy(badLength, "00099")
+
+	auth := NewRecord()
+	auth.SetLeader("00000cz  a2200000n  4500")
+	auth.AddControlField("001", "n  79021164")
+	auth.AddControlField("008", "790413n| azannaabn          |a aaa      ")
+	auth.AddDataField("100", "1", " ", SubField{Code: "a", Value: "Twain, Mark,"}, SubField{Code: "d", Value: "1835-1910"})
+	authority, err := auth.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling authority record: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		file    MarcFile
+		wantErr error
+	}{
+		{name: "binary", file: NewMarcFileBytes(good)},
+		{name: "record length", file: NewMarcFileBytes(badLength), wantErr: ErrBadRecordLength},
+		{name: "authority", file: NewMarcFileBytes(authority)},
+		{name: "mrk", file: NewMarcFile(strings.NewReader("=LDR  00000nam a2200000 i 4500\n=245  10$aThe title$c\n")), wantErr: ErrInvalidSubfield},
+		{name: "xml", file: NewMarcFile(strings.NewReader(`<record><leader>00000nam a2200000 i 4500</leader>` +
+			`<datafield tag="245" ind1="1" ind2="#"><subfield code="a">The title</subfield></datafield></record>`)), wantErr: ErrInvalidIndicators},
+	}
+	for _, tt := range tests {
+		tt.file.SetParseMode(ParseStrict)
+		if _, err := tt.file.Next(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+
+	// ParseStrict takes precedence over ParseLenient.
+	file := NewMarcFileBytes(badLength)
+	file.SetParseMode(ParseStrict | ParseLenient)
+	if _, err := file.Next(); !errors.Is(err, ErrBadRecordLength) {
+		t.Errorf("expected error %v, got %v", ErrBadRecordLength, err)
+	}
+}
+
+func TestEmptySubfields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode     ParseMode
+		want     []SubField
+		warnings int
+	}{
+		{mode: ParseDefault, want: []SubField{{Code: "a", Value: "Title"}}},
+		{mode: ParseLenient, want: []SubField{{Code: "a", Value: "Title"}}, warnings: 3},
+		{mode: ParseKeepEmptySubfields, want: []SubField{{Code: "a", Value: "Title"}, {Code: "c"}}, warnings: 3},
+	}
+	for _, tt := range tests {
+		rec := Record{}
+		got, err := makeField(&rec, "245", "10x\x1faTitle\x1f\x1fc", tt.mode)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %v", tt.mode, err)
+		}
+		if !cmp.Equal(tt.want, got.SubFields) {
+			t.Errorf("%d: %s", tt.mode, cmp.Diff(tt.want, got.SubFields))
+		}
+		if len(rec.Warnings) != tt.warnings {
+			t.Errorf("%d: expected %d warnings, got %v", tt.mode, tt.warnings, rec.Warnings)
+		}
+	}
+
+	// The dropped subfields are not in the MARC binary output.
+	r := NewRecord()
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "Title"}, SubField{Code: "c"})
+	binary, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+	encoded := encodeAndRead(t, NewMarcFileBytes(binary), ParseLenient)
+	if bytes.Contains(encoded.Data, []byte("\x1fc")) {
+		t.Errorf("expected the $c to be dropped in MARC binary, got %q", encoded.Data)
+	}
+
+	// The readers of MRK and XML follow the same policy.
+	mrk := "=LDR  00000nam a2200000 i 4500\n=245  10$aTitle$$c\n"
+	xml := `<record><leader>00000nam a2200000 i 4500</leader><datafield tag="245" ind1="1" ind2="0">` +
+		`<subfield code="a">Title</subfield><subfield code=""></subfield><subfield code="c"></subfield></datafield></record>`
+	for name, data := range map[string]string{"mrk": mrk, "xml": xml} {
+		for _, mode := range []ParseMode{ParseDefault, ParseKeepEmptySubfields} {
+			file := NewMarcFile(strings.NewReader(data))
+			file.SetParseMode(mode)
+			rec, err := file.Next()
+			if err != nil {
+				t.Fatalf("%s: error reading record: %v", name, err)
+			}
+			want := []SubField{{Code: "a", Value: "Title"}}
+			if mode == ParseKeepEmptySubfields {
+				want = append(want, SubField{Code: "c"})
+			}
+			if got, _ := rec.Fields.GetOne("245"); !cmp.Equal(want, got.SubFields) {
+				t.Errorf("%s %d: %s", name, mode, cmp.Diff(want, got.SubFields))
+			}
+		}
+	}
+}
+
+func TestEmptyControlFields(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddControlField("003", "DLC")
+	r.AddControlField("005", "")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "The title"})
+	binary, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+	// The same record with a zero-length 005 (no field terminator).
+	entry := leaderLength + 2*directoryEntry
+	zero := append([]byte(nil), binary...)
+	copy(zero[entry+lengthOfFieldStart:], "0000")
+	mrk := "=LDR  00000nam a2200000 i 4500\n=001  ocm57175940\n=003  DLC\n=005\n=245  10$aThe title\n"
+	xml := `<record><leader>00000nam a2200000 i 4500</leader><controlfield tag="001">ocm57175940</controlfield>` +
+		`<controlfield tag="003">DLC</controlfield><controlfield tag="005"></controlfield>` +
+		`<datafield tag="245" ind1="1" ind2="0"><subfield code="a">The title</subfield></datafield></record>`
+
+	newFiles := map[string]func() MarcFile{
+		"binary":      func() MarcFile { return NewMarcFile(bytes.NewReader(binary)) },
+		"zero length": func() MarcFile { return NewMarcFileBytes(zero) },
+		"mrk":         func() MarcFile { return NewMarcFile(strings.NewReader(mrk)) },
+		"xml":         func() MarcFile { return NewMarcFile(strings.NewReader(xml)) },
+	}
+	for name, newFile := range newFiles {
+		file := newFile()
+		rec, err := file.Next()
+		if err != nil {
+			t.Fatalf("%s: error reading record: %v", name, err)
+		}
+		if diff := cmp.Diff(r.Fields, rec.Fields); diff != "" {
+			t.Errorf("%s: fields mismatch (-want +got):\n%s", name, diff)
+		}
+
+		file = newFile()
+		file.SetParseMode(ParseDropEmptyFields)
+		rec, err = file.Next()
+		if err != nil {
+			t.Fatalf("%s: error reading record: %v", name, err)
+		}
+		if _, ok := rec.Fields.GetOne("005"); ok || len(rec.Fields) != 3 || len(rec.Warnings) != 1 {
+			t.Errorf("%s: expected the 005 to be dropped with a warning, got %v %v", name, rec.Fields, rec.Warnings)
+		}
+	}
+	// The dropped field is not in the MARC binary output.
+	encoded := encodeAndRead(t, NewMarcFileBytes(binary), ParseDropEmptyFields)
+	if _, ok := encoded.Fields.GetOne("005"); ok || len(encoded.Fields) != 3 {
+		t.Errorf("expected the 005 to be dropped in MARC binary, got %v", encoded.Fields)
+	}
+}
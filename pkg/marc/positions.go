@@ -0,0 +1,126 @@
+package marc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PositionFilter selects the records by the characters at some
+// positions (starting at 0, as in the MARC documentation) of the leader
+// or of a control field, e.g. "leader/17=#" for the full level records
+// or "008/35-37=eng" for the records in English.
+type PositionFilter struct {
+	Tag    string   // "LDR" for the leader, or the tag of a control field
+	Start  int      // first position
+	End    int      // last position, the same as Start for one position
+	Values []string // the record matches if it has any of them
+	Negate bool     // the record matches if it has none of the values
+}
+
+// ParsePositionFilter parses a condition in the format TAG/NN=value
+// or TAG/NN-NN=value, where TAG is leader (or LDR) or the tag of a
+// control field. Blanks are given as # (or the word blank for all the
+// positions), alternative values are separated with |, and != selects
+// the records that do not have the values. For example:
+//
+//	"leader/17=blank" the full level records.
+//	"leader/06-07=am|tm" the books.
+//	"008/35-37!=eng" the records not in English.
+//	"007/00=c" the records with an 007 for electronic resources.
+//
+// For repeatable control fields (e.g. 007) the record matches if any
+// of them has the value. Records without the field never match, or
+// always match with !=.
+func ParsePositionFilter(spec string) (PositionFilter, error) {
+	invalid := func(reason string) (PositionFilter, error) {
+		return PositionFilter{}, fmt.Errorf("%w %q: %s", ErrInvalidFieldSpec, spec, reason)
+	}
+	slash := strings.Index(spec, "/")
+	if slash == -1 {
+		return invalid("indicate the positions after a /, e.g. leader/17=#")
+	}
+	filter := PositionFilter{Tag: strings.TrimSpace(spec[:slash])}
+	if strings.EqualFold(filter.Tag, "leader") || strings.EqualFold(filter.Tag, "LDR") {
+		filter.Tag = "LDR"
+	} else if !isControlTag(filter.Tag) {
+		return invalid("the positions are only supported in the leader and the control fields")
+	}
+
+	rest := spec[slash+1:]
+	eq := strings.Index(rest, "=")
+	if eq == -1 {
+		return invalid("indicate the value after =, e.g. leader/17=#")
+	}
+	positions, value := rest[:eq], rest[eq+1:]
+	if strings.HasSuffix(positions, "!") {
+		filter.Negate = true
+		positions = positions[:len(positions)-1]
+	}
+	start, end := positions, positions
+	if i := strings.Index(positions, "-"); i != -1 {
+		start, end = positions[:i], positions[i+1:]
+	}
+	var err1, err2 error
+	filter.Start, err1 = strconv.Atoi(start)
+	filter.End, err2 = strconv.Atoi(end)
+	if err1 != nil || err2 != nil || filter.Start < 0 || filter.End < filter.Start {
+		return invalid("invalid positions")
+	}
+
+	length := filter.End - filter.Start + 1
+	for _, v := range strings.Split(value, "|") {
+		if v == "blank" {
+			v = strings.Repeat(" ", length)
+		}
+		v = strings.ReplaceAll(v, "#", " ")
+		if len(v) != length {
+			return invalid(fmt.Sprintf("the value %q does not have %d characters", v, length))
+		}
+		filter.Values = append(filter.Values, v)
+	}
+	return filter, nil
+}
+
+// Matches returns true if the record has (or, if Negate, does not have)
+// one of the values at the positions.
+func (filter PositionFilter) Matches(r Record) bool {
+	found := false
+	if filter.Tag == "LDR" {
+		found = filter.hasValue(r.Leader.Raw())
+	} else {
+		for _, field := range r.Fields {
+			if field.Tag == filter.Tag && filter.hasValue(field.Value) {
+				found = true
+				break
+			}
+		}
+	}
+	return found != filter.Negate
+}
+
+func (filter PositionFilter) hasValue(value string) bool {
+	if len(value) <= filter.End {
+		return false
+	}
+	value = value[filter.Start : filter.End+1]
+	for _, v := range filter.Values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the filter in the format that ParsePositionFilter
+// takes.
+func (filter PositionFilter) String() string {
+	str := fmt.Sprintf("%s/%02d", filter.Tag, filter.Start)
+	if filter.End != filter.Start {
+		str += fmt.Sprintf("-%02d", filter.End)
+	}
+	if filter.Negate {
+		str += "!"
+	}
+	return str + "=" + strings.ReplaceAll(strings.Join(filter.Values, "|"), " ", "#")
+}
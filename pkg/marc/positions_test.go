@@ -0,0 +1,85 @@
+package marc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePositionFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec string
+		want string // String() of the filter, empty if it is not valid
+	}{
+		{spec: "leader/17=#", want: "LDR/17=#"},
+		{spec: "LDR/17=blank", want: "LDR/17=#"},
+		{spec: "leader/06-07=am|tm", want: "LDR/06-07=am|tm"},
+		{spec: "008/35-37!=eng", want: "008/35-37!=eng"},
+		{spec: "008/07-10=blank", want: "008/07-10=####"},
+		{spec: "007/00=c", want: "007/00=c"},
+		{spec: "leader", want: ""},
+		{spec: "leader/17", want: ""},
+		{spec: "leader/x=a", want: ""},
+		{spec: "leader/10-05=a", want: ""},
+		{spec: "leader/17=ab", want: ""},
+		{spec: "245/00=a", want: ""},
+	}
+	for _, tt := range tests {
+		filter, err := ParsePositionFilter(tt.spec)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidFieldSpec) {
+				t.Errorf("%q: expected an invalid field spec error, got %v", tt.spec, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.spec, err)
+		} else if got := filter.String(); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.spec, tt.want, got)
+		}
+	}
+}
+
+func TestPositionFilter_Matches(t *testing.T) {
+	t.Parallel()
+
+	leader, err := NewLeader([]byte("01484cam a2200349 a 4500"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Record{Leader: leader, Fields: Fields{
+		{Tag: "001", Value: "ocm57178089"},
+		{Tag: "007", Value: "ta"},
+		{Tag: "007", Value: "cr mn-"},
+		{Tag: "008", Value: "041207s2004    mdu     s    f000 0 spa d"},
+	}}
+	tests := map[string]bool{
+		"leader/17=#":        true,
+		"leader/17=I":        false,
+		"leader/06-07=am|tm": true,
+		"leader/06-07!=am":   false,
+		"008/35-37=spa":      true,
+		"008/35-37=eng|fre":  false,
+		"008/35-37!=eng":     true,
+		"007/00=c":           true,
+		"007/01=r":           true,
+		"006/00=m":           false,
+		"006/00!=m":          true,
+		"008/40=a":           false,
+	}
+	for spec, want := range tests {
+		filter, err := ParsePositionFilter(spec)
+		if err != nil {
+			t.Fatalf("%q: %v", spec, err)
+		}
+		if got := filter.Matches(r); got != want {
+			t.Errorf("%q: expected %v, got %v", spec, want, got)
+		}
+	}
+
+	plan := FilterSpec{Search: "ocm", Positions: []PositionFilter{{Tag: "LDR", Start: 17, End: 17, Values: []string{"I"}}}}.Compile()
+	if plan.Match(r) {
+		t.Errorf("expected the plan not to match the record")
+	}
+}
@@ -0,0 +1,155 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidPrice is returned for the values that do not have an amount
+// or whose currency cannot be determined.
+var ErrInvalidPrice = errors.New("invalid price")
+
+// Price is an amount in a currency, e.g. from the 020 $c, the 365, or
+// the price in an item field.
+type Price struct {
+	Amount float64
+	// Currency is the ISO 4217 code, e.g. USD.
+	Currency string
+}
+
+func (p Price) String() string {
+	return fmt.Sprintf("%.2f %s", p.Amount, p.Currency)
+}
+
+// currencySymbols are the symbols and abbreviations used in the prices
+// with their ISO 4217 code. The symbols shared by several currencies
+// (e.g. kr) are not included.
+var currencySymbols = map[string]string{
+	"$":    "",
+	"US$":  "USD",
+	"C$":   "CAD",
+	"CA$":  "CAD",
+	"CAN$": "CAD",
+	"A$":   "AUD",
+	"AU$":  "AUD",
+	"NZ$":  "NZD",
+	"HK$":  "HKD",
+	"£":    "GBP",
+	"€":    "EUR",
+	"¥":    "JPY",
+	"₹":    "INR",
+	"RS":   "INR",
+	"RS.":  "INR",
+	"DM":   "DEM",
+	"FR.":  "CHF",
+	"SFR.": "CHF",
+	"R$":   "BRL",
+	"₩":    "KRW",
+	"₽":    "RUB",
+	"ZŁ":   "PLN",
+}
+
+// ParsePrice returns the amount and the currency of a price like
+// "$25.00", "£12.99 (pbk.)", "EUR 12,50", or "1.234,56 €". The currency
+// is the ISO code given before or after the amount, or the one of the
+// symbol, and defaultCurrency for the prices without currency and for
+// the dollar sign. Commas and periods are taken as the decimal separator
+// when followed by one or two digits at the end, e.g. 12,50 and 1,234.56
+// but not 1,234. The qualifiers in parentheses are ignored.
+func ParsePrice(value string, defaultCurrency string) (Price, error) {
+	invalid := func() (Price, error) {
+		return Price{}, fmt.Errorf("%w %q", ErrInvalidPrice, value)
+	}
+	s := value
+	if i := strings.Index(s, "("); i != -1 {
+		s = s[:i]
+	}
+	s = strings.TrimRight(strings.TrimSpace(s), " .,;:")
+
+	// Split the currency (before or after) from the amount.
+	start := strings.IndexFunc(s, unicode.IsDigit)
+	if start == -1 {
+		return invalid()
+	}
+	end := strings.LastIndexFunc(s, unicode.IsDigit) + 1
+	if start > 0 && s[start-1] == '.' {
+		// An amount like .99 in "$.99", but not the period of "Rs. 5".
+		if before, _ := utf8.DecodeLastRuneInString(s[:start-1]); !unicode.IsLetter(before) {
+			start--
+		}
+	}
+	currency := strings.TrimSpace(s[:start])
+	after := strings.TrimSpace(s[end:])
+	if currency != "" && after != "" {
+		return invalid()
+	}
+	if currency == "" {
+		currency = after
+	}
+
+	amount, ok := parseAmount(s[start:end])
+	if !ok {
+		return invalid()
+	}
+	code, ok := currencyCode(currency, defaultCurrency)
+	if !ok {
+		return invalid()
+	}
+	return Price{Amount: amount, Currency: code}, nil
+}
+
+// currencyCode returns the ISO code of the currency given as a symbol or
+// as a code.
+func currencyCode(currency string, defaultCurrency string) (string, bool) {
+	upper := strings.ToUpper(currency)
+	if upper == "" {
+		return defaultCurrency, defaultCurrency != ""
+	}
+	if code, ok := currencySymbols[upper]; ok {
+		if code == "" {
+			return defaultCurrency, defaultCurrency != ""
+		}
+		return code, true
+	}
+	// A code followed by a symbol, e.g. "USD $" or "AUD$".
+	upper = strings.TrimSpace(strings.TrimSuffix(upper, "$"))
+	if len(upper) != 3 {
+		return "", false
+	}
+	for _, r := range upper {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return upper, true
+}
+
+// parseAmount parses an amount with a comma or a period as the
+// thousands or the decimal separator.
+func parseAmount(s string) (float64, bool) {
+	decimal := -1
+	if i := strings.LastIndexAny(s, ".,"); i != -1 && len(s)-i-1 <= 2 {
+		decimal = i
+	}
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case i == decimal:
+			sb.WriteByte('.')
+		case r == '.' || r == ',':
+			// Thousands separator.
+		case r == ' ':
+			// Also used as thousands separator, e.g. 1 234,50.
+		default:
+			return 0, false
+		}
+	}
+	amount, err := strconv.ParseFloat(sb.String(), 64)
+	return amount, err == nil
+}
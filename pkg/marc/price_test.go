@@ -0,0 +1,43 @@
+package marc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePrice(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]Price{
+		"$25.00":           {Amount: 25, Currency: "USD"},
+		"25.00":            {Amount: 25, Currency: "USD"},
+		"$.99":             {Amount: 0.99, Currency: "USD"},
+		"$1,234.56 (pbk.)": {Amount: 1234.56, Currency: "USD"},
+		"£12.99 (hbk.)":    {Amount: 12.99, Currency: "GBP"},
+		"EUR 12,50":        {Amount: 12.5, Currency: "EUR"},
+		"1.234,56 €":       {Amount: 1234.56, Currency: "EUR"},
+		"12.50 eur":        {Amount: 12.5, Currency: "EUR"},
+		"Rs. 500":          {Amount: 500, Currency: "INR"},
+		"¥1,500":           {Amount: 1500, Currency: "JPY"},
+		"C$19.95":          {Amount: 19.95, Currency: "CAD"},
+		"AUD$29.95":        {Amount: 29.95, Currency: "AUD"},
+		"1 234,5 PLN":      {Amount: 1234.5, Currency: "PLN"},
+		"$10.00.":          {Amount: 10, Currency: "USD"},
+	}
+	for value, want := range tests {
+		got, err := ParsePrice(value, "USD")
+		if err != nil || got != want {
+			t.Errorf("ParsePrice(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"", "Free", "price not reported", "kr 120", "USD 10 EUR", "12-50"} {
+		if got, err := ParsePrice(value, "USD"); !errors.Is(err, ErrInvalidPrice) {
+			t.Errorf("ParsePrice(%q) = %v, %v, want ErrInvalidPrice", value, got, err)
+		}
+	}
+
+	if _, err := ParsePrice("$25.00", ""); !errors.Is(err, ErrInvalidPrice) {
+		t.Errorf("expected an error for a dollar sign without a default currency")
+	}
+}
@@ -0,0 +1,14 @@
+package marc
+
+// RecordReader is implemented by the readers for each of the input
+// formats (MARC binary, MARC XML, MRK, and JSON).
+//
+// Next returns the next record or io.EOF when there are no more records.
+// If a record cannot be parsed Next returns the (partial) record along
+// with the error and the caller can continue with the next record.
+// Errors reading the underlying data, on the other hand, are permanent
+// and are also reported by Err.
+type RecordReader interface {
+	Next() (Record, error)
+	Err() error
+}
@@ -0,0 +1,453 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReaders(t *testing.T) {
+	t.Parallel()
+
+	want := readTestRecords("testdata/test_10.mrc", t)
+
+	tests := []struct {
+		name      string
+		newWriter func(w io.Writer) Writer
+		newReader func(r io.Reader) RecordReader
+	}{
+		{
+			name:      "MRK",
+			newWriter: func(w io.Writer) Writer { return NewMrkWriter(w) },
+			newReader: func(r io.Reader) RecordReader { return NewMrkReader(r) },
+		},
+		{
+			name:      "JSON",
+			newWriter: func(w io.Writer) Writer { return NewJSONWriter(w) },
+			newReader: func(r io.Reader) RecordReader { return NewJSONReader(r) },
+		},
+		{
+			name:      "XML",
+			newWriter: func(w io.Writer) Writer { return NewXMLWriter(w) },
+			newReader: func(r io.Reader) RecordReader { return NewXMLReader(r) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := tt.newWriter(&buf)
+			for _, r := range want {
+				if err := w.WriteRecord(r); err != nil {
+					t.Fatalf("error writing record: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error closing writer: %v", err)
+			}
+
+			reader := tt.newReader(&buf)
+			for i := 0; ; i++ {
+				got, err := reader.Next()
+				if err == io.EOF {
+					if i != len(want) {
+						t.Errorf("expected %d records, got %d", len(want), i)
+					}
+					break
+				}
+				if err != nil {
+					t.Fatalf("error reading record %d: %v", i, err)
+				}
+				compareReadFields(want[i].Fields, got.Fields, t)
+			}
+			if err := reader.Err(); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestMrkReader(t *testing.T) {
+	t.Parallel()
+
+	mrk := "=LDR  01805nam a2200385 i 4500\r\n=001  ocm57175940\r\n=650  \\0$aCoal$xAnalysis.\r\n=945  \\\\$aPrice {dollar}1.00\r\n\r\n\r\n=LDR  01805nam a2200385 i 4500\r\n=001  ocm2\r\n"
+	reader := NewMrkReader(strings.NewReader(mrk))
+
+	r, err := reader.Next()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := Fields{
+		{Tag: "001", Value: "ocm57175940"},
+		{Tag: "650", Indicator1: " ", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "Coal"}, {Code: "x", Value: "Analysis."}}},
+		{Tag: "945", Indicator1: " ", Indicator2: " ", SubFields: []SubField{{Code: "a", Value: "Price $1.00"}}},
+	}
+	if !cmp.Equal(want, r.Fields) {
+		t.Error(cmp.Diff(want, r.Fields))
+	}
+	if r.Leader.Raw() != "01805nam a2200385 i 4500" {
+		t.Errorf("unexpected leader %q", r.Leader.Raw())
+	}
+
+	r, err = reader.Next()
+	if err != nil || r.ControlNum() != "ocm2" {
+		t.Errorf("expected second record, got %v (%v)", r.ControlNum(), err)
+	}
+	if want := int64(len("=LDR  01805nam a2200385 i 4500\r\n=001  ocm57175940\r\n=650  \\0$aCoal$xAnalysis.\r\n=945  \\\\$aPrice {dollar}1.00\r\n\r\n\r\n")); r.Pos != want {
+		t.Errorf("expected position %d, got %d", want, r.Pos)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestBytesReader(t *testing.T) {
+	t.Parallel()
+
+	for _, path := range []string{"testdata/test_10.mrc", "testdata/test_bad.xml"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error reading file: %v", err)
+		}
+
+		want := NewBinaryReader(bytes.NewReader(data))
+		got := NewBytesReader(data)
+		for {
+			wantRec, wantErr := want.Next()
+			gotRec, gotErr := got.Next()
+			if (wantErr == nil) != (gotErr == nil) {
+				t.Fatalf("%s: expected error %v, got %v", path, wantErr, gotErr)
+			}
+			if wantErr == io.EOF {
+				break
+			}
+			if !cmp.Equal(wantRec, gotRec) || wantRec.Pos != gotRec.Pos || !bytes.Equal(wantRec.Data, gotRec.Data) {
+				t.Errorf("%s: record at %d differs from the one read by BinaryReader", path, wantRec.Pos)
+			}
+		}
+	}
+}
+
+func TestParallelReader(t *testing.T) {
+	t.Parallel()
+
+	for _, path := range []string{"testdata/test_10.mrc", "testdata/test_bad.xml"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error reading file: %v", err)
+		}
+		data = bytes.Repeat(data, 20)
+
+		want := NewBinaryReader(bytes.NewReader(data))
+		got := NewParallelReader(bytes.NewReader(data), 4)
+		for {
+			wantRec, wantErr := want.Next()
+			gotRec, gotErr := got.Next()
+			if (wantErr == nil) != (gotErr == nil) {
+				t.Fatalf("%s: expected error %v, got %v", path, wantErr, gotErr)
+			}
+			if wantErr == io.EOF {
+				break
+			}
+			if !cmp.Equal(wantRec, gotRec) || wantRec.Pos != gotRec.Pos {
+				t.Errorf("%s: record at %d differs from the one read by BinaryReader", path, wantRec.Pos)
+			}
+		}
+	}
+}
+
+func TestParallelReader_Close(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/test_10.mrc")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	file := NewMarcFileParallel(bytes.NewReader(bytes.Repeat(data, 100)), 4)
+	if _, ok := file.reader.(*ParallelReader); !ok {
+		t.Fatalf("expected a parallel reader, got %T", file.reader)
+	}
+	if _, err := file.Next(); err != nil {
+		t.Fatalf("error reading record: %v", err)
+	}
+	// Stopping early must not leave goroutines blocked.
+	if err := file.Close(); err != nil {
+		t.Errorf("error closing file: %v", err)
+	}
+}
+
+func TestOpenMmap(t *testing.T) {
+	t.Parallel()
+
+	m, err := OpenMmap("testdata/test_10.mrc")
+	if err != nil {
+		t.Fatalf("error mapping file: %v", err)
+	}
+	defer m.Close()
+
+	want := readTestRecords("testdata/test_10.mrc", t)
+	file := NewMarcFileBytes(m.Bytes())
+	for i := 0; ; i++ {
+		r, err := file.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("expected %d records, got %d", len(want), i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("error reading record: %v", err)
+		}
+		if !cmp.Equal(want[i], r) {
+			t.Errorf("record %d: %s", i, cmp.Diff(want[i], r))
+		}
+	}
+}
+
+func TestNewMarcFileBytes_XML(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/test_10.xml")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	file := NewMarcFileBytes(data)
+	if _, ok := file.reader.(*XMLReader); !ok {
+		t.Errorf("expected an XML reader, got %T", file.reader)
+	}
+}
+
+func TestParseMrkField(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		line    string
+		want    Field
+		wantErr error
+	}{
+		{line: "=001  ocm1", want: Field{Tag: "001", Value: "ocm1"}},
+		{line: "=590  \\\\$aNote", want: Field{Tag: "590", Indicator1: " ", Indicator2: " ", SubFields: []SubField{{Code: "a", Value: "Note"}}}},
+		{line: "590  $aNote", wantErr: ErrInvalidMrkLine},
+		{line: "=590  1", wantErr: ErrInvalidIndicators},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMrkField(tt.line)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%q: expected error %v, got %v", tt.line, tt.wantErr, err)
+		}
+		if !cmp.Equal(tt.want, got) {
+			t.Errorf("%q: %s", tt.line, cmp.Diff(tt.want, got))
+		}
+	}
+}
+
+// compareReadFields compares the fields read from another format with the
+// original ones. Fields with a "$" in their values are skipped since they
+// don't survive a round trip through MRK.
+func compareReadFields(want, got Fields, t *testing.T) {
+	t.Helper()
+
+	if len(want) != len(got) {
+		t.Fatalf("expected %d fields, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if hasDollarSign(want[i]) {
+			continue
+		}
+		if !cmp.Equal(want[i], got[i]) {
+			t.Errorf("field %d: %s", i, cmp.Diff(want[i], got[i]))
+		}
+	}
+}
+
+func hasDollarSign(f Field) bool {
+	for _, sub := range f.SubFields {
+		if strings.Contains(sub.Value, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMaxRecordSize(t *testing.T) {
+	t.Parallel()
+
+	var mrk, json bytes.Buffer
+	writeTestRecords(NewMrkWriter(&mrk), "testdata/test_10.mrc", t)
+	writeTestRecords(NewJSONWriter(&json), "testdata/test_10.mrc", t)
+	binary, err := os.ReadFile("testdata/test_10.mrc")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	xml, err := os.ReadFile("testdata/test_10.xml")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+
+	tests := map[string]func() MarcFile{
+		"binary":   func() MarcFile { return NewMarcFile(bytes.NewReader(binary)) },
+		"parallel": func() MarcFile { return NewMarcFileParallel(bytes.NewReader(binary), 2) },
+		"xml":      func() MarcFile { return NewMarcFile(bytes.NewReader(xml)) },
+		"mrk":      func() MarcFile { return NewMarcFile(bytes.NewReader(mrk.Bytes())) },
+		"json":     func() MarcFile { return NewMarcFile(bytes.NewReader(json.Bytes())) },
+	}
+	for name, newFile := range tests {
+		file := newFile()
+		file.SetMaxRecordSize(1000)
+		if _, err := file.Next(); !errors.Is(err, ErrRecordTooLarge) {
+			t.Errorf("%s: expected ErrRecordTooLarge, got %v", name, err)
+		}
+		file.Close()
+
+		file = newFile()
+		file.SetMaxRecordSize(20000)
+		count := 0
+		for {
+			_, err := file.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: error reading record: %v", name, err)
+			}
+			count++
+		}
+		if count != 10 {
+			t.Errorf("%s: expected 10 records, got %d", name, count)
+		}
+	}
+
+	// A huge "record" with the default limit.
+	huge := append(bytes.Repeat([]byte("0"), 2*DefaultMaxRecordSize), rt)
+	file := NewMarcFile(bytes.NewReader(append(huge, binary...)))
+	if _, err := file.Next(); !errors.Is(err, ErrRecordTooLarge) {
+		t.Errorf("expected ErrRecordTooLarge, got %v", err)
+	}
+
+	// The records already in memory can be skipped.
+	file = NewMarcFileBytes(append(huge, binary...))
+	if _, err := file.Next(); !errors.Is(err, ErrRecordTooLarge) {
+		t.Errorf("expected ErrRecordTooLarge, got %v", err)
+	}
+	r, err := file.Next()
+	if err != nil || r.Pos != int64(len(huge)) {
+		t.Errorf("expected the record after the large one, got %d %v", r.Pos, err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/test_10.mrc")
+	if err != nil {
+		t.Fatalf("error reading test data: %v", err)
+	}
+	// Cut the third record in the middle.
+	first := bytes.IndexByte(data, rt) + 1
+	second := first + bytes.IndexByte(data[first:], rt) + 1
+	truncated := data[:second+100]
+
+	tests := []struct {
+		name string
+		file MarcFile
+	}{
+		{name: "binary", file: NewMarcFile(bytes.NewReader(truncated))},
+		{name: "mmap", file: NewMarcFileBytes(truncated)},
+		{name: "parallel", file: NewMarcFileParallel(bytes.NewReader(truncated), 2)},
+	}
+	for _, tt := range tests {
+		var records int
+		for {
+			rec, err := tt.file.Next()
+			if err == io.EOF {
+				t.Errorf("%s: expected an error for the truncated record", tt.name)
+				break
+			}
+			if err != nil {
+				if !errors.Is(err, ErrTruncated) {
+					t.Errorf("%s: expected error %v, got %v", tt.name, ErrTruncated, err)
+				}
+				if want := fmt.Sprintf("at byte %d after record 2", len(truncated)); !strings.Contains(err.Error(), want) {
+					t.Errorf("%s: expected %q in the error, got %q", tt.name, want, err)
+				}
+				if rec.Pos != int64(second) {
+					t.Errorf("%s: expected the record at %d, got %d", tt.name, second, rec.Pos)
+				}
+				break
+			}
+			records++
+		}
+		if records != 2 {
+			t.Errorf("%s: expected 2 records before the error, got %d", tt.name, records)
+		}
+		tt.file.Close()
+	}
+
+	// A complete record without the record terminator is also truncated.
+	file := NewMarcFileBytes(data[:first-1])
+	if _, err := file.Next(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected error %v, got %v", ErrTruncated, err)
+	}
+}
+
+func TestPaddingBetweenRecords(t *testing.T) {
+	t.Parallel()
+
+	want := readTestRecords("testdata/test_10.mrc", t)
+	// Whitespace and blank records (padding followed by a record
+	// terminator) between the records.
+	padding := []string{"\r\n", "     \x1d", "\x00\x00\x00", "\x1d\n"}
+	var data []byte
+	var positions []int64
+	for i, r := range want {
+		data = append(data, padding[i%len(padding)]...)
+		positions = append(positions, int64(len(data)))
+		data = append(data, r.Raw()...)
+	}
+	data = append(data, "\n\n"...)
+
+	tests := []struct {
+		name string
+		file MarcFile
+	}{
+		{name: "binary", file: NewMarcFile(bytes.NewReader(data))},
+		{name: "mmap", file: NewMarcFileBytes(data)},
+		{name: "parallel", file: NewMarcFileParallel(bytes.NewReader(data), 2)},
+	}
+	for _, tt := range tests {
+		var i int
+		for ; ; i++ {
+			got, err := tt.file.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: error reading record %d: %v", tt.name, i, err)
+			}
+			if got.Pos != positions[i] {
+				t.Errorf("%s: expected record %d at %d, got %d", tt.name, i, positions[i], got.Pos)
+			}
+			compareReadFields(want[i].Fields, got.Fields, t)
+		}
+		if i != len(want) {
+			t.Errorf("%s: expected %d records, got %d", tt.name, len(want), i)
+		}
+	}
+
+	// Skip also ignores the padding.
+	file := NewMarcFileBytes(data)
+	if n, err := file.Skip(len(want)); n != len(want) || err != nil {
+		t.Errorf("expected %d records skipped, got %d (%v)", len(want), n, err)
+	}
+	if _, err := file.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after skipping all the records, got %v", err)
+	}
+}
@@ -1,49 +1,48 @@
 package marc
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// ErrNoOriginalData is returned by Record.Original for the records that
+// were not read from MARC binary.
+var ErrNoOriginalData = errors.New("the original MARC binary data of the record is not available")
+
 // Record is a struct representing a MARC record. It has a Fields slice
 // which contains both ControlFields and DataFields.
 type Record struct {
-	Data   []byte
-	Fields []Field
-	Leader Leader
+	Data     []byte
+	Fields   Fields
+	Leader   Leader
+	Pos      int64     // byte offset of the record in the file
+	Warnings []Warning // problems fixed when parsing, see ParseLenient
+	// original are the bytes read from MARC binary once Data was dropped
+	// because the record was changed, see dropData.
+	original []byte
 }
 
 // Contains returns true if Record contains the value passed.
 // If searchFieldList is an empty array it searches in all fields for the record
-// otherwise the search is limited to only the fields in the array.
+// otherwise the search is limited to only the fields in the array. It is
+// the same search as FilterPlan.Match, which should be used instead to
+// search many records.
 func (r Record) Contains(searchValue string, searchFieldsList []string) bool {
-	if searchValue == "" {
-		return true
-	}
-
-	var searchFields []Field
-	if len(searchFieldsList) == 0 {
-		searchFields = r.Fields
-	} else {
-		for _, field := range r.Fields {
-			if r.arrayContains(searchFieldsList, field.Tag) {
-				searchFields = append(searchFields, field)
-			}
-		}
-	}
+	return FilterSpec{Search: searchValue, SearchFields: searchFieldsList}.Compile().contains(r)
+}
 
-	for _, field := range searchFields {
-		if field.Contains(searchValue) {
-			return true
-		}
-	}
-	return false
+// MatchedFields returns the fields that contain the value passed (see
+// Contains) together with the control number (001), to identify the
+// record, in the order they are in the record. It returns all the
+// fields if the value is empty.
+func (r Record) MatchedFields(searchValue string, searchFieldsList []string) []Field {
+	return FilterSpec{Search: searchValue, SearchFields: searchFieldsList, MatchedOnly: true}.Compile().Filter(r)
 }
 
 // HasFields returns true if the Record contains the fields indicated
 func (r Record) HasFields(filters FieldFilters) bool {
-	exclude := FieldFilters{}
-	return len(r.Filter(filters, exclude)) > 0
+	return FilterSpec{HasFields: filters}.Compile().has(r)
 }
 
 // ControlNum returns the control number (tag 001) for the record.
@@ -56,9 +55,77 @@ func (r Record) ControlNum() string {
 	return ""
 }
 
+// Clone returns a deep copy of the record. Changes to the fields (or
+// subfields) of the copy do not affect the original record.
+func (r Record) Clone() Record {
+	clone := r
+	clone.Data = append([]byte(nil), r.Data...)
+	clone.original = append([]byte(nil), r.original...)
+	clone.Leader.raw = append([]byte(nil), r.Leader.raw...)
+	if r.Fields != nil {
+		clone.Fields = make(Fields, len(r.Fields))
+		for i, field := range r.Fields {
+			clone.Fields[i] = field.Clone()
+		}
+	}
+	clone.Warnings = append([]Warning(nil), r.Warnings...)
+	return clone
+}
+
+// Equal returns true if both records have the same leader and the same
+// fields in the same order (see Field.Equal). The raw data, the
+// position in the file, and the warnings are not compared, therefore a
+// record read from MARC binary and the same record read from MARC XML
+// are equal.
+func (r Record) Equal(other Record) bool {
+	if r.Leader.Raw() != other.Leader.Raw() || len(r.Fields) != len(other.Fields) {
+		return false
+	}
+	for i, field := range r.Fields {
+		if !field.Equal(other.Fields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (r Record) Raw() []byte {
 	// Include the record terminator.
-	return append(r.Data, rt)
+	return append(r.originalData(), rt)
+}
+
+// originalData returns the bytes read from MARC binary, whether they
+// still represent the record or not.
+func (r Record) originalData() []byte {
+	if r.Data == nil {
+		return r.original
+	}
+	return r.Data
+}
+
+// dropData is called when the fields or the leader of a record read
+// from MARC binary are changed (e.g. the fixes of ParseLenient), the
+// original bytes no longer represent the record and EncodeMRC must
+// encode it again. They are kept for Raw and Original.
+func (r *Record) dropData() {
+	if r.Data != nil {
+		r.original = r.Data
+		r.Data = nil
+	}
+}
+
+// Original returns the bytes of the record exactly as they were read
+// from the MARC binary file, including the record terminator. Unlike
+// EncodeMRC the record is never serialized again, the bytes are the same
+// even when the leader was repaired when parsing (see ParseRepair) or
+// the fields were changed. It returns ErrNoOriginalData for the records
+// read from other formats or built from scratch.
+func (r Record) Original() ([]byte, error) {
+	data := r.originalData()
+	if len(data) <= leaderLength || data[len(data)-1] != ft {
+		return nil, ErrNoOriginalData
+	}
+	return r.Raw(), nil
 }
 
 func (r Record) String() string {
@@ -72,87 +139,26 @@ func (r Record) DebugString() string {
 		str += fmt.Sprintf("%s\r\n", field)
 	}
 	str += "BINARY:\n"
-	str += string(r.Data)
+	str += string(r.originalData())
 	return str
 }
 
 // Filter returns the fields in the record that match
 // the given filter.
 func (r Record) Filter(include FieldFilters, exclude FieldFilters) []Field {
-	if len(include.Fields) == 0 && len(exclude.Fields) == 0 {
-		// Nothing to filter
-		return r.Fields
-	}
-
-	if len(include.Fields) > 0 {
-		return r.filterInclude(include)
-	}
-
-	return r.filterExclude(exclude)
-}
-
-func (r Record) filterInclude(filters FieldFilters) []Field {
-	list := []Field{}
-	for _, filter := range filters.Fields {
-		// Get all the fields in the record that match the tag
-		// (there could be more than one)
-		for _, field := range r.FieldsByTag(filter.Tag) {
-			if len(filter.Subfields) == 0 {
-				// add the value as-is, no need to filter by subfield
-				list = append(list, field)
-			} else {
-				// extract the indicated subfields from the field
-				// before adding it to the list
-				filteredField := Field{
-					Tag:        field.Tag,
-					Value:      field.Value,
-					Indicator1: field.Indicator1,
-					Indicator2: field.Indicator2,
-					SubFields:  field.GetSubFields(filter.Subfields),
-				}
-				if len(filteredField.SubFields) > 0 {
-					list = append(list, filteredField)
-				}
-			}
-		}
-	}
-	return list
-}
-
-func (r Record) filterExclude(filters FieldFilters) []Field {
-	list := []Field{}
-	for _, field := range r.Fields {
-		include := true
-		for _, filter := range filters.Fields {
-			if len(filter.Subfields) > 0 {
-				panic("Exclude filter by subfields not supported yet")
-			}
-			if filter.Tag == field.Tag {
-				include = false
-				break
-			}
-		}
-		if include {
-			list = append(list, field)
-		}
-	}
-	return list
+	return FilterSpec{Include: include, Exclude: exclude}.Compile().Filter(r)
 }
 
 // FieldsByTag returns an array with the fields in the record for the given tag
+//
+// Deprecated: use r.Fields.GetAll(tag) instead.
 func (r Record) FieldsByTag(tag string) []Field {
-	var fields []Field
-	for _, field := range r.Fields {
-		if field.Tag == tag {
-			fields = append(fields, field)
-		}
-	}
-	return fields
+	return r.Fields.GetAll(tag)
 }
 
 // GetValue returns the first value for a field tag/subfield combination.
 func (r Record) GetValue(tag string, subfield string) string {
-	for _, field := range r.FieldsByTag(tag) {
+	for _, field := range r.Fields.GetAll(tag) {
 		if field.IsControlField() {
 			return field.Value
 		}
@@ -174,7 +180,7 @@ func (r Record) GetValue(tag string, subfield string) string {
 // GetValues returns the values that match the field tag/subfield combination.
 func (r Record) GetValues(tag string, subfield string) []string {
 	values := []string{}
-	for _, field := range r.FieldsByTag(tag) {
+	for _, field := range r.Fields.GetAll(tag) {
 		if strings.TrimSpace(subfield) == "" {
 			// No subfield indicated, return the string version of the field
 			values = append(values, field.String())
@@ -189,12 +195,3 @@ func (r Record) GetValues(tag string, subfield string) []string {
 	}
 	return values
 }
-
-func (r Record) arrayContains(array []string, value string) bool {
-	for _, element := range array {
-		if element == value {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,49 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxRecordSize is the size (in bytes of the input) of the
+// largest record that the readers accept, see MarcFile.SetMaxRecordSize.
+// A MARC binary record cannot be longer than 99999 bytes but the same
+// record takes several times more space in MARC XML, MRK, or JSON.
+const DefaultMaxRecordSize = 1024 * 1024
+
+var ErrRecordTooLarge = errors.New("record too large")
+
+func recordTooLargeError(pos int64, max int) error {
+	return fmt.Errorf("%w: the record at byte %d is larger than %d bytes", ErrRecordTooLarge, pos, max)
+}
+
+// sizeLimiter limits the data read by the readers that decode records
+// with encoding/xml and encoding/json, since those packages read as much
+// data as an element needs. Reading fails with ErrRecordTooLarge once
+// more than max bytes have been read since the last call to reset. The
+// decoders read ahead in blocks so the limit is not exact but the memory
+// used is still bounded.
+type sizeLimiter struct {
+	r    io.Reader
+	max  int
+	read int
+}
+
+func newSizeLimiter(r io.Reader) *sizeLimiter {
+	return &sizeLimiter{r: r, max: DefaultMaxRecordSize}
+}
+
+func (l *sizeLimiter) Read(p []byte) (int, error) {
+	if l.read > l.max {
+		return 0, ErrRecordTooLarge
+	}
+	n, err := l.r.Read(p)
+	l.read += n
+	return n, err
+}
+
+// reset is called at the beginning of each record.
+func (l *sizeLimiter) reset() {
+	l.read = 0
+}
@@ -2,6 +2,7 @@ package marc
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"testing"
 
@@ -117,6 +118,12 @@ func TestFilter(t *testing.T) {
 			}},
 			result: record.FieldsByTag("650"),
 		},
+		{
+			name:    "empty include, exclude subfields",
+			include: FieldFilters{},
+			exclude: FieldFilters{Fields: []FieldFilter{{Tag: "650", Subfields: "x"}}},
+			result:  excludeFromFields(record.Fields, "650", "x", t),
+		},
 	}
 
 	for _, tt := range filterTests {
@@ -144,6 +151,33 @@ func TestRecordRaw(t *testing.T) {
 	}
 }
 
+func TestRecordOriginal(t *testing.T) {
+	t.Parallel()
+
+	want, err := os.ReadFile("testdata/test_1a.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := setUpTestRecord("testdata/test_1a.mrc", t)
+	// Changes to the record are not in the original bytes.
+	record.Fields = record.Fields[1:]
+	got, err := record.Original()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	xml := setUpTestRecord("testdata/test_10.xml", t)
+	if _, err := xml.Original(); !errors.Is(err, ErrNoOriginalData) {
+		t.Errorf("expected ErrNoOriginalData for a MARC XML record, got %v", err)
+	}
+	if _, err := (Record{}).Original(); !errors.Is(err, ErrNoOriginalData) {
+		t.Errorf("expected ErrNoOriginalData for an empty record, got %v", err)
+	}
+}
+
 func TestRecordString(t *testing.T) {
 	t.Parallel()
 
@@ -259,6 +293,68 @@ func TestGetValues(t *testing.T) {
 	}
 }
 
+func TestRecordClone(t *testing.T) {
+	t.Parallel()
+
+	record := setUpTestRecord("testdata/test_1a.mrc", t)
+	clone := record.Clone()
+
+	if !clone.Equal(record) {
+		t.Fatalf("expected clone to be equal to the original")
+	}
+
+	clone.Fields[0].Value = "changed"
+	clone.Fields[5].SubFields[0].Value = "changed"
+	if err := clone.Leader.Set(5, 'd'); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if record.Fields[0].Value != "ocm57175940" || record.Fields[5].SubFields[0].Value != "GPO" {
+		t.Errorf("changes to the clone modified the original record")
+	}
+	if record.Leader.Status != 'n' {
+		t.Errorf("changes to the clone modified the original leader")
+	}
+	if clone.Equal(record) {
+		t.Errorf("expected modified clone not to be equal to the original")
+	}
+}
+
+func TestRecordEqual(t *testing.T) {
+	t.Parallel()
+
+	binary := setUpTestRecord("testdata/test_1a.mrc", t)
+	xml := readTestRecords("testdata/test_10.xml", t)[0]
+
+	if !binary.Equal(xml) {
+		t.Errorf("expected the same record in binary and XML to be equal")
+	}
+
+	other := readTestRecords("testdata/test_10.mrc", t)[1]
+	if binary.Equal(other) {
+		t.Errorf("expected different records not to be equal")
+	}
+
+	a := Field{Tag: "650", Indicator1: "", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "Coal"}}}
+	b := Field{Tag: "650", Indicator1: " ", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "Coal"}}}
+	if !a.Equal(b) {
+		t.Errorf("expected empty and blank indicators to be equal")
+	}
+	b.SubFields = append(b.SubFields, SubField{Code: "x", Value: "Analysis."})
+	if a.Equal(b) {
+		t.Errorf("expected fields with different subfields not to be equal")
+	}
+
+	// The order of the subfields matters.
+	reordered := Field{Tag: "650", Indicator1: " ", Indicator2: "0", SubFields: []SubField{{Code: "x", Value: "Analysis."}, {Code: "a", Value: "Coal"}}}
+	if b.Equal(reordered) || reordered.Equal(b) {
+		t.Errorf("expected fields with the subfields in a different order not to be equal")
+	}
+	if cmp.Equal(b, reordered) {
+		t.Errorf("expected cmp.Equal to use Field.Equal and take the order into account")
+	}
+}
+
 func setUpTestRecord(path string, t *testing.T) Record {
 	t.Helper()
 
@@ -298,3 +394,22 @@ func filterOnSubFields(fields []Field, subfield string, t *testing.T) []Field {
 
 	return outFields
 }
+
+func excludeFromFields(fields []Field, tag string, subfields string, t *testing.T) []Field {
+	t.Helper()
+
+	var list []Field
+	for _, field := range fields {
+		if field.Tag == tag {
+			var subs []SubField
+			for _, sub := range field.SubFields {
+				if !bytes.Contains([]byte(subfields), []byte(sub.Code)) {
+					subs = append(subs, sub)
+				}
+			}
+			field.SubFields = subs
+		}
+		list = append(list, field)
+	}
+	return list
+}
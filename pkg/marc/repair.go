@@ -0,0 +1,114 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotRepairable is wrapped by the errors of Repair for the data that
+// is not a MARC binary record at all.
+var ErrNotRepairable = errors.New("the record cannot be repaired")
+
+// Repaired is the result of repairing a MARC binary record, see Repair.
+type Repaired struct {
+	Record  Record
+	Bytes   []byte   // the record in MARC binary, with the record terminator
+	Changes []string // what was changed, empty if the record had no problems
+}
+
+// Repair fixes the structural problems of the bytes of a MARC binary
+// record (with or without the record terminator): it adds the missing
+// record and field terminators, parses the record with ParseLenient
+// (plus the options in mode, e.g. ParseDropEmptyFields) to fix the
+// leader, the directory, and the length of the fixed fields, and then
+// serializes it again to recompute the record length, the base address
+// of data, and the directory.
+//
+// The changes list each of the fixes. When there is nothing to fix,
+// Bytes are the original bytes of the record.
+func Repair(data []byte, mode ParseMode) (Repaired, error) {
+	repaired := Repaired{}
+	if len(data) > 0 && data[len(data)-1] == rt {
+		data = data[:len(data)-1]
+	} else {
+		repaired.Changes = append(repaired.Changes, "added the missing record terminator")
+	}
+	if len(data) <= leaderLength {
+		return repaired, fmt.Errorf("%w: it has only %d bytes", ErrNotRepairable, len(data))
+	}
+	if _, ok := parseDigits(string(data[:5])); !ok && bytes.IndexByte(data[leaderLength:], ft) == -1 {
+		return repaired, fmt.Errorf("%w: it does not look like MARC binary", ErrNotRepairable)
+	}
+	fieldTerminator := false
+	if data[len(data)-1] != ft {
+		data = append(data[:len(data):len(data)], ft)
+		fieldTerminator = true
+		repaired.Changes = append(repaired.Changes, "added the missing field terminator at the end of the data")
+	}
+
+	rec := &repaired.Record
+	mode = mode&^ParseStrict | ParseLenient
+	if err := makeRecordFromBinary(rec, data, nil, mode); err != nil {
+		return repaired, err
+	}
+	fieldWarnings := false
+	for _, warning := range rec.Warnings {
+		repaired.Changes = append(repaired.Changes, warning.String())
+		fieldWarnings = fieldWarnings || warning.Tag != ""
+	}
+
+	out, err := rec.Marshal()
+	if err != nil {
+		return repaired, err
+	}
+	leader := rec.Leader.Raw()
+	if leader[0:5] != string(out[0:5]) {
+		repaired.Changes = append(repaired.Changes, fmt.Sprintf("record length (leader/00-04) %s corrected to %s", leader[0:5], out[0:5]))
+	}
+	if leader[offsetStart:offsetEnd] != string(out[offsetStart:offsetEnd]) {
+		repaired.Changes = append(repaired.Changes, fmt.Sprintf("base address of data (leader/12-16) %s corrected to %s", leader[offsetStart:offsetEnd], out[offsetStart:offsetEnd]))
+	}
+
+	// The original directory ends with the first field terminator after
+	// the leader, it is there even if the base address is wrong.
+	end := leaderLength + bytes.IndexByte(data[leaderLength:], ft)
+	newEnd := leaderLength + bytes.IndexByte(out[leaderLength:], ft)
+	if change := directoryChanges(data[leaderLength:end], out[leaderLength:newEnd]); change != "" {
+		repaired.Changes = append(repaired.Changes, change)
+	}
+	if !fieldWarnings && !fieldTerminator && !bytes.Equal(data[end+1:], out[newEnd+1:len(out)-1]) {
+		repaired.Changes = append(repaired.Changes, "the data of the fields was encoded again")
+	}
+
+	if len(repaired.Changes) == 0 {
+		repaired.Bytes = append(data[:len(data):len(data)], rt)
+	} else {
+		repaired.Bytes = out
+	}
+	rec.Data = repaired.Bytes[:len(repaired.Bytes)-1]
+	return repaired, nil
+}
+
+// directoryChanges describes the differences between the original
+// directory and the new one, it returns an empty string if they are the
+// same.
+func directoryChanges(before, after []byte) string {
+	if bytes.Equal(before, after) {
+		return ""
+	}
+	n := len(before) / directoryEntry
+	if len(before)%directoryEntry != 0 || len(after) != len(before) {
+		return fmt.Sprintf("directory recomputed, it had %d entries and now has %d", n, len(after)/directoryEntry)
+	}
+	tags := []string{}
+	for i := 0; i < n; i++ {
+		a := before[i*directoryEntry : (i+1)*directoryEntry]
+		b := after[i*directoryEntry : (i+1)*directoryEntry]
+		if !bytes.Equal(a, b) {
+			tags = append(tags, string(b[:tagEnd]))
+		}
+	}
+	return fmt.Sprintf("directory recomputed, %d of %d entries corrected (%s)", len(tags), n, strings.Join(tags, ", "))
+}
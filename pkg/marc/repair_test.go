@@ -0,0 +1,77 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddControlField("008", "041206s1976    dcua    sb   f000 0 eng c")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "The title"})
+	good, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+
+	corrupt := func(pos int, value string) []byte {
+		data := append([]byte(nil), good...)
+		copy(data[pos:], value)
+		return data
+	}
+	// Two characters removed from the 008 (the second field, right after
+	// the 001 and its field terminator) and from its length in the
+	// directory, as if it had been edited by hand.
+	short008 := append([]byte(nil), good[:leaderLength+3*directoryEntry+1+12]...)
+	short008 = append(short008, good[leaderLength+3*directoryEntry+1+14:]...)
+	copy(short008[leaderLength+directoryEntry+lengthOfFieldStart:], "0039")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		changes []string
+		wantErr error
+	}{
+		{name: "good", data: good},
+		{name: "no record terminator", data: good[:len(good)-1], changes: []string{"record terminator"}},
+		{name: "no terminators", data: good[:len(good)-2], changes: []string{"record terminator", "field terminator"}},
+		{name: "record length", data: corrupt(0, "00999"), changes: []string{"record length (leader/00-04) 00999 corrected"}},
+		{name: "base address", data: corrupt(offsetStart, "00030"), changes: []string{"base address of data 30", "base address of data (leader/12-16) 00030 corrected"}},
+		{name: "directory", data: corrupt(leaderLength+2*directoryEntry+lengthOfFieldStart, "0011"), changes: []string{"rebuilt", "1 of 3 entries corrected (245)"}},
+		{name: "short 008", data: short008, changes: []string{"rebuilt", "field 008: padded with blanks to 40 characters, it had 38", "1 of 3 entries corrected (008)"}},
+		{name: "not MARC", data: []byte("<record><leader>00000nam a2200000 a 4500</leader></record>"), wantErr: ErrNotRepairable},
+	}
+
+	for _, tt := range tests {
+		got, err := Repair(tt.data, ParseDefault)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		changes := strings.Join(got.Changes, "\n")
+		for _, want := range tt.changes {
+			if !strings.Contains(changes, want) {
+				t.Errorf("%s: expected a change with %q, got %q", tt.name, want, got.Changes)
+			}
+		}
+		if len(tt.changes) == 0 && len(got.Changes) > 0 {
+			t.Errorf("%s: expected no changes, got %q", tt.name, got.Changes)
+		}
+		if tt.name == "short 008" {
+			continue
+		}
+		// All the other problems are only in the structure, the result
+		// is the good record.
+		if !bytes.Equal(got.Bytes, good) {
+			t.Errorf("%s: expected %q, got %q", tt.name, good, got.Bytes)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrSchema is wrapped by the errors for records with fields that do
+// not follow the schema, see SchemaError.
+var ErrSchema = errors.New("record does not follow the schema")
+
+// SchemaError describes the fields of a record that do not follow a
+// schema, e.g. "245: subfield $a is not repeatable".
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSchema, strings.Join(e.Problems, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchema
+}
+
+// FieldSpec describes a field in a Schema: whether it can be repeated
+// in a record and its subfields (the code and whether the subfield can
+// be repeated in the field). Control fields have no subfields.
+type FieldSpec struct {
+	Repeatable bool
+	Subfields  map[string]bool
+}
+
+// Schema describes the fields of a MARC format, keyed by tag. Fields
+// with tags that are not in the schema (e.g. local 9XX fields) are not
+// validated.
+//
+// Schemas are written one field per line with the tag, R or NR for
+// repeatable or non-repeatable, and the subfield codes, repeatable
+// codes followed by a plus sign:
+//
+//	245 NR a b c f g h k+ n+ p+ s 6 8+
+//
+// Empty lines and lines starting with # are ignored.
+type Schema map[string]FieldSpec
+
+// MARC21Schema returns the schema of the MARC 21 bibliographic format
+// bundled with the package. The returned schema can be modified (e.g.
+// with AddField) without affecting the next calls.
+func MARC21Schema() Schema {
+	schema, err := ParseSchema(marc21Bibliographic)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// ParseSchema reads a schema in the format described in Schema.
+func ParseSchema(text string) (Schema, error) {
+	schema := Schema{}
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid schema line %d: %q", n+1, line)
+		}
+		if err := schema.AddField(line[:i], line[i+1:]); err != nil {
+			return nil, fmt.Errorf("invalid schema line %d: %w", n+1, err)
+		}
+	}
+	return schema, nil
+}
+
+// AddField adds (or replaces) the field with the given tag in the
+// schema, spec is the rest of a line in the format described in Schema,
+// e.g. "R a b c+ 8+".
+func (s Schema) AddField(tag string, spec string) error {
+	if len(tag) != 3 {
+		return fmt.Errorf("invalid tag %q", tag)
+	}
+	words := strings.Fields(spec)
+	if len(words) == 0 || (words[0] != "R" && words[0] != "NR") {
+		return fmt.Errorf("field %s: expected R or NR, got %q", tag, spec)
+	}
+	field := FieldSpec{Repeatable: words[0] == "R"}
+	for _, code := range words[1:] {
+		repeatable := strings.HasSuffix(code, "+")
+		code = strings.TrimSuffix(code, "+")
+		if len(code) != 1 {
+			return fmt.Errorf("field %s: invalid subfield code %q", tag, code)
+		}
+		if field.Subfields == nil {
+			field.Subfields = map[string]bool{}
+		}
+		field.Subfields[code] = repeatable
+	}
+	s[tag] = field
+	return nil
+}
+
+// Validate checks that the fields of the record that are in the schema
+// are only repeated when they are repeatable, and that their subfield
+// codes are defined and only repeated when they are repeatable. The
+// error is a *SchemaError with the problems found.
+func (s Schema) Validate(r Record) error {
+	problems := []string{}
+	seen := map[string]bool{}
+	for _, f := range r.Fields {
+		spec, ok := s[f.Tag]
+		if !ok {
+			continue
+		}
+		if seen[f.Tag] && !spec.Repeatable {
+			problems = append(problems, fmt.Sprintf("%s: field is not repeatable", f.Tag))
+		}
+		seen[f.Tag] = true
+		if f.IsControlField() {
+			continue
+		}
+
+		codes := map[string]int{}
+		for _, sub := range f.SubFields {
+			codes[sub.Code]++
+		}
+		for _, code := range sortedKeys(codes) {
+			repeatable, ok := spec.Subfields[code]
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("%s: subfield $%s is not defined", f.Tag, code))
+			case codes[code] > 1 && !repeatable:
+				problems = append(problems, fmt.Sprintf("%s: subfield $%s is not repeatable", f.Tag, code))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &SchemaError{Problems: problems}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package marc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	t.Parallel()
+
+	schema := MARC21Schema()
+	if err := schema.AddField("949", "R a b+"); err != nil {
+		t.Fatalf("error adding field: %v", err)
+	}
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "The title"}, SubField{Code: "c", Value: "Author."})
+	r.AddDataField("650", " ", "0", SubField{Code: "a", Value: "Coal"}, SubField{Code: "x", Value: "Mining"}, SubField{Code: "x", Value: "History"})
+	r.AddDataField("949", " ", " ", SubField{Code: "b", Value: "1"}, SubField{Code: "b", Value: "2"})
+	r.AddDataField("999", " ", " ", SubField{Code: "z", Value: "Local"})
+	if err := schema.Validate(r); err != nil {
+		t.Errorf("expected a valid record, got %v", err)
+	}
+
+	r.AddControlField("001", "ocm12345678")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "One"}, SubField{Code: "a", Value: "Two"}, SubField{Code: "z", Value: "Bad"})
+	r.AddDataField("949", " ", " ", SubField{Code: "a", Value: "1"}, SubField{Code: "a", Value: "2"})
+	err := schema.Validate(r)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || !errors.Is(err, ErrSchema) {
+		t.Fatalf("expected a SchemaError, got %v", err)
+	}
+	want := []string{
+		"001: field is not repeatable",
+		"245: field is not repeatable",
+		"245: subfield $a is not repeatable",
+		"245: subfield $z is not defined",
+		"949: subfield $a is not repeatable",
+	}
+	if !cmp.Equal(want, schemaErr.Problems) {
+		t.Error(cmp.Diff(want, schemaErr.Problems))
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	t.Parallel()
+
+	schema, err := ParseSchema("# comment\n\n245 NR a b c+\n001 NR\n")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := Schema{
+		"245": {Subfields: map[string]bool{"a": false, "b": false, "c": true}},
+		"001": {},
+	}
+	if !cmp.Equal(want, schema) {
+		t.Error(cmp.Diff(want, schema))
+	}
+
+	for _, text := range []string{"245", "245 X a", "24 NR a", "245 NR ab"} {
+		if _, err := ParseSchema(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package marc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF8 is returned by Record.FixUTF8 with UTF8Fail when a
+// value of the record is not valid UTF-8.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+// UTF8Policy indicates what to do with the bytes that are not valid
+// UTF-8 in the values of a record, see Record.FixUTF8.
+type UTF8Policy int
+
+const (
+	// UTF8Replace replaces each invalid byte with U+FFFD.
+	UTF8Replace UTF8Policy = iota
+	// UTF8Strip removes the invalid bytes.
+	UTF8Strip
+	// UTF8NCR replaces each invalid byte with a numeric character
+	// reference of the byte as a Latin-1 character, e.g. "&#xE9;".
+	UTF8NCR
+	// UTF8Fail returns an error wrapping ErrInvalidUTF8.
+	UTF8Fail
+)
+
+var utf8PolicyNames = map[string]UTF8Policy{
+	"replace": UTF8Replace,
+	"strip":   UTF8Strip,
+	"ncr":     UTF8NCR,
+	"fail":    UTF8Fail,
+}
+
+// ParseUTF8Policy returns the policy with the given name: "replace",
+// "strip", "ncr", or "fail".
+func ParseUTF8Policy(name string) (UTF8Policy, error) {
+	policy, ok := utf8PolicyNames[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid UTF-8 policy %q, accepted values: replace, strip, ncr, fail", name)
+	}
+	return policy, nil
+}
+
+// FixUTF8 returns the record with the bytes that are not valid UTF-8
+// in the values of its fields (and subfield codes) handled as indicated
+// by the policy, and the number of invalid bytes found. The fields of
+// the original record are not modified, the record is returned as is
+// if all its values are valid. The fixed record is encoded again by
+// EncodeMRC rather than written with its original bytes.
+func (r Record) FixUTF8(policy UTF8Policy) (Record, int, error) {
+	count := 0
+	copied := false
+	for i, f := range r.Fields {
+		if fieldIsValidUTF8(f) {
+			continue
+		}
+		if policy == UTF8Fail {
+			return r, 0, fmt.Errorf("%w in field %s", ErrInvalidUTF8, f.Tag)
+		}
+		if !copied {
+			r.Fields = append(Fields(nil), r.Fields...)
+			copied = true
+		}
+		f.Value = fixUTF8(f.Value, policy, &count)
+		f.SubFields = append([]SubField(nil), f.SubFields...)
+		for j, sub := range f.SubFields {
+			f.SubFields[j].Code = fixUTF8(sub.Code, policy, &count)
+			f.SubFields[j].Value = fixUTF8(sub.Value, policy, &count)
+		}
+		r.Fields[i] = f
+	}
+	if copied {
+		r.dropData()
+	}
+	return r, count, nil
+}
+
+func fieldIsValidUTF8(f Field) bool {
+	if !utf8.ValidString(f.Value) {
+		return false
+	}
+	for _, sub := range f.SubFields {
+		if !utf8.ValidString(sub.Code) || !utf8.ValidString(sub.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// fixUTF8 returns s with its invalid bytes handled as indicated by the
+// policy, count is incremented with the number of invalid bytes.
+func fixUTF8(s string, policy UTF8Policy, count *int) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c != utf8.RuneError || size != 1 {
+			b.WriteString(s[i : i+size])
+			i += size
+			continue
+		}
+		*count++
+		switch policy {
+		case UTF8Replace:
+			b.WriteRune(utf8.RuneError)
+		case UTF8NCR:
+			fmt.Fprintf(&b, "&#x%02X;", s[i])
+		}
+		i++
+	}
+	return b.String()
+}
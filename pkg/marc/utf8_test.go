@@ -0,0 +1,68 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFixUTF8(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddDataField("245", "1", "0", SubField{Code: "a", Value: "Caf\xe9 \xc3\xa9t\xe9"})
+
+	tests := []struct {
+		policy UTF8Policy
+		want   string
+	}{
+		{policy: UTF8Replace, want: "Caf� \xc3\xa9t�"},
+		{policy: UTF8Strip, want: "Caf \xc3\xa9t"},
+		{policy: UTF8NCR, want: "Caf&#xE9; \xc3\xa9t&#xE9;"},
+	}
+	for _, tt := range tests {
+		got, count, err := r.FixUTF8(tt.policy)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %v", tt.policy, err)
+		}
+		if value := got.Fields[1].SubFields[0].Value; value != tt.want {
+			t.Errorf("%d: expected %q, got %q", tt.policy, tt.want, value)
+		}
+		if count != 2 {
+			t.Errorf("%d: expected 2 invalid bytes, got %d", tt.policy, count)
+		}
+	}
+	if r.Fields[1].SubFields[0].Value != "Caf\xe9 \xc3\xa9t\xe9" {
+		t.Errorf("the original record was modified: %q", r.Fields[1].SubFields[0].Value)
+	}
+
+	// The fixed values are in the MARC binary output.
+	binary, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+	file := NewMarcFileBytes(binary)
+	rec, err := file.Next()
+	if err != nil {
+		t.Fatalf("error reading record: %v", err)
+	}
+	fixed, _, err := rec.FixUTF8(UTF8Replace)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	data, err := EncodeMRC(fixed)
+	if err != nil {
+		t.Fatalf("error encoding record: %v", err)
+	}
+	if !bytes.Contains(data, []byte("Caf\ufffd \xc3\xa9t\ufffd")) {
+		t.Errorf("expected the invalid bytes to be replaced in MARC binary, got %q", data)
+	}
+
+	if _, _, err := r.FixUTF8(UTF8Fail); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("expected error %v, got %v", ErrInvalidUTF8, err)
+	}
+	if _, count, err := NewRecord().FixUTF8(UTF8Fail); count != 0 || err != nil {
+		t.Errorf("expected a valid record, got %d %v", count, err)
+	}
+}
@@ -0,0 +1,332 @@
+package marc
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Writer is implemented by the encoders for each of the output formats.
+// Records are written one at a time as they are received, Flush() pushes
+// any buffered data to the underlying io.Writer and Close() writes
+// whatever the format requires at the end (e.g. a closing tag) and
+// flushes. Close() does not close the underlying io.Writer.
+type Writer interface {
+	WriteRecord(r Record) error
+	Flush() error
+	Close() error
+}
+
+// Beginning and end of a MARC XML document.
+const (
+	XMLNamespace       = "http://www.loc.gov/MARC21/slim"
+	XMLProlog          = `<?xml version="1.0" encoding="UTF-8"?>`
+	XMLCollectionBegin = `<collection xmlns="http://www.loc.gov/MARC21/slim" xmlns:marc="http://www.loc.gov/MARC21/slim">`
+	XMLCollectionEnd   = `</collection>`
+)
+
+// EncodeMRC returns the record in MARC binary format. The original
+// bytes are used for records read from MARC binary files, other records
+// (and the ones whose problems were fixed when parsing) are marshaled
+// from their fields (see Record.Marshal).
+func EncodeMRC(r Record) ([]byte, error) {
+	if r.hasBinaryData() {
+		return r.Raw(), nil
+	}
+	return r.Marshal()
+}
+
+// EncodeMRK returns the record in mnemonic (MRK) format, the leader and
+// each of the fields in its own line.
+func EncodeMRK(r Record) []byte {
+	str := fmt.Sprintf("%s\r\n", r.Leader)
+	for _, field := range r.Fields {
+		str += fmt.Sprintf("%s\r\n", field)
+	}
+	return []byte(str)
+}
+
+// EncodeXML returns the record as a MARC XML <record> element.
+// The indent is applied to each nested element.
+func EncodeXML(r Record, indent string) ([]byte, error) {
+	return AppendXML(nil, r, indent)
+}
+
+// EncodeXMLDocument returns the record as a standalone MARC XML
+// document: the XML declaration and a <record> element with the MARC XML
+// namespace, rather than wrapped in a <collection>.
+func EncodeXMLDocument(r Record, indent string) ([]byte, error) {
+	out := &appendWriter{b: []byte(XMLProlog + "\n")}
+	if err := writeXML(out, r, indent, XMLNamespace); err != nil {
+		return nil, err
+	}
+	return append(out.b, '\n'), nil
+}
+
+// xmlBuffers keeps the buffers used by AppendXML. The xml.Encoder
+// allocates a 4 KB buffer unless it is given a *bufio.Writer, reusing
+// them avoids that allocation for every record.
+var xmlBuffers = sync.Pool{
+	New: func() interface{} { return bufio.NewWriter(nil) },
+}
+
+// AppendXML appends the record as a MARC XML <record> element (see
+// EncodeXML) to dst and returns the extended buffer. Passing the same
+// buffer, truncated, for each record avoids allocating a new one.
+func AppendXML(dst []byte, r Record, indent string) ([]byte, error) {
+	out := &appendWriter{b: dst}
+	w := xmlBuffers.Get().(*bufio.Writer)
+	w.Reset(out)
+	err := writeXML(w, r, indent, "")
+	w.Reset(nil)
+	xmlBuffers.Put(w)
+	return out.b, err
+}
+
+// appendWriter is an io.Writer that appends to a slice.
+type appendWriter struct {
+	b []byte
+}
+
+func (aw *appendWriter) Write(p []byte) (int, error) {
+	aw.b = append(aw.b, p...)
+	return len(p), nil
+}
+
+// writeXML writes the record as a MARC XML <record> element, with the
+// namespace given (if any) as its default namespace. The
+// elements are encoded as they are written, one field at a time, rather
+// than building the whole <record> first. If w is a *bufio.Writer the
+// xml.Encoder writes directly to it.
+func writeXML(w io.Writer, r Record, indent string, namespace string) error {
+	enc := xml.NewEncoder(w)
+	enc.Indent(indent, indent)
+
+	record := xml.StartElement{Name: xml.Name{Local: "record"}}
+	if namespace != "" {
+		record.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: namespace}}
+	}
+	if err := enc.EncodeToken(record); err != nil {
+		return err
+	}
+	if err := encodeXMLElement(enc, xml.StartElement{Name: xml.Name{Local: "leader"}}, r.Leader.Raw()); err != nil {
+		return err
+	}
+	// Control fields go before the data fields.
+	for _, f := range r.Fields {
+		if !f.IsControlField() {
+			continue
+		}
+		start := xml.StartElement{
+			Name: xml.Name{Local: "controlfield"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "tag"}, Value: f.Tag}},
+		}
+		if err := encodeXMLElement(enc, start, f.Value); err != nil {
+			return err
+		}
+	}
+	for _, f := range r.Fields {
+		if f.IsControlField() {
+			continue
+		}
+		start := xml.StartElement{
+			Name: xml.Name{Local: "datafield"},
+			Attr: []xml.Attr{
+				{Name: xml.Name{Local: "tag"}, Value: f.Tag},
+				{Name: xml.Name{Local: "ind1"}, Value: f.Indicator1},
+				{Name: xml.Name{Local: "ind2"}, Value: f.Indicator2},
+			},
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, s := range f.SubFields {
+			sub := xml.StartElement{
+				Name: xml.Name{Local: "subfield"},
+				Attr: []xml.Attr{{Name: xml.Name{Local: "code"}, Value: s.Code}},
+			}
+			if err := encodeXMLElement(enc, sub, s.Value); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(start.End()); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(record.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// encodeXMLElement encodes an element with only text in it.
+func encodeXMLElement(enc *xml.Encoder, start xml.StartElement, value string) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(xml.CharData(value)); err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// EncodeJSON returns the fields of the record as a JSON array.
+func EncodeJSON(r Record) ([]byte, error) {
+	return json.Marshal(r.Fields)
+}
+
+// MrcWriter writes records in MARC binary format (see EncodeMRC).
+type MrcWriter struct {
+	w *bufio.Writer
+}
+
+func NewMrcWriter(w io.Writer) *MrcWriter {
+	return &MrcWriter{w: bufio.NewWriter(w)}
+}
+
+func (mw *MrcWriter) WriteRecord(r Record) error {
+	b, err := EncodeMRC(r)
+	if err != nil {
+		return err
+	}
+	_, err = mw.w.Write(b)
+	return err
+}
+
+func (mw *MrcWriter) Flush() error {
+	return mw.w.Flush()
+}
+
+func (mw *MrcWriter) Close() error {
+	return mw.w.Flush()
+}
+
+// MrkWriter writes records in mnemonic (MRK) format, with an empty
+// line after each record.
+type MrkWriter struct {
+	w *bufio.Writer
+}
+
+func NewMrkWriter(w io.Writer) *MrkWriter {
+	return &MrkWriter{w: bufio.NewWriter(w)}
+}
+
+func (mw *MrkWriter) WriteRecord(r Record) error {
+	if _, err := mw.w.Write(EncodeMRK(r)); err != nil {
+		return err
+	}
+	_, err := mw.w.WriteString("\r\n")
+	return err
+}
+
+func (mw *MrkWriter) Flush() error {
+	return mw.w.Flush()
+}
+
+func (mw *MrkWriter) Close() error {
+	return mw.w.Flush()
+}
+
+// XMLWriter writes records as a MARC XML <collection> document. With
+// Fragments only the <record> elements are written, without the XML
+// declaration and the <collection>, e.g. to append them to another
+// document or to stream them to tools that read one element at a time.
+type XMLWriter struct {
+	w         *bufio.Writer
+	Indent    string
+	Fragments bool
+	started   bool
+}
+
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{w: bufio.NewWriter(w)}
+}
+
+func (xw *XMLWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+	if xw.Fragments {
+		return nil
+	}
+	_, err := fmt.Fprintf(xw.w, "%s\n%s\n", XMLProlog, XMLCollectionBegin)
+	return err
+}
+
+func (xw *XMLWriter) WriteRecord(r Record) error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	if err := writeXML(xw.w, r, xw.Indent, ""); err != nil {
+		return err
+	}
+	_, err := xw.w.WriteString("\r\n")
+	return err
+}
+
+func (xw *XMLWriter) Flush() error {
+	return xw.w.Flush()
+}
+
+func (xw *XMLWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	if xw.Fragments {
+		return xw.w.Flush()
+	}
+	if _, err := fmt.Fprintf(xw.w, "%s\n", XMLCollectionEnd); err != nil {
+		return err
+	}
+	return xw.w.Flush()
+}
+
+// JSONWriter writes records as a JSON array, each record is an array
+// with its fields.
+type JSONWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: bufio.NewWriter(w)}
+}
+
+func (jw *JSONWriter) WriteRecord(r Record) error {
+	b, err := EncodeJSON(r)
+	if err != nil {
+		return err
+	}
+	return jw.WriteElement(b)
+}
+
+// WriteElement writes an already encoded JSON value as the next
+// element of the array.
+func (jw *JSONWriter) WriteElement(b []byte) error {
+	separator := ",\r\n"
+	if jw.count == 0 {
+		separator = "[\r\n"
+	}
+	jw.count++
+	_, err := fmt.Fprintf(jw.w, "%s%s", separator, b)
+	return err
+}
+
+func (jw *JSONWriter) Flush() error {
+	return jw.w.Flush()
+}
+
+func (jw *JSONWriter) Close() error {
+	end := "\r\n]\r\n"
+	if jw.count == 0 {
+		end = "[" + end
+	}
+	if _, err := jw.w.WriteString(end); err != nil {
+		return err
+	}
+	return jw.w.Flush()
+}
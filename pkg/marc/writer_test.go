@@ -0,0 +1,314 @@
+package marc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMrcWriter(t *testing.T) {
+	t.Parallel()
+
+	want, err := os.ReadFile("testdata/test_10.mrc")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writeTestRecords(NewMrcWriter(&buf), "testdata/test_10.mrc", t)
+
+	if !bytes.Equal(want, buf.Bytes()) {
+		t.Errorf("expected output to be identical to the input file")
+	}
+}
+
+func TestMrkWriter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	writeTestRecords(NewMrkWriter(&buf), "testdata/test_1a.mrc", t)
+
+	got := buf.String()
+	if !strings.HasPrefix(got, "=LDR  01805nam a2200385 i 4500\r\n=001  ocm57175940\r\n") {
+		t.Errorf("unexpected beginning of output %q", got[:60])
+	}
+	if !strings.HasSuffix(got, "\r\n\r\n") {
+		t.Errorf("expected record to end with an empty line")
+	}
+}
+
+func TestMrkRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecord()
+	r.AddControlField("001", "ocm57175940")
+	r.AddDataField("650", " ", "0", SubField{Code: "a", Value: "US$ 5 Coal"}, SubField{Code: "x", Value: "Prices."})
+	want, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("error marshaling record: %v", err)
+	}
+
+	mrc := NewMarcFileBytes(want)
+	rec, err := mrc.Next()
+	if err != nil {
+		t.Fatalf("error reading MARC binary: %v", err)
+	}
+	mrk := NewMarcFile(bytes.NewReader(EncodeMRK(rec)))
+	rec, err = mrk.Next()
+	if err != nil {
+		t.Fatalf("error reading MRK: %v", err)
+	}
+	got, err := EncodeMRC(rec)
+	if err != nil {
+		t.Fatalf("error encoding MARC binary: %v", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestXMLWriter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	writeTestRecords(NewXMLWriter(&buf), "testdata/test_10.mrc", t)
+
+	// Read back the XML and make sure we get the same records
+	path := filepath.Join(t.TempDir(), "test.xml")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	got := readTestRecords(path, t)
+	want := readTestRecords("testdata/test_10.mrc", t)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Leader.Raw() != want[i].Leader.Raw() {
+			t.Errorf("expected leader %q, got %q", want[i].Leader.Raw(), got[i].Leader.Raw())
+		}
+		if len(got[i].Fields) != len(want[i].Fields) {
+			t.Errorf("expected %d fields, got %d", len(want[i].Fields), len(got[i].Fields))
+		}
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	t.Parallel()
+
+	r := Record{Fields: Fields{
+		{Tag: "245", Indicator1: "1", Indicator2: "0", SubFields: []SubField{{Code: "a", Value: "Fish & <chips>"}}},
+		{Tag: "001", Value: "ocm1"},
+	}}
+	r.Leader, _ = NewLeader([]byte("00000nam a2200000 a 4500"))
+
+	got, err := EncodeXML(r, "")
+	if err != nil {
+		t.Fatalf("error encoding record: %v", err)
+	}
+	want := `<record><leader>00000nam a2200000 a 4500</leader><controlfield tag="001">ocm1</controlfield>` +
+		`<datafield tag="245" ind1="1" ind2="0"><subfield code="a">Fish &amp; &lt;chips&gt;</subfield></datafield></record>`
+	if string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	got, err = EncodeXML(r, "  ")
+	if err != nil {
+		t.Fatalf("error encoding record: %v", err)
+	}
+	want = "  <record>\n" +
+		"    <leader>00000nam a2200000 a 4500</leader>\n" +
+		"    <controlfield tag=\"001\">ocm1</controlfield>\n" +
+		"    <datafield tag=\"245\" ind1=\"1\" ind2=\"0\">\n" +
+		"      <subfield code=\"a\">Fish &amp; &lt;chips&gt;</subfield>\n" +
+		"    </datafield>\n" +
+		"  </record>"
+	if string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestAppendXML(t *testing.T) {
+	t.Parallel()
+
+	r := Record{Fields: Fields{{Tag: "001", Value: "ocm1"}}}
+	r.Leader, _ = NewLeader([]byte("00000nam a2200000 a 4500"))
+
+	want := "<record><leader>00000nam a2200000 a 4500</leader><controlfield tag=\"001\">ocm1</controlfield></record>"
+	buf := []byte("prefix")
+	for i := 0; i < 3; i++ {
+		got, err := AppendXML(buf[:len("prefix")], r, "")
+		if err != nil {
+			t.Fatalf("error encoding record: %v", err)
+		}
+		if string(got) != "prefix"+want {
+			t.Errorf("expected prefix%s, got %s", want, got)
+		}
+		buf = got
+	}
+}
+
+func TestEncodeXMLDocument(t *testing.T) {
+	t.Parallel()
+
+	r := Record{Fields: Fields{{Tag: "001", Value: "ocm1"}}}
+	r.Leader, _ = NewLeader([]byte("00000nam a2200000 a 4500"))
+
+	got, err := EncodeXMLDocument(r, "")
+	if err != nil {
+		t.Fatalf("error encoding record: %v", err)
+	}
+	want := XMLProlog + "\n" + `<record xmlns="http://www.loc.gov/MARC21/slim"><leader>00000nam a2200000 a 4500</leader>` +
+		`<controlfield tag="001">ocm1</controlfield></record>` + "\n"
+	if string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// The document can be read back.
+	path := filepath.Join(t.TempDir(), "ocm1.xml")
+	if err := os.WriteFile(path, got, 0644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if records := readTestRecords(path, t); len(records) != 1 || records[0].ControlNum() != "ocm1" {
+		t.Errorf("expected to read back the record, got %d records", len(records))
+	}
+}
+
+func TestXMLWriterFragments(t *testing.T) {
+	t.Parallel()
+
+	r := Record{Fields: Fields{{Tag: "001", Value: "ocm1"}}}
+	r.Leader, _ = NewLeader([]byte("00000nam a2200000 a 4500"))
+
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf)
+	w.Fragments = true
+	for i := 0; i < 2; i++ {
+		if err := w.WriteRecord(r); err != nil {
+			t.Fatalf("error writing record: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing writer: %v", err)
+	}
+	record := "<record><leader>00000nam a2200000 a 4500</leader><controlfield tag=\"001\">ocm1</controlfield></record>\r\n"
+	if got := buf.String(); got != record+record {
+		t.Errorf("expected only the records, got %s", got)
+	}
+}
+
+// BenchmarkXMLWriter measures writing records in MARC XML, usually the
+// slowest of the output formats.
+func BenchmarkXMLWriter(b *testing.B) {
+	records := benchmarkRecords(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := NewXMLWriter(io.Discard)
+		for _, r := range records {
+			if err := w.WriteRecord(r); err != nil {
+				b.Fatalf("error writing record: %v", err)
+			}
+		}
+		w.Close()
+	}
+}
+
+// BenchmarkEncodeXML measures encoding records one at a time.
+func BenchmarkEncodeXML(b *testing.B) {
+	records := benchmarkRecords(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range records {
+			if _, err := EncodeXML(r, ""); err != nil {
+				b.Fatalf("error encoding record: %v", err)
+			}
+		}
+	}
+}
+
+// BenchmarkAppendXML measures encoding records one at a time reusing
+// the same buffer, as the marcli command does.
+func BenchmarkAppendXML(b *testing.B) {
+	records := benchmarkRecords(b)
+	buf := []byte{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range records {
+			var err error
+			if buf, err = AppendXML(buf[:0], r, ""); err != nil {
+				b.Fatalf("error encoding record: %v", err)
+			}
+		}
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		path  string
+		count int
+	}{
+		{name: "ten records", path: "testdata/test_10.mrc", count: 10},
+		{name: "no records", path: "", count: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewJSONWriter(&buf)
+			if tt.path != "" {
+				writeTestRecords(w, tt.path, t)
+			} else if err := w.Close(); err != nil {
+				t.Fatalf("error closing writer: %v", err)
+			}
+
+			var records [][]Field
+			if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if len(records) != tt.count {
+				t.Errorf("expected %d records, got %d", tt.count, len(records))
+			}
+		})
+	}
+}
+
+func writeTestRecords(w Writer, path string, t *testing.T) {
+	t.Helper()
+
+	for _, r := range readTestRecords(path, t) {
+		if err := w.WriteRecord(r); err != nil {
+			t.Fatalf("error writing record: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error closing writer: %v", err)
+	}
+}
+
+func readTestRecords(path string, t *testing.T) []Record {
+	t.Helper()
+
+	f := NewMarcFile(setUpTestFile(path, t))
+	var records []Record
+	for {
+		r, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error reading record: %v", err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
@@ -0,0 +1,142 @@
+package marc
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// XMLReader reads records in MARC XML format.
+type XMLReader struct {
+	decoder *xml.Decoder
+	limiter *sizeLimiter
+	mode    ParseMode
+	err     error
+}
+
+// NewXMLReader creates a reader for MARC XML data.
+func NewXMLReader(r io.Reader) *XMLReader {
+	// Uses a Decoder() to read one MARC record at a time.
+	limiter := newSizeLimiter(r)
+	return &XMLReader{decoder: xml.NewDecoder(limiter), limiter: limiter}
+}
+
+// SetMaxRecordSize sets the size of the largest record accepted, larger
+// records stop the reading with ErrRecordTooLarge.
+func (xr *XMLReader) SetMaxRecordSize(max int) {
+	xr.limiter.max = max
+}
+
+// SetParseMode sets how the records are parsed, see ParseMode.
+func (xr *XMLReader) SetParseMode(mode ParseMode) {
+	xr.mode = mode
+}
+
+// Next returns the next record, io.EOF when there are no more records.
+func (xr *XMLReader) Next() (Record, error) {
+	if xr.err != nil {
+		return Record{}, xr.err
+	}
+
+	xr.limiter.reset()
+	for {
+		pos := xr.decoder.InputOffset()
+		token, err := xr.decoder.Token()
+		if err == io.EOF || xr.unmatchedEnd(err) {
+			return Record{}, io.EOF
+		}
+		if err != nil {
+			xr.fail(err, pos)
+			return Record{}, xr.err
+		}
+
+		// Find the next "<record>" element in the XML
+		// and decode it.
+		element, ok := token.(xml.StartElement)
+		if ok && element.Name.Local == "record" {
+			rec := Record{Pos: pos}
+			err = xr.makeRecordFromXML(&rec, element)
+			return rec, err
+		}
+	}
+}
+
+// unmatchedEnd returns true for the error reported when the data has
+// an end element without its start element. This is the case when
+// reading starts in the middle of a document (e.g. see ReadRecordAt)
+// and we reach the </collection> at the end, which is not an error.
+func (xr *XMLReader) unmatchedEnd(err error) bool {
+	var syntaxErr *xml.SyntaxError
+	return errors.As(err, &syntaxErr) && strings.HasPrefix(syntaxErr.Msg, "unexpected end element")
+}
+
+// fail records an error reading the data, pos is the position of the
+// record being read.
+func (xr *XMLReader) fail(err error, pos int64) {
+	if errors.Is(err, ErrRecordTooLarge) {
+		err = recordTooLargeError(pos, xr.limiter.max)
+	}
+	xr.err = err
+}
+
+// Offset returns the position right after the last record read.
+func (xr *XMLReader) Offset() int64 {
+	return xr.decoder.InputOffset()
+}
+
+// Err returns the error reading the data (if any).
+func (xr *XMLReader) Err() error {
+	return xr.err
+}
+
+func (xr *XMLReader) makeRecordFromXML(rec *Record, element xml.StartElement) error {
+	// Decode the element into an XML Record...
+	var xmlRec XmlRecord
+	if err := xr.decoder.DecodeElement(&xmlRec, &element); err != nil {
+		xr.fail(err, rec.Pos)
+		return xr.err
+	}
+
+	// Ignore error because a bad data offset is not a problem
+	// in XML records.
+	leader, _ := NewLeader([]byte(xmlRec.Leader))
+	rec.Leader = leader
+	if xr.mode.strict() {
+		if err := leader.Validate(); err != nil {
+			return err
+		}
+	}
+	rec.Data = []byte("Raw data not supported in XML format\n")
+
+	// ...and then into a MARC Record.
+	for _, control := range xmlRec.ControlFields {
+		if control.Value == "" && !keepEmptyControlField(rec, control.Tag, xr.mode) {
+			continue
+		}
+		field := Field{Tag: control.Tag, Value: control.Value}
+		rec.Fields = append(rec.Fields, field)
+	}
+	for _, data := range xmlRec.DataFields {
+		field := Field{Tag: data.Tag, Indicator1: data.Ind1, Indicator2: data.Ind2}
+		if xr.mode.lenient() {
+			field.Indicator1 = fixIndicator(rec, data.Tag, data.Ind1)
+			field.Indicator2 = fixIndicator(rec, data.Tag, data.Ind2)
+		}
+		for _, sub := range data.SubFields {
+			subfield := SubField{Code: sub.Code, Value: sub.Value}
+			if sub.Value == "" && !xr.mode.strict() &&
+				!keepEmptySubfield(rec, data.Tag, sub.Code, xr.mode) {
+				continue
+			}
+			field.SubFields = append(field.SubFields, subfield)
+		}
+		if xr.mode.strict() {
+			if err := checkParsedField(field); err != nil {
+				return err
+			}
+		}
+		rec.Fields = append(rec.Fields, field)
+	}
+	return checkFixedFields(rec, xr.mode)
+}